@@ -0,0 +1,212 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// FaultTypeFlap brings the spokes down for downTime every interval
+	FaultTypeFlap = "flap"
+	// FaultTypeDown brings the spokes down once for downTime, or until the FaultSchedule is removed
+	FaultTypeDown = "down"
+	// FaultTypeRandom brings the spokes down for downTime after a random delay up to interval, repeatedly
+	FaultTypeRandom = "random"
+
+	DefaultFaultDownTime = time.Second
+	MinFaultInterval     = time.Second
+)
+
+// FaultScheduleSpec brings spokes of a LAN down and up on a schedule, the pods or VMs see carrier changes
+type FaultScheduleSpec struct {
+	// lan is the name of the LAN in the same namespace
+	// +required
+	LAN string `json:"lan"`
+	// spokes of the LAN to inject faults into, default is all spokes; they go down and up together
+	// +optional
+	Spokes []string `json:"spokes,omitempty"`
+	// type is one of flap, down and random
+	// +kubebuilder:validation:Enum=flap;down;random
+	// +required
+	Type string `json:"type"`
+	// interval is the period of flap, or the max delay between two random flaps; required by flap and random, min 1s
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// downTime is how long the spokes are held down each time, default is 1s for flap and random,
+	// it must be shorter than interval of flap; unset means until the FaultSchedule is removed for down
+	// +optional
+	DownTime *metav1.Duration `json:"downTime,omitempty"`
+	// duration is how long flap and random run since the FaultSchedule is created, the spokes are left up afterwards;
+	// default is until the FaultSchedule is removed
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// GetInterval returns the interval
+func (spec *FaultScheduleSpec) GetInterval() time.Duration {
+	if spec.Interval == nil {
+		return 0
+	}
+	return spec.Interval.Duration
+}
+
+// GetDownTime returns the down time with default applied, 0 means forever
+func (spec *FaultScheduleSpec) GetDownTime() time.Duration {
+	if spec.DownTime != nil && spec.DownTime.Duration > 0 {
+		return spec.DownTime.Duration
+	}
+	if spec.Type == FaultTypeDown {
+		return 0
+	}
+	return DefaultFaultDownTime
+}
+
+// GetDuration returns how long the schedule runs, 0 means forever
+func (spec *FaultScheduleSpec) GetDuration() time.Duration {
+	if spec.Duration == nil || spec.Type == FaultTypeDown {
+		return 0
+	}
+	return spec.Duration.Duration
+}
+
+// GetSpokes returns the spokes of lan to inject faults into
+func (spec *FaultScheduleSpec) GetSpokes(lan *LANSpec) []string {
+	if len(spec.Spokes) == 0 {
+		return lan.SpokeList
+	}
+	return spec.Spokes
+}
+
+// Validate checks the spec against the LAN
+func (spec *FaultScheduleSpec) Validate(lan *LANSpec) error {
+	for _, spoke := range spec.Spokes {
+		if !slices.Contains(lan.SpokeList, spoke) {
+			return fmt.Errorf("spoke %v is not a spoke of LAN %v", spoke, spec.LAN)
+		}
+	}
+	switch spec.Type {
+	case FaultTypeFlap, FaultTypeRandom:
+		if spec.GetInterval() < MinFaultInterval {
+			return fmt.Errorf("interval of %v must be at least %v", spec.Type, MinFaultInterval)
+		}
+		if spec.Type == FaultTypeFlap && spec.GetDownTime() >= spec.GetInterval() {
+			return fmt.Errorf("downTime %v must be shorter than interval %v", spec.GetDownTime(), spec.GetInterval())
+		}
+	case FaultTypeDown:
+	default:
+		return fmt.Errorf("unknown fault type %v, must be %v, %v or %v", spec.Type, FaultTypeFlap, FaultTypeDown, FaultTypeRandom)
+	}
+	if spec.Duration != nil && spec.Duration.Duration < 0 {
+		return fmt.Errorf("invalid duration %v", spec.Duration.Duration)
+	}
+	return nil
+}
+
+// NodeFaultScheduleStatus is the state of a FaultSchedule on a worker
+type NodeFaultScheduleStatus struct {
+	// +required
+	Node string `json:"node"`
+	// down lists the spokes currently held down by the FaultSchedule on the worker
+	// +optional
+	Down []string `json:"down,omitempty"`
+	// transitions is the number of spoke state changes made by the FaultSchedule on the worker
+	// +optional
+	Transitions int32 `json:"transitions,omitempty"`
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// completed is true if the schedule has ended on the worker
+	// +optional
+	Completed bool `json:"completed,omitempty"`
+	// message is the error of the last transition, if any
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// FaultScheduleStatus defines the observed state of FaultSchedule
+type FaultScheduleStatus struct {
+	// observedGeneration is the generation of the spec startTime is recorded for
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// startTime is when the schedule of observedGeneration started, shared by all workers
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// nodes lists the state of each worker with spokes of the LAN
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	Nodes []NodeFaultScheduleStatus `json:"nodes,omitempty"`
+}
+
+// GetNode returns the status of node, nil if not found
+func (status *FaultScheduleStatus) GetNode(node string) *NodeFaultScheduleStatus {
+	for i := range status.Nodes {
+		if status.Nodes[i].Node == node {
+			return &status.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// SetNode adds or replaces the status of nodeStatus.Node
+func (status *FaultScheduleStatus) SetNode(nodeStatus NodeFaultScheduleStatus) {
+	if existing := status.GetNode(nodeStatus.Node); existing != nil {
+		*existing = nodeStatus
+		return
+	}
+	status.Nodes = append(status.Nodes, nodeStatus)
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="LAN",type=string,JSONPath=`.spec.lan`
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// FaultSchedule is the Schema for the faultschedules API
+type FaultSchedule struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of FaultSchedule
+	// +required
+	Spec FaultScheduleSpec `json:"spec"`
+
+	// status defines the observed state of FaultSchedule
+	// +optional
+	Status FaultScheduleStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// FaultScheduleList contains a list of FaultSchedule
+type FaultScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FaultSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FaultSchedule{}, &FaultScheduleList{})
+}
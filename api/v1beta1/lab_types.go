@@ -0,0 +1,277 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// LabFinalizer is added to a Lab so that its workloads are removed before its LANs
+	LabFinalizer = "lan.k8slan.io/lab-teardown"
+	// LabLabel labels every object created for a Lab with the Lab name
+	LabLabel = "lan.k8slan.io/lab"
+	// LabNodeLabel labels the pod or VM of a Lab node with the node name
+	LabNodeLabel = "lan.k8slan.io/lab-node"
+	// LabConditionReady is the condition type set when all LANs and nodes of a Lab are ready
+	LabConditionReady = "Ready"
+)
+
+// LabEndpoint is an interface of a Lab node
+type LabEndpoint struct {
+	// node is the name of the Lab node
+	// +required
+	Node string `json:"node"`
+	// interface is the interface name in the pod, or the name of the kubevirt interface and network of the VM
+	// +kubebuilder:validation:MaxLength=15
+	// +required
+	Interface string `json:"interface"`
+}
+
+func (ep LabEndpoint) String() string {
+	return ep.Node + ":" + ep.Interface
+}
+
+// LabLink connects endpoints with a LAN
+type LabLink struct {
+	// name of the link, the LAN is named <lab>-<link>
+	// +required
+	Name string `json:"name"`
+	// endpoints connected by the link, each endpoint is a spoke of the LAN
+	// +kubebuilder:validation:MinItems=2
+	// +required
+	Endpoints []LabEndpoint `json:"endpoints"`
+	// mode of the LAN, default is p2p for a link with 2 endpoints, otherwise bridge
+	// +kubebuilder:validation:Enum=bridge;p2p
+	// +optional
+	Mode string `json:"mode,omitempty"`
+	// vni of the LAN, default is vniBase plus the index of the link
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=16777215
+	// +optional
+	VNI *int32 `json:"vni,omitempty"`
+}
+
+// GetMode returns the mode of the LAN of the link
+func (link *LabLink) GetMode() string {
+	if link.Mode != "" {
+		return link.Mode
+	}
+	if len(link.Endpoints) == 2 {
+		return LANModeP2P
+	}
+	return LANModeBridge
+}
+
+// LabNode is a pod or a kubevirt VM, its link endpoints are attached via the NADs of their spokes
+type LabNode struct {
+	// name of the node, the pod or VM is named <lab>-<node>
+	// +required
+	Name string `json:"name"`
+	// pod is the template of the pod, the veth NADs of the endpoints are added to its networks annotation,
+	// and their resources to the limits of the first container
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Pod *corev1.PodTemplateSpec `json:"pod,omitempty"`
+	// vm is the spec of a kubevirt VirtualMachine, a macvtap interface and multus network
+	// of each endpoint are added to its template
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	VM *runtime.RawExtension `json:"vm,omitempty"`
+}
+
+// LabSpec describes a topology of nodes connected by links
+type LabSpec struct {
+	// lanTemplate is the spec shared by all LANs of the Lab, e.g. vxlanGrp and defaultVxlanDev;
+	// ns, bridge, vxlan, vni, spokes and mode are set per link, spokeImpairments, spokeAdminStates and mirrors are ignored
+	// +optional
+	LANTemplate LANSpec `json:"lanTemplate,omitempty"`
+	// vniBase is the vni of the first link, a link without vni uses vniBase plus its index;
+	// it must not overlap with older Labs and other LANs using the same vxlan group, otherwise the Lab is not ready with reason Conflict
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=16777215
+	// +required
+	VNIBase int32 `json:"vniBase"`
+	// +listType=map
+	// +listMapKey=name
+	// +required
+	Nodes []LabNode `json:"nodes"`
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Links []LabLink `json:"links,omitempty"`
+}
+
+var labNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Validate checks node and link references of the spec
+func (spec *LabSpec) Validate() error {
+	nodes := map[string]bool{}
+	for _, node := range spec.Nodes {
+		if !labNameRegexp.MatchString(node.Name) {
+			return fmt.Errorf("invalid node name %v, must be a DNS label", node.Name)
+		}
+		if nodes[node.Name] {
+			return fmt.Errorf("duplicate node %v", node.Name)
+		}
+		nodes[node.Name] = true
+		if (node.Pod == nil) == (node.VM == nil) {
+			return fmt.Errorf("node %v must have exactly one of pod or vm", node.Name)
+		}
+		if node.Pod != nil && len(node.Pod.Spec.Containers) == 0 {
+			return fmt.Errorf("pod of node %v has no container", node.Name)
+		}
+	}
+	links := map[string]bool{}
+	endpoints := map[LabEndpoint]string{}
+	vnis := map[int32]string{}
+	for i, link := range spec.Links {
+		if !labNameRegexp.MatchString(link.Name) {
+			return fmt.Errorf("invalid link name %v, must be a DNS label", link.Name)
+		}
+		if links[link.Name] {
+			return fmt.Errorf("duplicate link %v", link.Name)
+		}
+		links[link.Name] = true
+		if link.GetMode() == LANModeP2P && len(link.Endpoints) != 2 {
+			return fmt.Errorf("p2p link %v requires exactly 2 endpoints, got %d", link.Name, len(link.Endpoints))
+		}
+		vni := spec.GetLinkVNI(i)
+		if other, ok := vnis[vni]; ok {
+			return fmt.Errorf("link %v and %v have the same vni %d", other, link.Name, vni)
+		}
+		vnis[vni] = link.Name
+		for _, ep := range link.Endpoints {
+			if !nodes[ep.Node] {
+				return fmt.Errorf("link %v refers to unknown node %v", link.Name, ep.Node)
+			}
+			if !labNameRegexp.MatchString(ep.Interface) || len(ep.Interface) > 15 {
+				return fmt.Errorf("invalid interface name %v of link %v", ep.Interface, link.Name)
+			}
+			if other, ok := endpoints[ep]; ok {
+				return fmt.Errorf("endpoint %v is used by both link %v and %v", ep, other, link.Name)
+			}
+			endpoints[ep] = link.Name
+		}
+	}
+	return nil
+}
+
+// GetLinkVNI returns the vni of the i-th link
+func (spec *LabSpec) GetLinkVNI(i int) int32 {
+	if spec.Links[i].VNI != nil {
+		return *spec.Links[i].VNI
+	}
+	return spec.VNIBase + int32(i)
+}
+
+// GetLabSpokeName returns the spoke name of an endpoint of a Lab, it is unique across Labs:
+// a prefix of <node>-<interface> with a hash suffix, within MaxSpokeNameLen
+func GetLabSpokeName(namespace, lab string, ep LabEndpoint) string {
+	const suffixLen = 6
+	h := fnv.New32a()
+	h.Write([]byte(namespace + "/" + lab + "/" + ep.String()))
+	prefix := ep.Node + "-" + ep.Interface
+	prefix = strings.TrimRight(prefix[:min(len(prefix), MaxSpokeNameLen-suffixLen)], "-")
+	return fmt.Sprintf("%v-%05x", prefix, h.Sum32()&0xfffff)
+}
+
+// LabNodeStatus is the readiness of a Lab node
+type LabNodeStatus struct {
+	// name of the node
+	Name string `json:"name"`
+	// ready is true if the pod or VM is ready
+	Ready bool `json:"ready"`
+	// message explains why the node is not ready
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// LabStatus is the aggregate readiness of a Lab
+type LabStatus struct {
+	// readyNodes is the number of ready nodes, in the format of <ready>/<total>
+	// +optional
+	ReadyNodes string `json:"readyNodes,omitempty"`
+	// lans is the names of the LANs of the Lab
+	// +optional
+	LANs []string `json:"lans,omitempty"`
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Nodes []LabNodeStatus `json:"nodes,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Nodes",type=string,JSONPath=`.status.readyNodes`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Lab is the Schema for the labs API, it owns the LANs, pods and VMs of a topology
+type Lab struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of Lab
+	// +required
+	Spec LabSpec `json:"spec"`
+
+	// status defines the observed state of Lab
+	// +optional
+	Status LabStatus `json:"status,omitempty,omitzero"`
+}
+
+// GetLANName returns the name of the LAN of link
+func (lab *Lab) GetLANName(link string) string {
+	return lab.Name + "-" + link
+}
+
+// GetNodeObjName returns the name of the pod or VM of node
+func (lab *Lab) GetNodeObjName(node string) string {
+	return lab.Name + "-" + node
+}
+
+// +kubebuilder:object:root=true
+
+// LabList contains a list of Lab
+type LabList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Lab `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Lab{}, &LabList{})
+}
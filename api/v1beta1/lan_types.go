@@ -18,10 +18,15 @@ package v1beta1
 
 import (
 	"fmt"
+	"net"
 	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -29,8 +34,46 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 const (
-	DefaultVxPort = 4789
-	DefaultVxGrp  = "FF02:0:0:0:0:0:0:14"
+	DefaultVxPort     = 4789
+	DefaultGenevePort = 6081
+	DefaultVxGrp      = "FF02:0:0:0:0:0:0:14"
+)
+
+const (
+	EncapVxLAN = "vxlan"
+	// EncapGeneve is unicast only, the LAN spans the two workers of tunnelEndpoints
+	EncapGeneve = "geneve"
+	// EncapGRETap requires an IPv4 vxlanGrp
+	EncapGRETap = "gretap"
+	// EncapIP6GRETap is unicast only, the LAN spans the two workers of tunnelEndpoints
+	EncapIP6GRETap = "ip6gretap"
+)
+
+const (
+	// LANModeBridge connects all spokes of the LAN via a MAC learning bridge
+	LANModeBridge = "bridge"
+	// LANModeP2P wires the two spokes of the LAN together without a bridge, joined by a single veth pair on the same node,
+	// so every frame including link-local control frames passes transparently
+	LANModeP2P = "p2p"
+)
+
+const (
+	// FDBModeLearn learns remote MAC addresses from vxlan traffic, BUM traffic is flooded to the multicast group
+	FDBModeLearn = "learn"
+	// FDBModeStatic programs remote MAC and IP addresses published in LANEndpoints as static FDB and neighbor entries,
+	// with learning off and ARP/ND suppressed by the vxlan interface
+	FDBModeStatic = "static"
+	// FDBModeEVPN programs remote MAC and IP addresses and VTEPs distributed by the BGP EVPN speaker of the LAN daemonset,
+	// as static FDB and neighbor entries, with learning off and ARP/ND suppressed by the vxlan interface
+	FDBModeEVPN = "evpn"
+)
+
+const (
+	// TransparencyStandard forwards link-local control frames allowed by bridge port group_fwd_mask
+	TransparencyStandard = "standard"
+	// TransparencyFull forwards all link-local control frames (01:80:C2:00:00:0X),
+	// including STP, PAUSE and LACP
+	TransparencyFull = "full"
 )
 
 // LANSpec defines the desired state of LAN
@@ -54,17 +97,439 @@ type LANSpec struct {
 	DefaultVxDev string `json:"defaultVxlanDev,omitempty"`
 	// +optional
 	VxDevMap map[string]string `json:"vxlanDevMap,omitempty"`
+	// vxlanPort is the UDP port of vxlan and geneve; could be changed on a live LAN
 	// +optional
 	VxPort *int32 `json:"vxlanPort,omitempty"`
+	// encapsulation is the tunnel type between workers, one of vxlan (default), geneve, gretap and ip6gretap;
+	// vni is used as the VNI of vxlan and geneve, or the key of gretap and ip6gretap; could be changed on a live LAN
+	// +optional
+	// +kubebuilder:validation:Enum=vxlan;geneve;gretap;ip6gretap
+	Encapsulation string `json:"encapsulation,omitempty"`
+	// ttl of tunnel packets, 0 or unset means the kernel default; could be changed on a live LAN
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=255
+	TTL *int32 `json:"ttl,omitempty"`
+	// tunnelEndpoints is the underlay address of each worker, key is the worker name;
+	// required by geneve and ip6gretap, which have no multicast support, the LAN spans the two listed workers;
+	// could be changed on a live LAN
+	// +optional
+	TunnelEndpoints map[string]string `json:"tunnelEndpoints,omitempty"`
 	// +required
 	SpokeList []string `json:"spokes,omitempty"`
+	// mode is either bridge (default) or p2p, p2p mode requires exactly two spokes
+	// +optional
+	// +kubebuilder:validation:Enum=bridge;p2p
+	Mode string `json:"mode,omitempty"`
+	// fdbMode is how remote MAC addresses are known, either learn (default), static or evpn, static and evpn require vxlan encapsulation
+	// and bridge mode, evpn requires the BGP EVPN speaker enabled in the LAN daemonset; could be changed on a live LAN
+	// +optional
+	// +kubebuilder:validation:Enum=learn;static;evpn
+	FDBMode string `json:"fdbMode,omitempty"`
+	// transparency is the link-local control frame forwarding profile, either standard (default) or full
+	// +optional
+	// +kubebuilder:validation:Enum=standard;full
+	Transparency string `json:"transparency,omitempty"`
+	// impairment applies to all spokes of the LAN, unless overridden in spokeImpairments;
+	// could be changed on a live LAN
+	// +optional
+	Impairment *Impairment `json:"impairment,omitempty"`
+	// spokeImpairments lists impairment of individual spokes, key is the spoke name;
+	// could be changed on a live LAN
+	// +optional
+	SpokeImpairments map[string]Impairment `json:"spokeImpairments,omitempty"`
+	// spokeAdminStates lists the admin state of individual spokes, key is the spoke name, default is up;
+	// the bridge side veth of a down spoke is brought down so that the pod or VM sees no carrier;
+	// could be changed on a live LAN
+	// +optional
+	SpokeAdminStates map[string]AdminState `json:"spokeAdminStates,omitempty"`
+	// mirrors lists port mirroring sessions, could be changed on a live LAN
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Mirrors []Mirror `json:"mirrors,omitempty"`
+	// encryption encrypts vxlan traffic of the LAN between workers
+	// +optional
+	Encryption *Encryption `json:"encryption,omitempty"`
+	// remoteVteps lists external VTEPs, e.g. hardware switches terminating vxlan, BUM traffic is replicated to each of them
+	// in addition to the multicast group; requires vxlan encapsulation and can't be used with encryption,
+	// since external VTEPs send cleartext vxlan; could be changed on a live LAN
+	// +optional
+	// +listType=map
+	// +listMapKey=address
+	RemoteVTEPs []RemoteVTEP `json:"remoteVteps,omitempty"`
+}
+
+// RemoteVTEP is an external VTEP the vxlan interface of the LAN replicates BUM traffic to
+type RemoteVTEP struct {
+	// address is the underlay address of the VTEP, in the same address family as vxlanGrp
+	// +required
+	Address string `json:"address"`
+	// sources is the source address of vxlan traffic to the VTEP on each worker, key is the worker name;
+	// the underlying interface owning the address is used to reach the VTEP, default is the vxlan device of the LAN
+	// +optional
+	Sources map[string]string `json:"sources,omitempty"`
+	// macs lists MAC addresses behind the VTEP, they are programmed as static FDB entries instead of being learned
+	// +optional
+	MACs []string `json:"macs,omitempty"`
+}
+
+// GetSource returns the source address of vxlan traffic to the VTEP on node, invalid if not specified
+func (v *RemoteVTEP) GetSource(node string) netip.Addr {
+	addr, _ := netip.ParseAddr(v.Sources[node])
+	return addr
+}
+
+const (
+	// EncryptionModeIPsec is IPsec ESP in transport mode with AES-GCM
+	EncryptionModeIPsec        = "ipsec"
+	DefaultKeyRotationInterval = 24 * time.Hour
+)
+
+// Encryption specifies how vxlan traffic of a LAN is encrypted between workers;
+// each worker encrypts with its own key, published in a Secret per worker in the namespace of the LAN
+type Encryption struct {
+	// mode is the encryption mode, only ipsec is supported
+	// +optional
+	// +kubebuilder:validation:Enum=ipsec
+	Mode string `json:"mode,omitempty"`
+	// keyRotationInterval is how often each worker replaces its key, default is 24h
+	// +optional
+	KeyRotationInterval *metav1.Duration `json:"keyRotationInterval,omitempty"`
+}
+
+// GetKeyRotationInterval returns the key rotation interval with default applied
+func (e *Encryption) GetKeyRotationInterval() time.Duration {
+	if e.KeyRotationInterval == nil || e.KeyRotationInterval.Duration <= 0 {
+		return DefaultKeyRotationInterval
+	}
+	return e.KeyRotationInterval.Duration
+}
+
+const (
+	// MirrorSourceUplink could be used as a mirror source, means the vxlan interface
+	MirrorSourceUplink = "vxlan"
+	// MirrorDirectionIngress mirrors traffic sent by the source
+	MirrorDirectionIngress = "ingress"
+	// MirrorDirectionEgress mirrors traffic received by the source
+	MirrorDirectionEgress = "egress"
+	MirrorDirectionBoth   = "both"
+	maxMirrors            = 64
+)
+
+// Mirror is a port mirroring session, copies traffic of sources to destination
+type Mirror struct {
+	// +required
+	Name string `json:"name"`
+	// sources is a list of spoke names, or "vxlan" for the vxlan interface
+	// +required
+	Sources []string `json:"sources"`
+	// direction is relative to the source, ingress means traffic sent by the source into the LAN,
+	// egress means traffic from the LAN to the source; default is both
+	// +optional
+	// +kubebuilder:validation:Enum=ingress;egress;both
+	Direction string `json:"direction,omitempty"`
+	// destination is the spoke receives the mirrored traffic
+	// +required
+	Destination string `json:"destination"`
+	// vni is the VNI carrying mirrored traffic when source and destination are on different workers,
+	// it must be different from any LAN's vni
+	// +required
+	VNI int32 `json:"vni"`
+}
+
+// MirrorIngress returns true if traffic sent by the sources is mirrored
+func (m *Mirror) MirrorIngress() bool {
+	return m.Direction != MirrorDirectionEgress
+}
+
+// MirrorEgress returns true if traffic received by the sources is mirrored
+func (m *Mirror) MirrorEgress() bool {
+	return m.Direction != MirrorDirectionIngress
+}
+
+func (spec *LANSpec) validateMirrors() error {
+	if len(spec.Mirrors) > maxMirrors {
+		return fmt.Errorf("the number of mirrors must be in range of 0..%d", maxMirrors)
+	}
+	names := map[string]bool{}
+	vnis := map[int32]bool{}
+	for _, m := range spec.Mirrors {
+		if m.Name == "" {
+			return fmt.Errorf("mirror name is not specified")
+		}
+		if names[m.Name] {
+			return fmt.Errorf("duplicate mirror name %v", m.Name)
+		}
+		names[m.Name] = true
+		if m.VNI <= 0 || m.VNI > 0xFFFFFF {
+			return fmt.Errorf("invalid vni %d of mirror %v, must be 1..16777215", m.VNI, m.Name)
+		}
+		if m.VNI == *spec.VNI || vnis[m.VNI] {
+			return fmt.Errorf("vni %d of mirror %v is already used", m.VNI, m.Name)
+		}
+		vnis[m.VNI] = true
+		switch m.Direction {
+		case "", MirrorDirectionIngress, MirrorDirectionEgress, MirrorDirectionBoth:
+		default:
+			return fmt.Errorf("unknown direction %v of mirror %v", m.Direction, m.Name)
+		}
+		if !slices.Contains(spec.SpokeList, m.Destination) {
+			return fmt.Errorf("destination %v of mirror %v is not a spoke", m.Destination, m.Name)
+		}
+		if len(m.Sources) == 0 {
+			return fmt.Errorf("mirror %v has no source", m.Name)
+		}
+		for _, src := range m.Sources {
+			if src == m.Destination {
+				return fmt.Errorf("destination %v of mirror %v is also a source", m.Destination, m.Name)
+			}
+			if src != MirrorSourceUplink && !slices.Contains(spec.SpokeList, src) {
+				return fmt.Errorf("source %v of mirror %v is not a spoke", src, m.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Impairment specifies network impairment of traffic sent to a spoke,
+// percentage values are strings like "0.5", means 0.5%
+type Impairment struct {
+	// +optional
+	Delay *metav1.Duration `json:"delay,omitempty"`
+	// +optional
+	Jitter *metav1.Duration `json:"jitter,omitempty"`
+	// loss percentage
+	// +optional
+	Loss string `json:"loss,omitempty"`
+	// corruption percentage
+	// +optional
+	Corrupt string `json:"corrupt,omitempty"`
+	// duplication percentage
+	// +optional
+	Duplicate string `json:"duplicate,omitempty"`
+	// reorder percentage, requires delay
+	// +optional
+	Reorder string `json:"reorder,omitempty"`
+	// rate limit in bits per second, e.g. 100M
+	// +optional
+	Rate *resource.Quantity `json:"rate,omitempty"`
+}
+
+// GetImpairment returns the impairment of the specified spoke, nil if there is none
+func (spec *LANSpec) GetImpairment(spoke string) *Impairment {
+	if imp, ok := spec.SpokeImpairments[spoke]; ok {
+		return &imp
+	}
+	return spec.Impairment
+}
+
+// AdminState is the admin state of a spoke
+// +kubebuilder:validation:Enum=up;down
+type AdminState string
+
+const (
+	AdminStateUp   AdminState = "up"
+	AdminStateDown AdminState = "down"
+)
+
+// GetAdminState returns the admin state of the specified spoke, default is up
+func (spec *LANSpec) GetAdminState(spoke string) AdminState {
+	if state, ok := spec.SpokeAdminStates[spoke]; ok && state != "" {
+		return state
+	}
+	return AdminStateUp
+}
+
+// ParsePercentage parses a percentage string of Impairment, empty string is 0
+func ParsePercentage(s string) (float32, error) {
+	if s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %v, %w", s, err)
+	}
+	if f < 0 || f > 100 {
+		return 0, fmt.Errorf("invalid percentage %v, must be 0..100", s)
+	}
+	return float32(f), nil
+}
+
+func (imp *Impairment) Validate() error {
+	for _, p := range []string{imp.Loss, imp.Corrupt, imp.Duplicate, imp.Reorder} {
+		if _, err := ParsePercentage(p); err != nil {
+			return err
+		}
+	}
+	if imp.Delay != nil && imp.Delay.Duration < 0 {
+		return fmt.Errorf("invalid delay %v", imp.Delay.Duration)
+	}
+	if imp.Jitter != nil && imp.Jitter.Duration < 0 {
+		return fmt.Errorf("invalid jitter %v", imp.Jitter.Duration)
+	}
+	if imp.Reorder != "" && (imp.Delay == nil || imp.Delay.Duration == 0) {
+		return fmt.Errorf("reorder requires delay")
+	}
+	if imp.Rate != nil && imp.Rate.Value() <= 0 {
+		return fmt.Errorf("invalid rate %v", imp.Rate.String())
+	}
+	return nil
+}
+
+// GetEncapsulation returns the encapsulation with default applied
+func (spec *LANSpec) GetEncapsulation() string {
+	if spec.Encapsulation == "" {
+		return EncapVxLAN
+	}
+	return spec.Encapsulation
+}
+
+// IsUnicastEncap returns true if the encapsulation has no multicast support,
+// the tunnel then connects the two workers of tunnelEndpoints instead of joining vxlanGrp
+func (spec *LANSpec) IsUnicastEncap() bool {
+	switch spec.GetEncapsulation() {
+	case EncapGeneve, EncapIP6GRETap:
+		return true
+	}
+	return false
+}
+
+// GetUDPPort returns the UDP port of the tunnel, 0 if the encapsulation is not over UDP
+func (spec *LANSpec) GetUDPPort() int32 {
+	switch spec.GetEncapsulation() {
+	case EncapVxLAN, EncapGeneve:
+		if spec.VxPort != nil {
+			return *spec.VxPort
+		}
+	}
+	return 0
+}
+
+// GetTTL returns the TTL of tunnel packets, 0 means the kernel default
+func (spec *LANSpec) GetTTL() int {
+	if spec.TTL == nil {
+		return 0
+	}
+	return int(*spec.TTL)
+}
+
+// GetTunnelEndpoints returns the underlay address of node and of the other worker in tunnelEndpoints
+func (spec *LANSpec) GetTunnelEndpoints(node string) (local, remote netip.Addr, err error) {
+	if _, ok := spec.TunnelEndpoints[node]; !ok {
+		return local, remote, fmt.Errorf("%v is not in tunnelEndpoints", node)
+	}
+	for n, addrStr := range spec.TunnelEndpoints {
+		addr, err := netip.ParseAddr(addrStr)
+		if err != nil {
+			return local, remote, fmt.Errorf("invalid tunnel endpoint %v of %v, %w", addrStr, n, err)
+		}
+		if n == node {
+			local = addr
+		} else {
+			remote = addr
+		}
+	}
+	if !remote.IsValid() {
+		return local, remote, fmt.Errorf("no other worker in tunnelEndpoints")
+	}
+	return local, remote, nil
+}
+
+func (spec *LANSpec) validateEncapsulation() error {
+	grp := netip.MustParseAddr(*spec.VxLANGrp)
+	switch spec.GetEncapsulation() {
+	case EncapVxLAN:
+	case EncapGRETap:
+		if !grp.Is4() {
+			return fmt.Errorf("%v requires an IPv4 vxlanGrp", EncapGRETap)
+		}
+	case EncapGeneve, EncapIP6GRETap:
+		if len(spec.TunnelEndpoints) != 2 {
+			return fmt.Errorf("%v requires exactly 2 tunnelEndpoints, got %d", spec.Encapsulation, len(spec.TunnelEndpoints))
+		}
+		families := map[bool]bool{}
+		for node, addrStr := range spec.TunnelEndpoints {
+			addr, err := netip.ParseAddr(addrStr)
+			if err != nil {
+				return fmt.Errorf("invalid tunnel endpoint of %v, %w", node, err)
+			}
+			if addr.IsMulticast() || addr.IsUnspecified() {
+				return fmt.Errorf("tunnel endpoint %v of %v is not a unicast address", addr, node)
+			}
+			if spec.Encapsulation == EncapIP6GRETap && !addr.Is6() {
+				return fmt.Errorf("%v requires IPv6 tunnelEndpoints, got %v", EncapIP6GRETap, addr)
+			}
+			families[addr.Is4()] = true
+		}
+		if len(families) != 1 {
+			return fmt.Errorf("tunnelEndpoints must be of the same address family")
+		}
+	default:
+		return fmt.Errorf("unknown encapsulation %v", spec.Encapsulation)
+	}
+	if spec.TTL != nil && (*spec.TTL < 0 || *spec.TTL > 255) {
+		return fmt.Errorf("invalid ttl %d, must be 0..255", *spec.TTL)
+	}
+	if spec.Encryption != nil && spec.GetEncapsulation() != EncapVxLAN {
+		return fmt.Errorf("encryption is only supported with %v encapsulation", EncapVxLAN)
+	}
+	return nil
+}
+
+// IsStaticFDB returns true if the LAN uses static FDB
+func (spec *LANSpec) IsStaticFDB() bool {
+	return spec.FDBMode == FDBModeStatic
+}
+
+// IsEVPN returns true if the LAN uses BGP EVPN to distribute remote MAC addresses
+func (spec *LANSpec) IsEVPN() bool {
+	return spec.FDBMode == FDBModeEVPN
+}
+
+// IsLearning returns true if the tunnel interface learns remote MAC addresses
+func (spec *LANSpec) IsLearning() bool {
+	return !spec.IsP2P() && !spec.IsStaticFDB() && !spec.IsEVPN()
+}
+
+// IsARPProxy returns true if the tunnel interface answers ARP/ND requests with the programmed neighbor entries
+func (spec *LANSpec) IsARPProxy() bool {
+	return spec.IsStaticFDB() || spec.IsEVPN()
+}
+
+// IsP2P returns true if the LAN is a point-to-point link
+func (spec *LANSpec) IsP2P() bool {
+	return spec.Mode == LANModeP2P
 }
 
 const (
 	maxLinuxIfNameLen = 13
-	FinalizerPrefix   = "finalizer.k8slan.io"
+	// MaxSpokeNameLen is the max length of a spoke name, the bridge side veth in the LAN NS has an extra suffix
+	MaxSpokeNameLen = maxLinuxIfNameLen
+	FinalizerPrefix = "finalizer.k8slan.io"
+	// LabelLAN and LabelNode label the IPsec key Secret of a worker for a LAN
+	LabelLAN  = "lan.k8slan.io/lan"
+	LabelNode = "lan.k8slan.io/node"
 )
 
+// GetKeySecretName returns the name of the IPsec key Secret of lan on node,
+// it is created by the controller for each node of an encrypted LAN, and filled by the LAN daemonset of the node
+func GetKeySecretName(lan, node string) string {
+	return fmt.Sprintf("%v-ipsec-%v", lan, node)
+}
+
+// GetNodes returns the workers whose LAN daemonset has processed the LAN,
+// each of them adds a finalizer FinalizerPrefix/<node>
+func (lan *LAN) GetNodes() []string {
+	r := []string{}
+	for _, fin := range lan.Finalizers {
+		if node, ok := strings.CutPrefix(fin, FinalizerPrefix+"/"); ok {
+			r = append(r, node)
+		}
+	}
+	return r
+}
+
 func checkInterfaceName(ifname string) error {
 	nlen := len(ifname)
 	if nlen == 0 || nlen > maxLinuxIfNameLen {
@@ -73,6 +538,46 @@ func checkInterfaceName(ifname string) error {
 	return nil
 }
 
+func (spec *LANSpec) validateRemoteVTEPs() error {
+	if len(spec.RemoteVTEPs) == 0 {
+		return nil
+	}
+	if spec.GetEncapsulation() != EncapVxLAN {
+		return fmt.Errorf("remote vteps require %v encapsulation", EncapVxLAN)
+	}
+	if spec.Encryption != nil {
+		//inbound IPsec policies require ESP on the vxlan port, cleartext vxlan of external VTEPs would be dropped
+		return fmt.Errorf("remote vteps can't be used with encryption")
+	}
+	grp := netip.MustParseAddr(*spec.VxLANGrp)
+	addrs := map[netip.Addr]bool{}
+	for _, v := range spec.RemoteVTEPs {
+		addr, err := netip.ParseAddr(v.Address)
+		if err != nil {
+			return fmt.Errorf("invalid remote vtep address %v, %w", v.Address, err)
+		}
+		if addr.IsMulticast() || addr.IsUnspecified() || addr.Is4() != grp.Is4() {
+			return fmt.Errorf("remote vtep address %v must be a unicast address of the same family as %v", v.Address, *spec.VxLANGrp)
+		}
+		if addrs[addr] {
+			return fmt.Errorf("duplicate remote vtep %v", v.Address)
+		}
+		addrs[addr] = true
+		for node, src := range v.Sources {
+			srcAddr, err := netip.ParseAddr(src)
+			if err != nil || srcAddr.Is4() != addr.Is4() {
+				return fmt.Errorf("invalid source %v of remote vtep %v on %v", src, v.Address, node)
+			}
+		}
+		for _, mac := range v.MACs {
+			if _, err := net.ParseMAC(mac); err != nil {
+				return fmt.Errorf("invalid mac %v of remote vtep %v, %w", mac, v.Address, err)
+			}
+		}
+	}
+	return nil
+}
+
 func (spec *LANSpec) Validate() error {
 
 	if err := checkInterfaceName(*spec.BridgeName); err != nil {
@@ -96,6 +601,9 @@ func (spec *LANSpec) Validate() error {
 	if !addr.IsMulticast() {
 		return fmt.Errorf("%v is not a multicast address", *spec.VxLANGrp)
 	}
+	if err := spec.validateEncapsulation(); err != nil {
+		return err
+	}
 	if len(spec.SpokeList) == 0 || len(spec.SpokeList) > 4095 {
 		return fmt.Errorf("the number of vlan names must be in range of 1..4095")
 	}
@@ -104,6 +612,58 @@ func (spec *LANSpec) Validate() error {
 			return err
 		}
 	}
+	switch spec.Mode {
+	case "", LANModeBridge:
+	case LANModeP2P:
+		if len(spec.SpokeList) != 2 {
+			return fmt.Errorf("p2p mode requires exactly 2 spokes, got %d", len(spec.SpokeList))
+		}
+	default:
+		return fmt.Errorf("unknown mode %v, must be %v or %v", spec.Mode, LANModeBridge, LANModeP2P)
+	}
+	switch spec.FDBMode {
+	case "", FDBModeLearn:
+	case FDBModeStatic, FDBModeEVPN:
+		if spec.IsP2P() || spec.GetEncapsulation() != EncapVxLAN {
+			return fmt.Errorf("%v fdb mode requires %v mode and %v encapsulation", spec.FDBMode, LANModeBridge, EncapVxLAN)
+		}
+	default:
+		return fmt.Errorf("unknown fdb mode %v, must be %v, %v or %v", spec.FDBMode, FDBModeLearn, FDBModeStatic, FDBModeEVPN)
+	}
+	switch spec.Transparency {
+	case "", TransparencyStandard, TransparencyFull:
+	default:
+		return fmt.Errorf("unknown transparency %v, must be %v or %v", spec.Transparency, TransparencyStandard, TransparencyFull)
+	}
+	if spec.Impairment != nil {
+		if err := spec.Impairment.Validate(); err != nil {
+			return fmt.Errorf("invalid impairment, %w", err)
+		}
+	}
+	for spoke, imp := range spec.SpokeImpairments {
+		if !slices.Contains(spec.SpokeList, spoke) {
+			return fmt.Errorf("impairment specified for unknown spoke %v", spoke)
+		}
+		if err := imp.Validate(); err != nil {
+			return fmt.Errorf("invalid impairment of spoke %v, %w", spoke, err)
+		}
+	}
+	for spoke, state := range spec.SpokeAdminStates {
+		if !slices.Contains(spec.SpokeList, spoke) {
+			return fmt.Errorf("admin state specified for unknown spoke %v", spoke)
+		}
+		switch state {
+		case AdminStateUp, AdminStateDown:
+		default:
+			return fmt.Errorf("unknown admin state %v of spoke %v, must be %v or %v", state, spoke, AdminStateUp, AdminStateDown)
+		}
+	}
+	if err := spec.validateMirrors(); err != nil {
+		return err
+	}
+	if err := spec.validateRemoteVTEPs(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -128,6 +688,160 @@ type LANStatus struct {
 	// +listMapKey=type
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// encryption lists the encryption state of each worker the LAN exists on, if encryption is enabled
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	Encryption []NodeEncryptionStatus `json:"encryption,omitempty"`
+	// remoteVteps lists the reachability of remote vteps from each worker the LAN exists on
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	RemoteVTEPs []NodeRemoteVTEPStatus `json:"remoteVteps,omitempty"`
+	// linkStates lists the link state of the bridge side veth of spokes on each worker, with their transitions
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	LinkStates []NodeLinkStates `json:"linkStates,omitempty"`
+}
+
+// NodeLinkStates is the link state of spokes on a worker
+type NodeLinkStates struct {
+	// +required
+	Node string `json:"node"`
+	// +listType=map
+	// +listMapKey=spoke
+	// +optional
+	Spokes []SpokeLinkState `json:"spokes,omitempty"`
+}
+
+// SpokeLinkState is the link state of the bridge side veth of a spoke
+type SpokeLinkState struct {
+	// +required
+	Spoke string `json:"spoke"`
+	// state is either up or down
+	// +required
+	State AdminState `json:"state"`
+	// reason is what changed the state last time, adminState or FaultSchedule/<name>
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// transitions is the number of state changes on the worker
+	// +optional
+	Transitions int32 `json:"transitions,omitempty"`
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// GetSpoke returns the link state of spoke, nil if not found
+func (states *NodeLinkStates) GetSpoke(spoke string) *SpokeLinkState {
+	for i := range states.Spokes {
+		if states.Spokes[i].Spoke == spoke {
+			return &states.Spokes[i]
+		}
+	}
+	return nil
+}
+
+// NodeRemoteVTEPStatus is the reachability of remote vteps from a worker
+type NodeRemoteVTEPStatus struct {
+	// +required
+	Node string `json:"node"`
+	// +optional
+	VTEPs []RemoteVTEPStatus `json:"vteps,omitempty"`
+}
+
+// RemoteVTEPStatus is the reachability of a remote vtep, checked by ICMP echo from the source address
+type RemoteVTEPStatus struct {
+	// +required
+	Address string `json:"address"`
+	// +required
+	Reachable bool `json:"reachable"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// NodeEncryptionStatus is the encryption state of a worker
+type NodeEncryptionStatus struct {
+	// +required
+	Node string `json:"node"`
+	// encryptedPeers lists workers whose traffic to and from this worker is encrypted
+	// +optional
+	EncryptedPeers []string `json:"encryptedPeers,omitempty"`
+	// +optional
+	LastKeyRotation *metav1.Time `json:"lastKeyRotation,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// GetNodeEncryption returns the encryption state of node, nil if not found
+func (status *LANStatus) GetNodeEncryption(node string) *NodeEncryptionStatus {
+	for i := range status.Encryption {
+		if status.Encryption[i].Node == node {
+			return &status.Encryption[i]
+		}
+	}
+	return nil
+}
+
+// SetNodeEncryption adds or replaces the encryption state of nodeStatus.Node
+func (status *LANStatus) SetNodeEncryption(nodeStatus NodeEncryptionStatus) {
+	if existing := status.GetNodeEncryption(nodeStatus.Node); existing != nil {
+		*existing = nodeStatus
+		return
+	}
+	status.Encryption = append(status.Encryption, nodeStatus)
+}
+
+// RemoveNodeEncryption removes the encryption state of node, returns false if not found
+func (status *LANStatus) RemoveNodeEncryption(node string) bool {
+	l := len(status.Encryption)
+	status.Encryption = slices.DeleteFunc(status.Encryption, func(n NodeEncryptionStatus) bool { return n.Node == node })
+	return len(status.Encryption) != l
+}
+
+// GetNodeRemoteVTEPs returns the remote vtep reachability of node, nil if not found
+func (status *LANStatus) GetNodeRemoteVTEPs(node string) *NodeRemoteVTEPStatus {
+	for i := range status.RemoteVTEPs {
+		if status.RemoteVTEPs[i].Node == node {
+			return &status.RemoteVTEPs[i]
+		}
+	}
+	return nil
+}
+
+// SetNodeRemoteVTEPs adds or replaces the remote vtep reachability of nodeStatus.Node
+func (status *LANStatus) SetNodeRemoteVTEPs(nodeStatus NodeRemoteVTEPStatus) {
+	if existing := status.GetNodeRemoteVTEPs(nodeStatus.Node); existing != nil {
+		*existing = nodeStatus
+		return
+	}
+	status.RemoteVTEPs = append(status.RemoteVTEPs, nodeStatus)
+}
+
+// RemoveNodeRemoteVTEPs removes the remote vtep reachability of node, returns false if not found
+func (status *LANStatus) RemoveNodeRemoteVTEPs(node string) bool {
+	l := len(status.RemoteVTEPs)
+	status.RemoteVTEPs = slices.DeleteFunc(status.RemoteVTEPs, func(n NodeRemoteVTEPStatus) bool { return n.Node == node })
+	return len(status.RemoteVTEPs) != l
+}
+
+// GetNodeLinkStates returns the link states of node, nil if not found
+func (status *LANStatus) GetNodeLinkStates(node string) *NodeLinkStates {
+	for i := range status.LinkStates {
+		if status.LinkStates[i].Node == node {
+			return &status.LinkStates[i]
+		}
+	}
+	return nil
+}
+
+// SetNodeLinkStates adds or replaces the link states of nodeStatus.Node
+func (status *LANStatus) SetNodeLinkStates(nodeStatus NodeLinkStates) {
+	if existing := status.GetNodeLinkStates(nodeStatus.Node); existing != nil {
+		*existing = nodeStatus
+		return
+	}
+	status.LinkStates = append(status.LinkStates, nodeStatus)
 }
 
 // +kubebuilder:object:root=true
@@ -205,12 +919,28 @@ func (lanspec *LANSpec) GetNADs(ns string) []*ncv1.NetworkAttachmentDefinition {
       "cniVersion": "0.3.1",
       "name": "%v",
       "type": "k8slanveth",
-	  "veth": "%v"
+	  "veth": "%v",
+	  "lanNS": "%v"%v
     }`
+	lanNS := ""
+	if lanspec.NS != nil {
+		lanNS = *lanspec.NS
+	}
 	genNAD := func(name, ns string) *ncv1.NetworkAttachmentDefinition {
 		cfgStr := fmt.Sprintf(macvtapTemplate, name)
 		if !IsMACVTAPResource(name) {
-			cfgStr = fmt.Sprintf(vethTempalte, name, GetSpokeNameFromResourceName(name))
+			spoke := GetSpokeNameFromResourceName(name)
+			//when the pod is removed, the CNI moves the pod side of a p2p link joined by a single veth pair
+			//back to the LAN NS as the bridge side veth of the other spoke
+			p2pPeer := ""
+			if lanspec.IsP2P() && len(lanspec.SpokeList) == 2 {
+				other := lanspec.SpokeList[0]
+				if other == spoke {
+					other = lanspec.SpokeList[1]
+				}
+				p2pPeer = fmt.Sprintf(",\n\t  \"p2pPeer\": \"%v\"", other)
+			}
+			cfgStr = fmt.Sprintf(vethTempalte, name, spoke, lanNS, p2pPeer)
 		}
 		return &ncv1.NetworkAttachmentDefinition{
 			TypeMeta: metav1.TypeMeta{
@@ -0,0 +1,32 @@
+package v1beta1
+
+import "testing"
+
+func newTestLANSpec() *LANSpec {
+	str := func(s string) *string { return &s }
+	vni := int32(100)
+	return &LANSpec{
+		NS:         str("lan1"),
+		BridgeName: str("br-lan1"),
+		VxLANName:  str("vx-lan1"),
+		VNI:        &vni,
+		VxLANGrp:   str("239.1.1.1"),
+		SpokeList:  []string{"s1", "s2"},
+	}
+}
+
+func TestValidateRemoteVTEPs(t *testing.T) {
+	spec := newTestLANSpec()
+	spec.RemoteVTEPs = []RemoteVTEP{{Address: "192.0.2.1"}}
+	if err := spec.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	spec.Encryption = &Encryption{}
+	if err := spec.Validate(); err == nil {
+		t.Error("expect error for remote vteps with encryption")
+	}
+	spec.RemoteVTEPs = nil
+	if err := spec.Validate(); err != nil {
+		t.Errorf("encryption without remote vteps is rejected, %v", err)
+	}
+}
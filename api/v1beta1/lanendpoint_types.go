@@ -0,0 +1,89 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LANEndpointSpec is the MAC and IP bindings of a LAN on a worker, published by the LAN daemonset of the worker
+// if the LAN uses static FDB; other workers program them on their vxlan interfaces
+type LANEndpointSpec struct {
+	// lan is the name of the LAN, in the same namespace as the LANEndpoint
+	// +required
+	LAN string `json:"lan"`
+	// +required
+	Node string `json:"node"`
+	// vtep is the source address of vxlan traffic of the LAN on the worker
+	// +required
+	VTEP string `json:"vtep"`
+	// bindings lists the pods and VMs attached to spokes of the LAN on the worker
+	// +optional
+	Bindings []EndpointBinding `json:"bindings,omitempty"`
+}
+
+// EndpointBinding is the MAC and IP addresses of a pod or VM interface attached to a spoke
+type EndpointBinding struct {
+	// +required
+	Spoke string `json:"spoke"`
+	// +required
+	MAC string `json:"mac"`
+	// +optional
+	IPs []string `json:"ips,omitempty"`
+	// pod is the pod the interface belongs to, in the format of <namespace>/<name>
+	// +optional
+	Pod string `json:"pod,omitempty"`
+}
+
+// GetLANEndpointName returns the name of the LANEndpoint of lan on node
+func GetLANEndpointName(lan, node string) string {
+	return fmt.Sprintf("%v-%v", lan, node)
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="LAN",type=string,JSONPath=`.spec.lan`
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.node`
+// +kubebuilder:printcolumn:name="VTEP",type=string,JSONPath=`.spec.vtep`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// LANEndpoint is the Schema for the lanendpoints API
+type LANEndpoint struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the bindings of the LAN on the worker
+	// +required
+	Spec LANEndpointSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// LANEndpointList contains a list of LANEndpoint
+type LANEndpointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LANEndpoint `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LANEndpoint{}, &LANEndpointList{})
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"net/netip"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultPeeringPort is the default UDP port of the vxlan between gateways,
+	// it is different from DefaultVxLANPort so that the peering doesn't clash with the LAN's own vxlan interface
+	DefaultPeeringPort = 8472
+	// PeeringKubeconfigKey is the key of the kubeconfig in the Secret referred by a LANPeering
+	PeeringKubeconfigKey = "kubeconfig"
+	// PeeringConditionReady is the condition type set when the remote gateway is known
+	PeeringConditionReady = "Ready"
+)
+
+// LANPeeringSpec stretches a LAN to the same-named LAN in a remote cluster,
+// via a unicast vxlan tunnel between a gateway worker in each cluster.
+// a LANPeering needs to be created in both clusters, pointing to each other.
+type LANPeeringSpec struct {
+	// lan is the name of the LAN, in the same namespace as the LANPeering;
+	// the remote cluster must have a LAN with the same name
+	// +required
+	LAN string `json:"lan"`
+	// kubeconfigSecret is the name of a Secret in the same namespace as the LANPeering,
+	// its key "kubeconfig" is a kubeconfig of the remote cluster,
+	// it needs permission to get LANs and list LANPeerings in remoteNamespace
+	// +required
+	KubeconfigSecret string `json:"kubeconfigSecret"`
+	// remoteNamespace is the namespace of the LAN and LANPeering in the remote cluster,
+	// default is the namespace of the LANPeering
+	// +optional
+	RemoteNamespace string `json:"remoteNamespace,omitempty"`
+	// gatewayNode is the local worker that terminates the tunnel to the remote cluster
+	// +required
+	GatewayNode string `json:"gatewayNode"`
+	// gatewayAddress is the underlay address of gatewayNode, must be reachable from the remote gateway
+	// +required
+	GatewayAddress string `json:"gatewayAddress"`
+	// vni of the tunnel between gateways, default is the vni of the LAN, must be the same on both sides
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=16777215
+	// +optional
+	VNI *int32 `json:"vni,omitempty"`
+	// port is the UDP port of the tunnel between gateways, default is 8472, must be the same on both sides
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+}
+
+// GetRemoteNamespace returns the namespace of the peer in the remote cluster
+func (spec *LANPeeringSpec) GetRemoteNamespace(ns string) string {
+	if spec.RemoteNamespace != "" {
+		return spec.RemoteNamespace
+	}
+	return ns
+}
+
+// GetVNI returns the vni of the tunnel, lan is the spec of the local LAN
+func (spec *LANPeeringSpec) GetVNI(lan *LANSpec) int32 {
+	if spec.VNI != nil {
+		return *spec.VNI
+	}
+	return *lan.VNI
+}
+
+// GetPort returns the UDP port of the tunnel
+func (spec *LANPeeringSpec) GetPort() int32 {
+	if spec.Port != nil {
+		return *spec.Port
+	}
+	return DefaultPeeringPort
+}
+
+// Validate checks the spec against the local LAN
+func (spec *LANPeeringSpec) Validate(lan *LANSpec) error {
+	if _, err := netip.ParseAddr(spec.GatewayAddress); err != nil {
+		return fmt.Errorf("invalid gateway address %v, %w", spec.GatewayAddress, err)
+	}
+	if lan.IsP2P() {
+		return fmt.Errorf("LAN %v is p2p, only bridge mode LAN could be peered", spec.LAN)
+	}
+	if lan.GetEncapsulation() != EncapVxLAN {
+		return fmt.Errorf("LAN %v uses %v encapsulation, only vxlan LAN could be peered", spec.LAN, lan.GetEncapsulation())
+	}
+	if lan.VxPort != nil && spec.GetPort() == *lan.VxPort {
+		return fmt.Errorf("port %d is already used by LAN %v", spec.GetPort(), spec.LAN)
+	}
+	return nil
+}
+
+// LANPeeringStatus is the remote gateway learned from the remote cluster
+type LANPeeringStatus struct {
+	// remoteGatewayNode is the gateway worker in the remote cluster
+	// +optional
+	RemoteGatewayNode string `json:"remoteGatewayNode,omitempty"`
+	// remoteGatewayAddress is the underlay address of the remote gateway
+	// +optional
+	RemoteGatewayAddress string `json:"remoteGatewayAddress,omitempty"`
+	// lastSyncTime is the last time the remote cluster was successfully queried
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="LAN",type=string,JSONPath=`.spec.lan`
+// +kubebuilder:printcolumn:name="Gateway",type=string,JSONPath=`.spec.gatewayNode`
+// +kubebuilder:printcolumn:name="Remote",type=string,JSONPath=`.status.remoteGatewayAddress`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// LANPeering is the Schema for the lanpeerings API
+type LANPeering struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of LANPeering
+	// +required
+	Spec LANPeeringSpec `json:"spec"`
+
+	// status defines the observed state of LANPeering
+	// +optional
+	Status LANPeeringStatus `json:"status,omitempty,omitzero"`
+}
+
+// IsReady returns true if the remote gateway is known
+func (p *LANPeering) IsReady() bool {
+	return p.Status.RemoteGatewayAddress != "" && meta.IsStatusConditionTrue(p.Status.Conditions, PeeringConditionReady)
+}
+
+// +kubebuilder:object:root=true
+
+// LANPeeringList contains a list of LANPeering
+type LANPeeringList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LANPeering `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LANPeering{}, &LANPeeringList{})
+}
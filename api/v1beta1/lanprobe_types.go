@@ -0,0 +1,188 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"slices"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	DefaultProbeInterval = 30 * time.Second
+	DefaultProbeCount    = 3
+	DefaultProbeTimeout  = time.Second
+)
+
+// LANProbeSpec defines the desired state of LANProbe
+type LANProbeSpec struct {
+	// lan is the name of the LAN to probe, in the same namespace as the LANProbe
+	// +required
+	LAN string `json:"lan"`
+	// interval between two probe rounds, default is 30s
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// count is the number of probe frames sent in each round, default is 3
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Count *int32 `json:"count,omitempty"`
+	// timeout is how long to wait for replies after the last probe frame of a round is sent, default is 1s
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// GetInterval returns the probe interval with default applied
+func (spec *LANProbeSpec) GetInterval() time.Duration {
+	if spec.Interval == nil || spec.Interval.Duration <= 0 {
+		return DefaultProbeInterval
+	}
+	return spec.Interval.Duration
+}
+
+// GetCount returns the number of probe frames per round with default applied
+func (spec *LANProbeSpec) GetCount() int {
+	if spec.Count == nil || *spec.Count <= 0 {
+		return DefaultProbeCount
+	}
+	return int(*spec.Count)
+}
+
+// GetTimeout returns the reply timeout with default applied
+func (spec *LANProbeSpec) GetTimeout() time.Duration {
+	if spec.Timeout == nil || spec.Timeout.Duration <= 0 {
+		return DefaultProbeTimeout
+	}
+	return spec.Timeout.Duration
+}
+
+// PeerProbeResult is the probe result from a worker to another worker
+type PeerProbeResult struct {
+	// node is the peer worker
+	// +required
+	Node string `json:"node"`
+	// reachable is true if any probe frame of the last round is replied
+	Reachable bool `json:"reachable"`
+	// +optional
+	Sent int32 `json:"sent,omitempty"`
+	// +optional
+	Received int32 `json:"received,omitempty"`
+	// rtt is the average round trip time of the last round
+	// +optional
+	RTT *metav1.Duration `json:"rtt,omitempty"`
+}
+
+// NodeProbeStatus is the probe result of a worker to all other workers of the LAN,
+// which is a row of the reachability matrix
+type NodeProbeStatus struct {
+	// +required
+	Node string `json:"node"`
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	Peers []PeerProbeResult `json:"peers,omitempty"`
+	// +optional
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// LANProbeStatus defines the observed state of LANProbe.
+type LANProbeStatus struct {
+	// nodes lists probe results of each worker the LAN exists on
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	Nodes []NodeProbeStatus `json:"nodes,omitempty"`
+	// unreachable is the number of worker pairs unreachable in the last round
+	// +optional
+	Unreachable int32 `json:"unreachable,omitempty"`
+}
+
+// GetNodeStatus returns the probe status of the specified node, nil if not found
+func (status *LANProbeStatus) GetNodeStatus(node string) *NodeProbeStatus {
+	for i := range status.Nodes {
+		if status.Nodes[i].Node == node {
+			return &status.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// SetNodeStatus adds or replaces the probe status of nodeStatus.Node, and updates Unreachable
+func (status *LANProbeStatus) SetNodeStatus(nodeStatus NodeProbeStatus) {
+	if existing := status.GetNodeStatus(nodeStatus.Node); existing != nil {
+		*existing = nodeStatus
+	} else {
+		status.Nodes = append(status.Nodes, nodeStatus)
+	}
+	status.countUnreachable()
+}
+
+// RemoveNodeStatus removes the probe status of the specified node, and updates Unreachable
+func (status *LANProbeStatus) RemoveNodeStatus(node string) {
+	status.Nodes = slices.DeleteFunc(status.Nodes, func(n NodeProbeStatus) bool { return n.Node == node })
+	status.countUnreachable()
+}
+
+func (status *LANProbeStatus) countUnreachable() {
+	status.Unreachable = 0
+	for _, n := range status.Nodes {
+		for _, p := range n.Peers {
+			if !p.Reachable {
+				status.Unreachable++
+			}
+		}
+	}
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="LAN",type=string,JSONPath=`.spec.lan`
+// +kubebuilder:printcolumn:name="Unreachable",type=integer,JSONPath=`.status.unreachable`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// LANProbe is the Schema for the lanprobes API
+type LANProbe struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of LANProbe
+	// +required
+	Spec LANProbeSpec `json:"spec"`
+
+	// status defines the observed state of LANProbe
+	// +optional
+	Status LANProbeStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// LANProbeList contains a list of LANProbe
+type LANProbeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LANProbe `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LANProbe{}, &LANProbeList{})
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultCaptureDuration is used when neither duration nor packetLimit is specified
+	DefaultCaptureDuration = time.Minute
+	DefaultCaptureSnapLen  = 262144
+)
+
+const (
+	CapturePhaseRunning   = "Running"
+	CapturePhaseCompleted = "Completed"
+	CapturePhaseFailed    = "Failed"
+)
+
+// PacketCaptureSpec defines the desired state of PacketCapture
+type PacketCaptureSpec struct {
+	// lan is the name of the LAN to capture, in the same namespace as the PacketCapture
+	// +required
+	LAN string `json:"lan"`
+	// spoke is the spoke to capture, the bridge side veth of the spoke in the LAN namespace is captured;
+	// if not specified, the bridge (or the vxlan interface in p2p mode) is captured
+	// +optional
+	Spoke string `json:"spoke,omitempty"`
+	// node is the worker to capture on; if not specified, capture on every worker the LAN exists
+	// +optional
+	Node string `json:"node,omitempty"`
+	// filter is a pcap filter expression, e.g. "tcp port 179 or arp", only a subset of the pcap-filter syntax is supported
+	// +optional
+	Filter string `json:"filter,omitempty"`
+	// filterBytecode is raw classic BPF bytecode in the output format of "tcpdump -ddd <expression>",
+	// for expressions not supported by filter; it can't be used together with filter
+	// +optional
+	FilterBytecode string `json:"filterBytecode,omitempty"`
+	// duration of the capture
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// packetLimit stops the capture after the specified number of packets are captured
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PacketLimit *int64 `json:"packetLimit,omitempty"`
+	// snapLen is the max number of bytes captured of each packet
+	// +optional
+	// +kubebuilder:validation:Minimum=64
+	// +kubebuilder:validation:Maximum=262144
+	SnapLen *int32 `json:"snapLen,omitempty"`
+	// maxFileSize stops the capture before the capture file on a worker exceeds it,
+	// it is capped by the max capture file size of the LAN daemonset
+	// +optional
+	MaxFileSize *resource.Quantity `json:"maxFileSize,omitempty"`
+}
+
+// NodeCaptureStatus is the capture status on a worker
+type NodeCaptureStatus struct {
+	// +required
+	Node string `json:"node"`
+	// phase is one of Running, Completed and Failed
+	// +required
+	Phase string `json:"phase"`
+	// +optional
+	Packets int64 `json:"packets,omitempty"`
+	// +optional
+	Bytes int64 `json:"bytes,omitempty"`
+	// url to download the capture file in pcapng format
+	// +optional
+	URL string `json:"url,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// PacketCaptureStatus defines the observed state of PacketCapture.
+type PacketCaptureStatus struct {
+	// nodes lists capture status of each worker
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	Nodes []NodeCaptureStatus `json:"nodes,omitempty"`
+}
+
+// GetNodeStatus returns the capture status of the specified node, nil if not found
+func (status *PacketCaptureStatus) GetNodeStatus(node string) *NodeCaptureStatus {
+	for i := range status.Nodes {
+		if status.Nodes[i].Node == node {
+			return &status.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// SetNodeStatus adds or replaces the capture status of nodeStatus.Node
+func (status *PacketCaptureStatus) SetNodeStatus(nodeStatus NodeCaptureStatus) {
+	if existing := status.GetNodeStatus(nodeStatus.Node); existing != nil {
+		*existing = nodeStatus
+		return
+	}
+	status.Nodes = append(status.Nodes, nodeStatus)
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="LAN",type=string,JSONPath=`.spec.lan`
+// +kubebuilder:printcolumn:name="Spoke",type=string,JSONPath=`.spec.spoke`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PacketCapture is the Schema for the packetcaptures API
+type PacketCapture struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of PacketCapture
+	// +required
+	Spec PacketCaptureSpec `json:"spec"`
+
+	// status defines the observed state of PacketCapture
+	// +optional
+	Status PacketCaptureStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// PacketCaptureList contains a list of PacketCapture
+type PacketCaptureList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PacketCapture `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PacketCapture{}, &PacketCaptureList{})
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxCarrierFlap is the max carrierFlap of a SpokeBinding, the LAN DS holds the spoke down for it while reconciling
+const MaxCarrierFlap = 10 * time.Second
+
+// SpokeBindingSpec connects a spoke to a LAN other than its home LAN, i.e. the LAN listing it in spokes,
+// without restarting the pod or VM using it
+type SpokeBindingSpec struct {
+	// spoke is a spoke of a LAN in the same namespace
+	// +required
+	Spoke string `json:"spoke"`
+	// lan is a bridge mode LAN in the same namespace the spoke is connected to,
+	// the spoke is connected back to its home LAN if it is the home LAN or the SpokeBinding is removed
+	// +required
+	LAN string `json:"lan"`
+	// carrierFlap is how long the carrier of the spoke is held down when it is moved, to signal the change to the pod;
+	// default 0 means it is brought up right after moving, max 10s
+	// +optional
+	CarrierFlap *metav1.Duration `json:"carrierFlap,omitempty"`
+}
+
+// GetCarrierFlap returns the carrier flap duration
+func (spec *SpokeBindingSpec) GetCarrierFlap() time.Duration {
+	if spec.CarrierFlap == nil {
+		return 0
+	}
+	return spec.CarrierFlap.Duration
+}
+
+// Validate checks the spec against the home LAN and the target LAN
+func (spec *SpokeBindingSpec) Validate(home, target *LANSpec) error {
+	if !slices.Contains(home.SpokeList, spec.Spoke) {
+		return fmt.Errorf("spoke %v is not a spoke of its home LAN", spec.Spoke)
+	}
+	if target.IsP2P() {
+		return fmt.Errorf("LAN %v is p2p, a spoke could only be bound to a bridge mode LAN", spec.LAN)
+	}
+	if f := spec.GetCarrierFlap(); f < 0 || f > MaxCarrierFlap {
+		return fmt.Errorf("invalid carrier flap %v, must be 0..%v", f, MaxCarrierFlap)
+	}
+	return nil
+}
+
+// NodeSpokeBindingStatus is where the spoke is connected on a node
+type NodeSpokeBindingStatus struct {
+	// node is the worker the spoke is on
+	Node string `json:"node"`
+	// lan is the LAN the spoke is currently connected to
+	// +optional
+	LAN string `json:"lan,omitempty"`
+	// lastMoveTime is the last time the spoke was moved between LANs on the node
+	// +optional
+	LastMoveTime *metav1.Time `json:"lastMoveTime,omitempty"`
+	// message is the error of the last attempt, if any
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// SpokeBindingStatus is where the spoke is connected on each node it is on
+type SpokeBindingStatus struct {
+	// home is the LAN listing the spoke
+	// +optional
+	Home string `json:"home,omitempty"`
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	Nodes []NodeSpokeBindingStatus `json:"nodes,omitempty"`
+}
+
+// GetNode returns the status of node, nil if not found
+func (status *SpokeBindingStatus) GetNode(node string) *NodeSpokeBindingStatus {
+	for i := range status.Nodes {
+		if status.Nodes[i].Node == node {
+			return &status.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// SetNode adds or replaces the status of nodeStatus.Node
+func (status *SpokeBindingStatus) SetNode(nodeStatus NodeSpokeBindingStatus) {
+	if existing := status.GetNode(nodeStatus.Node); existing != nil {
+		*existing = nodeStatus
+		return
+	}
+	status.Nodes = append(status.Nodes, nodeStatus)
+}
+
+// RemoveNode removes the status of node, returns false if not found
+func (status *SpokeBindingStatus) RemoveNode(node string) bool {
+	l := len(status.Nodes)
+	status.Nodes = slices.DeleteFunc(status.Nodes, func(n NodeSpokeBindingStatus) bool { return n.Node == node })
+	return len(status.Nodes) != l
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Spoke",type=string,JSONPath=`.spec.spoke`
+// +kubebuilder:printcolumn:name="LAN",type=string,JSONPath=`.spec.lan`
+// +kubebuilder:printcolumn:name="Home",type=string,JSONPath=`.status.home`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// SpokeBinding is the Schema for the spokebindings API
+type SpokeBinding struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of SpokeBinding
+	// +required
+	Spec SpokeBindingSpec `json:"spec"`
+
+	// status defines the observed state of SpokeBinding
+	// +optional
+	Status SpokeBindingStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// SpokeBindingList contains a list of SpokeBinding
+type SpokeBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SpokeBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SpokeBinding{}, &SpokeBindingList{})
+}
@@ -21,10 +21,201 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Encryption) DeepCopyInto(out *Encryption) {
+	*out = *in
+	if in.KeyRotationInterval != nil {
+		in, out := &in.KeyRotationInterval, &out.KeyRotationInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Encryption.
+func (in *Encryption) DeepCopy() *Encryption {
+	if in == nil {
+		return nil
+	}
+	out := new(Encryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointBinding) DeepCopyInto(out *EndpointBinding) {
+	*out = *in
+	if in.IPs != nil {
+		in, out := &in.IPs, &out.IPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointBinding.
+func (in *EndpointBinding) DeepCopy() *EndpointBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FaultSchedule) DeepCopyInto(out *FaultSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FaultSchedule.
+func (in *FaultSchedule) DeepCopy() *FaultSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(FaultSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FaultSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FaultScheduleList) DeepCopyInto(out *FaultScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FaultSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FaultScheduleList.
+func (in *FaultScheduleList) DeepCopy() *FaultScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(FaultScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FaultScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FaultScheduleSpec) DeepCopyInto(out *FaultScheduleSpec) {
+	*out = *in
+	if in.Spokes != nil {
+		in, out := &in.Spokes, &out.Spokes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DownTime != nil {
+		in, out := &in.DownTime, &out.DownTime
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FaultScheduleSpec.
+func (in *FaultScheduleSpec) DeepCopy() *FaultScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FaultScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FaultScheduleStatus) DeepCopyInto(out *FaultScheduleStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeFaultScheduleStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FaultScheduleStatus.
+func (in *FaultScheduleStatus) DeepCopy() *FaultScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FaultScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Impairment) DeepCopyInto(out *Impairment) {
+	*out = *in
+	if in.Delay != nil {
+		in, out := &in.Delay, &out.Delay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Jitter != nil {
+		in, out := &in.Jitter, &out.Jitter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Rate != nil {
+		in, out := &in.Rate, &out.Rate
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Impairment.
+func (in *Impairment) DeepCopy() *Impairment {
+	if in == nil {
+		return nil
+	}
+	out := new(Impairment)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LAN) DeepCopyInto(out *LAN) {
 	*out = *in
@@ -34,18 +225,907 @@ func (in *LAN) DeepCopyInto(out *LAN) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LAN.
-func (in *LAN) DeepCopy() *LAN {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LAN.
+func (in *LAN) DeepCopy() *LAN {
+	if in == nil {
+		return nil
+	}
+	out := new(LAN)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LAN) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANEndpoint) DeepCopyInto(out *LANEndpoint) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANEndpoint.
+func (in *LANEndpoint) DeepCopy() *LANEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(LANEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LANEndpoint) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANEndpointList) DeepCopyInto(out *LANEndpointList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LANEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANEndpointList.
+func (in *LANEndpointList) DeepCopy() *LANEndpointList {
+	if in == nil {
+		return nil
+	}
+	out := new(LANEndpointList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LANEndpointList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANEndpointSpec) DeepCopyInto(out *LANEndpointSpec) {
+	*out = *in
+	if in.Bindings != nil {
+		in, out := &in.Bindings, &out.Bindings
+		*out = make([]EndpointBinding, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANEndpointSpec.
+func (in *LANEndpointSpec) DeepCopy() *LANEndpointSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LANEndpointSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANList) DeepCopyInto(out *LANList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LAN, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANList.
+func (in *LANList) DeepCopy() *LANList {
+	if in == nil {
+		return nil
+	}
+	out := new(LANList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LANList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANPeering) DeepCopyInto(out *LANPeering) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANPeering.
+func (in *LANPeering) DeepCopy() *LANPeering {
+	if in == nil {
+		return nil
+	}
+	out := new(LANPeering)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LANPeering) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANPeeringList) DeepCopyInto(out *LANPeeringList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LANPeering, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANPeeringList.
+func (in *LANPeeringList) DeepCopy() *LANPeeringList {
+	if in == nil {
+		return nil
+	}
+	out := new(LANPeeringList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LANPeeringList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANPeeringSpec) DeepCopyInto(out *LANPeeringSpec) {
+	*out = *in
+	if in.VNI != nil {
+		in, out := &in.VNI, &out.VNI
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANPeeringSpec.
+func (in *LANPeeringSpec) DeepCopy() *LANPeeringSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LANPeeringSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANPeeringStatus) DeepCopyInto(out *LANPeeringStatus) {
+	*out = *in
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANPeeringStatus.
+func (in *LANPeeringStatus) DeepCopy() *LANPeeringStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LANPeeringStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANProbe) DeepCopyInto(out *LANProbe) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANProbe.
+func (in *LANProbe) DeepCopy() *LANProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(LANProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LANProbe) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANProbeList) DeepCopyInto(out *LANProbeList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LANProbe, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANProbeList.
+func (in *LANProbeList) DeepCopy() *LANProbeList {
+	if in == nil {
+		return nil
+	}
+	out := new(LANProbeList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LANProbeList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANProbeSpec) DeepCopyInto(out *LANProbeSpec) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Count != nil {
+		in, out := &in.Count, &out.Count
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANProbeSpec.
+func (in *LANProbeSpec) DeepCopy() *LANProbeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LANProbeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANProbeStatus) DeepCopyInto(out *LANProbeStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeProbeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANProbeStatus.
+func (in *LANProbeStatus) DeepCopy() *LANProbeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LANProbeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANSpec) DeepCopyInto(out *LANSpec) {
+	*out = *in
+	if in.NS != nil {
+		in, out := &in.NS, &out.NS
+		*out = new(string)
+		**out = **in
+	}
+	if in.BridgeName != nil {
+		in, out := &in.BridgeName, &out.BridgeName
+		*out = new(string)
+		**out = **in
+	}
+	if in.VxLANName != nil {
+		in, out := &in.VxLANName, &out.VxLANName
+		*out = new(string)
+		**out = **in
+	}
+	if in.VNI != nil {
+		in, out := &in.VNI, &out.VNI
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VxLANGrp != nil {
+		in, out := &in.VxLANGrp, &out.VxLANGrp
+		*out = new(string)
+		**out = **in
+	}
+	if in.VxDevMap != nil {
+		in, out := &in.VxDevMap, &out.VxDevMap
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.VxPort != nil {
+		in, out := &in.VxPort, &out.VxPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TunnelEndpoints != nil {
+		in, out := &in.TunnelEndpoints, &out.TunnelEndpoints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SpokeList != nil {
+		in, out := &in.SpokeList, &out.SpokeList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Impairment != nil {
+		in, out := &in.Impairment, &out.Impairment
+		*out = new(Impairment)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SpokeImpairments != nil {
+		in, out := &in.SpokeImpairments, &out.SpokeImpairments
+		*out = make(map[string]Impairment, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.SpokeAdminStates != nil {
+		in, out := &in.SpokeAdminStates, &out.SpokeAdminStates
+		*out = make(map[string]AdminState, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Mirrors != nil {
+		in, out := &in.Mirrors, &out.Mirrors
+		*out = make([]Mirror, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(Encryption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RemoteVTEPs != nil {
+		in, out := &in.RemoteVTEPs, &out.RemoteVTEPs
+		*out = make([]RemoteVTEP, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANSpec.
+func (in *LANSpec) DeepCopy() *LANSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LANSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LANStatus) DeepCopyInto(out *LANStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = make([]NodeEncryptionStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RemoteVTEPs != nil {
+		in, out := &in.RemoteVTEPs, &out.RemoteVTEPs
+		*out = make([]NodeRemoteVTEPStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LinkStates != nil {
+		in, out := &in.LinkStates, &out.LinkStates
+		*out = make([]NodeLinkStates, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANStatus.
+func (in *LANStatus) DeepCopy() *LANStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LANStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Lab) DeepCopyInto(out *Lab) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Lab.
+func (in *Lab) DeepCopy() *Lab {
+	if in == nil {
+		return nil
+	}
+	out := new(Lab)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Lab) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabEndpoint) DeepCopyInto(out *LabEndpoint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabEndpoint.
+func (in *LabEndpoint) DeepCopy() *LabEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(LabEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabLink) DeepCopyInto(out *LabLink) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]LabEndpoint, len(*in))
+		copy(*out, *in)
+	}
+	if in.VNI != nil {
+		in, out := &in.VNI, &out.VNI
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabLink.
+func (in *LabLink) DeepCopy() *LabLink {
+	if in == nil {
+		return nil
+	}
+	out := new(LabLink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabList) DeepCopyInto(out *LabList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Lab, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabList.
+func (in *LabList) DeepCopy() *LabList {
+	if in == nil {
+		return nil
+	}
+	out := new(LabList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LabList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabNode) DeepCopyInto(out *LabNode) {
+	*out = *in
+	if in.Pod != nil {
+		in, out := &in.Pod, &out.Pod
+		*out = new(corev1.PodTemplateSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VM != nil {
+		in, out := &in.VM, &out.VM
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabNode.
+func (in *LabNode) DeepCopy() *LabNode {
+	if in == nil {
+		return nil
+	}
+	out := new(LabNode)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabNodeStatus) DeepCopyInto(out *LabNodeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabNodeStatus.
+func (in *LabNodeStatus) DeepCopy() *LabNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LabNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabSpec) DeepCopyInto(out *LabSpec) {
+	*out = *in
+	in.LANTemplate.DeepCopyInto(&out.LANTemplate)
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]LabNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Links != nil {
+		in, out := &in.Links, &out.Links
+		*out = make([]LabLink, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabSpec.
+func (in *LabSpec) DeepCopy() *LabSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LabSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabStatus) DeepCopyInto(out *LabStatus) {
+	*out = *in
+	if in.LANs != nil {
+		in, out := &in.LANs, &out.LANs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]LabNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabStatus.
+func (in *LabStatus) DeepCopy() *LabStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LabStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Mirror) DeepCopyInto(out *Mirror) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Mirror.
+func (in *Mirror) DeepCopy() *Mirror {
+	if in == nil {
+		return nil
+	}
+	out := new(Mirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeCaptureStatus) DeepCopyInto(out *NodeCaptureStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeCaptureStatus.
+func (in *NodeCaptureStatus) DeepCopy() *NodeCaptureStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeCaptureStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeEncryptionStatus) DeepCopyInto(out *NodeEncryptionStatus) {
+	*out = *in
+	if in.EncryptedPeers != nil {
+		in, out := &in.EncryptedPeers, &out.EncryptedPeers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastKeyRotation != nil {
+		in, out := &in.LastKeyRotation, &out.LastKeyRotation
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeEncryptionStatus.
+func (in *NodeEncryptionStatus) DeepCopy() *NodeEncryptionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeEncryptionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeFaultScheduleStatus) DeepCopyInto(out *NodeFaultScheduleStatus) {
+	*out = *in
+	if in.Down != nil {
+		in, out := &in.Down, &out.Down
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeFaultScheduleStatus.
+func (in *NodeFaultScheduleStatus) DeepCopy() *NodeFaultScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeFaultScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeLinkStates) DeepCopyInto(out *NodeLinkStates) {
+	*out = *in
+	if in.Spokes != nil {
+		in, out := &in.Spokes, &out.Spokes
+		*out = make([]SpokeLinkState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeLinkStates.
+func (in *NodeLinkStates) DeepCopy() *NodeLinkStates {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeLinkStates)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeProbeStatus) DeepCopyInto(out *NodeProbeStatus) {
+	*out = *in
+	if in.Peers != nil {
+		in, out := &in.Peers, &out.Peers
+		*out = make([]PeerProbeResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastProbeTime != nil {
+		in, out := &in.LastProbeTime, &out.LastProbeTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeProbeStatus.
+func (in *NodeProbeStatus) DeepCopy() *NodeProbeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeProbeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeRemoteVTEPStatus) DeepCopyInto(out *NodeRemoteVTEPStatus) {
+	*out = *in
+	if in.VTEPs != nil {
+		in, out := &in.VTEPs, &out.VTEPs
+		*out = make([]RemoteVTEPStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeRemoteVTEPStatus.
+func (in *NodeRemoteVTEPStatus) DeepCopy() *NodeRemoteVTEPStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeRemoteVTEPStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeSpokeBindingStatus) DeepCopyInto(out *NodeSpokeBindingStatus) {
+	*out = *in
+	if in.LastMoveTime != nil {
+		in, out := &in.LastMoveTime, &out.LastMoveTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeSpokeBindingStatus.
+func (in *NodeSpokeBindingStatus) DeepCopy() *NodeSpokeBindingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeSpokeBindingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PacketCapture) DeepCopyInto(out *PacketCapture) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PacketCapture.
+func (in *PacketCapture) DeepCopy() *PacketCapture {
 	if in == nil {
 		return nil
 	}
-	out := new(LAN)
+	out := new(PacketCapture)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *LAN) DeepCopyObject() runtime.Object {
+func (in *PacketCapture) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -53,31 +1133,31 @@ func (in *LAN) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LANList) DeepCopyInto(out *LANList) {
+func (in *PacketCaptureList) DeepCopyInto(out *PacketCaptureList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]LAN, len(*in))
+		*out = make([]PacketCapture, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANList.
-func (in *LANList) DeepCopy() *LANList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PacketCaptureList.
+func (in *PacketCaptureList) DeepCopy() *PacketCaptureList {
 	if in == nil {
 		return nil
 	}
-	out := new(LANList)
+	out := new(PacketCaptureList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *LANList) DeepCopyObject() runtime.Object {
+func (in *PacketCaptureList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -85,80 +1165,240 @@ func (in *LANList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LANSpec) DeepCopyInto(out *LANSpec) {
+func (in *PacketCaptureSpec) DeepCopyInto(out *PacketCaptureSpec) {
 	*out = *in
-	if in.NS != nil {
-		in, out := &in.NS, &out.NS
-		*out = new(string)
-		**out = **in
-	}
-	if in.BridgeName != nil {
-		in, out := &in.BridgeName, &out.BridgeName
-		*out = new(string)
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
 		**out = **in
 	}
-	if in.VxLANName != nil {
-		in, out := &in.VxLANName, &out.VxLANName
-		*out = new(string)
+	if in.PacketLimit != nil {
+		in, out := &in.PacketLimit, &out.PacketLimit
+		*out = new(int64)
 		**out = **in
 	}
-	if in.VNI != nil {
-		in, out := &in.VNI, &out.VNI
+	if in.SnapLen != nil {
+		in, out := &in.SnapLen, &out.SnapLen
 		*out = new(int32)
 		**out = **in
 	}
-	if in.VxLANGrp != nil {
-		in, out := &in.VxLANGrp, &out.VxLANGrp
-		*out = new(string)
+	if in.MaxFileSize != nil {
+		in, out := &in.MaxFileSize, &out.MaxFileSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PacketCaptureSpec.
+func (in *PacketCaptureSpec) DeepCopy() *PacketCaptureSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PacketCaptureSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PacketCaptureStatus) DeepCopyInto(out *PacketCaptureStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeCaptureStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PacketCaptureStatus.
+func (in *PacketCaptureStatus) DeepCopy() *PacketCaptureStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PacketCaptureStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerProbeResult) DeepCopyInto(out *PeerProbeResult) {
+	*out = *in
+	if in.RTT != nil {
+		in, out := &in.RTT, &out.RTT
+		*out = new(v1.Duration)
 		**out = **in
 	}
-	if in.VxDevMap != nil {
-		in, out := &in.VxDevMap, &out.VxDevMap
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerProbeResult.
+func (in *PeerProbeResult) DeepCopy() *PeerProbeResult {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerProbeResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteVTEP) DeepCopyInto(out *RemoteVTEP) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
 		*out = make(map[string]string, len(*in))
 		for key, val := range *in {
 			(*out)[key] = val
 		}
 	}
-	if in.VxPort != nil {
-		in, out := &in.VxPort, &out.VxPort
-		*out = new(int32)
-		**out = **in
-	}
-	if in.SpokeList != nil {
-		in, out := &in.SpokeList, &out.SpokeList
+	if in.MACs != nil {
+		in, out := &in.MACs, &out.MACs
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANSpec.
-func (in *LANSpec) DeepCopy() *LANSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteVTEP.
+func (in *RemoteVTEP) DeepCopy() *RemoteVTEP {
 	if in == nil {
 		return nil
 	}
-	out := new(LANSpec)
+	out := new(RemoteVTEP)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LANStatus) DeepCopyInto(out *LANStatus) {
+func (in *RemoteVTEPStatus) DeepCopyInto(out *RemoteVTEPStatus) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteVTEPStatus.
+func (in *RemoteVTEPStatus) DeepCopy() *RemoteVTEPStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteVTEPStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpokeBinding) DeepCopyInto(out *SpokeBinding) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpokeBinding.
+func (in *SpokeBinding) DeepCopy() *SpokeBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(SpokeBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SpokeBinding) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpokeBindingList) DeepCopyInto(out *SpokeBindingList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SpokeBinding, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LANStatus.
-func (in *LANStatus) DeepCopy() *LANStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpokeBindingList.
+func (in *SpokeBindingList) DeepCopy() *SpokeBindingList {
 	if in == nil {
 		return nil
 	}
-	out := new(LANStatus)
+	out := new(SpokeBindingList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SpokeBindingList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpokeBindingSpec) DeepCopyInto(out *SpokeBindingSpec) {
+	*out = *in
+	if in.CarrierFlap != nil {
+		in, out := &in.CarrierFlap, &out.CarrierFlap
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpokeBindingSpec.
+func (in *SpokeBindingSpec) DeepCopy() *SpokeBindingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SpokeBindingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpokeBindingStatus) DeepCopyInto(out *SpokeBindingStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeSpokeBindingStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpokeBindingStatus.
+func (in *SpokeBindingStatus) DeepCopy() *SpokeBindingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SpokeBindingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpokeLinkState) DeepCopyInto(out *SpokeLinkState) {
+	*out = *in
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpokeLinkState.
+func (in *SpokeLinkState) DeepCopy() *SpokeLinkState {
+	if in == nil {
+		return nil
+	}
+	out := new(SpokeLinkState)
 	in.DeepCopyInto(out)
 	return out
 }
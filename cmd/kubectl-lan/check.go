@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	lanv1beta1 "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// suffix of the name of the LANProbe created by check
+	checkProbeSuffix = "-check"
+	// interval of polling the LANProbe status
+	checkPollInterval = time.Second
+)
+
+var (
+	checkToken        string
+	checkProbeTimeout time.Duration
+	checkKeepProbe    bool
+)
+
+var checkCmd = &command{
+	name:  "check",
+	usage: "Probe connectivity between the nodes of the LAN, and check its dataplane on every node where its spokes are used",
+	addFlags: func(fs *flag.FlagSet) {
+		fs.StringVar(&checkToken, "token", "", "Bearer token bound to the lan-inspector ClusterRole to access the LAN daemonset, e.g. kubectl create token <serviceaccount>.")
+		fs.DurationVar(&checkProbeTimeout, "probe-timeout", 20*time.Second, "How long to wait for the probe result.")
+		fs.BoolVar(&checkKeepProbe, "keep-probe", false, "Keep the LANProbe created by check, so the next check reuses it.")
+	},
+	run: runCheck,
+}
+
+// checker collects check results
+type checker struct {
+	failures int
+}
+
+func (c *checker) ok(format string, args ...any) {
+	fmt.Printf("[OK]   "+format+"\n", args...)
+}
+
+func (c *checker) warn(format string, args ...any) {
+	fmt.Printf("[WARN] "+format+"\n", args...)
+}
+
+func (c *checker) fail(format string, args ...any) {
+	c.failures++
+	fmt.Printf("[FAIL] "+format+"\n", args...)
+}
+
+func runCheck(ctx context.Context, env *cmdEnv, args []string) error {
+	name, err := getLANName(args)
+	if err != nil {
+		return err
+	}
+	info, err := loadLAN(ctx, env.client, env.namespace, name)
+	if err != nil {
+		return err
+	}
+	c := &checker{}
+	if err := checkProbe(ctx, env, info, c); err != nil {
+		c.fail("%v", err)
+	}
+	fmt.Println()
+	ds, err := newDSClient(ctx, env, checkToken)
+	if err != nil {
+		return err
+	}
+	spec := &info.LAN.Spec
+	//key is node, value is spokes used on the node
+	usedSpokes := map[string][]string{}
+	for _, spoke := range spec.SpokeList {
+		users := info.Users[spoke]
+		switch len(users) {
+		case 0:
+			c.warn("spoke %v is not used by any pod", spoke)
+		case 1:
+			usedSpokes[users[0].Node] = append(usedSpokes[users[0].Node], spoke)
+		default:
+			c.fail("spoke %v is used by %d pods, it could only be used by one", spoke, len(users))
+			for _, u := range users {
+				usedSpokes[u.Node] = append(usedSpokes[u.Node], spoke)
+			}
+		}
+	}
+	vxMTU := map[int][]string{}
+	remoteMACs := map[string]int{}
+	for _, node := range info.Nodes {
+		state := &dataplane.LANState{}
+		path := dataplane.GetURLPath(info.LAN.Namespace, info.LAN.Name, dataplane.StatePath)
+		if err := ds.get(ctx, node, path, state); err != nil {
+			c.fail("%v", err)
+			continue
+		}
+		if !state.NSExists {
+			if len(usedSpokes[node]) > 0 {
+				c.fail("node %v: LAN namespace %v doesn't exist", node, *spec.NS)
+			}
+			continue
+		}
+		//both spokes of a p2p LAN on the same node are joined by a single veth pair, without bridge side veths
+		joined := spec.IsP2P() && len(usedSpokes[node]) == 2 && !slices.ContainsFunc(state.Links, func(l dataplane.LinkState) bool {
+			return l.Role == dataplane.RolePeer && l.Exists
+		})
+		if joined {
+			c.ok("node %v: spokes %v are joined by a veth pair", node, strings.Join(usedSpokes[node], ", "))
+		}
+		for _, link := range state.Links {
+			if link.Role == dataplane.RolePeer && (joined || !slices.Contains(usedSpokes[node], link.Spoke)) {
+				//spoke is not used on this node, or joined
+				continue
+			}
+			switch {
+			case !link.Exists:
+				c.fail("node %v: %v interface %v doesn't exist", node, link.Role, link.Name)
+			case !link.Up:
+				c.fail("node %v: %v interface %v is down", node, link.Role, link.Name)
+			default:
+				c.ok("node %v: %v interface %v is up", node, link.Role, link.Name)
+			}
+			if link.Role == dataplane.RoleVxLAN && link.Exists {
+				vxMTU[link.MTU] = append(vxMTU[link.MTU], node)
+			}
+		}
+		steps := []string{}
+		if err := ds.get(ctx, node, dataplane.GetURLPath(info.LAN.Namespace, info.LAN.Name, dataplane.PlanPath), &steps); err != nil {
+			c.fail("%v", err)
+		} else {
+			for _, step := range steps {
+				c.warn("node %v: drift to be repaired: %v", node, step)
+			}
+		}
+		if spec.IsP2P() {
+			continue
+		}
+		entries := []dataplane.FDBEntry{}
+		if err := ds.get(ctx, node, dataplane.GetURLPath(info.LAN.Namespace, info.LAN.Name, dataplane.FDBPath), &entries); err != nil {
+			c.fail("%v", err)
+			continue
+		}
+		for _, e := range entries {
+			if e.Learned && e.Port == *spec.VxLANName {
+				remoteMACs[node]++
+			}
+		}
+	}
+	if len(vxMTU) > 1 {
+		c.warn("vxlan interfaces have different MTU on nodes: %v", vxMTU)
+	}
+	//traffic across workers is expected if spokes are used on multiple nodes
+	if !spec.IsP2P() && len(usedSpokes) > 1 {
+		for node := range usedSpokes {
+			if remoteMACs[node] == 0 {
+				c.warn("node %v: no MAC learned via vxlan, traffic may not pass across workers", node)
+			} else {
+				c.ok("node %v: %d MACs learned via vxlan", node, remoteMACs[node])
+			}
+		}
+	}
+	if c.failures > 0 {
+		return fmt.Errorf("%d checks failed", c.failures)
+	}
+	return nil
+}
+
+// checkProbe runs a connectivity probe between the nodes of the LAN with a LANProbe, and prints the reachability matrix;
+// an existing LANProbe of the LAN is reused, otherwise one is created and removed afterwards unless checkKeepProbe
+func checkProbe(ctx context.Context, env *cmdEnv, info *lanInfo, c *checker) error {
+	lp, created, err := getCheckProbe(ctx, env, info.LAN)
+	if err != nil {
+		return err
+	}
+	if created && !checkKeepProbe {
+		defer func() {
+			//ctx might be done already
+			delCtx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+			defer cancel()
+			if err := env.client.Delete(delCtx, lp); client.IgnoreNotFound(err) != nil {
+				fmt.Fprintf(os.Stderr, "failed to remove LANProbe %v, %v\n", lp.Name, err)
+			}
+		}()
+	}
+	//results of a reused LANProbe are fresh if they are from its last round
+	since := lp.CreationTimestamp.Time
+	if !created {
+		since = time.Now().Add(-lp.Spec.GetInterval())
+	}
+	fmt.Printf("probing with LANProbe %v...\n", lp.Name)
+	status, err := waitProbeResult(ctx, env, client.ObjectKeyFromObject(lp), info.Nodes, since)
+	if err != nil {
+		return err
+	}
+	printReachability(status, c)
+	return nil
+}
+
+// getCheckProbe returns a LANProbe of lan, created is true if it is created by this call
+func getCheckProbe(ctx context.Context, env *cmdEnv, lan *lanv1beta1.LAN) (lp *lanv1beta1.LANProbe, created bool, err error) {
+	list := &lanv1beta1.LANProbeList{}
+	if err := env.client.List(ctx, list, client.InNamespace(lan.Namespace)); err != nil {
+		return nil, false, fmt.Errorf("failed to list LANProbes, %w", err)
+	}
+	for i := range list.Items {
+		if list.Items[i].Spec.LAN == lan.Name && list.Items[i].DeletionTimestamp.IsZero() {
+			return &list.Items[i], false, nil
+		}
+	}
+	lp = &lanv1beta1.LANProbe{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: lan.Namespace,
+			Name:      lan.Name + checkProbeSuffix,
+		},
+		Spec: lanv1beta1.LANProbeSpec{LAN: lan.Name},
+	}
+	if err := env.client.Create(ctx, lp); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil, false, fmt.Errorf("LANProbe %v exists but doesn't probe LAN %v", lp.Name, lan.Name)
+		}
+		return nil, false, fmt.Errorf("failed to create LANProbe %v, %w", lp.Name, err)
+	}
+	return lp, true, nil
+}
+
+// waitProbeResult waits until every node of nodes reports a probe round after since,
+// the last status is returned if some nodes don't report before checkProbeTimeout
+func waitProbeResult(ctx context.Context, env *cmdEnv, key client.ObjectKey, nodes []string, since time.Time) (*lanv1beta1.LANProbeStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkProbeTimeout)
+	defer cancel()
+	lp := &lanv1beta1.LANProbe{}
+	for {
+		if err := env.client.Get(ctx, key, lp); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, fmt.Errorf("failed to get LANProbe %v, %w", key.Name, err)
+		}
+		pending := slices.DeleteFunc(slices.Clone(nodes), func(node string) bool {
+			ns := lp.Status.GetNodeStatus(node)
+			return ns != nil && ns.LastProbeTime != nil && !ns.LastProbeTime.Time.Before(since.Truncate(time.Second))
+		})
+		if len(pending) == 0 {
+			return &lp.Status, nil
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Printf("[WARN] no probe result from nodes %v\n", strings.Join(pending, ","))
+			return &lp.Status, nil
+		case <-time.After(checkPollInterval):
+		}
+	}
+	return &lp.Status, nil
+}
+
+// printReachability prints the reachability matrix of status, a row is the result of a node probing the other nodes
+func printReachability(status *lanv1beta1.LANProbeStatus, c *checker) {
+	nodes := []string{}
+	for _, n := range status.Nodes {
+		nodes = append(nodes, n.Node)
+	}
+	slices.Sort(nodes)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "FROM\\TO\t%v\n", strings.Join(nodes, "\t"))
+	for _, from := range nodes {
+		ns := status.GetNodeStatus(from)
+		row := []string{from}
+		for _, to := range nodes {
+			row = append(row, formatProbeResult(ns, to))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	for _, from := range nodes {
+		ns := status.GetNodeStatus(from)
+		if ns.Message != "" {
+			c.fail("node %v: probe failed, %v", from, ns.Message)
+		}
+		for _, p := range ns.Peers {
+			if !p.Reachable {
+				c.fail("node %v can't reach node %v, %d/%d replied", from, p.Node, p.Received, p.Sent)
+			}
+		}
+	}
+	if status.Unreachable == 0 && len(nodes) > 1 {
+		c.ok("all %d nodes reach each other", len(nodes))
+	}
+}
+
+// formatProbeResult returns a cell of the reachability matrix
+func formatProbeResult(ns *lanv1beta1.NodeProbeStatus, to string) string {
+	if ns.Node == to {
+		return "-"
+	}
+	for _, p := range ns.Peers {
+		if p.Node != to {
+			continue
+		}
+		if !p.Reachable {
+			return fmt.Sprintf("FAIL(%d/%d)", p.Received, p.Sent)
+		}
+		if p.RTT != nil {
+			return fmt.Sprintf("ok(%v)", p.RTT.Duration.Round(10*time.Microsecond))
+		}
+		return "ok"
+	}
+	return "?"
+}
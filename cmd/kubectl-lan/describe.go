@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	lanv1beta1 "github.com/hujun-open/k8slan/api/v1beta1"
+)
+
+var describeCmd = &command{
+	name:  "describe",
+	usage: "Show spokes of the LAN, the pod or VM uses each spoke, the node and the NADs",
+	run:   runDescribe,
+}
+
+func runDescribe(ctx context.Context, env *cmdEnv, args []string) error {
+	name, err := getLANName(args)
+	if err != nil {
+		return err
+	}
+	info, err := loadLAN(ctx, env.client, env.namespace, name)
+	if err != nil {
+		return err
+	}
+	spec := &info.LAN.Spec
+	fmt.Printf("Name:       %v\n", info.LAN.Name)
+	fmt.Printf("Namespace:  %v\n", info.LAN.Namespace)
+	mode := spec.Mode
+	if mode == "" {
+		mode = "bridge"
+	}
+	fmt.Printf("Mode:       %v\n", mode)
+	fmt.Printf("LAN NS:     %v\n", *spec.NS)
+	if !spec.IsP2P() {
+		fmt.Printf("Bridge:     %v\n", *spec.BridgeName)
+	}
+	switch encap := spec.GetEncapsulation(); encap {
+	case lanv1beta1.EncapVxLAN:
+		fmt.Printf("VxLAN:      %v (vni %d, group %v, port %d)\n", *spec.VxLANName, *spec.VNI, *spec.VxLANGrp, *spec.VxPort)
+	case lanv1beta1.EncapGeneve:
+		fmt.Printf("Geneve:     %v (vni %d, endpoints %v, port %d)\n", *spec.VxLANName, *spec.VNI, spec.TunnelEndpoints, *spec.VxPort)
+	case lanv1beta1.EncapGRETap:
+		fmt.Printf("GRETap:     %v (key %d, group %v)\n", *spec.VxLANName, *spec.VNI, *spec.VxLANGrp)
+	default:
+		fmt.Printf("IP6GRETap:  %v (key %d, endpoints %v)\n", *spec.VxLANName, *spec.VNI, spec.TunnelEndpoints)
+	}
+	fmt.Printf("Nodes:      %v\n", strings.Join(info.Nodes, ", "))
+	if len(spec.RemoteVTEPs) > 0 {
+		fmt.Printf("\nRemote VTEPs:\n")
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  VTEP\tNODE\tREACHABLE\tMESSAGE")
+		for _, v := range spec.RemoteVTEPs {
+			fmt.Fprintf(w, "  %v\t\t\t\n", v.Address)
+			for _, nodeStatus := range info.LAN.Status.RemoteVTEPs {
+				for _, vs := range nodeStatus.VTEPs {
+					if vs.Address == v.Address {
+						fmt.Fprintf(w, "  \t%v\t%v\t%v\n", nodeStatus.Node, vs.Reachable, vs.Message)
+					}
+				}
+			}
+		}
+		w.Flush()
+	}
+	fmt.Printf("\nSpokes:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  SPOKE\tPOD\tVM\tNODE\tNAD")
+	for _, spoke := range spec.SpokeList {
+		users := info.Users[spoke]
+		if len(users) == 0 {
+			fmt.Fprintf(w, "  %v\t<none>\t\t\t\n", spoke)
+			continue
+		}
+		for _, u := range users {
+			fmt.Fprintf(w, "  %v\t%v\t%v\t%v\t%v\n", spoke, u.Pod, u.VM, u.Node, u.NAD)
+		}
+	}
+	w.Flush()
+	fmt.Printf("\nNetworkAttachmentDefinitions:\n")
+	if len(info.NADs) == 0 {
+		fmt.Println("  <none>")
+	}
+	for _, nad := range info.NADs {
+		fmt.Printf("  %v/%v\n", nad.Namespace, nad.Name)
+	}
+	return nil
+}
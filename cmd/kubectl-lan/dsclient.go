@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// labels of the LAN daemonset pods
+var dsPodLabels = client.MatchingLabels{
+	"control-plane":          "ds",
+	"app.kubernetes.io/name": "k8slan",
+}
+
+const (
+	// name of the container port of the daemonset metrics server
+	dsPortName = "https"
+)
+
+// dsClient gets dataplane state from the LAN daemonset on each worker,
+// via the pods/proxy subresource of the API server so that the daemonset is never reached directly
+type dsClient struct {
+	rest  rest.Interface
+	token string
+	// key is node name, value is the pods/proxy name of the daemonset pod, i.e. https:<pod>:<port>
+	pods map[string]client.ObjectKey
+}
+
+// newDSClient finds the LAN daemonset pods, token is used to authenticate to them;
+// the token of the kubeconfig is never sent to the daemonset
+func newDSClient(ctx context.Context, env *cmdEnv, token string) (*dsClient, error) {
+	if token == "" {
+		return nil, fmt.Errorf("a bearer token bound to the lan-inspector ClusterRole is required, use --token to specify it")
+	}
+	clientset, err := kubernetes.NewForConfig(env.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client, %w", err)
+	}
+	podList := &corev1.PodList{}
+	if err := env.client.List(ctx, podList, dsPodLabels); err != nil {
+		return nil, fmt.Errorf("failed to list LAN daemonset pods, %w", err)
+	}
+	r := &dsClient{
+		rest:  clientset.CoreV1().RESTClient(),
+		token: token,
+		pods:  map[string]client.ObjectKey{},
+	}
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			for _, port := range c.Ports {
+				if port.Name == dsPortName {
+					r.pods[pod.Spec.NodeName] = client.ObjectKey{
+						Namespace: pod.Namespace,
+						Name:      "https:" + pod.Name + ":" + strconv.Itoa(int(port.ContainerPort)),
+					}
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// get gets path from the daemonset on node and decodes the json result into v
+func (c *dsClient) get(ctx context.Context, node, path string, v any) error {
+	pod, ok := c.pods[node]
+	if !ok {
+		return fmt.Errorf("LAN daemonset not found on node %v", node)
+	}
+	buf, err := c.rest.Get().
+		Namespace(pod.Namespace).
+		Resource("pods").
+		Name(pod.Name).
+		SubResource("proxy").
+		Suffix(path).
+		SetHeader(dataplane.TokenHeader, c.token).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return fmt.Errorf("failed to query LAN daemonset on node %v, %w", node, err)
+	}
+	return json.Unmarshal(buf, v)
+}
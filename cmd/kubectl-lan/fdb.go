@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+)
+
+var (
+	fdbNode  string
+	fdbToken string
+)
+
+var fdbCmd = &command{
+	name:  "fdb",
+	usage: "Dump the bridge FDB of the LAN on a node",
+	addFlags: func(fs *flag.FlagSet) {
+		fs.StringVar(&fdbNode, "node", "", "The node to dump FDB, all nodes of the LAN if not specified.")
+		fs.StringVar(&fdbToken, "token", "", "Bearer token bound to the lan-inspector ClusterRole to access the LAN daemonset, e.g. kubectl create token <serviceaccount>.")
+	},
+	run: runFDB,
+}
+
+func runFDB(ctx context.Context, env *cmdEnv, args []string) error {
+	name, err := getLANName(args)
+	if err != nil {
+		return err
+	}
+	info, err := loadLAN(ctx, env.client, env.namespace, name)
+	if err != nil {
+		return err
+	}
+	if info.LAN.Spec.IsP2P() {
+		return fmt.Errorf("LAN %v is in p2p mode, there is no bridge", name)
+	}
+	nodes := info.Nodes
+	if fdbNode != "" {
+		nodes = []string{fdbNode}
+	}
+	ds, err := newDSClient(ctx, env, fdbToken)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tMAC\tPORT\tSPOKE\tVLAN\tLEARNED")
+	for _, node := range nodes {
+		entries := []dataplane.FDBEntry{}
+		err := ds.get(ctx, node, dataplane.GetURLPath(info.LAN.Namespace, info.LAN.Name, dataplane.FDBPath), &entries)
+		if err != nil {
+			w.Flush()
+			return err
+		}
+		for _, e := range entries {
+			vlan := ""
+			if e.VLAN != 0 {
+				vlan = fmt.Sprint(e.VLAN)
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", node, e.MAC, e.Port, e.Spoke, vlan, e.Learned)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	lanv1beta1 "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/clab"
+)
+
+var (
+	importVNIBase  int
+	importVxLANGrp string
+	importVxDev    string
+	importOutput   string
+)
+
+var importCmd = &command{
+	name:  "import",
+	usage: "Convert a containerlab topology file to LANs and pods/VMs, e.g. kubectl lan import clab <file> | kubectl apply -f -",
+	addFlags: func(fs *flag.FlagSet) {
+		fs.IntVar(&importVNIBase, "vni-base", 0, "The first VNI allocated to the LANs, derived from the namespace and topology name if 0.")
+		fs.StringVar(&importVxLANGrp, "vxlan-group", clab.DefaultVxLANGrp, "The vxlan multicast group of the LANs.")
+		fs.StringVar(&importVxDev, "vxlan-dev", clab.DefaultVxDev, "The default vxlan device of the LANs.")
+		fs.StringVar(&importOutput, "o", "", "The output file, stdout if not specified.")
+	},
+	run:   runImport,
+	local: true,
+}
+
+func runImport(ctx context.Context, env *cmdEnv, args []string) error {
+	if len(args) != 2 || args[0] != "clab" {
+		return fmt.Errorf("expect clab <file>")
+	}
+	data, err := os.ReadFile(args[1])
+	if err != nil {
+		return fmt.Errorf("failed to read %v, %w", args[1], err)
+	}
+	topo, err := clab.Parse(data)
+	if err != nil {
+		return err
+	}
+	used, err := getUsedVNIs(ctx, env, topo.Name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: VNIs are not checked against existing LANs, %v\n", err)
+	}
+	result, err := clab.Convert(topo, clab.Options{
+		Namespace:    env.namespace,
+		VNIBase:      int32(importVNIBase),
+		UsedVNIs:     used,
+		VxLANGrp:     importVxLANGrp,
+		DefaultVxDev: importVxDev,
+	})
+	if err != nil {
+		return err
+	}
+	for _, w := range result.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", w)
+	}
+	out := os.Stdout
+	if importOutput != "" {
+		if out, err = os.Create(importOutput); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	return result.Write(out)
+}
+
+// getUsedVNIs returns the VNIs of LANs and their mirrors in the cluster,
+// except the LANs of topology in env.namespace, which are replaced by the import
+func getUsedVNIs(ctx context.Context, env *cmdEnv, topology string) ([]int32, error) {
+	if env.client == nil {
+		return nil, fmt.Errorf("no kubeconfig")
+	}
+	lans := &lanv1beta1.LANList{}
+	if err := env.client.List(ctx, lans); err != nil {
+		return nil, fmt.Errorf("failed to list LANs, %w", err)
+	}
+	used := []int32{}
+	for _, lan := range lans.Items {
+		if lan.Namespace == env.namespace && lan.Labels[clab.LabelTopology] == topology {
+			continue
+		}
+		if lan.Spec.VNI != nil {
+			used = append(used, *lan.Spec.VNI)
+		}
+		for _, m := range lan.Spec.Mirrors {
+			used = append(used, m.VNI)
+		}
+	}
+	return used, nil
+}
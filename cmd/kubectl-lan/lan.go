@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	lanv1beta1 "github.com/hujun-open/k8slan/api/v1beta1"
+	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// pod annotation of multus listing attached networks
+	networksAnnotation = "k8s.v1.cni.cncf.io/networks"
+	// label of kubevirt virt-launcher pod, value is the VM name
+	vmNameLabel = "vm.kubevirt.io/name"
+)
+
+// spokeUser is a pod (or kubevirt VM) uses a spoke
+type spokeUser struct {
+	Pod  types.NamespacedName
+	VM   string
+	Node string
+	// NAD used to attach, either the veth one or the macvtap one
+	NAD string
+}
+
+// lanInfo is a LAN and related objects
+type lanInfo struct {
+	LAN *lanv1beta1.LAN
+	// key is spoke name, a spoke is normally used by at most one pod
+	Users map[string][]spokeUser
+	NADs  []ncv1.NetworkAttachmentDefinition
+	// all workers the LAN exists on
+	Nodes []string
+}
+
+// getPodNADs returns NADs in namespace referenced by pod, via multus annotation or resource requests
+func getPodNADs(pod *corev1.Pod, namespace string) []string {
+	r := []string{}
+	for _, network := range strings.Split(pod.Annotations[networksAnnotation], ",") {
+		//format is [<namespace>/]<name>[@<ifname>]
+		network = strings.TrimSpace(network)
+		nadNS := pod.Namespace
+		if i := strings.LastIndex(network, "/"); i >= 0 {
+			nadNS = network[:i]
+			network = network[i+1:]
+		}
+		network, _, _ = strings.Cut(network, "@")
+		if network != "" && nadNS == namespace {
+			r = append(r, network)
+		}
+	}
+	//device plugin resources are cluster wide
+	for _, c := range pod.Spec.Containers {
+		for res := range c.Resources.Limits {
+			if name, ok := strings.CutPrefix(string(res), lanv1beta1.ResourceNamespace+"/"); ok && !slices.Contains(r, name) {
+				r = append(r, name)
+			}
+		}
+	}
+	return r
+}
+
+// loadLAN loads the named LAN and related objects
+func loadLAN(ctx context.Context, c client.Client, namespace, name string) (*lanInfo, error) {
+	lan := &lanv1beta1.LAN{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, lan); err != nil {
+		return nil, fmt.Errorf("failed to get LAN %v/%v, %w", namespace, name, err)
+	}
+	info := &lanInfo{
+		LAN:   lan,
+		Users: map[string][]spokeUser{},
+		Nodes: lan.GetNodes(),
+	}
+	nadNames := map[string]bool{}
+	for _, nad := range lan.Spec.GetNADs(namespace) {
+		nadNames[nad.Name] = true
+		existing := &ncv1.NetworkAttachmentDefinition{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(nad), existing); err == nil {
+			info.NADs = append(info.NADs, *existing)
+		}
+	}
+	podList := &corev1.PodList{}
+	if err := c.List(ctx, podList); err != nil {
+		//could be forbidden to list pods in all namespaces
+		if err := c.List(ctx, podList, client.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("failed to list pods, %w", err)
+		}
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, nad := range getPodNADs(pod, namespace) {
+			if !nadNames[nad] {
+				continue
+			}
+			spoke := lanv1beta1.GetSpokeNameFromResourceName(nad)
+			info.Users[spoke] = append(info.Users[spoke], spokeUser{
+				Pod:  client.ObjectKeyFromObject(pod),
+				VM:   pod.Labels[vmNameLabel],
+				Node: pod.Spec.NodeName,
+				NAD:  nad,
+			})
+			if pod.Spec.NodeName != "" && !slices.Contains(info.Nodes, pod.Spec.NodeName) {
+				info.Nodes = append(info.Nodes, pod.Spec.NodeName)
+			}
+		}
+	}
+	slices.Sort(info.Nodes)
+	return info, nil
+}
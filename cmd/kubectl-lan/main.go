@@ -0,0 +1,163 @@
+// kubectl-lan is a kubectl plugin to inspect LANs, e.g. kubectl lan describe <lan>
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	lanv1beta1 "github.com/hujun-open/k8slan/api/v1beta1"
+	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(ncv1.AddToScheme(scheme))
+	utilruntime.Must(lanv1beta1.AddToScheme(scheme))
+}
+
+// command is a subcommand of the plugin
+type command struct {
+	name  string
+	usage string
+	// addFlags adds subcommand specific flags to fs
+	addFlags func(fs *flag.FlagSet)
+	// run runs the subcommand with positional args
+	run func(ctx context.Context, env *cmdEnv, args []string) error
+	// local is true if the subcommand doesn't require the cluster,
+	// env.client and env.config are nil unless a kubeconfig is loaded
+	local bool
+}
+
+// cmdEnv is the common environment of all subcommands
+type cmdEnv struct {
+	client    client.Client
+	config    *rest.Config
+	namespace string
+}
+
+var commands = []*command{
+	describeCmd,
+	topologyCmd,
+	fdbCmd,
+	checkCmd,
+	importCmd,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: kubectl lan <command> [flags] <lan>\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"kubectl lan <command> -h\" for flags of a command.\n")
+}
+
+// parseArgs parses args with fs, flags could be placed after positional args
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	var cmd *command
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		usage()
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("kubectl lan "+cmd.name, flag.ExitOnError)
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{}
+	fs.StringVar(&loadingRules.ExplicitPath, "kubeconfig", "", "Path to the kubeconfig file.")
+	fs.StringVar(&overrides.CurrentContext, "context", "", "The kubeconfig context to use.")
+	fs.StringVar(&overrides.Context.Namespace, "n", "", "The namespace of the LAN.")
+	fs.StringVar(&overrides.Context.Namespace, "namespace", "", "The namespace of the LAN.")
+	timeout := fs.Duration("timeout", defaultTimeout, "Timeout of the command.")
+	if cmd.addFlags != nil {
+		cmd.addFlags(fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: kubectl lan %v [flags] <lan>\n%v\n\nFlags:\n", cmd.name, cmd.usage)
+		fs.PrintDefaults()
+	}
+	args, err := parseArgs(fs, os.Args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	clientCfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+	env := &cmdEnv{}
+	if cmd.local {
+		// kubeconfig is optional for a local command
+		if env.namespace, _, err = clientCfg.Namespace(); err != nil {
+			env.namespace = "default"
+		}
+		if env.config, err = clientCfg.ClientConfig(); err == nil {
+			if env.client, err = client.New(env.config, client.Options{Scheme: scheme}); err != nil {
+				env.config = nil
+			}
+		}
+	} else {
+		if env.config, err = clientCfg.ClientConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to load kubeconfig, %v\n", err)
+			os.Exit(1)
+		}
+		if env.namespace, _, err = clientCfg.Namespace(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get namespace, %v\n", err)
+			os.Exit(1)
+		}
+		if env.client, err = client.New(env.config, client.Options{Scheme: scheme}); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create client, %v\n", err)
+			os.Exit(1)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := cmd.run(ctx, env, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		cancel()
+		os.Exit(1)
+	}
+}
+
+// getLANName returns the LAN name from positional args
+func getLANName(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expect exactly one LAN name, got %d args", len(args))
+	}
+	return args[0], nil
+}
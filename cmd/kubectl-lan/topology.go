@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	formatMermaid = "mermaid"
+	formatDot     = "dot"
+)
+
+var topologyFormat string
+
+var topologyCmd = &command{
+	name:  "topology",
+	usage: "Render the topology of the LAN in mermaid or graphviz dot format",
+	addFlags: func(fs *flag.FlagSet) {
+		fs.StringVar(&topologyFormat, "format", formatMermaid, "Output format, mermaid or dot.")
+	},
+	run: runTopology,
+}
+
+// topoVertex is a vertex in the topology graph
+type topoVertex struct {
+	ID    string
+	Label string
+}
+
+// topoWorker is the part of the topology on a worker
+type topoWorker struct {
+	topoVertex
+	// interfaces in the LAN NS
+	NS         topoVertex
+	Interfaces []topoVertex
+	// pods and VMs on the worker
+	Pods []topoVertex
+}
+
+// topology is the topology graph of a LAN
+type topology struct {
+	LAN     topoVertex
+	Workers []*topoWorker
+	// spokes not used by any pod
+	Unused []topoVertex
+	Edges  [][2]string
+}
+
+var nonIDChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func topoID(parts ...string) string {
+	return nonIDChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+// buildTopology builds the topology graph of the LAN
+func buildTopology(info *lanInfo) *topology {
+	spec := &info.LAN.Spec
+	topo := &topology{
+		LAN: topoVertex{
+			ID:    "lan",
+			Label: fmt.Sprintf("LAN %v/%v", info.LAN.Namespace, info.LAN.Name),
+		},
+	}
+	for _, node := range info.Nodes {
+		w := &topoWorker{
+			topoVertex: topoVertex{ID: topoID("worker", node), Label: node},
+			NS:         topoVertex{ID: topoID("ns", node), Label: "LAN NS " + *spec.NS},
+		}
+		vx := topoVertex{ID: topoID(node, "vxlan"), Label: spec.GetEncapsulation() + " " + *spec.VxLANName}
+		w.Interfaces = append(w.Interfaces, vx)
+		topo.Edges = append(topo.Edges, [2]string{vx.ID, topo.LAN.ID})
+		var br topoVertex
+		if !spec.IsP2P() {
+			br = topoVertex{ID: topoID(node, "bridge"), Label: "bridge " + *spec.BridgeName}
+			w.Interfaces = append(w.Interfaces, br)
+			topo.Edges = append(topo.Edges, [2]string{br.ID, vx.ID})
+		}
+		localPeers := []topoVertex{}
+		//a pod could use multiple spokes
+		pods := map[string]bool{}
+		for _, spoke := range spec.SpokeList {
+			for _, u := range info.Users[spoke] {
+				if u.Node != node {
+					continue
+				}
+				peer := topoVertex{ID: topoID(node, "peer", spoke), Label: "veth peer of " + spoke}
+				w.Interfaces = append(w.Interfaces, peer)
+				localPeers = append(localPeers, peer)
+				pod := topoVertex{ID: topoID(node, "pod", u.Pod.String()), Label: "pod " + u.Pod.String()}
+				if u.VM != "" {
+					pod.Label = fmt.Sprintf("VM %v/%v<br/>pod %v", u.Pod.Namespace, u.VM, u.Pod.Name)
+				}
+				if !pods[pod.ID] {
+					pods[pod.ID] = true
+					w.Pods = append(w.Pods, pod)
+				}
+				topo.Edges = append(topo.Edges, [2]string{pod.ID, peer.ID})
+				if !spec.IsP2P() {
+					topo.Edges = append(topo.Edges, [2]string{peer.ID, br.ID})
+				}
+			}
+		}
+		if spec.IsP2P() {
+			//peers are wired to each other if both are local, otherwise to the vxlan interface
+			if len(localPeers) == 2 {
+				topo.Edges = append(topo.Edges, [2]string{localPeers[0].ID, localPeers[1].ID})
+			} else {
+				for _, peer := range localPeers {
+					topo.Edges = append(topo.Edges, [2]string{peer.ID, vx.ID})
+				}
+			}
+		}
+		topo.Workers = append(topo.Workers, w)
+	}
+	for _, spoke := range spec.SpokeList {
+		if len(info.Users[spoke]) == 0 {
+			topo.Unused = append(topo.Unused, topoVertex{ID: topoID("unused", spoke), Label: "unused spoke " + spoke})
+		}
+	}
+	return topo
+}
+
+func (topo *topology) writeMermaid(w io.Writer) {
+	fmt.Fprintln(w, "flowchart TB")
+	fmt.Fprintf(w, "    %v((%q))\n", topo.LAN.ID, topo.LAN.Label)
+	for _, worker := range topo.Workers {
+		fmt.Fprintf(w, "    subgraph %v[%q]\n", worker.ID, worker.Label)
+		fmt.Fprintf(w, "        subgraph %v[%q]\n", worker.NS.ID, worker.NS.Label)
+		for _, v := range worker.Interfaces {
+			fmt.Fprintf(w, "            %v[%q]\n", v.ID, v.Label)
+		}
+		fmt.Fprintln(w, "        end")
+		for _, v := range worker.Pods {
+			fmt.Fprintf(w, "        %v[%q]\n", v.ID, v.Label)
+		}
+		fmt.Fprintln(w, "    end")
+	}
+	for _, v := range topo.Unused {
+		fmt.Fprintf(w, "    %v[%q]\n", v.ID, v.Label)
+	}
+	for _, e := range topo.Edges {
+		fmt.Fprintf(w, "    %v --- %v\n", e[0], e[1])
+	}
+}
+
+func (topo *topology) writeDot(w io.Writer) {
+	dotLabel := func(l string) string {
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(l, `"`, `\"`), "<br/>", `\n`) + `"`
+	}
+	fmt.Fprintln(w, "graph lan {")
+	fmt.Fprintln(w, "    compound=true;")
+	fmt.Fprintln(w, "    node [shape=box];")
+	fmt.Fprintf(w, "    %v [label=%v, shape=ellipse];\n", topo.LAN.ID, dotLabel(topo.LAN.Label))
+	for _, worker := range topo.Workers {
+		fmt.Fprintf(w, "    subgraph cluster_%v {\n", worker.ID)
+		fmt.Fprintf(w, "        label=%v;\n", dotLabel(worker.Label))
+		fmt.Fprintf(w, "        subgraph cluster_%v {\n", worker.NS.ID)
+		fmt.Fprintf(w, "            label=%v;\n", dotLabel(worker.NS.Label))
+		for _, v := range worker.Interfaces {
+			fmt.Fprintf(w, "            %v [label=%v];\n", v.ID, dotLabel(v.Label))
+		}
+		fmt.Fprintln(w, "        }")
+		for _, v := range worker.Pods {
+			fmt.Fprintf(w, "        %v [label=%v];\n", v.ID, dotLabel(v.Label))
+		}
+		fmt.Fprintln(w, "    }")
+	}
+	for _, v := range topo.Unused {
+		fmt.Fprintf(w, "    %v [label=%v, style=dashed];\n", v.ID, dotLabel(v.Label))
+	}
+	for _, e := range topo.Edges {
+		fmt.Fprintf(w, "    %v -- %v;\n", e[0], e[1])
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func runTopology(ctx context.Context, env *cmdEnv, args []string) error {
+	name, err := getLANName(args)
+	if err != nil {
+		return err
+	}
+	if topologyFormat != formatMermaid && topologyFormat != formatDot {
+		return fmt.Errorf("unknown format %v, must be %v or %v", topologyFormat, formatMermaid, formatDot)
+	}
+	info, err := loadLAN(ctx, env.client, env.namespace, name)
+	if err != nil {
+		return err
+	}
+	topo := buildTopology(info)
+	if topologyFormat == formatDot {
+		topo.writeDot(os.Stdout)
+	} else {
+		topo.writeMermaid(os.Stdout)
+	}
+	return nil
+}
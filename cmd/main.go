@@ -193,6 +193,20 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "LAN")
 		os.Exit(1)
 	}
+	if err := (&controller.LANPeeringReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "LANPeering")
+		os.Exit(1)
+	}
+	if err := (&controller.LabReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Lab")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
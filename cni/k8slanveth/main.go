@@ -28,12 +28,16 @@ import (
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/cni/pkg/version"
 	"github.com/containernetworking/plugins/pkg/ipam"
-	"github.com/containernetworking/plugins/pkg/ns"
 	bv "github.com/containernetworking/plugins/pkg/utils/buildversion"
 	"github.com/containernetworking/plugins/pkg/utils/sysctl"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
 	"github.com/vishvananda/netlink"
 )
 
+// nl is used for all link and NS operations, replaced by a fake in tests
+var nl interfaces.Netlinker = interfaces.NewKernelNetlinker()
+
 // PluginConf is whatever you expect your configuration json to be. This is whatever
 // is passed in on stdin. Your plugin may wish to expose its functionality via
 // runtime args, see CONVENTIONS.md in the CNI spec.
@@ -44,6 +48,13 @@ type PluginConf struct {
 	types.NetConf
 	VethName  string `json:"veth"`
 	EnableDad bool   `json:"enableDad"`
+	// LANNS is the LAN namespace the veth belongs to
+	LANNS string `json:"lanNS,omitempty"`
+	// NetNSDir is the dir where LAN namespaces are mounted, it must be the same as the --netns-dir of the LAN DS;
+	// default is env K8SLAN_NETNS_DIR, or /run/k8slan/netns
+	NetNSDir string `json:"netnsDir,omitempty"`
+	// P2PPeer is the other spoke of a p2p LAN, the veth may be joined with it by a single veth pair
+	P2PPeer string `json:"p2pPeer,omitempty"`
 }
 
 // MacEnvArgs represents CNI_ARGS
@@ -72,7 +83,9 @@ func parseConfig(stdin []byte, envArgs string) (*PluginConf, error) {
 		return nil, fmt.Errorf("could not parse prevResult: %v", err)
 	}
 	// End previous result parsing
-
+	if conf.NetNSDir != "" {
+		interfaces.SetNSRunDir(conf.NetNSDir)
+	}
 	return &conf, nil
 }
 
@@ -83,27 +96,29 @@ func cmdAdd(args *skel.CmdArgs) error {
 	if err != nil {
 		return err
 	}
-	podNS, err := ns.GetNS(args.Netns)
-	if err != nil {
-		return fmt.Errorf("failed to open pod netns %q: %v", args.Netns, err)
+	if !nl.NSExists(args.Netns) {
+		return fmt.Errorf("failed to open pod netns %q: not found", args.Netns)
 	}
 	//locate the veth
-	vlink, err := netlink.LinkByName(conf.VethName)
+	vlink, err := nl.LinkByName(conf.VethName)
 	if err != nil {
+		if conf.LANNS != "" && !nl.NSExists(interfaces.GetNSPath(conf.LANNS)) {
+			return fmt.Errorf("failed to locate veth interface %v, LAN namespace %v doesn't exist on this node", conf.VethName, interfaces.GetNSPath(conf.LANNS))
+		}
 		return fmt.Errorf("failed to locate veth interface %v, %w", conf.VethName, err)
 	}
 	//move interface to pod NS
-	err = netlink.LinkSetNsFd(vlink, int(podNS.Fd()))
+	err = nl.LinkSetNS(vlink, args.Netns)
 	if err != nil {
 		return fmt.Errorf("failed to move veth interface %v into pod NS, %w", conf.VethName, err)
 	}
 	//rename it
-	err = podNS.Do(func(_ ns.NetNS) error {
-		err := netlink.LinkSetName(vlink, args.IfName)
+	err = nl.InNS(args.Netns, func() error {
+		err := nl.LinkSetName(vlink, args.IfName)
 		if err != nil {
 			return err
 		}
-		vlink, err = netlink.LinkByName(args.IfName)
+		vlink, err = nl.LinkByName(args.IfName)
 		return err
 	})
 
@@ -114,7 +129,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 	podIface := &current.Interface{}
 	podIface.Name = vlink.Attrs().Name
 	podIface.Mac = vlink.Attrs().HardwareAddr.String()
-	podIface.Sandbox = podNS.Path()
+	podIface.Sandbox = args.Netns
 	result := &current.Result{
 		CNIVersion: current.ImplementedSpecVersion,
 		Interfaces: []*current.Interface{
@@ -153,7 +168,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 		}
 
 		// Configure the container hardware address and IP address(es)
-		if err := podNS.Do(func(_ ns.NetNS) error {
+		if err := nl.InNS(args.Netns, func() error {
 			if conf.EnableDad {
 				_, _ = sysctl.Sysctl(fmt.Sprintf("/net/ipv6/conf/%s/enhanced_dad", args.IfName), "1")
 				_, _ = sysctl.Sysctl(fmt.Sprintf("net/ipv6/conf/%s/accept_dad", args.IfName), "1")
@@ -186,17 +201,106 @@ func dnsConfSet(dnsConf types.DNS) bool {
 		dnsConf.Domain != ""
 }
 
-// cmdDel is called for DELETE requests
+// cmdDel is called for DELETE requests, it splits a p2p link joined by a single veth pair, so the other pod keeps its interface
 func cmdDel(args *skel.CmdArgs) error {
 	conf, err := parseConfig(args.StdinData, args.Args)
 	if err != nil {
 		return err
 	}
-	_ = conf
+	if conf.P2PPeer == "" || conf.LANNS == "" || args.Netns == "" {
+		return nil
+	}
+	return splitP2P(args.Netns, args.IfName, conf)
+}
 
-	// Do your delete here
+// findPeerVeth returns true if the bridge side veth of spoke of the LAN with uid is in any LAN NS on this node
+func findPeerVeth(uid string, spoke string) (bool, error) {
+	all, err := nl.ListNS()
+	if err != nil {
+		return false, fmt.Errorf("failed to list LAN namespaces, %w", err)
+	}
+	found := false
+	for _, nsname := range all {
+		nl.InNS(interfaces.GetNSPath(nsname), func() error {
+			link, err := nl.LinkByName(interfaces.GetPeerVethName(spoke))
+			if err != nil {
+				return nil
+			}
+			o, ok := interfaces.GetOwner(link)
+			found = found || ok && string(o.LANUID) == uid && o.Spoke == spoke
+			return nil
+		})
+	}
+	return found, nil
+}
 
-	return nil
+// splitP2P moves ifName in podNS to the LAN NS as the bridge side veth of conf.P2PPeer, if the veth is joined with it,
+// i.e. the bridge side veth of neither spoke exists; nothing is done if the pod NS or the LAN NS is gone
+func splitP2P(podNS, ifName string, conf *PluginConf) error {
+	lanNSPath := interfaces.GetNSPath(conf.LANNS)
+	if !nl.NSExists(podNS) || !nl.NSExists(lanNSPath) {
+		return nil
+	}
+	var nsOwner interfaces.Owner
+	err := nl.InNS(lanNSPath, func() error {
+		lo, err := nl.LinkByName("lo")
+		if err != nil {
+			return fmt.Errorf("failed to find lo, %w", err)
+		}
+		var ok bool
+		if nsOwner, ok = interfaces.GetOwner(lo); !ok || nsOwner.Role != interfaces.RoleNamespace {
+			return fmt.Errorf("%v is not a LAN namespace", lanNSPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var link netlink.Link
+	err = nl.InNS(podNS, func() error {
+		link, err = nl.LinkByName(ifName)
+		return err
+	})
+	if err != nil {
+		//already removed
+		return nil
+	}
+	if o, ok := interfaces.GetOwner(link); !ok || o != (interfaces.Owner{LANUID: nsOwner.LANUID, Role: interfaces.RoleSpoke, Spoke: conf.VethName}) {
+		return nil
+	}
+	for _, spoke := range []string{conf.VethName, conf.P2PPeer} {
+		found, err := findPeerVeth(string(nsOwner.LANUID), spoke)
+		if err != nil || found {
+			return err
+		}
+	}
+	err = nl.InNS(podNS, func() error {
+		if err := nl.LinkSetNS(link, lanNSPath); err != nil {
+			return fmt.Errorf("failed to move veth %v to LAN namespace %v, %w", ifName, lanNSPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	peerName := interfaces.GetPeerVethName(conf.P2PPeer)
+	return nl.InNS(lanNSPath, func() error {
+		link, err := nl.LinkByName(ifName)
+		if err != nil {
+			return fmt.Errorf("failed to find veth %v in LAN namespace, %w", ifName, err)
+		}
+		if err := nl.LinkSetName(link, peerName); err != nil {
+			return fmt.Errorf("failed to rename veth %v to %v, %w", ifName, peerName, err)
+		}
+		owner := interfaces.Owner{LANUID: nsOwner.LANUID, Role: dataplane.RolePeer, Spoke: conf.P2PPeer}
+		if err := nl.LinkSetAlias(link, owner.Alias()); err != nil {
+			return fmt.Errorf("failed to set alias of %v, %w", peerName, err)
+		}
+		if err := nl.LinkSetUp(link); err != nil {
+			return fmt.Errorf("failed to bring %v up, %w", peerName, err)
+		}
+		return nil
+	})
 }
 
 func main() {
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"github.com/vishvananda/netlink"
+)
+
+func TestCmdAdd(t *testing.T) {
+	fake := interfaces.NewFakeNetlinker()
+	origNL := nl
+	nl = fake
+	t.Cleanup(func() {
+		nl = origNL
+		interfaces.SetNSRunDir(interfaces.DefaultNSRunDir)
+	})
+	const podNS = "/var/run/netns/pod1"
+	fake.AddNS(podNS)
+	if err := fake.LinkAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "lan1s1"}}); err != nil {
+		t.Fatal(err)
+	}
+	args := &skel.CmdArgs{
+		ContainerID: "c1",
+		Netns:       podNS,
+		IfName:      "net1",
+		StdinData:   []byte(`{"cniVersion":"1.0.0","name":"lan1","type":"k8slanveth","veth":"lan1s1"}`),
+	}
+	if err := cmdAdd(args); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range fake.Links("") {
+		if l.Attrs().Name == "lan1s1" {
+			t.Error("veth is not moved out of host ns")
+		}
+	}
+	found := false
+	for _, l := range fake.Links(podNS) {
+		if l.Attrs().Name == "net1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("veth is not renamed in pod ns")
+	}
+	//the veth is gone from host ns
+	if err := cmdAdd(args); err == nil {
+		t.Error("expect error when the veth doesn't exist")
+	}
+	args.StdinData = []byte(`{"cniVersion":"1.0.0","name":"lan1","type":"k8slanveth","veth":"lan1s1","lanNS":"lan1","netnsDir":"/run/test/netns"}`)
+	if err := cmdAdd(args); err == nil || !strings.Contains(err.Error(), "/run/test/netns/lan1") {
+		t.Errorf("expect error of missing LAN namespace, got %v", err)
+	}
+	args.Netns = "/var/run/netns/nonexist"
+	if err := cmdAdd(args); err == nil {
+		t.Error("expect error when the pod ns doesn't exist")
+	}
+}
+
+func TestCmdDelSplitsP2P(t *testing.T) {
+	fake := interfaces.NewFakeNetlinker()
+	origNL := nl
+	nl = fake
+	t.Cleanup(func() {
+		nl = origNL
+	})
+	const podNS1, podNS2 = "/var/run/netns/pod1", "/var/run/netns/pod2"
+	lanNS := interfaces.GetNSPath("lan1")
+	for _, path := range []string{podNS1, podNS2, lanNS} {
+		fake.AddNS(path)
+	}
+	setAlias := func(path, name string, o interfaces.Owner) {
+		t.Helper()
+		err := fake.InNS(path, func() error {
+			link, err := fake.LinkByName(name)
+			if err != nil {
+				return err
+			}
+			return fake.LinkSetAlias(link, o.Alias())
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	setAlias(lanNS, "lo", interfaces.Owner{LANUID: "uid1", Role: interfaces.RoleNamespace})
+	//lan1s1 and lan1s2 are joined by a single veth pair, net1 of each pod
+	err := fake.InNS(podNS1, func() error {
+		if err := fake.LinkAdd(&netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "net1"}, PeerName: "peer"}); err != nil {
+			return err
+		}
+		peer, err := fake.LinkByName("peer")
+		if err != nil {
+			return err
+		}
+		return fake.LinkSetNS(peer, podNS2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setAlias(podNS1, "net1", interfaces.Owner{LANUID: "uid1", Role: interfaces.RoleSpoke, Spoke: "lan1s1"})
+	args := &skel.CmdArgs{
+		ContainerID: "c1",
+		Netns:       podNS1,
+		IfName:      "net1",
+		StdinData:   []byte(`{"cniVersion":"1.0.0","name":"lan1","type":"k8slanveth","veth":"lan1s1","lanNS":"lan1"}`),
+	}
+	findLink := func(path, name string) netlink.Link {
+		for _, l := range fake.Links(path) {
+			if l.Attrs().Name == name {
+				return l
+			}
+		}
+		return nil
+	}
+	//not a p2p LAN
+	if err := cmdDel(args); err != nil || findLink(podNS1, "net1") == nil {
+		t.Fatalf("veth of a bridge mode LAN is moved, %v", err)
+	}
+	args.StdinData = []byte(`{"cniVersion":"1.0.0","name":"lan1","type":"k8slanveth","veth":"lan1s1","lanNS":"lan1","p2pPeer":"lan1s2"}`)
+	if err := cmdDel(args); err != nil {
+		t.Fatal(err)
+	}
+	if findLink(podNS1, "net1") != nil {
+		t.Error("veth is not moved out of pod ns")
+	}
+	link := findLink(lanNS, "lan1s2p")
+	if link == nil {
+		t.Fatal("veth is not moved to the LAN ns as the bridge side veth of lan1s2")
+	}
+	if o, _ := interfaces.GetOwner(link); o != (interfaces.Owner{LANUID: "uid1", Role: "peer", Spoke: "lan1s2"}) {
+		t.Errorf("owner of lan1s2p is %+v", o)
+	}
+	if link.Attrs().Flags&net.FlagUp == 0 {
+		t.Error("lan1s2p is not up")
+	}
+	//the bridge side veth of lan1s2 exists, so net1 of pod2 is not joined
+	args.Netns, args.StdinData = podNS2, []byte(`{"cniVersion":"1.0.0","name":"lan1","type":"k8slanveth","veth":"lan1s2","lanNS":"lan1","p2pPeer":"lan1s1"}`)
+	err = fake.InNS(podNS2, func() error {
+		peer, err := fake.LinkByName("peer")
+		if err != nil {
+			return err
+		}
+		return fake.LinkSetName(peer, "net1")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setAlias(podNS2, "net1", interfaces.Owner{LANUID: "uid1", Role: interfaces.RoleSpoke, Spoke: "lan1s2"})
+	if err := cmdDel(args); err != nil || findLink(podNS2, "net1") == nil {
+		t.Errorf("veth not joined is moved, %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/capture"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"golang.org/x/sys/unix"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// capture files are served under this path of the metrics server
+	captureURLPath = "/captures/"
+	// interval of updating capture progress in status
+	captureProgressInterval = 5 * time.Second
+)
+
+// PacketCaptureReconciler runs PacketCapture on this node
+type PacketCaptureReconciler struct {
+	client.Client
+	hostName string
+	// directory to store capture files
+	captureDir string
+	// max size of a capture file in bytes
+	maxFileSize int64
+	// prefix of capture file download url, e.g. https://10.1.1.1:8444
+	urlPrefix string
+	lock      *sync.Mutex
+	// key is the namespaced name of the PacketCapture
+	running map[types.NamespacedName]context.CancelFunc
+}
+
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=packetcaptures,verbs=get;list;watch
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=packetcaptures/status,verbs=get;update;patch
+
+// captureFilePrefix returns the common prefix of all capture files of a PacketCapture
+func captureFilePrefix(key types.NamespacedName) string {
+	return fmt.Sprintf("%v_%v_", key.Namespace, key.Name)
+}
+
+// getCaptureIfName returns the interface to capture in the LAN NS
+func getCaptureIfName(lan *k8slan.LANSpec, spoke string) string {
+	switch {
+	case spoke != "":
+		return interfaces.GetPeerVethName(spoke)
+	case lan.IsP2P():
+		return *lan.VxLANName
+	default:
+		return *lan.BridgeName
+	}
+}
+
+// getCaptureFilter returns the BPF filter of spec, either compiled from filter or parsed from filterBytecode
+func getCaptureFilter(spec *k8slan.PacketCaptureSpec, snapLen int) ([]unix.SockFilter, error) {
+	switch {
+	case spec.Filter != "" && spec.FilterBytecode != "":
+		return nil, fmt.Errorf("filter and filterBytecode can't be used together")
+	case spec.FilterBytecode != "":
+		return capture.ParseBytecode(spec.FilterBytecode)
+	}
+	return capture.CompileFilter(spec.Filter, snapLen)
+}
+
+func (r *PacketCaptureReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.Log.WithValues("packetcapture", req.NamespacedName)
+	pc := &k8slan.PacketCapture{}
+	if err := r.Get(ctx, req.NamespacedName, pc); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.stop(req.NamespacedName)
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	if pc.Spec.Node != "" && pc.Spec.Node != r.hostName {
+		return reconcile.Result{}, nil
+	}
+	r.lock.Lock()
+	_, isRunning := r.running[req.NamespacedName]
+	r.lock.Unlock()
+	if isRunning {
+		return reconcile.Result{}, nil
+	}
+	if nodeStatus := pc.Status.GetNodeStatus(r.hostName); nodeStatus != nil {
+		if nodeStatus.Phase == k8slan.CapturePhaseRunning {
+			//the daemonset restarted while capturing
+			nodeStatus.Phase = k8slan.CapturePhaseFailed
+			nodeStatus.Message = "capture interrupted"
+			return reconcile.Result{}, r.updateNodeStatus(ctx, req.NamespacedName, *nodeStatus)
+		}
+		return reconcile.Result{}, nil
+	}
+	fail := func(msg string) error {
+		return r.updateNodeStatus(ctx, req.NamespacedName, k8slan.NodeCaptureStatus{
+			Node:    r.hostName,
+			Phase:   k8slan.CapturePhaseFailed,
+			Message: msg,
+		})
+	}
+	lan := &k8slan.LAN{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: pc.Namespace, Name: pc.Spec.LAN}, lan); err != nil {
+		if apierrors.IsNotFound(err) && pc.Spec.Node != "" {
+			return reconcile.Result{}, fail(fmt.Sprintf("LAN %v not found", pc.Spec.LAN))
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	ifName := getCaptureIfName(&lan.Spec, pc.Spec.Spoke)
+	if !interfaces.LinkExistsInNS(*lan.Spec.NS, ifName) {
+		//nothing to capture on this node
+		if pc.Spec.Node != "" {
+			return reconcile.Result{}, fail(fmt.Sprintf("interface %v doesn't exist in LAN namespace on this node", ifName))
+		}
+		return reconcile.Result{}, nil
+	}
+	c := &capture.Capture{
+		NSPath:        interfaces.GetNSPath(*lan.Spec.NS),
+		IfName:        ifName,
+		SnapLen:       k8slan.DefaultCaptureSnapLen,
+		FileSizeLimit: r.maxFileSize,
+	}
+	if pc.Spec.SnapLen != nil {
+		c.SnapLen = int(*pc.Spec.SnapLen)
+	}
+	if pc.Spec.MaxFileSize != nil && pc.Spec.MaxFileSize.Value() < c.FileSizeLimit {
+		c.FileSizeLimit = pc.Spec.MaxFileSize.Value()
+	}
+	var err error
+	if c.Filter, err = getCaptureFilter(&pc.Spec, c.SnapLen); err != nil {
+		return reconcile.Result{}, fail(fmt.Sprintf("invalid filter, %v", err))
+	}
+	if pc.Spec.PacketLimit != nil {
+		c.PacketLimit = *pc.Spec.PacketLimit
+	}
+	if pc.Spec.Duration != nil {
+		c.Duration = pc.Spec.Duration.Duration
+	}
+	if c.Duration == 0 && c.PacketLimit == 0 {
+		c.Duration = k8slan.DefaultCaptureDuration
+	}
+	fileName := fmt.Sprintf("%v%v_%v.pcapng", captureFilePrefix(req.NamespacedName), pc.UID, r.hostName)
+	f, err := os.Create(filepath.Join(r.captureDir, fileName))
+	if err != nil {
+		return reconcile.Result{}, fail(fmt.Sprintf("failed to create capture file, %v", err))
+	}
+	nodeStatus := k8slan.NodeCaptureStatus{
+		Node:      r.hostName,
+		Phase:     k8slan.CapturePhaseRunning,
+		URL:       r.urlPrefix + captureURLPath + fileName,
+		StartTime: &metav1.Time{Time: time.Now()},
+	}
+	if err := r.updateNodeStatus(ctx, req.NamespacedName, nodeStatus); err != nil {
+		f.Close()
+		return reconcile.Result{}, err
+	}
+	capCtx, cancel := context.WithCancel(context.Background())
+	r.lock.Lock()
+	r.running[req.NamespacedName] = cancel
+	r.lock.Unlock()
+	log.Info("capture started", "interface", ifName, "file", fileName)
+	go r.run(capCtx, req.NamespacedName, c, f, nodeStatus)
+	return reconcile.Result{}, nil
+}
+
+// run runs the capture c and reports its progress until it is done
+func (r *PacketCaptureReconciler) run(ctx context.Context, key types.NamespacedName, c *capture.Capture, f *os.File, nodeStatus k8slan.NodeCaptureStatus) {
+	log := ctrl.Log.WithValues("packetcapture", key)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx, f)
+	}()
+	ticker := time.NewTicker(captureProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			nodeStatus.Packets, nodeStatus.Bytes = c.Stats()
+			if err := r.updateNodeStatus(context.Background(), key, nodeStatus); err != nil {
+				log.Error(err, "failed to update capture progress")
+			}
+		case err := <-done:
+			f.Close()
+			r.lock.Lock()
+			delete(r.running, key)
+			r.lock.Unlock()
+			if ctx.Err() != nil {
+				//PacketCapture is removed
+				return
+			}
+			nodeStatus.Packets, nodeStatus.Bytes = c.Stats()
+			nodeStatus.Phase = k8slan.CapturePhaseCompleted
+			nodeStatus.CompletionTime = &metav1.Time{Time: time.Now()}
+			if errors.Is(err, capture.ErrFileSizeLimit) {
+				nodeStatus.Message = err.Error()
+				err = nil
+			}
+			if err != nil {
+				nodeStatus.Phase = k8slan.CapturePhaseFailed
+				nodeStatus.Message = err.Error()
+			}
+			log.Info("capture finished", "phase", nodeStatus.Phase, "packets", nodeStatus.Packets)
+			if err := r.updateNodeStatus(context.Background(), key, nodeStatus); err != nil {
+				log.Error(err, "failed to update capture status")
+			}
+			return
+		}
+	}
+}
+
+// stop stops the capture of a removed PacketCapture and removes its capture files
+func (r *PacketCaptureReconciler) stop(key types.NamespacedName) {
+	r.lock.Lock()
+	if cancel, ok := r.running[key]; ok {
+		cancel()
+	}
+	r.lock.Unlock()
+	entries, err := os.ReadDir(r.captureDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), captureFilePrefix(key)) {
+			os.Remove(filepath.Join(r.captureDir, entry.Name()))
+		}
+	}
+}
+
+// updateNodeStatus sets the capture status of this node
+func (r *PacketCaptureReconciler) updateNodeStatus(ctx context.Context, key types.NamespacedName, nodeStatus k8slan.NodeCaptureStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pc := &k8slan.PacketCapture{}
+		if err := r.Get(ctx, key, pc); err != nil {
+			return err
+		}
+		pc.Status.SetNodeStatus(nodeStatus)
+		return r.Status().Update(ctx, pc)
+	})
+}
+
+// captureFileHandler serves capture files
+func (r *PacketCaptureReconciler) captureFileHandler() http.Handler {
+	return http.StripPrefix(captureURLPath, http.FileServer(http.Dir(r.captureDir)))
+}
+
+func (r *PacketCaptureReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&k8slan.PacketCapture{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// keys of the IPsec key Secret data
+const (
+	secretKeyAddr      = "addr"
+	secretKeySPI       = "spi"
+	secretKeyKey       = "key"
+	secretKeyNextSPI   = "nextSpi"
+	secretKeyNextKey   = "nextKey"
+	secretKeyRotatedAt = "rotatedAt"
+)
+
+// nodeKeys is the content of the IPsec key Secret of a worker for a LAN;
+// the next key is published one rotation interval before it is used, so that peers could install it in time
+type nodeKeys struct {
+	addr      netip.Addr
+	current   interfaces.IPsecKey
+	next      interfaces.IPsecKey
+	rotatedAt time.Time
+}
+
+func spiBytes(spi uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, spi)
+}
+
+func parseSPI(buf []byte) (uint32, error) {
+	if len(buf) != 4 {
+		return 0, fmt.Errorf("invalid spi length %d", len(buf))
+	}
+	return binary.BigEndian.Uint32(buf), nil
+}
+
+func parseNodeKeys(s *corev1.Secret) (*nodeKeys, error) {
+	k := &nodeKeys{}
+	var err error
+	if k.addr, err = netip.ParseAddr(string(s.Data[secretKeyAddr])); err != nil {
+		return nil, fmt.Errorf("invalid addr in secret %v, %w", s.Name, err)
+	}
+	if k.current.SPI, err = parseSPI(s.Data[secretKeySPI]); err != nil {
+		return nil, fmt.Errorf("invalid spi in secret %v, %w", s.Name, err)
+	}
+	if k.next.SPI, err = parseSPI(s.Data[secretKeyNextSPI]); err != nil {
+		return nil, fmt.Errorf("invalid next spi in secret %v, %w", s.Name, err)
+	}
+	k.current.Key = s.Data[secretKeyKey]
+	k.next.Key = s.Data[secretKeyNextKey]
+	if k.rotatedAt, err = time.Parse(time.RFC3339, string(s.Data[secretKeyRotatedAt])); err != nil {
+		return nil, fmt.Errorf("invalid rotation time in secret %v, %w", s.Name, err)
+	}
+	return k, nil
+}
+
+func (k *nodeKeys) toSecret(s *corev1.Secret) {
+	s.Type = corev1.SecretTypeOpaque
+	s.Data = map[string][]byte{
+		secretKeyAddr:      []byte(k.addr.String()),
+		secretKeySPI:       spiBytes(k.current.SPI),
+		secretKeyKey:       k.current.Key,
+		secretKeyNextSPI:   spiBytes(k.next.SPI),
+		secretKeyNextKey:   k.next.Key,
+		secretKeyRotatedAt: []byte(k.rotatedAt.Format(time.RFC3339)),
+	}
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=lans/status,verbs=get;update;patch
+
+// reconcileEncryption publishes the keys of this node, installs IPsec SAs with the other nodes of lan,
+// and reports encrypted peers in status; it returns the time until next key rotation, 0 if encryption is not used on this node
+func (r *LANReconciler) reconcileEncryption(ctx context.Context, lan *k8slan.LAN) (time.Duration, error) {
+	reqid := interfaces.IPsecReqID(lan.UID)
+	if _, err := os.Stat(interfaces.GetNSPath(*lan.Spec.NS)); lan.Spec.Encryption == nil || err != nil {
+		//encryption is not used, or the LAN doesn't exist on this node
+		return 0, r.removeEncryption(ctx, lan, reqid)
+	}
+	//the XFRM policies select by port, unencrypted traffic of another user of the port would be dropped
+	sharing, err := r.getPortSharing(ctx, lan)
+	if err != nil {
+		return 0, err
+	}
+	if len(sharing) > 0 {
+		err := fmt.Errorf("%w, port %d is also used by %v on this node", interfaces.ErrIPsecPortShared, lan.Spec.GetUDPPort(), strings.Join(sharing, ", "))
+		if rerr := interfaces.RemoveIPsec(&lan.Spec, reqid); rerr != nil {
+			return 0, rerr
+		}
+		if serr := r.setEncryptionStatus(ctx, lan, &k8slan.NodeEncryptionStatus{Node: r.hostName, Message: err.Error()}); serr != nil {
+			return 0, serr
+		}
+		return 0, err
+	}
+	keys, err := r.ensureKeySecret(ctx, lan)
+	if err != nil {
+		return 0, err
+	}
+	secrets := &corev1.SecretList{}
+	if err := r.List(ctx, secrets, client.InNamespace(lan.Namespace), client.MatchingLabels{k8slan.LabelLAN: lan.Name}); err != nil {
+		return 0, fmt.Errorf("failed to list key secrets, %w", err)
+	}
+	peers := []interfaces.IPsecPeer{}
+	for _, s := range secrets.Items {
+		node := s.Labels[k8slan.LabelNode]
+		if node == r.hostName || !isOwnedBy(&s, lan) || len(s.Data) == 0 {
+			//not filled by the node yet
+			continue
+		}
+		peerKeys, err := parseNodeKeys(&s)
+		if err != nil {
+			ctrl.Log.Error(err, "ignore invalid key secret", "lan", lan.Name, "node", node)
+			continue
+		}
+		peers = append(peers, interfaces.IPsecPeer{
+			Node: node,
+			Addr: peerKeys.addr,
+			Keys: []interfaces.IPsecKey{peerKeys.current, peerKeys.next},
+		})
+	}
+	slices.SortFunc(peers, func(a, b interfaces.IPsecPeer) int { return strings.Compare(a.Node, b.Node) })
+	status := k8slan.NodeEncryptionStatus{
+		Node:            r.hostName,
+		LastKeyRotation: &metav1.Time{Time: keys.rotatedAt},
+	}
+	if err := interfaces.EnsureIPsec(&lan.Spec, reqid, keys.addr, keys.current, peers); err != nil {
+		status.Message = err.Error()
+	} else {
+		for _, p := range peers {
+			status.EncryptedPeers = append(status.EncryptedPeers, p.Node)
+		}
+	}
+	if err := r.setEncryptionStatus(ctx, lan, &status); err != nil {
+		return 0, err
+	}
+	if status.Message != "" {
+		return 0, fmt.Errorf("failed to install IPsec SAs, %v", status.Message)
+	}
+	return time.Until(keys.rotatedAt.Add(lan.Spec.Encryption.GetKeyRotationInterval())), nil
+}
+
+// getPortSharing returns the other LANs and peerings on this node using the tunnel port of lan, as "<kind> <namespace>/<name>"
+func (r *LANReconciler) getPortSharing(ctx context.Context, lan *k8slan.LAN) ([]string, error) {
+	port := lan.Spec.GetUDPPort()
+	sharing := []string{}
+	if port == 0 {
+		return sharing, nil
+	}
+	lans := &k8slan.LANList{}
+	if err := r.List(ctx, lans); err != nil {
+		return nil, fmt.Errorf("failed to list LANs, %w", err)
+	}
+	for _, other := range lans.Items {
+		if other.UID == lan.UID || other.Spec.NS == nil || other.Spec.GetUDPPort() != port {
+			continue
+		}
+		if _, err := os.Stat(interfaces.GetNSPath(*other.Spec.NS)); err == nil {
+			sharing = append(sharing, "LAN "+client.ObjectKeyFromObject(&other).String())
+		}
+	}
+	peerings := &k8slan.LANPeeringList{}
+	if err := r.List(ctx, peerings); err != nil {
+		return nil, fmt.Errorf("failed to list peerings, %w", err)
+	}
+	for _, p := range peerings.Items {
+		if p.Spec.GatewayNode == r.hostName && p.Spec.GetPort() == port {
+			sharing = append(sharing, "LANPeering "+client.ObjectKeyFromObject(&p).String())
+		}
+	}
+	return sharing, nil
+}
+
+// encryptedLANsOnPort returns the encrypted LANs using port, except the one with uid;
+// so that an encrypted LAN is rechecked when another user of its port shows up
+func (r *LANReconciler) encryptedLANsOnPort(ctx context.Context, port int32, uid types.UID) []reconcile.Request {
+	lans := &k8slan.LANList{}
+	if err := r.List(ctx, lans); err != nil {
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, lan := range lans.Items {
+		if lan.UID != uid && lan.Spec.Encryption != nil && lan.Spec.GetUDPPort() == port {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&lan)})
+		}
+	}
+	return reqs
+}
+
+// lanToEncryptedLANs returns the encrypted LANs sharing the tunnel port of a LAN
+func (r *LANReconciler) lanToEncryptedLANs(ctx context.Context, obj client.Object) []reconcile.Request {
+	lan, ok := obj.(*k8slan.LAN)
+	if !ok || lan.Spec.GetUDPPort() == 0 {
+		return nil
+	}
+	return r.encryptedLANsOnPort(ctx, lan.Spec.GetUDPPort(), lan.UID)
+}
+
+func isOwnedBy(obj metav1.Object, lan *k8slan.LAN) bool {
+	return slices.ContainsFunc(obj.GetOwnerReferences(), func(ref metav1.OwnerReference) bool { return ref.UID == lan.UID })
+}
+
+// ensureKeySecret fills the key Secret of this node for lan created by the controller, or rotates the keys if it is due
+func (r *LANReconciler) ensureKeySecret(ctx context.Context, lan *k8slan.LAN) (*nodeKeys, error) {
+	addr, err := interfaces.GetVTEPAddr(&lan.Spec, r.hostName)
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: lan.Namespace, Name: k8slan.GetKeySecretName(lan.Name, r.hostName)}
+	if err := r.Get(ctx, key, secret); err != nil {
+		//the Secret is watched, so this is retried once the controller creates it
+		return nil, fmt.Errorf("failed to get key secret %v, %w", key, err)
+	}
+	keys, err := parseNodeKeys(secret)
+	changed := false
+	if err != nil {
+		//generate the keys of a new secret, or regenerate a corrupted one
+		if len(secret.Data) > 0 {
+			ctrl.Log.Error(err, "regenerate key secret", "lan", lan.Name)
+		}
+		keys = &nodeKeys{}
+		if keys.next, err = interfaces.NewIPsecKey(); err != nil {
+			return nil, err
+		}
+	}
+	if keys.current.Key == nil || time.Since(keys.rotatedAt) >= lan.Spec.Encryption.GetKeyRotationInterval() {
+		keys.current = keys.next
+		if keys.next, err = interfaces.NewIPsecKey(); err != nil {
+			return nil, err
+		}
+		keys.rotatedAt = time.Now()
+		changed = true
+		if len(secret.Data) > 0 {
+			r.Recorder.Eventf(lan, corev1.EventTypeNormal, interfaces.ReasonKeyRotated,
+				"node %v: rotated IPsec key, spi is %#x", r.hostName, keys.current.SPI)
+		}
+	}
+	if keys.addr != addr {
+		keys.addr = addr
+		changed = true
+	}
+	if changed {
+		keys.toSecret(secret)
+		if err := r.Update(ctx, secret); err != nil {
+			return nil, fmt.Errorf("failed to update key secret %v, %w", key, err)
+		}
+	}
+	return keys, nil
+}
+
+// removeEncryption removes IPsec SAs, the keys in the key Secret and the encryption status of this node for lan,
+// the Secret itself is removed by the controller
+func (r *LANReconciler) removeEncryption(ctx context.Context, lan *k8slan.LAN, reqid int) error {
+	if err := interfaces.RemoveIPsec(&lan.Spec, reqid); err != nil {
+		return err
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: lan.Namespace, Name: k8slan.GetKeySecretName(lan.Name, r.hostName)}
+	if err := r.Get(ctx, key, secret); err == nil {
+		if len(secret.Data) > 0 {
+			secret.Data = nil
+			if err := r.Update(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to clear key secret %v, %w", key, err)
+			}
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get key secret %v, %w", key, err)
+	}
+	return r.setEncryptionStatus(ctx, lan, nil)
+}
+
+// setEncryptionStatus sets the encryption status of this node in lan, nil removes it
+func (r *LANReconciler) setEncryptionStatus(ctx context.Context, lan *k8slan.LAN, status *k8slan.NodeEncryptionStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &k8slan.LAN{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(lan), latest); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if status == nil {
+			if !latest.Status.RemoveNodeEncryption(r.hostName) {
+				return nil
+			}
+		} else {
+			if existing := latest.Status.GetNodeEncryption(r.hostName); existing != nil && equality.Semantic.DeepEqual(existing, status) {
+				return nil
+			}
+			latest.Status.SetNodeEncryption(*status)
+		}
+		return r.Status().Update(ctx, latest)
+	})
+}
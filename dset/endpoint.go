@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=lanendpoints,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// isPodActive returns true if pod is not terminated or being removed
+func isPodActive(pod *corev1.Pod) bool {
+	return pod.DeletionTimestamp == nil && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
+
+// getPodBindings returns the interfaces of pod attached to spokes of lan, according to the network status of the pod
+func getPodBindings(pod *corev1.Pod, lan *k8slan.LAN) []k8slan.EndpointBinding {
+	annotation, ok := pod.Annotations[ncv1.NetworkStatusAnnot]
+	if !ok {
+		return nil
+	}
+	statusList := []ncv1.NetworkStatus{}
+	if err := json.Unmarshal([]byte(annotation), &statusList); err != nil {
+		return nil
+	}
+	r := []k8slan.EndpointBinding{}
+	for _, status := range statusList {
+		nadName, ok := strings.CutPrefix(status.Name, lan.Namespace+"/")
+		if !ok || status.Mac == "" {
+			continue
+		}
+		spoke := k8slan.GetSpokeNameFromResourceName(nadName)
+		if !slices.Contains(lan.Spec.SpokeList, spoke) {
+			continue
+		}
+		r = append(r, k8slan.EndpointBinding{
+			Spoke: spoke,
+			MAC:   status.Mac,
+			IPs:   status.IPs,
+			Pod:   client.ObjectKeyFromObject(pod).String(),
+		})
+	}
+	return r
+}
+
+// reconcileEndpoints programs the static entries of the vxlan interface of lan on this node, i.e. the remote vteps of lan,
+// and if lan uses static FDB, the bindings of other nodes, while the bindings of this node are published in its LANEndpoint
+func (r *LANReconciler) reconcileEndpoints(ctx context.Context, lan *k8slan.LAN) error {
+	if _, err := os.Stat(interfaces.GetNSPath(*lan.Spec.NS)); err != nil {
+		//the LAN doesn't exist on this node
+		r.withdrawEVPN(lan)
+		return r.removeEndpoint(ctx, lan)
+	}
+	fdb := &interfaces.RemoteFDB{}
+	if lan.Spec.IsStaticFDB() {
+		macs, err := r.syncEndpoints(ctx, lan)
+		if err != nil {
+			return err
+		}
+		fdb.MACs = macs
+	} else if err := r.removeEndpoint(ctx, lan); err != nil {
+		return err
+	}
+	if lan.Spec.IsEVPN() {
+		if err := r.syncEVPN(ctx, lan, fdb); err != nil {
+			return err
+		}
+	} else {
+		r.withdrawEVPN(lan)
+	}
+	if lan.Spec.GetEncapsulation() != k8slan.EncapVxLAN {
+		return nil
+	}
+	addRemoteVTEPs(fdb, &lan.Spec, r.hostName)
+	return interfaces.SyncRemoteFDB(&lan.Spec, r.hostName, fdb)
+}
+
+// syncEndpoints publishes the bindings of lan on this node in the LANEndpoint of this node,
+// and returns the bindings of other nodes
+func (r *LANReconciler) syncEndpoints(ctx context.Context, lan *k8slan.LAN) ([]interfaces.RemoteMAC, error) {
+	vtep, err := interfaces.GetVTEPAddr(&lan.Spec, r.hostName)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := r.getLocalBindings(ctx, lan)
+	if err != nil {
+		return nil, err
+	}
+	ep := &k8slan.LANEndpoint{}
+	ep.Namespace = lan.Namespace
+	ep.Name = k8slan.GetLANEndpointName(lan.Name, r.hostName)
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, ep, func() error {
+		ep.Labels = map[string]string{k8slan.LabelLAN: lan.Name, k8slan.LabelNode: r.hostName}
+		ep.Spec = k8slan.LANEndpointSpec{
+			LAN:      lan.Name,
+			Node:     r.hostName,
+			VTEP:     vtep.String(),
+			Bindings: bindings,
+		}
+		return controllerutil.SetOwnerReference(lan, ep, r.Scheme())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update LANEndpoint %v, %w", ep.Name, err)
+	}
+	endpoints := &k8slan.LANEndpointList{}
+	if err := r.List(ctx, endpoints, client.InNamespace(lan.Namespace), client.MatchingLabels{k8slan.LabelLAN: lan.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list LANEndpoints, %w", err)
+	}
+	macs := []interfaces.RemoteMAC{}
+	for _, e := range endpoints.Items {
+		if e.Spec.Node == r.hostName || !isOwnedBy(&e, lan) {
+			continue
+		}
+		remoteVTEP, err := netip.ParseAddr(e.Spec.VTEP)
+		if err != nil {
+			ctrl.Log.Error(err, "ignore invalid LANEndpoint", "lan", lan.Name, "node", e.Spec.Node)
+			continue
+		}
+		for _, b := range e.Spec.Bindings {
+			mac, err := net.ParseMAC(b.MAC)
+			if err != nil {
+				continue
+			}
+			m := interfaces.RemoteMAC{MAC: mac, VTEP: remoteVTEP}
+			for _, ipStr := range b.IPs {
+				if ip, err := netip.ParseAddr(ipStr); err == nil {
+					m.IPs = append(m.IPs, ip)
+				}
+			}
+			macs = append(macs, m)
+		}
+	}
+	return macs, nil
+}
+
+// getLocalBindings returns the bindings of the active pods of this node attached to lan, sorted by MAC
+func (r *LANReconciler) getLocalBindings(ctx context.Context, lan *k8slan.LAN) ([]k8slan.EndpointBinding, error) {
+	pods := &corev1.PodList{}
+	//only pods of this node are cached
+	if err := r.List(ctx, pods); err != nil {
+		return nil, fmt.Errorf("failed to list pods, %w", err)
+	}
+	bindings := []k8slan.EndpointBinding{}
+	for i := range pods.Items {
+		if isPodActive(&pods.Items[i]) {
+			bindings = append(bindings, getPodBindings(&pods.Items[i], lan)...)
+		}
+	}
+	slices.SortFunc(bindings, func(a, b k8slan.EndpointBinding) int { return strings.Compare(a.MAC, b.MAC) })
+	return bindings, nil
+}
+
+// removeEndpoint removes the LANEndpoint of this node for lan
+func (r *LANReconciler) removeEndpoint(ctx context.Context, lan *k8slan.LAN) error {
+	ep := &k8slan.LANEndpoint{}
+	key := types.NamespacedName{Namespace: lan.Namespace, Name: k8slan.GetLANEndpointName(lan.Name, r.hostName)}
+	if err := r.Get(ctx, key, ep); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if err := r.Delete(ctx, ep); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove LANEndpoint %v, %w", key, err)
+	}
+	return nil
+}
+
+// podToLANs returns the LANs using static FDB or EVPN, or having bound spokes, that pod is attached to;
+// so that a bound spoke of a new pod is rebound without waiting for the drift interval
+func (r *LANReconciler) podToLANs(ctx context.Context, obj client.Object) []reconcile.Request {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Annotations[ncv1.NetworkStatusAnnot] == "" {
+		return nil
+	}
+	lans := &k8slan.LANList{}
+	if err := r.List(ctx, lans); err != nil {
+		return nil
+	}
+	bindings := &k8slan.SpokeBindingList{}
+	if err := r.List(ctx, bindings, client.InNamespace(pod.Namespace)); err != nil {
+		return nil
+	}
+	bound := map[string]bool{}
+	for _, b := range bindings.Items {
+		bound[b.Spec.Spoke] = true
+	}
+	reqs := []reconcile.Request{}
+	for i := range lans.Items {
+		lan := &lans.Items[i]
+		if !lan.Spec.IsStaticFDB() && !lan.Spec.IsEVPN() && (lan.Namespace != pod.Namespace || !slices.ContainsFunc(lan.Spec.SpokeList, func(s string) bool { return bound[s] })) {
+			continue
+		}
+		if len(getPodBindings(pod, lan)) > 0 {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(lan)})
+		}
+	}
+	return reqs
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// networkStatus returns the network status annotation of the attachments, each as "<namespace>/<nad> <mac> <ip>..."
+func networkStatus(attachments ...string) string {
+	r := []string{`{"name":"k8s-pod-network","interface":"eth0","ips":["10.244.0.5"],"default":true}`}
+	for _, a := range attachments {
+		fields := strings.Fields(a)
+		r = append(r, fmt.Sprintf(`{"name":%q,"interface":"net%d","mac":%q,"ips":["%v"]}`,
+			fields[0], len(r), fields[1], strings.Join(fields[2:], `","`)))
+	}
+	return "[" + strings.Join(r, ",") + "]"
+}
+
+func TestGetPodBindings(t *testing.T) {
+	lan := &k8slan.LAN{ObjectMeta: metav1.ObjectMeta{Name: "lan1", Namespace: "ns1"}}
+	lan.Spec.SpokeList = []string{"s1", "s2"}
+	s1 := "ns1/" + k8slan.GetNADName("s1", false)
+	s2 := "ns1/" + k8slan.GetNADName("s2", true)
+	cases := []struct {
+		name       string
+		annotation *string
+		phase      corev1.PodPhase
+		deleting   bool
+		expected   []string
+	}{
+		{
+			name:       "macvtap and veth spokes",
+			annotation: ptr(networkStatus(s1+" 02:00:00:00:00:01 10.1.1.1", s2+" 02:00:00:00:00:02 10.1.1.2 fd00::2")),
+			expected:   []string{"s1 02:00:00:00:00:01 [10.1.1.1]", "s2 02:00:00:00:00:02 [10.1.1.2 fd00::2]"},
+		},
+		{
+			name:       "no annotation",
+			annotation: nil,
+		},
+		{
+			name:       "invalid annotation",
+			annotation: ptr(`[{"name":`),
+		},
+		{
+			name:       "interface without mac",
+			annotation: ptr(`[{"name":"` + s1 + `","interface":"net1"}]`),
+		},
+		{
+			name: "spokes of a LAN in another namespace",
+			annotation: ptr(networkStatus("ns2/"+k8slan.GetNADName("s1", false)+" 02:00:00:00:00:01 10.1.1.1",
+				s2+" 02:00:00:00:00:02 10.1.1.2")),
+			expected: []string{"s2 02:00:00:00:00:02 [10.1.1.2]"},
+		},
+		{
+			name:       "spoke of another LAN in the same namespace",
+			annotation: ptr(networkStatus("ns1/" + k8slan.GetNADName("s3", false) + " 02:00:00:00:00:03 10.1.1.3")),
+		},
+		{
+			name:       "attachment not created by a LAN",
+			annotation: ptr(networkStatus("ns1/macvlan-conf 02:00:00:00:00:04 10.1.1.4")),
+		},
+		{
+			name:       "succeeded pod",
+			annotation: ptr(networkStatus(s1 + " 02:00:00:00:00:01 10.1.1.1")),
+			phase:      corev1.PodSucceeded,
+		},
+		{
+			name:       "failed pod",
+			annotation: ptr(networkStatus(s1 + " 02:00:00:00:00:01 10.1.1.1")),
+			phase:      corev1.PodFailed,
+		},
+		{
+			name:       "pod being removed",
+			annotation: ptr(networkStatus(s1 + " 02:00:00:00:00:01 10.1.1.1")),
+			deleting:   true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"}}
+			if c.annotation != nil {
+				pod.Annotations = map[string]string{ncv1.NetworkStatusAnnot: *c.annotation}
+			}
+			pod.Status.Phase = corev1.PodRunning
+			if c.phase != "" {
+				pod.Status.Phase = c.phase
+			}
+			if c.deleting {
+				now := metav1.Now()
+				pod.DeletionTimestamp = &now
+				pod.Finalizers = []string{"test"}
+			}
+			r := &LANReconciler{Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(pod).Build()}
+			bindings, err := r.getLocalBindings(context.Background(), lan)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, b := range bindings {
+				if b.Pod != "ns1/pod1" {
+					t.Errorf("binding %v is of pod %v, expect ns1/pod1", b.MAC, b.Pod)
+				}
+				got = append(got, fmt.Sprintf("%v %v %v", b.Spoke, b.MAC, b.IPs))
+			}
+			if strings.Join(got, ",") != strings.Join(c.expected, ",") {
+				t.Errorf("bindings are %v, expect %v", got, c.expected)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
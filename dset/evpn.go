@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/evpn"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// newEVPNSpeaker returns the BGP EVPN speaker of this node with router ID nodeIP, and the channel of its route changes;
+// the speaker connects to each of reflectors, a list of host[:port], unless nodeIP is one of them,
+// in which case it is a route reflector accepting sessions on the port
+func newEVPNSpeaker(reflectors string, as uint32, nodeIP string) (*evpn.Speaker, chan event.GenericEvent, error) {
+	routerID, err := netip.ParseAddr(nodeIP)
+	if err != nil || !routerID.Is4() {
+		return nil, nil, fmt.Errorf("evpn requires an IPv4 node address as router id, got %q", nodeIP)
+	}
+	cfg := evpn.Config{RouterID: routerID, AS: as}
+	for _, rr := range strings.Split(reflectors, ",") {
+		host, port, err := net.SplitHostPort(rr)
+		if err != nil {
+			host, port = rr, strconv.Itoa(evpn.DefaultPort)
+		}
+		if addr, err := netip.ParseAddr(host); err == nil && addr == routerID {
+			cfg.RouteReflector = true
+			cfg.ListenAddr = net.JoinHostPort("", port)
+			continue
+		}
+		cfg.Neighbors = append(cfg.Neighbors, net.JoinHostPort(host, port))
+	}
+	if cfg.RouteReflector {
+		//route reflectors don't peer with each other, every client connects to all of them
+		cfg.Neighbors = nil
+	}
+	changes := make(chan event.GenericEvent, chanDepth)
+	cfg.OnChange = func(vni uint32) {
+		lan := &k8slan.LAN{}
+		v := int32(vni)
+		lan.Spec.VNI = &v
+		select {
+		case changes <- event.GenericEvent{Object: lan}:
+		default:
+			//dropped change is picked up by the drift interval
+		}
+	}
+	speaker, err := evpn.NewSpeaker(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return speaker, changes, nil
+}
+
+// syncEVPN advertises the VTEP and the bindings of lan on this node via the BGP EVPN speaker,
+// and adds the VTEPs and bindings of other nodes to fdb; BUM traffic is only replicated to the VTEPs
+func (r *LANReconciler) syncEVPN(ctx context.Context, lan *k8slan.LAN, fdb *interfaces.RemoteFDB) error {
+	if r.evpn == nil {
+		return fmt.Errorf("%v fdb mode requires the BGP EVPN speaker, which is enabled by --evpn-route-reflectors", k8slan.FDBModeEVPN)
+	}
+	vtep, err := interfaces.GetVTEPAddr(&lan.Spec, r.hostName)
+	if err != nil {
+		return err
+	}
+	bindings, err := r.getLocalBindings(ctx, lan)
+	if err != nil {
+		return err
+	}
+	macs := []evpn.MACIP{}
+	for _, b := range bindings {
+		mac, err := net.ParseMAC(b.MAC)
+		if err != nil {
+			continue
+		}
+		m := evpn.MACIP{MAC: mac, VTEP: vtep}
+		for _, ipStr := range b.IPs {
+			if ip, err := netip.ParseAddr(ipStr); err == nil {
+				m.IPs = append(m.IPs, ip)
+			}
+		}
+		macs = append(macs, m)
+	}
+	vni := uint32(*lan.Spec.VNI)
+	r.evpnLock.Lock()
+	if old, ok := r.evpnVNIs[lan.UID]; ok && old != vni {
+		//VNI of a live LAN is changed
+		r.evpn.SetRoutes(old, netip.Addr{}, nil)
+	}
+	r.evpnVNIs[lan.UID] = vni
+	r.evpnLock.Unlock()
+	r.evpn.SetRoutes(vni, vtep, macs)
+	vteps, remote := r.evpn.Remote(vni)
+	fdb.NoGroup = true
+	for _, v := range vteps {
+		if !slices.Contains(fdb.FloodVTEPs, v) {
+			fdb.FloodVTEPs = append(fdb.FloodVTEPs, v)
+		}
+	}
+	for _, m := range remote {
+		fdb.MACs = append(fdb.MACs, interfaces.RemoteMAC{MAC: m.MAC, VTEP: m.VTEP, IPs: m.IPs})
+	}
+	return nil
+}
+
+// withdrawEVPN withdraws the routes advertised for lan on this node, if any
+func (r *LANReconciler) withdrawEVPN(lan *k8slan.LAN) {
+	if r.evpn == nil {
+		return
+	}
+	r.evpnLock.Lock()
+	defer r.evpnLock.Unlock()
+	if vni, ok := r.evpnVNIs[lan.UID]; ok {
+		r.evpn.SetRoutes(vni, netip.Addr{}, nil)
+		delete(r.evpnVNIs, lan.UID)
+	}
+}
+
+// evpnChangeToLANs returns the LANs using EVPN with the VNI of a route change
+func (r *LANReconciler) evpnChangeToLANs(ctx context.Context, obj client.Object) []reconcile.Request {
+	change, ok := obj.(*k8slan.LAN)
+	if !ok || change.Spec.VNI == nil {
+		return nil
+	}
+	lans := &k8slan.LANList{}
+	if err := r.List(ctx, lans); err != nil {
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, lan := range lans.Items {
+		if lan.Spec.IsEVPN() && lan.Spec.VNI != nil && *lan.Spec.VNI == *change.Spec.VNI {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&lan)})
+		}
+	}
+	return reqs
+}
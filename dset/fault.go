@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// interval of retrying a FaultSchedule whose LAN doesn't exist or doesn't match
+	faultRetryInterval = 30 * time.Second
+	// max time to release the spokes of a stopped FaultSchedule
+	faultReleaseTimeout = 10 * time.Second
+)
+
+// faultRunner runs a FaultSchedule on this node
+type faultRunner struct {
+	cancel     context.CancelFunc
+	generation int64
+	// closed after the spokes are released
+	done chan struct{}
+}
+
+// FaultScheduleReconciler runs FaultSchedule on this node
+type FaultScheduleReconciler struct {
+	client.Client
+	hostName string
+	Recorder record.EventRecorder
+	lock     *sync.Mutex
+	// key is the namespaced name of the FaultSchedule
+	running map[types.NamespacedName]*faultRunner
+}
+
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=faultschedules,verbs=get;list;watch
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=faultschedules/status,verbs=get;update;patch
+
+func (r *FaultScheduleReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.Log.WithValues("faultschedule", req.NamespacedName)
+	fs := &k8slan.FaultSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, fs); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.stop(req.NamespacedName)
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	r.lock.Lock()
+	runner, isRunning := r.running[req.NamespacedName]
+	r.lock.Unlock()
+	if isRunning {
+		if runner.generation == fs.Generation {
+			return reconcile.Result{}, nil
+		}
+		//spec changed, restart
+		r.stop(req.NamespacedName)
+	}
+	lan := &k8slan.LAN{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: fs.Namespace, Name: fs.Spec.LAN}, lan); err != nil {
+		//LAN might be created later
+		return reconcile.Result{RequeueAfter: faultRetryInterval}, client.IgnoreNotFound(err)
+	}
+	if err := fs.Spec.Validate(&lan.Spec); err != nil {
+		log.Error(err, "invalid fault schedule")
+		return reconcile.Result{RequeueAfter: faultRetryInterval}, nil
+	}
+	start, err := r.recordStartTime(ctx, req.NamespacedName, fs.Generation)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to record start time, %w", err)
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	runner = &faultRunner{
+		cancel:     cancel,
+		generation: fs.Generation,
+		done:       make(chan struct{}),
+	}
+	r.lock.Lock()
+	r.running[req.NamespacedName] = runner
+	r.lock.Unlock()
+	log.Info("fault schedule started", "lan", lan.Name)
+	go r.run(runCtx, fs, lan, start, runner.done)
+	return reconcile.Result{}, nil
+}
+
+// recordStartTime returns the start time of generation of the FaultSchedule in its status,
+// now is recorded if it is not there yet; the first worker records it, and the others use it
+func (r *FaultScheduleReconciler) recordStartTime(ctx context.Context, key types.NamespacedName, generation int64) (time.Time, error) {
+	var start time.Time
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		fs := &k8slan.FaultSchedule{}
+		if err := r.Get(ctx, key, fs); err != nil {
+			return err
+		}
+		if fs.Generation != generation {
+			return fmt.Errorf("generation %d is changed to %d", generation, fs.Generation)
+		}
+		if fs.Status.ObservedGeneration == generation && fs.Status.StartTime != nil {
+			start = fs.Status.StartTime.Time
+			return nil
+		}
+		//the time is stored in seconds, all workers use the same
+		now := metav1.Now().Rfc3339Copy()
+		fs.Status.ObservedGeneration = generation
+		fs.Status.StartTime = &now
+		start = now.Time
+		return r.Status().Update(ctx, fs)
+	})
+	return start, err
+}
+
+// nextFault returns whether the spokes should be down now and how long until the next change,
+// a negative wait means never; completed is true if the schedule has ended.
+// random flaps are not aligned to the start time, down is the current state and rnd draws the delay of the next flap
+func nextFault(spec *k8slan.FaultScheduleSpec, start, now time.Time, down bool, rnd *rand.Rand) (wantDown bool, wait time.Duration, completed bool) {
+	elapsed := max(now.Sub(start), 0)
+	duration := spec.GetDuration()
+	if duration > 0 && elapsed >= duration {
+		return false, 0, true
+	}
+	downTime := spec.GetDownTime()
+	switch spec.Type {
+	case k8slan.FaultTypeDown:
+		if downTime == 0 {
+			return true, -1, false
+		}
+		if elapsed >= downTime {
+			return false, 0, true
+		}
+		return true, downTime - elapsed, false
+	case k8slan.FaultTypeFlap:
+		phase := elapsed % spec.GetInterval()
+		if phase < downTime {
+			wantDown, wait = true, downTime-phase
+		} else {
+			wantDown, wait = false, spec.GetInterval()-phase
+		}
+	default:
+		if down {
+			wantDown, wait = false, randomFaultDelay(spec, rnd)
+		} else {
+			wantDown, wait = true, downTime
+		}
+	}
+	if duration > 0 && elapsed+wait > duration {
+		wait = duration - elapsed
+	}
+	return wantDown, wait, false
+}
+
+// randomFaultDelay returns the delay of the next random flap, in (0, interval]
+func randomFaultDelay(spec *k8slan.FaultScheduleSpec, rnd *rand.Rand) time.Duration {
+	return time.Duration(rnd.Int64N(int64(spec.GetInterval()))) + 1
+}
+
+// run brings the spokes of the FaultSchedule down and up on this node from start, until ctx is done or the schedule ends;
+// the spokes are released before done is closed
+func (r *FaultScheduleReconciler) run(ctx context.Context, fs *k8slan.FaultSchedule, lan *k8slan.LAN, start time.Time, done chan struct{}) {
+	defer close(done)
+	key := client.ObjectKeyFromObject(fs)
+	log := ctrl.Log.WithValues("faultschedule", key)
+	source := "FaultSchedule/" + fs.Name
+	spokes := fs.Spec.GetSpokes(&lan.Spec)
+	h := fnv.New64a()
+	h.Write([]byte(string(fs.UID) + "/" + r.hostName))
+	rnd := rand.New(rand.NewPCG(h.Sum64(), uint64(time.Now().UnixNano())))
+	down := false
+	apply := func(ctx context.Context, wantDown, completed bool) {
+		for _, spoke := range spokes {
+			interfaces.SetSpokeFault(lan.UID, spoke, source, wantDown)
+		}
+		down = wantDown
+		//admin states of the LAN might be changed since the runner started
+		latest := lan.DeepCopy()
+		if err := r.Get(ctx, client.ObjectKeyFromObject(lan), latest); err != nil || latest.UID != lan.UID {
+			latest = lan
+		}
+		transitions, err := interfaces.UpdateLinkStates(latest, source, r.eventFunc(fs, latest))
+		if err != nil {
+			log.Error(err, "failed to update link states")
+			r.Recorder.Eventf(fs, corev1.EventTypeWarning, interfaces.ReasonLinkStateFailed, "node %v: %v", r.hostName, err)
+		}
+		if err := recordLinkTransitions(ctx, r.Client, r.hostName, client.ObjectKeyFromObject(lan), transitions); err != nil {
+			log.Error(err, "failed to record link transitions in LAN status")
+		}
+		if err := r.updateNodeStatus(ctx, key, spokes, transitions, wantDown, completed, err); err != nil {
+			log.Error(err, "failed to update fault schedule status")
+		}
+	}
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), faultReleaseTimeout)
+		defer cancel()
+		if down {
+			apply(releaseCtx, false, false)
+		}
+	}()
+	if fs.Spec.Type == k8slan.FaultTypeRandom {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(randomFaultDelay(&fs.Spec, rnd)):
+		}
+	}
+	for {
+		wantDown, wait, completed := nextFault(&fs.Spec, start, time.Now(), down, rnd)
+		if wantDown != down || completed {
+			apply(ctx, wantDown, completed)
+		}
+		if completed {
+			log.Info("fault schedule completed")
+			return
+		}
+		var after <-chan time.Time
+		if wait >= 0 {
+			after = time.After(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-after:
+		}
+	}
+}
+
+// eventFunc returns an interfaces.EventFunc records events on both fs and lan
+func (r *FaultScheduleReconciler) eventFunc(fs *k8slan.FaultSchedule, lan *k8slan.LAN) interfaces.EventFunc {
+	return func(eventType, reason, message string) {
+		r.Recorder.Eventf(lan, eventType, reason, "node %v: %v", r.hostName, message)
+		r.Recorder.Eventf(fs, eventType, reason, "node %v: %v", r.hostName, message)
+	}
+}
+
+// updateNodeStatus adds transitions of spokes of the FaultSchedule to the status of this node,
+// nothing is added if none of its spokes is on this node
+func (r *FaultScheduleReconciler) updateNodeStatus(ctx context.Context, key types.NamespacedName, spokes []string,
+	transitions []interfaces.LinkTransition, down, completed bool, lastErr error) error {
+	transitions = slices.DeleteFunc(slices.Clone(transitions), func(t interfaces.LinkTransition) bool {
+		return !slices.Contains(spokes, t.Spoke)
+	})
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		fs := &k8slan.FaultSchedule{}
+		if err := r.Get(ctx, key, fs); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		existing := fs.Status.GetNode(r.hostName)
+		if existing == nil && len(transitions) == 0 && lastErr == nil {
+			return nil
+		}
+		status := k8slan.NodeFaultScheduleStatus{Node: r.hostName}
+		if existing != nil {
+			status = *existing.DeepCopy()
+		}
+		for _, t := range transitions {
+			status.Down = slices.DeleteFunc(status.Down, func(s string) bool { return s == t.Spoke })
+			if t.State == k8slan.AdminStateDown {
+				status.Down = append(status.Down, t.Spoke)
+			}
+		}
+		if !down {
+			status.Down = nil
+		}
+		status.Transitions += int32(len(transitions))
+		if len(transitions) > 0 {
+			status.LastTransitionTime = &metav1.Time{Time: time.Now()}
+		}
+		status.Completed = completed
+		status.Message = ""
+		if lastErr != nil {
+			status.Message = lastErr.Error()
+		}
+		if existing != nil && equality.Semantic.DeepEqual(existing, &status) {
+			return nil
+		}
+		fs.Status.SetNode(status)
+		return r.Status().Update(ctx, fs)
+	})
+}
+
+// stop stops the runner of a FaultSchedule, and waits until its spokes are released
+func (r *FaultScheduleReconciler) stop(key types.NamespacedName) {
+	r.lock.Lock()
+	runner, ok := r.running[key]
+	delete(r.running, key)
+	r.lock.Unlock()
+	if !ok {
+		return
+	}
+	runner.cancel()
+	<-runner.done
+}
+
+func (r *FaultScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&k8slan.FaultSchedule{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
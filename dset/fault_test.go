@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecordStartTime(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := k8slan.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "ns1", Name: "fs1"}
+	fs := &k8slan.FaultSchedule{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace, Generation: 1}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(fs).WithStatusSubresource(fs).Build()
+	r := &FaultScheduleReconciler{Client: c}
+	ctx := context.Background()
+	start, err := r.recordStartTime(ctx, key, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//another worker, or a restarted one, uses the recorded start time
+	time.Sleep(time.Second)
+	if again, err := r.recordStartTime(ctx, key, 1); err != nil || !again.Equal(start) {
+		t.Errorf("start time of the same generation is %v, expect %v, %v", again, start, err)
+	}
+	//the schedule restarts when the spec is changed
+	if err := c.Get(ctx, key, fs); err != nil {
+		t.Fatal(err)
+	}
+	fs.Generation = 2
+	if err := c.Update(ctx, fs); err != nil {
+		t.Fatal(err)
+	}
+	restart, err := r.recordStartTime(ctx, key, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restart.After(start) {
+		t.Errorf("start time of a new generation %v is not after %v", restart, start)
+	}
+	if err := c.Get(ctx, key, fs); err != nil {
+		t.Fatal(err)
+	}
+	if fs.Status.ObservedGeneration != 2 || fs.Status.StartTime == nil || !fs.Status.StartTime.Time.Equal(restart) {
+		t.Errorf("unexpected status %+v", fs.Status)
+	}
+	if _, err := r.recordStartTime(ctx, key, 1); err == nil {
+		t.Error("expect error for a stale generation")
+	}
+}
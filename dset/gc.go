@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// garbageCollector removes LAN NS and host interfaces left behind on this node,
+// e.g. when a LAN is removed while the node is down, or its finalizer is removed by force
+type garbageCollector struct {
+	client.Reader
+	recorder record.EventRecorder
+	hostName string
+	// only report orphans without removing them
+	dryRun bool
+	// 0 means only collect once at startup
+	interval time.Duration
+}
+
+// Start implements manager.Runnable
+func (gc *garbageCollector) Start(ctx context.Context) error {
+	for {
+		gc.collect(ctx)
+		if gc.interval <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(gc.interval):
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every node collects its own garbage
+func (gc *garbageCollector) NeedLeaderElection() bool {
+	return false
+}
+
+// nodeRef returns the reference of this node, to record events on
+func (gc *garbageCollector) nodeRef() *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind: "Node",
+		Name: gc.hostName,
+	}
+}
+
+func (gc *garbageCollector) collect(ctx context.Context) {
+	log := ctrl.Log.WithName("gc")
+	orphans, err := interfaces.FindOrphans(func() ([]*k8slan.LAN, error) {
+		lanList := &k8slan.LANList{}
+		if err := gc.List(ctx, lanList); err != nil {
+			return nil, fmt.Errorf("failed to list LANs, %w", err)
+		}
+		//LAN being removed is still a valid owner, its NS is removed by LANReconciler
+		lans := []*k8slan.LAN{}
+		for i := range lanList.Items {
+			lans = append(lans, &lanList.Items[i])
+		}
+		return lans, nil
+	})
+	if err != nil {
+		log.Error(err, "failed to find orphans")
+		gc.recorder.Eventf(gc.nodeRef(), corev1.EventTypeWarning, interfaces.ReasonOrphanGCFailed,
+			"failed to find orphaned LAN namespaces and interfaces, %v", err)
+		return
+	}
+	for _, o := range orphans {
+		if gc.dryRun {
+			log.Info("found orphan", "orphan", o)
+			gc.recorder.Eventf(gc.nodeRef(), corev1.EventTypeNormal, interfaces.ReasonOrphanFound,
+				"found orphaned %v not backed by any LAN, not removed in dry-run mode", o)
+			continue
+		}
+		err := interfaces.RemoveOrphan(o)
+		if errors.Is(err, interfaces.ErrNotOrphan) {
+			log.Info("skipped orphan taken by a LAN", "orphan", o)
+			continue
+		}
+		if err != nil {
+			log.Error(err, "failed to remove orphan", "orphan", o)
+			gc.recorder.Eventf(gc.nodeRef(), corev1.EventTypeWarning, interfaces.ReasonOrphanGCFailed,
+				"failed to remove orphaned %v, %v", o, err)
+			continue
+		}
+		log.Info("removed orphan", "orphan", o)
+		gc.recorder.Eventf(gc.nodeRef(), corev1.EventTypeNormal, interfaces.ReasonOrphanRemoved,
+			"removed orphaned %v not backed by any LAN", o)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// withTokenHeader is filters.WithAuthenticationAndAuthorization that also accepts the bearer token in dataplane.TokenHeader,
+// which is used by kubectl-lan via the pods/proxy subresource
+func withTokenHeader(c *rest.Config, httpClient *http.Client) (metricsserver.Filter, error) {
+	filter, err := filters.WithAuthenticationAndAuthorization(c, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return func(log logr.Logger, handler http.Handler) (http.Handler, error) {
+		h, err := filter(log, handler)
+		if err != nil {
+			return nil, err
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if token := req.Header.Get(dataplane.TokenHeader); token != "" {
+				req.Header.Del(dataplane.TokenHeader)
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			h.ServeHTTP(w, req)
+		}), nil
+	}, nil
+}
+
+// lanInspector serves dataplane state of LANs on this node
+type lanInspector struct {
+	client.Reader
+	hostName string
+}
+
+func (li *lanInspector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	fields := strings.Split(strings.TrimPrefix(req.URL.Path, dataplane.URLPathPrefix), "/")
+	if req.Method != http.MethodGet || len(fields) != 3 {
+		http.NotFound(w, req)
+		return
+	}
+	lan := &k8slan.LAN{}
+	err := li.Get(req.Context(), types.NamespacedName{Namespace: fields[0], Name: fields[1]}, lan)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, req)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var result any
+	switch fields[2] {
+	case dataplane.FDBPath:
+		result, err = interfaces.GetFDB(&lan.Spec)
+	case dataplane.StatePath:
+		result, err = interfaces.GetLANState(&lan.Spec, li.hostName)
+	case dataplane.PlanPath:
+		result, err = getDriftSteps(lan, li.hostName)
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// getDriftSteps returns the steps to repair drift of lan on this node without applying them
+func getDriftSteps(lan *k8slan.LAN, hostName string) ([]string, error) {
+	plan, err := interfaces.PlanDrift(lan, hostName)
+	if err != nil || plan == nil {
+		return []string{}, err
+	}
+	return plan.Strings(), nil
+}
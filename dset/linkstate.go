@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"slices"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// recordLinkTransitions adds link state transitions of spokes on hostName to the status of the LAN of key,
+// spokes no longer in the LAN are removed from it
+func recordLinkTransitions(ctx context.Context, c client.Client, hostName string, key types.NamespacedName, transitions []interfaces.LinkTransition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+	now := metav1.Now()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lan := &k8slan.LAN{}
+		if err := c.Get(ctx, key, lan); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		states := k8slan.NodeLinkStates{Node: hostName}
+		if existing := lan.Status.GetNodeLinkStates(hostName); existing != nil {
+			states = *existing.DeepCopy()
+		}
+		for _, t := range transitions {
+			s := states.GetSpoke(t.Spoke)
+			if s == nil {
+				states.Spokes = append(states.Spokes, k8slan.SpokeLinkState{Spoke: t.Spoke})
+				s = &states.Spokes[len(states.Spokes)-1]
+			}
+			s.State = t.State
+			s.Reason = t.Reason
+			s.Transitions++
+			s.LastTransitionTime = &now
+		}
+		states.Spokes = slices.DeleteFunc(states.Spokes, func(s k8slan.SpokeLinkState) bool {
+			return !slices.Contains(lan.Spec.SpokeList, s.Spoke)
+		})
+		lan.Status.SetNodeLinkStates(states)
+		return c.Status().Update(ctx, lan)
+	})
+}
@@ -4,21 +4,40 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/hujun-open/k8slan/api/v1beta1"
 	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
 	"github.com/hujun-open/k8slan/pkg/deviceplugin"
+	"github.com/hujun-open/k8slan/pkg/evpn"
 	"github.com/hujun-open/k8slan/pkg/interfaces"
 	"github.com/kubevirt/device-plugin-manager/pkg/dpm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // ============================================================================
@@ -28,11 +47,22 @@ import (
 type LANReconciler struct {
 	client.Client
 	hostName     string
-	DPAddChan    chan *v1beta1.LANSpec
-	DPRemoveChan chan *v1beta1.LANSpec
+	Recorder     record.EventRecorder
+	DPAddChan    chan *v1beta1.LAN
+	DPRemoveChan chan *v1beta1.LAN
+	// interval of checking and repairing drift of interfaces, 0 means only on LAN changes
+	driftInterval time.Duration
+	// BGP EVPN speaker and its route changes, nil if not enabled
+	evpn       *evpn.Speaker
+	evpnEvents chan event.GenericEvent
+	evpnLock   *sync.Mutex
+	// VNI advertised via evpn, keyed by LAN UID
+	evpnVNIs map[types.UID]uint32
 }
 
 // +kubebuilder:rbac:groups=lan.k8slan.io,resources=lans,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
 
 func makeFinalizerPatch(in v1beta1.LAN, fin string) client.Patch {
 	p := &v1beta1.LAN{}
@@ -77,9 +107,25 @@ func (r *LANReconciler) Reconcile(ctx context.Context, req reconcile.Request) (r
 		if controllerutil.ContainsFinalizer(lan, myFinalizerName) {
 			// our finalizer is present, so let's handle any external dependency
 			log.Info("removing lan", "name", lan.Name)
-			interfaces.Remove(*lan.Spec.NS)
-			spec := lan.Spec
-			r.DPRemoveChan <- &spec
+			//spokes of other LANs bound to this one go back home before its namespace is removed
+			if err := r.releaseSpokes(ctx, lan); err != nil {
+				r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonSpokeBindFailed,
+					"node %v: %v", r.hostName, err)
+			}
+			removed, err := interfaces.Remove(*lan.Spec.NS)
+			if err != nil {
+				r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonRemoveFailed,
+					"node %v: failed to remove namespace %v, %v", r.hostName, *lan.Spec.NS, err)
+			} else if removed {
+				r.Recorder.Eventf(lan, corev1.EventTypeNormal, interfaces.ReasonRemoved,
+					"node %v: removed namespace %v", r.hostName, *lan.Spec.NS)
+			}
+			if err := r.removeEncryption(ctx, lan, interfaces.IPsecReqID(lan.UID)); err != nil {
+				r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonEncryptionFailed,
+					"node %v: failed to remove encryption, %v", r.hostName, err)
+			}
+			r.withdrawEVPN(lan)
+			r.DPRemoveChan <- lan.DeepCopy()
 			// remove our finalizer from the list and update it.
 			// patch := client.MergeFrom(lan.DeepCopy())
 			controllerutil.RemoveFinalizer(lan, myFinalizerName)
@@ -100,16 +146,86 @@ func (r *LANReconciler) Reconcile(ctx context.Context, req reconcile.Request) (r
 	// 	log.Error(err, "failed to ensure lan")
 	// 	return ctrl.Result{}, nil
 	// }
-	spec := lan.Spec
-	r.DPAddChan <- &spec
+	r.DPAddChan <- lan.DeepCopy()
+	//admin states could be changed on a live LAN, update them before drift repair brings up spokes no longer held down
+	transitions, err := interfaces.UpdateLinkStates(lan, interfaces.LinkStateReasonAdmin, r.eventFunc(lan))
+	if err != nil {
+		log.Error(err, "failed to update link states")
+		r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonLinkStateFailed,
+			"node %v: failed to update link states, %v", r.hostName, err)
+	}
+	if err := recordLinkTransitions(ctx, r.Client, r.hostName, req.NamespacedName, transitions); err != nil {
+		log.Error(err, "failed to record link transitions")
+	}
+	//repair interfaces changed out of band, this also applies live changes of impairments, mirrors and transparency
+	plan, err := interfaces.RepairDrift(lan, r.hostName, r.eventFunc(lan))
+	if err != nil {
+		log.Error(err, "failed to repair drift")
+		r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonDriftRepairFailed,
+			"node %v: failed to repair drift, %v", r.hostName, err)
+	} else if plan != nil && !plan.Empty() {
+		r.Recorder.Eventf(lan, corev1.EventTypeNormal, interfaces.ReasonDriftRepaired,
+			"node %v: repaired drift, %v", r.hostName, plan)
+	}
+	if err := r.reconcileEndpoints(ctx, lan); err != nil {
+		log.Error(err, "failed to reconcile remote fdb")
+		r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonStaticFDBFailed,
+			"node %v: %v", r.hostName, err)
+	}
+	if err := r.reconcilePeerings(ctx, lan); err != nil {
+		log.Error(err, "failed to reconcile peerings")
+		r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonPeeringFailed,
+			"node %v: %v", r.hostName, err)
+	}
+	if err := r.reconcileSpokeBindings(ctx, lan); err != nil {
+		log.Error(err, "failed to reconcile spoke bindings")
+		r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonSpokeBindFailed,
+			"node %v: %v", r.hostName, err)
+	}
+	requeueAfter := r.driftInterval
+	untilRotation, err := r.reconcileEncryption(ctx, lan)
+	if err != nil {
+		log.Error(err, "failed to reconcile encryption")
+		r.Recorder.Eventf(lan, corev1.EventTypeWarning, interfaces.ReasonEncryptionFailed,
+			"node %v: %v", r.hostName, err)
+	} else if untilRotation > 0 && (requeueAfter == 0 || untilRotation < requeueAfter) {
+		requeueAfter = untilRotation
+	}
+	untilCheck, err := r.reconcileRemoteVTEPs(ctx, lan)
+	if err != nil {
+		log.Error(err, "failed to check remote vteps")
+	} else if untilCheck > 0 && (requeueAfter == 0 || untilCheck < requeueAfter) {
+		requeueAfter = untilCheck
+	}
 	log.Info("lan created")
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// eventFunc returns an interfaces.EventFunc records events on lan
+func (r *LANReconciler) eventFunc(lan *v1beta1.LAN) interfaces.EventFunc {
+	return func(eventType, reason, message string) {
+		r.Recorder.Eventf(lan, eventType, reason, "node %v: %v", r.hostName, message)
+	}
 }
 
 func (r *LANReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&k8slan.LAN{}).
-		Complete(r)
+		//encrypted LANs sharing the tunnel port of another LAN
+		Watches(&k8slan.LAN{}, handler.EnqueueRequestsFromMapFunc(r.lanToEncryptedLANs)).
+		//key secrets of other nodes
+		Owns(&corev1.Secret{}, builder.MatchEveryOwner).
+		//LANEndpoints of other nodes, and pods of this node for static FDB
+		Owns(&k8slan.LANEndpoint{}, builder.MatchEveryOwner).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.podToLANs)).
+		//peerings of which this node might be the gateway
+		Watches(&k8slan.LANPeering{}, handler.EnqueueRequestsFromMapFunc(r.peeringToLAN)).
+		Watches(&k8slan.SpokeBinding{}, handler.EnqueueRequestsFromMapFunc(r.spokeBindingToLAN))
+	if r.evpnEvents != nil {
+		//routes of other nodes
+		b = b.WatchesRawSource(source.Channel(r.evpnEvents, handler.EnqueueRequestsFromMapFunc(r.evpnChangeToLANs)))
+	}
+	return b.Complete(r)
 }
 
 // ============================================================================
@@ -120,36 +236,162 @@ const (
 )
 
 func main() {
+	var metricsAddr, captureDir, netnsDir string
+	var gcInterval time.Duration
+	var gcDryRun bool
+	var captureMaxFileSize int64
+	var driftInterval time.Duration
+	var evpnReflectors string
+	var evpnAS uint
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8444", "The address the metrics and packet capture file endpoint binds to, "+
+		"served via HTTPS with authn/authz. Use 0 to disable it.")
+	flag.StringVar(&captureDir, "capture-dir", "/var/lib/k8slan/captures", "The directory to store packet capture files.")
+	flag.Int64Var(&captureMaxFileSize, "capture-max-file-size", 100<<20, "Max size in bytes of a packet capture file, "+
+		"a capture stops before its file exceeds it; a PacketCapture could set a smaller maxFileSize.")
+	flag.DurationVar(&gcInterval, "gc-interval", 10*time.Minute, "Interval of removing orphaned LAN namespaces and interfaces on this node, "+
+		"which are not backed by any LAN. Use 0 to only remove them at startup.")
+	flag.BoolVar(&gcDryRun, "gc-dry-run", false, "Only report orphaned LAN namespaces and interfaces via events without removing them.")
+	flag.DurationVar(&driftInterval, "drift-interval", 5*time.Minute, "Interval of checking and repairing drift of LAN interfaces on this node, "+
+		"e.g. a vxlan interface changed out of band. Use 0 to only check on LAN changes.")
+	flag.StringVar(&evpnReflectors, "evpn-route-reflectors", "", "Comma separated addresses as host[:port] of the BGP route reflectors "+
+		"the EVPN speaker connects to, it enables the speaker required by LANs with fdbMode evpn; "+
+		"a worker whose NODE_IP is one of them runs its speaker as a route reflector listening on the port, so no external router is needed.")
+	flag.UintVar(&evpnAS, "evpn-as", evpn.DefaultAS, "AS of the EVPN speaker and the route reflectors, only iBGP is supported.")
+	flag.StringVar(&netnsDir, "netns-dir", interfaces.DefaultNSRunDirFromEnv(), "The directory where LAN namespaces are mounted, "+
+		"the default could be overridden by env "+interfaces.NSRunDirEnv+"; it should be different from /run/netns used by ip netns.")
+	flag.Parse()
+	interfaces.SetNSRunDir(netnsDir)
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 	hostName, err := os.Hostname()
 	if err != nil {
 		panic(err)
 	}
+	if err := os.MkdirAll(captureDir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create capture dir: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Create scheme and register custom resource types
 	scheme := runtime.NewScheme()
 	k8slan.AddToScheme(scheme)
+	clientgoscheme.AddToScheme(scheme)
 
+	captureReconciler := &PacketCaptureReconciler{
+		hostName:    hostName,
+		captureDir:  captureDir,
+		maxFileSize: captureMaxFileSize,
+		lock:        new(sync.Mutex),
+		running:     make(map[types.NamespacedName]context.CancelFunc),
+	}
+	inspector := &lanInspector{hostName: hostName}
+	if metricsAddr != "0" {
+		_, port, err := net.SplitHostPort(metricsAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid metrics bind address: %v\n", err)
+			os.Exit(1)
+		}
+		//NODE_IP is set via downward API
+		nodeAddr := os.Getenv("NODE_IP")
+		if nodeAddr == "" {
+			nodeAddr = hostName
+		}
+		captureReconciler.urlPrefix = "https://" + net.JoinHostPort(nodeAddr, port)
+	}
+	//only cache key secrets of LANs, and pods of this node
+	keySecretSelector, err := labels.Parse(k8slan.LabelLAN)
+	if err != nil {
+		panic(err)
+	}
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Secret{}: {Label: keySecretSelector},
+				&corev1.Pod{}:    {Field: fields.OneTermEqualSelector("spec.nodeName", hostName)},
+			},
+		},
+		Metrics: metricsserver.Options{
+			BindAddress:    metricsAddr,
+			SecureServing:  true,
+			FilterProvider: withTokenHeader,
+			ExtraHandlers: map[string]http.Handler{
+				captureURLPath:          captureReconciler.captureFileHandler(),
+				dataplane.URLPathPrefix: inspector,
+			},
+		},
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "unable to start manager: %v\n", err)
 		os.Exit(1)
 	}
 	reconciler := &LANReconciler{
-		Client:       mgr.GetClient(),
-		hostName:     hostName,
-		DPAddChan:    make(chan *k8slan.LANSpec, chanDepth),
-		DPRemoveChan: make(chan *k8slan.LANSpec, chanDepth),
+		Client:        mgr.GetClient(),
+		hostName:      hostName,
+		Recorder:      mgr.GetEventRecorderFor("k8slan-ds"),
+		DPAddChan:     make(chan *k8slan.LAN, chanDepth),
+		DPRemoveChan:  make(chan *k8slan.LAN, chanDepth),
+		driftInterval: driftInterval,
+		evpnLock:      new(sync.Mutex),
+		evpnVNIs:      make(map[types.UID]uint32),
+	}
+	if evpnReflectors != "" {
+		reconciler.evpn, reconciler.evpnEvents, err = newEVPNSpeaker(evpnReflectors, uint32(evpnAS), os.Getenv("NODE_IP"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to create evpn speaker: %v\n", err)
+			os.Exit(1)
+		}
+		if err = mgr.Add(reconciler.evpn); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to add evpn speaker: %v\n", err)
+			os.Exit(1)
+		}
 	}
 	if err = reconciler.SetupWithManager(mgr); err != nil {
 		fmt.Fprintf(os.Stderr, "unable to create controller: %v\n", err)
 		os.Exit(1)
 	}
+	metrics.Registry.MustRegister(&lanCollector{Reader: mgr.GetClient()})
+	captureReconciler.Client = mgr.GetClient()
+	inspector.Reader = mgr.GetClient()
+	if err = captureReconciler.SetupWithManager(mgr); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create packet capture controller: %v\n", err)
+		os.Exit(1)
+	}
+	probeReconciler := &LANProbeReconciler{
+		Client:   mgr.GetClient(),
+		hostName: hostName,
+		lock:     new(sync.Mutex),
+		running:  make(map[types.NamespacedName]*probeRunner),
+	}
+	if err = probeReconciler.SetupWithManager(mgr); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create LAN probe controller: %v\n", err)
+		os.Exit(1)
+	}
+	faultReconciler := &FaultScheduleReconciler{
+		Client:   mgr.GetClient(),
+		hostName: hostName,
+		Recorder: reconciler.Recorder,
+		lock:     new(sync.Mutex),
+		running:  make(map[types.NamespacedName]*faultRunner),
+	}
+	if err = faultReconciler.SetupWithManager(mgr); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create fault schedule controller: %v\n", err)
+		os.Exit(1)
+	}
+	gc := &garbageCollector{
+		Reader:   mgr.GetClient(),
+		recorder: reconciler.Recorder,
+		hostName: hostName,
+		dryRun:   gcDryRun,
+		interval: gcInterval,
+	}
+	if err = mgr.Add(gc); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to add garbage collector: %v\n", err)
+		os.Exit(1)
+	}
 	//create device plugin
 	mainNsPath := deviceplugin.GetMainThreadNetNsPath()
-	manager := dpm.NewManager(deviceplugin.NewMacvtapLister(mainNsPath, reconciler.DPAddChan, reconciler.DPRemoveChan))
+	manager := dpm.NewManager(deviceplugin.NewMacvtapLister(mainNsPath, reconciler.DPAddChan, reconciler.DPRemoveChan,
+		reconciler.Recorder, mgr.GetAPIReader()))
 	go manager.Run()
 
 	//start controller
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// timeout of listing LANs when collecting metrics
+	metricsListTimeout = 5 * time.Second
+)
+
+var (
+	lanLabels  = []string{"namespace", "lan"}
+	linkLabels = []string{"namespace", "lan", "interface", "role", "spoke"}
+)
+
+type linkCounterDesc struct {
+	desc *prometheus.Desc
+	get  func(s *interfaces.LinkStats) uint64
+}
+
+func newLinkCounterDesc(name, help string, get func(s *interfaces.LinkStats) uint64) linkCounterDesc {
+	return linkCounterDesc{
+		desc: prometheus.NewDesc("k8slan_interface_"+name, help, linkLabels, nil),
+		get:  get,
+	}
+}
+
+var (
+	linkCounters = []linkCounterDesc{
+		newLinkCounterDesc("rx_bytes_total", "Bytes received by the interface in the LAN namespace",
+			func(s *interfaces.LinkStats) uint64 { return s.RxBytes }),
+		newLinkCounterDesc("tx_bytes_total", "Bytes sent by the interface in the LAN namespace",
+			func(s *interfaces.LinkStats) uint64 { return s.TxBytes }),
+		newLinkCounterDesc("rx_packets_total", "Packets received by the interface in the LAN namespace",
+			func(s *interfaces.LinkStats) uint64 { return s.RxPackets }),
+		newLinkCounterDesc("tx_packets_total", "Packets sent by the interface in the LAN namespace",
+			func(s *interfaces.LinkStats) uint64 { return s.TxPackets }),
+		newLinkCounterDesc("rx_dropped_total", "Received packets dropped by the interface in the LAN namespace",
+			func(s *interfaces.LinkStats) uint64 { return s.RxDropped }),
+		newLinkCounterDesc("tx_dropped_total", "Sent packets dropped by the interface in the LAN namespace",
+			func(s *interfaces.LinkStats) uint64 { return s.TxDropped }),
+		newLinkCounterDesc("rx_errors_total", "Receive errors of the interface in the LAN namespace",
+			func(s *interfaces.LinkStats) uint64 { return s.RxErrors }),
+		newLinkCounterDesc("tx_errors_total", "Transmit errors of the interface in the LAN namespace",
+			func(s *interfaces.LinkStats) uint64 { return s.TxErrors }),
+	}
+	fdbEntriesDesc = prometheus.NewDesc("k8slan_fdb_entries",
+		"Number of entries in the bridge FDB of the LAN", lanLabels, nil)
+	learnedMACsDesc = prometheus.NewDesc("k8slan_fdb_learned_macs",
+		"Number of MAC addresses learned by the bridge of the LAN", lanLabels, nil)
+)
+
+// lanCollector collects statistics of LANs on this node via netlink when scraped
+type lanCollector struct {
+	client.Reader
+	// returns statistics of a LAN on this node, interfaces.GetLANStats if nil
+	getStats func(lan *k8slan.LANSpec) (*interfaces.LANStats, error)
+}
+
+func (c *lanCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, lc := range linkCounters {
+		ch <- lc.desc
+	}
+	ch <- fdbEntriesDesc
+	ch <- learnedMACsDesc
+}
+
+func (c *lanCollector) Collect(ch chan<- prometheus.Metric) {
+	log := ctrl.Log.WithName("metrics")
+	ctx, cancel := context.WithTimeout(context.Background(), metricsListTimeout)
+	defer cancel()
+	lanList := &k8slan.LANList{}
+	if err := c.List(ctx, lanList); err != nil {
+		log.Error(err, "failed to list LANs")
+		return
+	}
+	getStats := c.getStats
+	if getStats == nil {
+		getStats = interfaces.GetLANStats
+	}
+	for _, lan := range lanList.Items {
+		stats, err := getStats(&lan.Spec)
+		if err != nil {
+			log.Error(err, "failed to get LAN statistics", "lan", client.ObjectKeyFromObject(&lan))
+			continue
+		}
+		if stats == nil {
+			//LAN doesn't exist on this node
+			continue
+		}
+		for i := range stats.Links {
+			s := &stats.Links[i]
+			for _, lc := range linkCounters {
+				ch <- prometheus.MustNewConstMetric(lc.desc, prometheus.CounterValue, float64(lc.get(s)),
+					lan.Namespace, lan.Name, s.Name, s.Role, s.Spoke)
+			}
+		}
+		if lan.Spec.IsP2P() {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(fdbEntriesDesc, prometheus.GaugeValue, float64(stats.FDBEntries), lan.Namespace, lan.Name)
+		ch <- prometheus.MustNewConstMetric(learnedMACsDesc, prometheus.GaugeValue, float64(stats.LearnedMACs), lan.Namespace, lan.Name)
+	}
+}
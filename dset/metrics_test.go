@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vishvananda/netlink"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLANCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := k8slan.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	str := func(s string) *string { return &s }
+	lan1 := &k8slan.LAN{ObjectMeta: metav1.ObjectMeta{Name: "lan1", Namespace: "ns1"}, Spec: k8slan.LANSpec{NS: str("lan1")}}
+	lan2 := &k8slan.LAN{ObjectMeta: metav1.ObjectMeta{Name: "lan2", Namespace: "ns1"}, Spec: k8slan.LANSpec{NS: str("lan2")}}
+	c := &lanCollector{
+		Reader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(lan1, lan2).Build(),
+		getStats: func(lan *k8slan.LANSpec) (*interfaces.LANStats, error) {
+			//lan2 is not on this node
+			if *lan.NS != "lan1" {
+				return nil, nil
+			}
+			return &interfaces.LANStats{
+				Links: []interfaces.LinkStats{
+					{Name: "vx-lan1", Role: dataplane.RoleVxLAN, LinkStatistics: netlink.LinkStatistics{RxBytes: 100, TxBytes: 200}},
+					{Name: "lan1s1p", Role: dataplane.RolePeer, Spoke: "lan1s1", LinkStatistics: netlink.LinkStatistics{RxBytes: 300}},
+				},
+				FDBEntries:  3,
+				LearnedMACs: 2,
+			}, nil
+		},
+	}
+	expected := `
+# HELP k8slan_fdb_entries Number of entries in the bridge FDB of the LAN
+# TYPE k8slan_fdb_entries gauge
+k8slan_fdb_entries{lan="lan1",namespace="ns1"} 3
+# HELP k8slan_fdb_learned_macs Number of MAC addresses learned by the bridge of the LAN
+# TYPE k8slan_fdb_learned_macs gauge
+k8slan_fdb_learned_macs{lan="lan1",namespace="ns1"} 2
+# HELP k8slan_interface_rx_bytes_total Bytes received by the interface in the LAN namespace
+# TYPE k8slan_interface_rx_bytes_total counter
+k8slan_interface_rx_bytes_total{interface="lan1s1p",lan="lan1",namespace="ns1",role="peer",spoke="lan1s1"} 300
+k8slan_interface_rx_bytes_total{interface="vx-lan1",lan="lan1",namespace="ns1",role="vxlan",spoke=""} 100
+# HELP k8slan_interface_tx_bytes_total Bytes sent by the interface in the LAN namespace
+# TYPE k8slan_interface_tx_bytes_total counter
+k8slan_interface_tx_bytes_total{interface="lan1s1p",lan="lan1",namespace="ns1",role="peer",spoke="lan1s1"} 0
+k8slan_interface_tx_bytes_total{interface="vx-lan1",lan="lan1",namespace="ns1",role="vxlan",spoke=""} 200
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"k8slan_fdb_entries", "k8slan_fdb_learned_macs", "k8slan_interface_rx_bytes_total", "k8slan_interface_tx_bytes_total"); err != nil {
+		t.Error(err)
+	}
+	//8 counters for each of the 2 links, and 2 FDB gauges
+	if n := testutil.CollectAndCount(c); n != 8*2+2 {
+		t.Errorf("collected %v metrics", n)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=lanpeerings,verbs=get;list;watch
+
+// reconcilePeerings creates the vxlan interfaces to remote gateways of lan, if this node is the gateway of its peerings
+func (r *LANReconciler) reconcilePeerings(ctx context.Context, lan *k8slan.LAN) error {
+	list := &k8slan.LANPeeringList{}
+	if err := r.List(ctx, list, client.InNamespace(lan.Namespace)); err != nil {
+		return fmt.Errorf("failed to list peerings, %w", err)
+	}
+	peers := []interfaces.PeerLink{}
+	for _, peering := range list.Items {
+		if peering.Spec.LAN != lan.Name || peering.Spec.GatewayNode != r.hostName || !peering.IsReady() {
+			continue
+		}
+		remote, err := netip.ParseAddr(peering.Status.RemoteGatewayAddress)
+		if err != nil {
+			return fmt.Errorf("invalid remote gateway address %v of peering %v, %w", peering.Status.RemoteGatewayAddress, peering.Name, err)
+		}
+		peers = append(peers, interfaces.PeerLink{
+			Peering: peering.Name,
+			VNI:     int(peering.Spec.GetVNI(&lan.Spec)),
+			Port:    int(peering.Spec.GetPort()),
+			Remote:  remote,
+		})
+	}
+	return interfaces.UpdatePeerings(lan, r.hostName, peers, r.eventFunc(lan))
+}
+
+// peeringToLAN returns the LAN of a LANPeering, and the encrypted LANs sharing its port if this node is the gateway
+func (r *LANReconciler) peeringToLAN(ctx context.Context, obj client.Object) []reconcile.Request {
+	peering, ok := obj.(*k8slan.LANPeering)
+	if !ok {
+		return nil
+	}
+	reqs := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: peering.Namespace, Name: peering.Spec.LAN}}}
+	if peering.Spec.GatewayNode == r.hostName {
+		reqs = append(reqs, r.encryptedLANsOnPort(ctx, peering.Spec.GetPort(), "")...)
+	}
+	return reqs
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"github.com/hujun-open/k8slan/pkg/probe"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// probeRunner runs a LANProbe on this node
+type probeRunner struct {
+	cancel     context.CancelFunc
+	generation int64
+	// spec of the probed LAN
+	lan k8slan.LANSpec
+}
+
+// LANProbeReconciler runs LANProbe on this node
+type LANProbeReconciler struct {
+	client.Client
+	hostName string
+	lock     *sync.Mutex
+	// key is the namespaced name of the LANProbe
+	running map[types.NamespacedName]*probeRunner
+}
+
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=lanprobes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=lanprobes/status,verbs=get;update;patch
+
+func (r *LANProbeReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := ctrl.Log.WithValues("lanprobe", req.NamespacedName)
+	lp := &k8slan.LANProbe{}
+	if err := r.Get(ctx, req.NamespacedName, lp); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.stop(req.NamespacedName)
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	r.lock.Lock()
+	runner, isRunning := r.running[req.NamespacedName]
+	r.lock.Unlock()
+	if isRunning {
+		if runner.generation == lp.Generation {
+			return reconcile.Result{}, nil
+		}
+		//spec changed, restart
+		r.stop(req.NamespacedName)
+	}
+	lan := &k8slan.LAN{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: lp.Namespace, Name: lp.Spec.LAN}, lan); err != nil {
+		//LAN might be created later
+		return reconcile.Result{RequeueAfter: lp.Spec.GetInterval()}, client.IgnoreNotFound(err)
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	r.lock.Lock()
+	r.running[req.NamespacedName] = &probeRunner{
+		cancel:     cancel,
+		generation: lp.Generation,
+		lan:        lan.Spec,
+	}
+	r.lock.Unlock()
+	log.Info("probe started", "lan", lan.Name)
+	go r.run(runCtx, req.NamespacedName, lp.Spec, lan.Spec, getProbeID(lp))
+	return reconcile.Result{}, nil
+}
+
+// getProbeID returns the id in probe frames of lp
+func getProbeID(lp *k8slan.LANProbe) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(lp.UID))
+	return h.Sum32()
+}
+
+// run probes other nodes periodically and reports the result in status, until ctx is done
+func (r *LANProbeReconciler) run(ctx context.Context, key types.NamespacedName, spec k8slan.LANProbeSpec, lan k8slan.LANSpec, probeID uint32) {
+	log := ctrl.Log.WithValues("lanprobe", key)
+	var prober *probe.Prober
+	var serveCancel context.CancelFunc
+	closeProber := func() {
+		if prober != nil {
+			serveCancel()
+			prober.Close()
+			prober = nil
+		}
+	}
+	defer closeProber()
+	ticker := time.NewTicker(spec.GetInterval())
+	defer ticker.Stop()
+	for {
+		if _, err := os.Stat(interfaces.GetNSPath(*lan.NS)); err != nil {
+			//LAN doesn't exist on this node
+			closeProber()
+			if err := r.removeNodeStatus(ctx, key); err != nil {
+				log.Error(err, "failed to remove probe status")
+			}
+		} else {
+			var err error
+			if prober == nil {
+				prober, serveCancel, err = r.startProber(ctx, &lan, probeID)
+			}
+			nodeStatus := k8slan.NodeProbeStatus{
+				Node:          r.hostName,
+				LastProbeTime: &metav1.Time{Time: time.Now()},
+			}
+			var results map[string]*probe.PeerResult
+			if err == nil {
+				results, err = prober.Round(ctx, spec.GetCount(), spec.GetTimeout())
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				//the LAN NS might be recreated, start over in next round
+				closeProber()
+				nodeStatus.Message = err.Error()
+			}
+			if err := r.updateNodeStatus(ctx, key, nodeStatus, results); err != nil {
+				log.Error(err, "failed to update probe status")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startProber creates the probe interface and starts serving probe requests
+func (r *LANProbeReconciler) startProber(ctx context.Context, lan *k8slan.LANSpec, probeID uint32) (*probe.Prober, context.CancelFunc, error) {
+	if err := interfaces.EnsureProbeIf(lan); err != nil {
+		return nil, nil, err
+	}
+	prober := &probe.Prober{
+		NSPath:  interfaces.GetNSPath(*lan.NS),
+		IfName:  interfaces.ProbeIfName,
+		Node:    r.hostName,
+		ProbeID: probeID,
+	}
+	if err := prober.Open(); err != nil {
+		return nil, nil, err
+	}
+	serveCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		if err := prober.Serve(serveCtx); err != nil {
+			ctrl.Log.Error(err, "probe responder stopped", "node", r.hostName)
+		}
+	}()
+	return prober, cancel, nil
+}
+
+// updateNodeStatus sets the probe result of this node; peers are nodes replied,
+// plus all other nodes reported their results, so that a node not replying shows up as unreachable
+func (r *LANProbeReconciler) updateNodeStatus(ctx context.Context, key types.NamespacedName, nodeStatus k8slan.NodeProbeStatus, results map[string]*probe.PeerResult) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lp := &k8slan.LANProbe{}
+		if err := r.Get(ctx, key, lp); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		peers := []string{}
+		for node := range results {
+			peers = append(peers, node)
+		}
+		for _, n := range lp.Status.Nodes {
+			if n.Node != r.hostName && !slices.Contains(peers, n.Node) {
+				peers = append(peers, n.Node)
+			}
+		}
+		slices.Sort(peers)
+		nodeStatus.Peers = nil
+		if nodeStatus.Message == "" {
+			for _, node := range peers {
+				peer := k8slan.PeerProbeResult{Node: node}
+				if result, ok := results[node]; ok {
+					peer.Reachable = result.Received > 0
+					peer.Sent = int32(result.Sent)
+					peer.Received = int32(result.Received)
+					peer.RTT = &metav1.Duration{Duration: result.RTT}
+				} else {
+					peer.Sent = int32(lp.Spec.GetCount())
+				}
+				nodeStatus.Peers = append(nodeStatus.Peers, peer)
+			}
+		}
+		lp.Status.SetNodeStatus(nodeStatus)
+		return r.Status().Update(ctx, lp)
+	})
+}
+
+// removeNodeStatus removes the probe result of this node if any
+func (r *LANProbeReconciler) removeNodeStatus(ctx context.Context, key types.NamespacedName) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lp := &k8slan.LANProbe{}
+		if err := r.Get(ctx, key, lp); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if lp.Status.GetNodeStatus(r.hostName) == nil {
+			return nil
+		}
+		lp.Status.RemoveNodeStatus(r.hostName)
+		return r.Status().Update(ctx, lp)
+	})
+}
+
+// stop stops the runner of a LANProbe, and removes the probe interface if no other LANProbe uses it
+func (r *LANProbeReconciler) stop(key types.NamespacedName) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	runner, ok := r.running[key]
+	if !ok {
+		return
+	}
+	runner.cancel()
+	delete(r.running, key)
+	for _, other := range r.running {
+		if *other.lan.NS == *runner.lan.NS {
+			return
+		}
+	}
+	if err := interfaces.RemoveProbeIf(&runner.lan); err != nil {
+		ctrl.Log.Error(err, "failed to remove probe interface", "ns", *runner.lan.NS)
+	}
+}
+
+func (r *LANProbeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&k8slan.LANProbe{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// interval of checking reachability of remote vteps
+	remoteVTEPCheckInterval = time.Minute
+	// timeout of each ICMP echo to a remote vtep
+	remoteVTEPPingTimeout = time.Second
+	// number of ICMP echos before a remote vtep is reported unreachable
+	remoteVTEPPingCount = 3
+)
+
+// addRemoteVTEPs adds the remote vteps of lan to fdb, BUM traffic is replicated to them
+func addRemoteVTEPs(fdb *interfaces.RemoteFDB, lan *k8slan.LANSpec, node string) {
+	for _, v := range lan.RemoteVTEPs {
+		addr, err := netip.ParseAddr(v.Address)
+		if err != nil {
+			continue
+		}
+		fdb.FloodVTEPs = append(fdb.FloodVTEPs, addr)
+		if src := v.GetSource(node); src.IsValid() {
+			if fdb.Sources == nil {
+				fdb.Sources = map[netip.Addr]netip.Addr{}
+			}
+			fdb.Sources[addr] = src
+		}
+		for _, macStr := range v.MACs {
+			if mac, err := net.ParseMAC(macStr); err == nil {
+				fdb.MACs = append(fdb.MACs, interfaces.RemoteMAC{MAC: mac, VTEP: addr})
+			}
+		}
+	}
+}
+
+// reconcileRemoteVTEPs checks reachability of the remote vteps of lan from this node and reports it in status,
+// it returns the time until next check, 0 if lan has no remote vtep on this node
+func (r *LANReconciler) reconcileRemoteVTEPs(ctx context.Context, lan *k8slan.LAN) (time.Duration, error) {
+	if _, err := os.Stat(interfaces.GetNSPath(*lan.Spec.NS)); len(lan.Spec.RemoteVTEPs) == 0 || err != nil {
+		return 0, r.setRemoteVTEPStatus(ctx, lan, nil)
+	}
+	//vxlan traffic is sourced from the VTEP address, unless a source is specified
+	vtepAddr, _ := interfaces.GetVTEPAddr(&lan.Spec, r.hostName)
+	zone := lan.Spec.DefaultVxDev
+	if dev, ok := lan.Spec.VxDevMap[r.hostName]; ok {
+		zone = dev
+	}
+	status := k8slan.NodeRemoteVTEPStatus{
+		Node:  r.hostName,
+		VTEPs: make([]k8slan.RemoteVTEPStatus, len(lan.Spec.RemoteVTEPs)),
+	}
+	wg := new(sync.WaitGroup)
+	for i, v := range lan.Spec.RemoteVTEPs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status.VTEPs[i].Address = v.Address
+			src := v.GetSource(r.hostName)
+			if !src.IsValid() {
+				src = vtepAddr
+			}
+			var err error
+			for range remoteVTEPPingCount {
+				if err = pingVTEP(src, netip.MustParseAddr(v.Address), zone, remoteVTEPPingTimeout); err == nil {
+					break
+				}
+			}
+			status.VTEPs[i].Reachable = err == nil
+			if err != nil {
+				status.VTEPs[i].Message = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	return remoteVTEPCheckInterval, r.setRemoteVTEPStatus(ctx, lan, &status)
+}
+
+// pingVTEP sends an ICMP echo from src to dst and waits for the reply, src is not bound if invalid;
+// zone is the interface of a link-local dst
+func pingVTEP(src, dst netip.Addr, zone string, timeout time.Duration) error {
+	network, proto := "ip4:icmp", 1
+	var request, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if dst.Is6() {
+		network, proto = "ip6:ipv6-icmp", 58
+		request, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	laddr := ""
+	if src.IsValid() {
+		laddr = src.String()
+	}
+	conn, err := icmp.ListenPacket(network, laddr)
+	if err != nil {
+		return fmt.Errorf("failed to open icmp socket, %w", err)
+	}
+	defer conn.Close()
+	id := rand.IntN(0xffff)
+	msg := icmp.Message{Type: request, Body: &icmp.Echo{ID: id, Seq: 1, Data: []byte("k8slan")}}
+	buf, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	peer := &net.IPAddr{IP: dst.AsSlice()}
+	if dst.IsLinkLocalUnicast() {
+		peer.Zone = zone
+	}
+	if _, err := conn.WriteTo(buf, peer); err != nil {
+		return fmt.Errorf("failed to send icmp echo to %v, %w", dst, err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	buf = make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("no icmp echo reply from %v, %w", dst, err)
+		}
+		m, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || m.Type != reply {
+			continue
+		}
+		//raw sockets receive all icmp packets
+		if echo, ok := m.Body.(*icmp.Echo); ok && echo.ID == id && from.(*net.IPAddr).IP.Equal(peer.IP) {
+			return nil
+		}
+	}
+}
+
+// setRemoteVTEPStatus sets the remote vtep reachability of this node in status of lan, it is removed if status is nil
+func (r *LANReconciler) setRemoteVTEPStatus(ctx context.Context, lan *k8slan.LAN, status *k8slan.NodeRemoteVTEPStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &k8slan.LAN{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(lan), latest); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if status == nil {
+			if !latest.Status.RemoveNodeRemoteVTEPs(r.hostName) {
+				return nil
+			}
+		} else {
+			if existing := latest.Status.GetNodeRemoteVTEPs(r.hostName); existing != nil && equality.Semantic.DeepEqual(existing, status) {
+				return nil
+			}
+			latest.Status.SetNodeRemoteVTEPs(*status)
+		}
+		return r.Status().Update(ctx, latest)
+	})
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	k8slan "github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=spokebindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=spokebindings/status,verbs=get;update;patch
+
+// getSpokeBindings returns the SpokeBindings of each spoke of lan
+func (r *LANReconciler) getSpokeBindings(ctx context.Context, lan *k8slan.LAN) (map[string][]*k8slan.SpokeBinding, error) {
+	list := &k8slan.SpokeBindingList{}
+	if err := r.List(ctx, list, client.InNamespace(lan.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list spoke bindings, %w", err)
+	}
+	r2 := map[string][]*k8slan.SpokeBinding{}
+	for i := range list.Items {
+		b := &list.Items[i]
+		r2[b.Spec.Spoke] = append(r2[b.Spec.Spoke], b)
+	}
+	return r2, nil
+}
+
+// getBindingTarget returns the LAN the spoke of binding should be connected to, nil means its home LAN
+func (r *LANReconciler) getBindingTarget(ctx context.Context, home *k8slan.LAN, binding *k8slan.SpokeBinding) (*k8slan.LAN, error) {
+	if binding.Spec.LAN == home.Name {
+		return nil, nil
+	}
+	target := &k8slan.LAN{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: home.Namespace, Name: binding.Spec.LAN}, target); err != nil {
+		return nil, fmt.Errorf("failed to get LAN %v, %w", binding.Spec.LAN, err)
+	}
+	if !target.DeletionTimestamp.IsZero() {
+		return nil, fmt.Errorf("LAN %v is being deleted", target.Name)
+	}
+	if err := binding.Spec.Validate(&home.Spec, &target.Spec); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// reconcileSpokeBindings connects the spokes of lan on this node to the LANs of their SpokeBindings,
+// a spoke without SpokeBinding, or whose target LAN is unavailable, is connected back to lan
+func (r *LANReconciler) reconcileSpokeBindings(ctx context.Context, lan *k8slan.LAN) error {
+	bindings, err := r.getSpokeBindings(ctx, lan)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, spoke := range lan.Spec.SpokeList {
+		if len(bindings[spoke]) > 1 {
+			errs = append(errs, fmt.Errorf("spoke %v has more than one SpokeBinding, it is left in place", spoke))
+			continue
+		}
+		var binding *k8slan.SpokeBinding
+		var target *k8slan.LAN
+		var bindErr error
+		if len(bindings[spoke]) == 1 {
+			binding = bindings[spoke][0]
+			if target, bindErr = r.getBindingTarget(ctx, lan, binding); bindErr != nil {
+				bindErr = fmt.Errorf("spoke %v is connected to its home LAN, %w", spoke, bindErr)
+			}
+		}
+		var flap time.Duration
+		if binding != nil && bindErr == nil {
+			flap = binding.Spec.GetCarrierFlap()
+		}
+		found, moved, err := interfaces.BindSpoke(lan, target, r.hostName, spoke, flap, r.eventFunc(lan))
+		if err != nil {
+			bindErr = fmt.Errorf("failed to bind spoke %v, %w", spoke, err)
+		}
+		if bindErr != nil {
+			errs = append(errs, bindErr)
+		}
+		if binding == nil {
+			continue
+		}
+		var status *k8slan.NodeSpokeBindingStatus
+		if found {
+			status = &k8slan.NodeSpokeBindingStatus{Node: r.hostName, LAN: lan.Name}
+			if target != nil && err == nil {
+				status.LAN = target.Name
+			}
+			if bindErr != nil {
+				status.Message = bindErr.Error()
+			}
+		}
+		if err := r.setSpokeBindingStatus(ctx, binding, lan.Name, status, moved); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setSpokeBindingStatus updates the status of this node in binding, the status is removed if status is nil
+func (r *LANReconciler) setSpokeBindingStatus(ctx context.Context, binding *k8slan.SpokeBinding, home string,
+	status *k8slan.NodeSpokeBindingStatus, moved bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &k8slan.SpokeBinding{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(binding), latest); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		changed := latest.Status.Home != home
+		latest.Status.Home = home
+		existing := latest.Status.GetNode(r.hostName)
+		switch {
+		case status == nil:
+			changed = latest.Status.RemoveNode(r.hostName) || changed
+		case moved:
+			status.LastMoveTime = &metav1.Time{Time: time.Now()}
+			latest.Status.SetNode(*status)
+			changed = true
+		default:
+			if existing != nil {
+				status.LastMoveTime = existing.LastMoveTime
+			}
+			if existing == nil || !equality.Semantic.DeepEqual(existing, status) {
+				latest.Status.SetNode(*status)
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		return r.Status().Update(ctx, latest)
+	})
+}
+
+// releaseSpokes moves the spokes bound to lan back to their home LANs, before the LAN NS of lan is removed
+func (r *LANReconciler) releaseSpokes(ctx context.Context, lan *k8slan.LAN) error {
+	list := &k8slan.LANList{}
+	if err := r.List(ctx, list, client.InNamespace(lan.Namespace)); err != nil {
+		return fmt.Errorf("failed to list LANs, %w", err)
+	}
+	homes := map[types.UID]*k8slan.LAN{}
+	for i := range list.Items {
+		homes[list.Items[i].UID] = &list.Items[i]
+	}
+	return interfaces.ReleaseSpokes(lan, r.hostName, homes, r.eventFunc(lan))
+}
+
+// spokeBindingToLAN returns the home LAN of the spoke of a SpokeBinding
+func (r *LANReconciler) spokeBindingToLAN(ctx context.Context, obj client.Object) []reconcile.Request {
+	binding, ok := obj.(*k8slan.SpokeBinding)
+	if !ok {
+		return nil
+	}
+	if home := r.getHomeLAN(ctx, binding.Namespace, binding.Spec.Spoke); home != nil {
+		return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(home)}}
+	}
+	return nil
+}
+
+// getHomeLAN returns the LAN in namespace listing spoke, nil if not found
+func (r *LANReconciler) getHomeLAN(ctx context.Context, namespace, spoke string) *k8slan.LAN {
+	list := &k8slan.LANList{}
+	if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil
+	}
+	for i := range list.Items {
+		for _, s := range list.Items[i].Spec.SpokeList {
+			if s == spoke {
+				return &list.Items[i]
+			}
+		}
+	}
+	return nil
+}
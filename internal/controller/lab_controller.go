@@ -0,0 +1,643 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// labRequeueInterval is how often a Lab is reconciled while it is not ready or has VMs, VMs are not watched
+	labRequeueInterval = 10 * time.Second
+	// labHashAnnotation is the hash of the desired state of an object of a Lab, the object is recreated if it changes
+	labHashAnnotation = "lan.k8slan.io/lab-hash"
+)
+
+var vmGVK = schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachine"}
+
+// LabReconciler reconciles a Lab object
+type LabReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=labs,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=labs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=labs/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachines,verbs=get;list;watch;create;delete
+
+// labNode is a workload of a Lab with its desired state
+type labNode struct {
+	name     string
+	obj      client.Object
+	lans     []string
+	nads     []string
+	existing client.Object
+}
+
+// Reconcile creates the LANs of a Lab, then the pods and VMs once the NADs of their spokes exist;
+// an object whose desired state changes is recreated, LANs are only removed after the workloads using them
+func (r *LabReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := logf.FromContext(ctx)
+	lab := new(v1beta1.Lab)
+	if err := r.Get(ctx, req.NamespacedName, lab); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !lab.DeletionTimestamp.IsZero() {
+		return r.teardown(ctx, lab)
+	}
+	if controllerutil.AddFinalizer(lab, v1beta1.LabFinalizer) {
+		if err := r.Update(ctx, lab); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add finalizer to lab %v, %w", req.NamespacedName, err)
+		}
+	}
+	status := v1beta1.LabStatus{Conditions: slices.Clone(lab.Status.Conditions)}
+	if err := lab.Spec.Validate(); err != nil {
+		setLabReady(lab, &status, false, "Invalid", err.Error())
+		return ctrl.Result{}, r.updateLabStatus(ctx, lab, status)
+	}
+	lans, err := r.desiredLANs(lab)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if msg, err := r.findConflict(ctx, lab, lans); err != nil {
+		return ctrl.Result{}, err
+	} else if msg != "" {
+		//other Labs and LANs are not watched
+		setLabReady(lab, &status, false, "Conflict", msg)
+		return ctrl.Result{RequeueAfter: labRequeueInterval}, r.updateLabStatus(ctx, lab, status)
+	}
+	nodes, err := r.desiredNodes(lab, lans)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	existingLANs, existingWorkloads, err := r.listOwned(ctx, lab)
+	if err != nil {
+		setLabReady(lab, &status, false, "ListFailed", err.Error())
+		return ctrl.Result{}, r.updateLabStatus(ctx, lab, status)
+	}
+
+	//remove obsolete and outdated workloads first
+	pending := false
+	for _, obj := range existingWorkloads {
+		node, ok := nodes[obj.GetLabels()[v1beta1.LabNodeLabel]]
+		if ok && reflect.TypeOf(node.obj) == reflect.TypeOf(obj) && sameHash(node.obj, obj) && obj.GetDeletionTimestamp().IsZero() {
+			node.existing = obj
+			continue
+		}
+		pending = true
+		if obj.GetDeletionTimestamp().IsZero() {
+			logger.Info("removing workload of lab", "lab", req.NamespacedName, "workload", obj.GetName())
+			if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to remove %v, %w", obj.GetName(), err)
+			}
+		}
+	}
+	//then obsolete and outdated LANs, create missing LANs
+	readyLANs := map[string]bool{}
+	for _, lan := range existingLANs {
+		if desired, ok := lans[lan.Name]; ok && sameHash(desired, lan) && lan.DeletionTimestamp.IsZero() {
+			readyLANs[lan.Name] = true
+			continue
+		}
+		if !pending && lan.DeletionTimestamp.IsZero() {
+			logger.Info("removing LAN of lab", "lab", req.NamespacedName, "lan", lan.Name)
+			if err := r.Delete(ctx, lan); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to remove LAN %v, %w", lan.Name, err)
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(lans)) {
+		if readyLANs[name] || slices.ContainsFunc(existingLANs, func(l *v1beta1.LAN) bool { return l.Name == name }) {
+			continue
+		}
+		if err := r.Create(ctx, lans[name]); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to create LAN %v, %w", name, err)
+		}
+		logger.Info("created LAN of lab", "lab", req.NamespacedName, "lan", name)
+	}
+
+	//create missing workloads once their LANs and NADs exist
+	for _, name := range slices.Sorted(maps.Keys(nodes)) {
+		node := nodes[name]
+		nodeStatus := v1beta1.LabNodeStatus{Name: name}
+		if node.existing != nil {
+			nodeStatus.Ready, nodeStatus.Message = workloadReady(node.existing)
+		} else if msg, err := r.waitingFor(ctx, lab, node, readyLANs); err != nil {
+			return ctrl.Result{}, err
+		} else if msg != "" {
+			nodeStatus.Message = msg
+		} else if slices.ContainsFunc(existingWorkloads, func(o client.Object) bool { return o.GetName() == node.obj.GetName() }) {
+			nodeStatus.Message = "waiting for the previous workload to be removed"
+		} else {
+			if err := r.Create(ctx, node.obj); err != nil {
+				nodeStatus.Message = fmt.Sprintf("failed to create, %v", err)
+				logger.Error(err, "failed to create workload of lab", "lab", req.NamespacedName, "workload", node.obj.GetName())
+			} else {
+				logger.Info("created workload of lab", "lab", req.NamespacedName, "workload", node.obj.GetName())
+				nodeStatus.Message = "created"
+			}
+		}
+		status.Nodes = append(status.Nodes, nodeStatus)
+	}
+
+	readyNodes := 0
+	for _, n := range status.Nodes {
+		if n.Ready {
+			readyNodes++
+		}
+	}
+	status.ReadyNodes = fmt.Sprintf("%d/%d", readyNodes, len(status.Nodes))
+	status.LANs = slices.Sorted(maps.Keys(lans))
+	switch {
+	case len(readyLANs) != len(lans):
+		setLabReady(lab, &status, false, "Progressing", fmt.Sprintf("%d/%d LANs are created", len(readyLANs), len(lans)))
+	case readyNodes != len(status.Nodes):
+		setLabReady(lab, &status, false, "Progressing", fmt.Sprintf("%v nodes are ready", status.ReadyNodes))
+	default:
+		setLabReady(lab, &status, true, "Ready", "all LANs and nodes are ready")
+	}
+	if err := r.updateLabStatus(ctx, lab, status); err != nil {
+		return ctrl.Result{}, err
+	}
+	hasVM := slices.ContainsFunc(lab.Spec.Nodes, func(n v1beta1.LabNode) bool { return n.VM != nil })
+	if hasVM || !meta.IsStatusConditionTrue(status.Conditions, v1beta1.LabConditionReady) {
+		return ctrl.Result{RequeueAfter: labRequeueInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// waitingFor returns what node is waiting for before it could be created, empty if nothing
+func (r *LabReconciler) waitingFor(ctx context.Context, lab *v1beta1.Lab, node *labNode, readyLANs map[string]bool) (string, error) {
+	for _, lan := range node.lans {
+		if !readyLANs[lan] {
+			return fmt.Sprintf("waiting for LAN %v", lan), nil
+		}
+	}
+	for _, nad := range node.nads {
+		err := r.Get(ctx, types.NamespacedName{Namespace: lab.Namespace, Name: nad}, new(ncv1.NetworkAttachmentDefinition))
+		if apierrors.IsNotFound(err) {
+			return fmt.Sprintf("waiting for NAD %v", nad), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get NAD %v, %w", nad, err)
+		}
+	}
+	return "", nil
+}
+
+// teardown removes the workloads of a Lab, then its LANs, then the finalizer
+func (r *LabReconciler) teardown(ctx context.Context, lab *v1beta1.Lab) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(lab, v1beta1.LabFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	lans, workloads, err := r.listOwned(ctx, lab)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	objs := workloads
+	msg := fmt.Sprintf("removing %d workloads", len(workloads))
+	if len(workloads) == 0 {
+		objs = nil
+		for _, lan := range lans {
+			objs = append(objs, lan)
+		}
+		msg = fmt.Sprintf("removing %d LANs", len(lans))
+	}
+	if len(objs) > 0 {
+		for _, obj := range objs {
+			if !obj.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+			if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to remove %v, %w", obj.GetName(), err)
+			}
+		}
+		status := *lab.Status.DeepCopy()
+		setLabReady(lab, &status, false, "Terminating", msg)
+		if err := r.updateLabStatus(ctx, lab, status); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: labRequeueInterval}, nil
+	}
+	controllerutil.RemoveFinalizer(lab, v1beta1.LabFinalizer)
+	if err := r.Update(ctx, lab); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to remove finalizer of lab %v, %w", lab.Name, err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// listOwned returns the LANs, and pods and VMs controlled by lab
+func (r *LabReconciler) listOwned(ctx context.Context, lab *v1beta1.Lab) ([]*v1beta1.LAN, []client.Object, error) {
+	lanList := new(v1beta1.LANList)
+	if err := r.List(ctx, lanList, client.InNamespace(lab.Namespace), client.MatchingFields{labOwnerKey: lab.Name}); err != nil {
+		return nil, nil, fmt.Errorf("failed to list LANs, %w", err)
+	}
+	lans := []*v1beta1.LAN{}
+	for i := range lanList.Items {
+		lans = append(lans, &lanList.Items[i])
+	}
+	podList := new(corev1.PodList)
+	if err := r.List(ctx, podList, client.InNamespace(lab.Namespace), client.MatchingFields{labOwnerKey: lab.Name}); err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods, %w", err)
+	}
+	workloads := []client.Object{}
+	for i := range podList.Items {
+		workloads = append(workloads, &podList.Items[i])
+	}
+	vmList := new(unstructured.UnstructuredList)
+	vmList.SetGroupVersionKind(vmGVK.GroupVersion().WithKind(vmGVK.Kind + "List"))
+	err := r.List(ctx, vmList, client.InNamespace(lab.Namespace), client.MatchingLabels{v1beta1.LabLabel: lab.Name})
+	switch {
+	case meta.IsNoMatchError(err):
+		//kubevirt is not installed
+		if slices.ContainsFunc(lab.Spec.Nodes, func(n v1beta1.LabNode) bool { return n.VM != nil }) {
+			return nil, nil, fmt.Errorf("lab has VM nodes but kubevirt is not installed")
+		}
+	case err != nil:
+		return nil, nil, fmt.Errorf("failed to list VMs, %w", err)
+	}
+	for i := range vmList.Items {
+		if metav1.IsControlledBy(&vmList.Items[i], lab) {
+			workloads = append(workloads, &vmList.Items[i])
+		}
+	}
+	return lans, workloads, nil
+}
+
+// desiredLANs returns the LAN of each link, keyed by name
+func (r *LabReconciler) desiredLANs(lab *v1beta1.Lab) (map[string]*v1beta1.LAN, error) {
+	str := func(s string) *string { return &s }
+	lans := map[string]*v1beta1.LAN{}
+	for i, link := range lab.Spec.Links {
+		vni := lab.Spec.GetLinkVNI(i)
+		spec := lab.Spec.LANTemplate.DeepCopy()
+		spec.SpokeImpairments = nil
+		spec.Mirrors = nil
+		spec.SpokeAdminStates = nil
+		spec.NS = str(fmt.Sprintf("%v-%v", lab.Namespace, lab.GetLANName(link.Name)))
+		spec.BridgeName = str(fmt.Sprintf("br%d", vni))
+		spec.VxLANName = str(fmt.Sprintf("vx%d", vni))
+		spec.VNI = &vni
+		spec.Mode = link.GetMode()
+		spec.SpokeList = nil
+		for _, ep := range link.Endpoints {
+			spec.SpokeList = append(spec.SpokeList, v1beta1.GetLabSpokeName(lab.Namespace, lab.Name, ep))
+		}
+		lan := &v1beta1.LAN{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      lab.GetLANName(link.Name),
+				Namespace: lab.Namespace,
+				Labels:    map[string]string{v1beta1.LabLabel: lab.Name},
+			},
+			Spec: *spec,
+		}
+		if err := setHash(lan, lan.Spec); err != nil {
+			return nil, err
+		}
+		if err := ctrl.SetControllerReference(lab, lan, r.Scheme); err != nil {
+			return nil, fmt.Errorf("failed to set owner of LAN %v, %w", lan.Name, err)
+		}
+		lans[lan.Name] = lan
+	}
+	return lans, nil
+}
+
+// sameVxLANGrp returns true if vxlan traffic of a and b could be mixed, an unset group is the defaulted one
+func sameVxLANGrp(a, b *v1beta1.LANSpec) bool {
+	return a.VxLANGrp == nil || b.VxLANGrp == nil || *a.VxLANGrp == *b.VxLANGrp
+}
+
+// findConflict returns why the desired LANs of lab conflict with other LANs or Labs, empty if they don't:
+// a spoke name, i.e. an interface name on the workers, must be unique in the cluster,
+// so is a vni within a vxlan group; a Lab only conflicts with Labs created before it, so the older one keeps working
+func (r *LabReconciler) findConflict(ctx context.Context, lab *v1beta1.Lab, lans map[string]*v1beta1.LAN) (string, error) {
+	type labLAN struct {
+		spec  *v1beta1.LANSpec
+		owner string
+	}
+	others := []labLAN{}
+	labList := new(v1beta1.LabList)
+	if err := r.List(ctx, labList); err != nil {
+		return "", fmt.Errorf("failed to list labs, %w", err)
+	}
+	for i := range labList.Items {
+		other := &labList.Items[i]
+		if !olderLab(other, lab) || other.Spec.Validate() != nil {
+			continue
+		}
+		desired, err := r.desiredLANs(other)
+		if err != nil {
+			return "", err
+		}
+		for _, lan := range desired {
+			others = append(others, labLAN{spec: &lan.Spec, owner: fmt.Sprintf("lab %v/%v", other.Namespace, other.Name)})
+		}
+	}
+	lanList := new(v1beta1.LANList)
+	if err := r.List(ctx, lanList); err != nil {
+		return "", fmt.Errorf("failed to list LANs, %w", err)
+	}
+	for i := range lanList.Items {
+		lan := &lanList.Items[i]
+		//LANs of Labs are checked above
+		if owner := metav1.GetControllerOf(lan); owner != nil && owner.Kind == "Lab" {
+			continue
+		}
+		others = append(others, labLAN{spec: &lan.Spec, owner: fmt.Sprintf("LAN %v/%v", lan.Namespace, lan.Name)})
+	}
+	spokes := map[string]string{}
+	for _, name := range slices.Sorted(maps.Keys(lans)) {
+		spec := &lans[name].Spec
+		for _, spoke := range spec.SpokeList {
+			//hash collision within the Lab
+			if other, ok := spokes[spoke]; ok {
+				return fmt.Sprintf("spoke %v of LAN %v is also used by LAN %v", spoke, name, other), nil
+			}
+			spokes[spoke] = name
+		}
+		for _, other := range others {
+			if sameVxLANGrp(spec, other.spec) && other.spec.VNI != nil && *other.spec.VNI == *spec.VNI {
+				return fmt.Sprintf("vni %d of LAN %v is used by %v", *spec.VNI, name, other.owner), nil
+			}
+			for _, spoke := range spec.SpokeList {
+				if slices.Contains(other.spec.SpokeList, spoke) {
+					return fmt.Sprintf("spoke %v of LAN %v is used by %v", spoke, name, other.owner), nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// olderLab returns true if a is created before b, by namespace and name if at the same time
+func olderLab(a, b *v1beta1.Lab) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+}
+
+// labAttachment is a link endpoint of a node
+type labAttachment struct {
+	ifName string
+	spoke  string
+	lan    string
+}
+
+// desiredNodes returns the pod or VM of each node, keyed by node name
+func (r *LabReconciler) desiredNodes(lab *v1beta1.Lab, lans map[string]*v1beta1.LAN) (map[string]*labNode, error) {
+	attachments := map[string][]labAttachment{}
+	for _, link := range lab.Spec.Links {
+		for _, ep := range link.Endpoints {
+			attachments[ep.Node] = append(attachments[ep.Node], labAttachment{
+				ifName: ep.Interface,
+				spoke:  v1beta1.GetLabSpokeName(lab.Namespace, lab.Name, ep),
+				lan:    lab.GetLANName(link.Name),
+			})
+		}
+	}
+	nodes := map[string]*labNode{}
+	for _, n := range lab.Spec.Nodes {
+		node := &labNode{name: n.Name}
+		labels := map[string]string{v1beta1.LabLabel: lab.Name, v1beta1.LabNodeLabel: n.Name}
+		isVeth := n.Pod != nil
+		var err error
+		if isVeth {
+			node.obj = labPod(lab, n, labels, attachments[n.Name])
+		} else if node.obj, err = labVM(lab, n, labels, attachments[n.Name]); err != nil {
+			return nil, err
+		}
+		//the workload is recreated along with any of its LANs
+		lanHashes := []string{}
+		for _, a := range attachments[n.Name] {
+			node.nads = append(node.nads, v1beta1.GetNADName(a.spoke, isVeth))
+			if !slices.Contains(node.lans, a.lan) {
+				node.lans = append(node.lans, a.lan)
+				lanHashes = append(lanHashes, lans[a.lan].Annotations[labHashAnnotation])
+			}
+		}
+		if err := setHash(node.obj, []any{node.obj, lanHashes}); err != nil {
+			return nil, err
+		}
+		if err := ctrl.SetControllerReference(lab, node.obj, r.Scheme); err != nil {
+			return nil, fmt.Errorf("failed to set owner of %v, %w", node.obj.GetName(), err)
+		}
+		nodes[n.Name] = node
+	}
+	return nodes, nil
+}
+
+// labPod returns the pod of node from its template, with the veth NAD of each attachment
+func labPod(lab *v1beta1.Lab, node v1beta1.LabNode, labels map[string]string, attachments []labAttachment) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: *node.Pod.ObjectMeta.DeepCopy(),
+		Spec:       *node.Pod.Spec.DeepCopy(),
+	}
+	pod.Name = lab.GetNodeObjName(node.Name)
+	pod.Namespace = lab.Namespace
+	if pod.Labels == nil {
+		pod.Labels = map[string]string{}
+	}
+	maps.Copy(pod.Labels, labels)
+	if len(attachments) == 0 {
+		return pod
+	}
+	networks := []string{}
+	if existing := pod.Annotations[ncv1.NetworkAttachmentAnnot]; existing != "" {
+		networks = append(networks, existing)
+	}
+	container := &pod.Spec.Containers[0]
+	if container.Resources.Limits == nil {
+		container.Resources.Limits = corev1.ResourceList{}
+	}
+	for _, a := range attachments {
+		nad := v1beta1.GetNADName(a.spoke, true)
+		networks = append(networks, fmt.Sprintf("%v@%v", nad, a.ifName))
+		container.Resources.Limits[corev1.ResourceName(v1beta1.ResourceNamespace+"/"+nad)] = resource.MustParse("1")
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[ncv1.NetworkAttachmentAnnot] = strings.Join(networks, ",")
+	return pod
+}
+
+// labVM returns the kubevirt VirtualMachine of node from its spec, with a macvtap interface of each attachment
+func labVM(lab *v1beta1.Lab, node v1beta1.LabNode, labels map[string]string, attachments []labAttachment) (*unstructured.Unstructured, error) {
+	spec := map[string]any{}
+	if len(node.VM.Raw) > 0 {
+		if err := json.Unmarshal(node.VM.Raw, &spec); err != nil {
+			return nil, fmt.Errorf("invalid vm spec of node %v, %w", node.Name, err)
+		}
+	}
+	vm := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	vm.SetGroupVersionKind(vmGVK)
+	vm.SetName(lab.GetNodeObjName(node.Name))
+	vm.SetNamespace(lab.Namespace)
+	vm.SetLabels(labels)
+	templateLabels, _, _ := unstructured.NestedStringMap(vm.Object, "spec", "template", "metadata", "labels")
+	if templateLabels == nil {
+		templateLabels = map[string]string{}
+	}
+	maps.Copy(templateLabels, labels)
+	if err := unstructured.SetNestedStringMap(vm.Object, templateLabels, "spec", "template", "metadata", "labels"); err != nil {
+		return nil, fmt.Errorf("invalid vm spec of node %v, %w", node.Name, err)
+	}
+	if len(attachments) == 0 {
+		return vm, nil
+	}
+	interfacesPath := []string{"spec", "template", "spec", "domain", "devices", "interfaces"}
+	networksPath := []string{"spec", "template", "spec", "networks"}
+	interfaces, _, err := unstructured.NestedSlice(vm.Object, interfacesPath...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vm interfaces of node %v, %w", node.Name, err)
+	}
+	networks, _, err := unstructured.NestedSlice(vm.Object, networksPath...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vm networks of node %v, %w", node.Name, err)
+	}
+	for _, a := range attachments {
+		interfaces = append(interfaces, map[string]any{"name": a.ifName, "binding": map[string]any{"name": "macvtap"}})
+		networks = append(networks, map[string]any{
+			"name":   a.ifName,
+			"multus": map[string]any{"networkName": v1beta1.GetNADName(a.spoke, false)},
+		})
+	}
+	if err := unstructured.SetNestedSlice(vm.Object, interfaces, interfacesPath...); err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedSlice(vm.Object, networks, networksPath...); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// workloadReady returns true if the pod or VM is ready, otherwise why it is not
+func workloadReady(obj client.Object) (bool, string) {
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return false, "terminating"
+	}
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		for _, cond := range o.Status.Conditions {
+			if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("pod is %v", o.Status.Phase)
+	case *unstructured.Unstructured:
+		if ready, _, _ := unstructured.NestedBool(o.Object, "status", "ready"); ready {
+			return true, ""
+		}
+		status, _, _ := unstructured.NestedString(o.Object, "status", "printableStatus")
+		return false, fmt.Sprintf("vm is %v", status)
+	}
+	return false, "unknown workload"
+}
+
+// setHash sets labHashAnnotation of obj to the hash of v
+func setHash(obj client.Object, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to hash %v, %w", obj.GetName(), err)
+	}
+	h := fnv.New64a()
+	h.Write(buf)
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[labHashAnnotation] = fmt.Sprintf("%016x", h.Sum64())
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+func sameHash(desired, existing client.Object) bool {
+	return desired.GetAnnotations()[labHashAnnotation] == existing.GetAnnotations()[labHashAnnotation]
+}
+
+// setLabReady sets the Ready condition in status
+func setLabReady(lab *v1beta1.Lab, status *v1beta1.LabStatus, ready bool, reason, msg string) {
+	cond := metav1.Condition{
+		Type:               v1beta1.LabConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: lab.Generation,
+	}
+	if ready {
+		cond.Status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&status.Conditions, cond)
+}
+
+// updateLabStatus updates the status of lab if it changes
+func (r *LabReconciler) updateLabStatus(ctx context.Context, lab *v1beta1.Lab, status v1beta1.LabStatus) error {
+	if reflect.DeepEqual(lab.Status, status) {
+		return nil
+	}
+	lab.Status = status
+	if err := r.Status().Update(ctx, lab); err != nil {
+		return fmt.Errorf("failed to update status of lab %v, %w", lab.Name, err)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *LabReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := registrResource[v1beta1.LAN](context.Background(), mgr); err != nil {
+		return err
+	}
+	if err := registrResource[corev1.Pod](context.Background(), mgr); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		//status updates are not reconciled, deletion bumps the generation of a Lab with finalizer
+		For(&v1beta1.Lab{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&v1beta1.LAN{}).
+		Owns(&corev1.Pod{}).
+		Named("lab").
+		Complete(r)
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestLab() *v1beta1.Lab {
+	grp := "239.1.1.1"
+	pod := func() *corev1.PodTemplateSpec {
+		return &corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "alpine"}}}}
+	}
+	return &v1beta1.Lab{
+		ObjectMeta: metav1.ObjectMeta{Name: "lab1", Namespace: "default"},
+		Spec: v1beta1.LabSpec{
+			LANTemplate: v1beta1.LANSpec{VxLANGrp: &grp, DefaultVxDev: "eth0"},
+			VNIBase:     100,
+			Nodes:       []v1beta1.LabNode{{Name: "a", Pod: pod()}, {Name: "b", Pod: pod()}},
+			Links: []v1beta1.LabLink{{
+				Name:      "l1",
+				Endpoints: []v1beta1.LabEndpoint{{Node: "a", Interface: "eth1"}, {Node: "b", Interface: "eth1"}},
+			}},
+		},
+	}
+}
+
+var _ = Describe("Lab Controller", func() {
+	Context("When reconciling a Lab", func() {
+		// the field index of owned objects is not served by the API server, a fake client is used instead
+		var c client.Client
+		var r *LabReconciler
+		key := types.NamespacedName{Namespace: "default", Name: "lab1"}
+		reconcile := func() {
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			s := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(s)).To(Succeed())
+			Expect(ncv1.AddToScheme(s)).To(Succeed())
+			Expect(v1beta1.AddToScheme(s)).To(Succeed())
+			c = fake.NewClientBuilder().WithScheme(s).
+				WithIndex(&v1beta1.LAN{}, labOwnerKey, extractKey[*v1beta1.LAN]).
+				WithIndex(&corev1.Pod{}, labOwnerKey, extractKey[*corev1.Pod]).
+				WithStatusSubresource(&v1beta1.Lab{}, &corev1.Pod{}).Build()
+			r = &LabReconciler{Client: c, Scheme: s}
+			Expect(c.Create(ctx, newTestLab())).To(Succeed())
+		})
+
+		It("should create LANs before pods and tear down in order", func() {
+			reconcile()
+			lan := new(v1beta1.LAN)
+			Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "lab1-l1"}, lan)).To(Succeed())
+			Expect(lan.Spec.IsP2P()).To(BeTrue())
+			Expect(*lan.Spec.VNI).To(Equal(int32(100)))
+			Expect(lan.Spec.SpokeList).To(HaveLen(2))
+			pods := new(corev1.PodList)
+			Expect(c.List(ctx, pods)).To(Succeed())
+			Expect(pods.Items).To(BeEmpty(), "pods are created after the NADs")
+
+			By("creating the NADs of the LAN")
+			_, err := (&LANReconciler{Client: c, Scheme: r.Scheme}).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(lan)})
+			Expect(err).NotTo(HaveOccurred())
+			reconcile()
+			pod := new(corev1.Pod)
+			Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "lab1-a"}, pod)).To(Succeed())
+			nad := v1beta1.GetNADName(lan.Spec.SpokeList[0], true)
+			Expect(pod.Annotations[ncv1.NetworkAttachmentAnnot]).To(Equal(nad + "@eth1"))
+			Expect(pod.Spec.Containers[0].Resources.Limits).To(HaveKey(corev1.ResourceName(v1beta1.ResourceNamespace + "/" + nad)))
+			lab := new(v1beta1.Lab)
+			Expect(c.Get(ctx, key, lab)).To(Succeed())
+			Expect(lab.Status.ReadyNodes).To(Equal("0/2"))
+			Expect(meta.IsStatusConditionTrue(lab.Status.Conditions, v1beta1.LabConditionReady)).To(BeFalse())
+
+			By("marking the pods ready")
+			for _, name := range []string{"lab1-a", "lab1-b"} {
+				Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, pod)).To(Succeed())
+				pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+				Expect(c.Status().Update(ctx, pod)).To(Succeed())
+			}
+			reconcile()
+			Expect(c.Get(ctx, key, lab)).To(Succeed())
+			Expect(lab.Status.ReadyNodes).To(Equal("2/2"))
+			Expect(meta.IsStatusConditionTrue(lab.Status.Conditions, v1beta1.LabConditionReady)).To(BeTrue())
+
+			By("deleting the Lab")
+			Expect(c.Delete(ctx, lab)).To(Succeed())
+			reconcile()
+			Expect(c.List(ctx, pods)).To(Succeed())
+			Expect(pods.Items).To(BeEmpty())
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(lan), lan)).To(Succeed(), "LANs are removed after the pods")
+			reconcile()
+			Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(lan), lan))).To(BeTrue())
+			reconcile()
+			Expect(apierrors.IsNotFound(c.Get(ctx, key, lab))).To(BeTrue())
+		})
+
+		It("should not create LANs conflicting with other Labs and LANs", func() {
+			expectConflict := func(key types.NamespacedName, msg string) {
+				_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+				lab := new(v1beta1.Lab)
+				Expect(c.Get(ctx, key, lab)).To(Succeed())
+				cond := meta.FindStatusCondition(lab.Status.Conditions, v1beta1.LabConditionReady)
+				Expect(cond).NotTo(BeNil())
+				Expect(cond.Reason).To(Equal("Conflict"))
+				Expect(cond.Message).To(ContainSubstring(msg))
+			}
+			By("creating a Lab with the vni range of an older Lab")
+			lab2 := newTestLab()
+			lab2.Name = "lab2"
+			lab2.Spec.VNIBase = 99
+			lab2.Spec.Links = append(lab2.Spec.Links, v1beta1.LabLink{
+				Name:      "l2",
+				Endpoints: []v1beta1.LabEndpoint{{Node: "a", Interface: "eth2"}, {Node: "b", Interface: "eth2"}},
+			})
+			Expect(c.Create(ctx, lab2)).To(Succeed())
+			expectConflict(client.ObjectKeyFromObject(lab2), "vni 100 of LAN lab2-l2 is used by lab default/lab1")
+			lans := new(v1beta1.LANList)
+			Expect(c.List(ctx, lans, client.MatchingLabels{v1beta1.LabLabel: "lab2"})).To(Succeed())
+			Expect(lans.Items).To(BeEmpty())
+			reconcile()
+			Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "lab1-l1"}, new(v1beta1.LAN))).To(Succeed())
+
+			By("creating a LAN with a spoke of the Lab")
+			Expect(c.Delete(ctx, lab2)).To(Succeed())
+			lab := new(v1beta1.Lab)
+			Expect(c.Get(ctx, key, lab)).To(Succeed())
+			spoke := v1beta1.GetLabSpokeName(lab.Namespace, lab.Name, lab.Spec.Links[0].Endpoints[0])
+			vni := int32(200)
+			lan := &v1beta1.LAN{
+				ObjectMeta: metav1.ObjectMeta{Name: "lan1", Namespace: "other"},
+				Spec:       v1beta1.LANSpec{VNI: &vni, SpokeList: []string{spoke}},
+			}
+			Expect(c.Create(ctx, lan)).To(Succeed())
+			lab.Spec.VNIBase = 300
+			Expect(c.Update(ctx, lab)).To(Succeed())
+			expectConflict(key, "spoke "+spoke+" of LAN lab1-l1 is used by LAN other/lan1")
+		})
+	})
+})
@@ -18,9 +18,13 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"slices"
 
 	"github.com/hujun-open/k8slan/api/v1beta1"
 	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -28,6 +32,9 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// labOwnerKey is the field index of the Lab controlling an object
+const labOwnerKey = ".metadata.controller"
+
 // LANReconciler reconciles a LAN object
 type LANReconciler struct {
 	client.Client
@@ -37,6 +44,7 @@ type LANReconciler struct {
 // +kubebuilder:rbac:groups=lan.k8slan.io,resources=lans,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=lan.k8slan.io,resources=lans/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=lan.k8slan.io,resources=lans/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;delete
 
 //+kubebuilder:rbac:groups=k8s.cni.cncf.io,resources=network-attachment-definitions,verbs=get;list;watch;create;update;patch;delete;deletecollection
 
@@ -66,6 +74,13 @@ func (r *LANReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		for _, enad := range existingNads.Items {
 			if nad.Name == enad.Name {
 				found = true
+				//the config of an existing NAD is updated, e.g. the p2p peer of the k8slanveth CNI
+				if enad.Spec.Config != nad.Spec.Config && metav1.IsControlledBy(&enad, lan) {
+					enad.Spec.Config = nad.Spec.Config
+					if err := r.Update(ctx, &enad); err != nil {
+						logger.Error(err, "failed to update nad", "nad", nad.Name)
+					}
+				}
 				break
 			}
 		}
@@ -84,10 +99,60 @@ func (r *LANReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 
 		}
 	}
+	if err := r.reconcileKeySecrets(ctx, lan); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// reconcileKeySecrets creates an empty IPsec key Secret for each node of an encrypted lan, which is filled by the LAN daemonset of the node,
+// and removes the ones of nodes no longer having lan, or all of them if lan is not encrypted;
+// so the daemonset only needs to update Secrets
+func (r *LANReconciler) reconcileKeySecrets(ctx context.Context, lan *v1beta1.LAN) error {
+	nodes := []string{}
+	if lan.Spec.Encryption != nil {
+		nodes = lan.GetNodes()
+	}
+	secrets := new(corev1.SecretList)
+	if err := r.List(ctx, secrets, client.InNamespace(lan.Namespace), client.MatchingLabels{v1beta1.LabelLAN: lan.Name}); err != nil {
+		return fmt.Errorf("failed to list key secrets, %w", err)
+	}
+	existing := []string{}
+	for _, secret := range secrets.Items {
+		if !metav1.IsControlledBy(&secret, lan) {
+			continue
+		}
+		if slices.Contains(nodes, secret.Labels[v1beta1.LabelNode]) {
+			existing = append(existing, secret.Labels[v1beta1.LabelNode])
+			continue
+		}
+		if err := r.Delete(ctx, &secret); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to remove key secret %v, %w", secret.Name, err)
+		}
+	}
+	for _, node := range nodes {
+		if slices.Contains(existing, node) {
+			continue
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: lan.Namespace,
+				Name:      v1beta1.GetKeySecretName(lan.Name, node),
+				Labels:    map[string]string{v1beta1.LabelLAN: lan.Name, v1beta1.LabelNode: node},
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+		if err := ctrl.SetControllerReference(lan, secret, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, secret); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create key secret %v, %w", secret.Name, err)
+		}
+	}
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *LANReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err := registrResource[ncv1.NetworkAttachmentDefinition](context.Background(), mgr); err != nil {
@@ -97,6 +162,7 @@ func (r *LANReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		// Uncomment the following line adding a pointer to an instance of the controlled resource as an argument
 		For(&v1beta1.LAN{}).
 		Owns(&ncv1.NetworkAttachmentDefinition{}).
+		Owns(&corev1.Secret{}).
 		Named("lan").
 		Complete(r)
 }
@@ -125,6 +191,6 @@ func extractKey[T client.Object](rawObj client.Object) []string {
 func registrResource[T any, PT myObj[T]](ctx context.Context, mgr ctrl.Manager) error {
 	return mgr.GetFieldIndexer().IndexField(ctx,
 		PT(new(T)),
-		".metadata.controller",
+		labOwnerKey,
 		extractKey[PT])
 }
@@ -18,6 +18,13 @@ package controller
 
 import (
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("LAN Controller", func() {
@@ -29,4 +36,53 @@ var _ = Describe("LAN Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When reconciling an encrypted LAN", func() {
+		key := types.NamespacedName{Namespace: "default", Name: "lan-encrypted"}
+
+		AfterEach(func() {
+			lan := new(v1beta1.LAN)
+			Expect(k8sClient.Get(ctx, key, lan)).To(Succeed())
+			lan.Finalizers = nil
+			Expect(k8sClient.Update(ctx, lan)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, lan)).To(Succeed())
+		})
+
+		It("should create the key secrets of its nodes", func() {
+			lan := newPeeringTestLAN(key.Name)
+			lan.Spec.Encryption = &v1beta1.Encryption{Mode: "ipsec"}
+			lan.Finalizers = []string{v1beta1.FinalizerPrefix + "/worker-a", v1beta1.FinalizerPrefix + "/worker-b"}
+			Expect(k8sClient.Create(ctx, lan)).To(Succeed())
+			r := &LANReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			keySecretNodes := func() []string {
+				GinkgoHelper()
+				_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+				secrets := new(corev1.SecretList)
+				Expect(k8sClient.List(ctx, secrets, client.InNamespace(key.Namespace), client.MatchingLabels{v1beta1.LabelLAN: key.Name})).To(Succeed())
+				nodes := []string{}
+				for _, s := range secrets.Items {
+					if s.DeletionTimestamp.IsZero() {
+						Expect(s.Name).To(Equal(v1beta1.GetKeySecretName(key.Name, s.Labels[v1beta1.LabelNode])))
+						Expect(s.Data).To(BeEmpty())
+						nodes = append(nodes, s.Labels[v1beta1.LabelNode])
+					}
+				}
+				return nodes
+			}
+			Expect(keySecretNodes()).To(ConsistOf("worker-a", "worker-b"))
+
+			By("removing a node of the LAN")
+			Expect(k8sClient.Get(ctx, key, lan)).To(Succeed())
+			lan.Finalizers = []string{v1beta1.FinalizerPrefix + "/worker-a"}
+			Expect(k8sClient.Update(ctx, lan)).To(Succeed())
+			Expect(keySecretNodes()).To(ConsistOf("worker-a"))
+
+			By("disabling encryption")
+			Expect(k8sClient.Get(ctx, key, lan)).To(Succeed())
+			lan.Spec.Encryption = nil
+			Expect(k8sClient.Update(ctx, lan)).To(Succeed())
+			Expect(keySecretNodes()).To(BeEmpty())
+		})
+	})
 })
@@ -0,0 +1,166 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// peeringResyncInterval is how often the remote cluster is queried
+const peeringResyncInterval = time.Minute
+
+// RemoteClientFunc returns a client of the remote cluster from a kubeconfig
+type RemoteClientFunc func(kubeconfig []byte, scheme *runtime.Scheme) (client.Client, error)
+
+// NewRemoteClient is the default RemoteClientFunc
+func NewRemoteClient(kubeconfig []byte, scheme *runtime.Scheme) (client.Client, error) {
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig, %w", err)
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+// LANPeeringReconciler reconciles a LANPeering object,
+// it learns the remote gateway from the peer LANPeering in the remote cluster
+type LANPeeringReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// RemoteClient is NewRemoteClient if nil
+	RemoteClient RemoteClientFunc
+}
+
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=lanpeerings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=lan.k8slan.io,resources=lanpeerings/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Reconcile queries the remote cluster and updates the status of the LANPeering
+func (r *LANPeeringReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := logf.FromContext(ctx)
+	peering := new(v1beta1.LANPeering)
+	if err := r.Get(ctx, req.NamespacedName, peering); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	remote, err := r.getRemote(ctx, peering)
+	if err != nil {
+		logger.Error(err, "failed to sync with remote cluster", "peering", req.NamespacedName)
+		peering.Status.RemoteGatewayNode = ""
+		peering.Status.RemoteGatewayAddress = ""
+		meta.SetStatusCondition(&peering.Status.Conditions, metav1.Condition{
+			Type:               v1beta1.PeeringConditionReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             "SyncFailed",
+			Message:            err.Error(),
+			ObservedGeneration: peering.Generation,
+		})
+	} else {
+		peering.Status.RemoteGatewayNode = remote.Spec.GatewayNode
+		peering.Status.RemoteGatewayAddress = remote.Spec.GatewayAddress
+		peering.Status.LastSyncTime = &metav1.Time{Time: time.Now()}
+		meta.SetStatusCondition(&peering.Status.Conditions, metav1.Condition{
+			Type:               v1beta1.PeeringConditionReady,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Synced",
+			Message:            fmt.Sprintf("peered with %v/%v", remote.Namespace, remote.Name),
+			ObservedGeneration: peering.Generation,
+		})
+	}
+	if err := r.Status().Update(ctx, peering); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update status of peering %v, %w", req.NamespacedName, err)
+	}
+	return ctrl.Result{RequeueAfter: peeringResyncInterval}, nil
+}
+
+// getRemote returns the LANPeering in the remote cluster that peers with peering
+func (r *LANPeeringReconciler) getRemote(ctx context.Context, peering *v1beta1.LANPeering) (*v1beta1.LANPeering, error) {
+	lan := new(v1beta1.LAN)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: peering.Namespace, Name: peering.Spec.LAN}, lan); err != nil {
+		return nil, fmt.Errorf("failed to get LAN %v, %w", peering.Spec.LAN, err)
+	}
+	if err := peering.Spec.Validate(&lan.Spec); err != nil {
+		return nil, err
+	}
+	secret := new(corev1.Secret)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: peering.Namespace, Name: peering.Spec.KubeconfigSecret}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get secret %v, %w", peering.Spec.KubeconfigSecret, err)
+	}
+	kubeconfig, ok := secret.Data[v1beta1.PeeringKubeconfigKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %v has no key %v", peering.Spec.KubeconfigSecret, v1beta1.PeeringKubeconfigKey)
+	}
+	newClient := r.RemoteClient
+	if newClient == nil {
+		newClient = NewRemoteClient
+	}
+	remoteClient, err := newClient(kubeconfig, r.Scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client of remote cluster, %w", err)
+	}
+	remoteNS := peering.Spec.GetRemoteNamespace(peering.Namespace)
+	remoteLAN := new(v1beta1.LAN)
+	if err := remoteClient.Get(ctx, types.NamespacedName{Namespace: remoteNS, Name: peering.Spec.LAN}, remoteLAN); err != nil {
+		return nil, fmt.Errorf("failed to get LAN %v/%v in remote cluster, %w", remoteNS, peering.Spec.LAN, err)
+	}
+	list := new(v1beta1.LANPeeringList)
+	if err := remoteClient.List(ctx, list, client.InNamespace(remoteNS)); err != nil {
+		return nil, fmt.Errorf("failed to list peerings in remote cluster, %w", err)
+	}
+	var remote *v1beta1.LANPeering
+	for i := range list.Items {
+		if list.Items[i].Spec.LAN != peering.Spec.LAN {
+			continue
+		}
+		if remote != nil {
+			return nil, fmt.Errorf("more than one peering of LAN %v/%v in remote cluster", remoteNS, peering.Spec.LAN)
+		}
+		remote = &list.Items[i]
+	}
+	if remote == nil {
+		return nil, fmt.Errorf("no peering of LAN %v/%v in remote cluster", remoteNS, peering.Spec.LAN)
+	}
+	if vni, rvni := peering.Spec.GetVNI(&lan.Spec), remote.Spec.GetVNI(&remoteLAN.Spec); vni != rvni {
+		return nil, fmt.Errorf("vni %d is different from %d of remote peering", vni, rvni)
+	}
+	if remote.Spec.GetPort() != peering.Spec.GetPort() {
+		return nil, fmt.Errorf("port %d is different from %d of remote peering", peering.Spec.GetPort(), remote.Spec.GetPort())
+	}
+	return remote, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *LANPeeringReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		//status updates are not reconciled, the remote cluster is polled every peeringResyncInterval
+		For(&v1beta1.LANPeering{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("lanpeering").
+		Complete(r)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+func newPeeringTestLAN(name string) *v1beta1.LAN {
+	str := func(s string) *string { return &s }
+	vni, port := int32(100), int32(4789)
+	return &v1beta1.LAN{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1beta1.LANSpec{
+			NS:         str(name),
+			BridgeName: str("br-" + name),
+			VxLANName:  str("vx-" + name),
+			VNI:        &vni,
+			VxLANGrp:   str("239.1.1.1"),
+			VxPort:     &port,
+			SpokeList:  []string{"s1"},
+		},
+	}
+}
+
+func newTestPeering(name, node, addr string) *v1beta1.LANPeering {
+	return &v1beta1.LANPeering{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1beta1.LANPeeringSpec{
+			LAN:              "lan-peered",
+			KubeconfigSecret: "remote",
+			GatewayNode:      node,
+			GatewayAddress:   addr,
+		},
+	}
+}
+
+// kubeconfigFromConfig returns a kubeconfig file with the server and credentials of cfg
+func kubeconfigFromConfig(cfg *rest.Config) ([]byte, error) {
+	kubeconfig := clientcmdapi.NewConfig()
+	kubeconfig.Clusters["remote"] = &clientcmdapi.Cluster{
+		Server:                   cfg.Host,
+		CertificateAuthorityData: cfg.CAData,
+	}
+	kubeconfig.AuthInfos["remote"] = &clientcmdapi.AuthInfo{
+		ClientCertificateData: cfg.CertData,
+		ClientKeyData:         cfg.KeyData,
+		Token:                 cfg.BearerToken,
+	}
+	kubeconfig.Contexts["remote"] = &clientcmdapi.Context{Cluster: "remote", AuthInfo: "remote"}
+	kubeconfig.CurrentContext = "remote"
+	return clientcmd.Write(*kubeconfig)
+}
+
+var _ = Describe("LANPeering Controller", func() {
+	Context("When peering with a remote cluster", func() {
+		var remoteEnv *envtest.Environment
+		var remoteClient client.Client
+		key := types.NamespacedName{Namespace: "default", Name: "to-remote"}
+
+		BeforeEach(func() {
+			By("starting the control plane of the remote cluster")
+			remoteEnv = &envtest.Environment{
+				CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+				ErrorIfCRDPathMissing: true,
+			}
+			if getFirstFoundEnvTestBinaryDir() != "" {
+				remoteEnv.BinaryAssetsDirectory = getFirstFoundEnvTestBinaryDir()
+			}
+			remoteCfg, err := remoteEnv.Start()
+			Expect(err).NotTo(HaveOccurred())
+			remoteClient, err = client.New(remoteCfg, client.Options{Scheme: scheme.Scheme})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(remoteClient.Create(ctx, newPeeringTestLAN("lan-peered"))).To(Succeed())
+			Expect(remoteClient.Create(ctx, newTestPeering("to-local", "worker-b", "192.0.2.2"))).To(Succeed())
+			Expect(k8sClient.Create(ctx, newPeeringTestLAN("lan-peered"))).To(Succeed())
+			Expect(k8sClient.Create(ctx, newTestPeering(key.Name, "worker-a", "192.0.2.1"))).To(Succeed())
+			kubeconfig, err := kubeconfigFromConfig(remoteCfg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "default"},
+				Data:       map[string][]byte{v1beta1.PeeringKubeconfigKey: kubeconfig},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, &v1beta1.LANPeering{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}})).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &v1beta1.LAN{ObjectMeta: metav1.ObjectMeta{Name: "lan-peered", Namespace: "default"}})).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "default"}})).To(Succeed())
+			Expect(remoteEnv.Stop()).To(Succeed())
+		})
+
+		It("should learn the remote gateway", func() {
+			//RemoteClient is not set, so the remote cluster is reached with the kubeconfig in the Secret
+			r := &LANPeeringReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			peering := new(v1beta1.LANPeering)
+			Expect(k8sClient.Get(ctx, key, peering)).To(Succeed())
+			Expect(peering.Status.RemoteGatewayNode).To(Equal("worker-b"))
+			Expect(peering.Status.RemoteGatewayAddress).To(Equal("192.0.2.2"))
+			Expect(meta.IsStatusConditionTrue(peering.Status.Conditions, v1beta1.PeeringConditionReady)).To(BeTrue())
+
+			By("changing the port of the remote peering")
+			remote := new(v1beta1.LANPeering)
+			Expect(remoteClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "to-local"}, remote)).To(Succeed())
+			port := int32(8473)
+			remote.Spec.Port = &port
+			Expect(remoteClient.Update(ctx, remote)).To(Succeed())
+			_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, key, peering)).To(Succeed())
+			Expect(peering.IsReady()).To(BeFalse())
+			Expect(peering.Status.RemoteGatewayAddress).To(BeEmpty())
+		})
+	})
+})
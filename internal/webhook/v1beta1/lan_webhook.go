@@ -42,8 +42,10 @@ func SetupLANWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&lanv1beta1.LAN{}).
 		WithValidator(&LANCustomValidator{}).
 		WithDefaulter(&LANCustomDefaulter{
-			vxport: v1beta1.DefaultVxPort,
-			vxgrp:  v1beta1.DefaultVxGrp,
+			vxport:       v1beta1.DefaultVxPort,
+			vxgrp:        v1beta1.DefaultVxGrp,
+			mode:         v1beta1.LANModeBridge,
+			transparency: v1beta1.TransparencyStandard,
 		}).
 		Complete()
 }
@@ -59,8 +61,10 @@ func SetupLANWebhookWithManager(mgr ctrl.Manager) error {
 // as it is used only for temporary operations and does not need to be deeply copied.
 type LANCustomDefaulter struct {
 	// TODO(user): Add more fields as needed for defaulting
-	vxport int32
-	vxgrp  string
+	vxport       int32
+	vxgrp        string
+	mode         string
+	transparency string
 }
 
 // SetDefaultGeneric return inval if it is not nil, otherwise return defVal
@@ -83,8 +87,18 @@ func (d *LANCustomDefaulter) Default(_ context.Context, obj runtime.Object) erro
 		return fmt.Errorf("expected an LAN object but got %T", obj)
 	}
 	lanlog.Info("Defaulting for LAN", "name", lan.GetName())
-	lan.Spec.VxPort = SetDefaultGeneric(lan.Spec.VxPort, d.vxport)
+	if lan.Spec.Encapsulation == v1beta1.EncapGeneve {
+		lan.Spec.VxPort = SetDefaultGeneric(lan.Spec.VxPort, v1beta1.DefaultGenevePort)
+	} else {
+		lan.Spec.VxPort = SetDefaultGeneric(lan.Spec.VxPort, d.vxport)
+	}
 	lan.Spec.VxLANGrp = SetDefaultGeneric(lan.Spec.VxLANGrp, d.vxgrp)
+	if lan.Spec.Mode == "" {
+		lan.Spec.Mode = d.mode
+	}
+	if lan.Spec.Transparency == "" {
+		lan.Spec.Transparency = d.transparency
+	}
 
 	return nil
 }
@@ -131,16 +145,32 @@ func (v *LANCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj ru
 
 	lanlog.Info("Validation for LAN upon update", "name", lan.GetName())
 
-	if !reflect.DeepEqual(lan.Spec, old.Spec) {
+	if !reflect.DeepEqual(immutableSpec(lan.Spec), immutableSpec(old.Spec)) {
 		return nil, field.Forbidden(
 			field.NewPath("spec"),
-			"updates to the spec are not allowed; delete and recreate the resource instead",
+			"updates to the spec are not allowed except impairment, spoke admin states, mirrors, tunnel settings, fdb mode and remote vteps; delete and recreate the resource instead",
 		)
 	}
 
 	return nil, lan.Spec.Validate()
 }
 
+// immutableSpec returns a copy of spec with all fields that could be changed on a live LAN cleared
+func immutableSpec(spec lanv1beta1.LANSpec) *lanv1beta1.LANSpec {
+	r := spec.DeepCopy()
+	r.Impairment = nil
+	r.SpokeImpairments = nil
+	r.SpokeAdminStates = nil
+	r.Mirrors = nil
+	r.VxPort = nil
+	r.Encapsulation = ""
+	r.TTL = nil
+	r.TunnelEndpoints = nil
+	r.FDBMode = ""
+	r.RemoteVTEPs = nil
+	return r
+}
+
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type LAN.
 func (v *LANCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	lan, ok := obj.(*lanv1beta1.LAN)
@@ -82,6 +82,26 @@ var _ = Describe("LAN Webhook", func() {
 		//     obj.SomeRequiredField = "updated_value"
 		//     Expect(validator.ValidateUpdate(ctx, oldObj, obj)).To(BeNil())
 		// })
+
+		It("Should admit changing spoke admin states of a live LAN", func() {
+			str := func(s string) *string { return &s }
+			vni := int32(100)
+			oldObj.Spec = lanv1beta1.LANSpec{
+				NS:         str("lan1"),
+				BridgeName: str("br-lan1"),
+				VxLANName:  str("vx-lan1"),
+				VNI:        &vni,
+				VxLANGrp:   str("239.1.1.1"),
+				SpokeList:  []string{"s1", "s2"},
+			}
+			obj = oldObj.DeepCopy()
+			obj.Spec.SpokeAdminStates = map[string]lanv1beta1.AdminState{"s1": lanv1beta1.AdminStateDown}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+
+			By("rejecting a change of an immutable field")
+			obj.Spec.SpokeList = []string{"s1", "s3"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		})
 	})
 
 })
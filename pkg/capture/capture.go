@@ -0,0 +1,195 @@
+// Package capture implements packet capture on AF_PACKET socket inside a network namespace
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// max number of classic BPF instructions accepted by kernel
+	maxFilterLen = 4096
+	// socket read timeout, so that cancellation is checked periodically
+	readTimeout = 500 * time.Millisecond
+)
+
+// ErrFileSizeLimit is returned by Run when the capture stops because the next packet would exceed FileSizeLimit
+var ErrFileSizeLimit = errors.New("file size limit reached")
+
+// ParseBytecode parses classic BPF bytecode in the output format of "tcpdump -ddd <expression>",
+// which is the number of instructions in the first line, followed by one "code jt jf k" per line
+func ParseBytecode(s string) ([]unix.SockFilter, error) {
+	lines := []string{}
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid instruction count %v, %w", lines[0], err)
+	}
+	if count <= 0 || count > maxFilterLen {
+		return nil, fmt.Errorf("invalid instruction count %d, must be 1..%d", count, maxFilterLen)
+	}
+	if count != len(lines)-1 {
+		return nil, fmt.Errorf("instruction count is %d but there are %d instructions", count, len(lines)-1)
+	}
+	r := make([]unix.SockFilter, count)
+	for i, l := range lines[1:] {
+		fields := strings.Fields(l)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid instruction %q, must be 4 numbers", l)
+		}
+		vals := make([]uint64, 4)
+		for j, f := range fields {
+			bitSize := 8
+			switch j {
+			case 0:
+				bitSize = 16
+			case 3:
+				bitSize = 32
+			}
+			vals[j], err = strconv.ParseUint(f, 10, bitSize)
+			if err != nil {
+				return nil, fmt.Errorf("invalid instruction %q, %w", l, err)
+			}
+		}
+		r[i] = unix.SockFilter{
+			Code: uint16(vals[0]),
+			Jt:   uint8(vals[1]),
+			Jf:   uint8(vals[2]),
+			K:    uint32(vals[3]),
+		}
+	}
+	return r, nil
+}
+
+// Capture captures packets on an interface in a network namespace
+type Capture struct {
+	// path of the network namespace
+	NSPath string
+	IfName string
+	Filter []unix.SockFilter
+	// max number of bytes captured of each packet
+	SnapLen int
+	// capture stops after Duration if it is not zero
+	Duration time.Duration
+	// capture stops after PacketLimit packets are captured if it is not zero
+	PacketLimit int64
+	// capture stops before the written pcapng data exceeds FileSizeLimit bytes if it is not zero
+	FileSizeLimit int64
+	packets       atomic.Int64
+	bytes         atomic.Int64
+}
+
+// Stats returns number of packets and bytes captured so far
+func (c *Capture) Stats() (packets, bytes int64) {
+	return c.packets.Load(), c.bytes.Load()
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// open creates the AF_PACKET socket in the network namespace,
+// the socket stays in the namespace after it is created
+func (c *Capture) open() (int, error) {
+	netns, err := ns.GetNS(c.NSPath)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open ns %v, %w", c.NSPath, err)
+	}
+	defer netns.Close()
+	fd := -1
+	err = netns.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(c.IfName)
+		if err != nil {
+			return fmt.Errorf("failed to find interface %v, %w", c.IfName, err)
+		}
+		//protocol 0 receives nothing until bind, so no packet slips through before the filter is attached
+		fd, err = unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("failed to create packet socket, %w", err)
+		}
+		if len(c.Filter) > 0 {
+			prog := &unix.SockFprog{
+				Len:    uint16(len(c.Filter)),
+				Filter: &c.Filter[0],
+			}
+			if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, prog); err != nil {
+				return fmt.Errorf("failed to attach filter, %w", err)
+			}
+		}
+		tv := unix.NsecToTimeval(readTimeout.Nanoseconds())
+		if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+			return fmt.Errorf("failed to set read timeout, %w", err)
+		}
+		return unix.Bind(fd, &unix.SockaddrLinklayer{
+			Protocol: htons(unix.ETH_P_ALL),
+			Ifindex:  link.Attrs().Index,
+		})
+	})
+	if err != nil {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+		return -1, err
+	}
+	return fd, nil
+}
+
+// Run captures packets and writes them to w in pcapng format,
+// until ctx is done, or Duration passed, or PacketLimit is reached, or FileSizeLimit is reached
+func (c *Capture) Run(ctx context.Context, w io.Writer) error {
+	fd, err := c.open()
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	pw, err := newPcapngWriter(w, c.IfName, c.SnapLen)
+	if err != nil {
+		return fmt.Errorf("failed to write pcapng header, %w", err)
+	}
+	if c.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Duration)
+		defer cancel()
+	}
+	buf := make([]byte, c.SnapLen)
+	for ctx.Err() == nil {
+		//with MSG_TRUNC, n is the real length of the packet even if it is longer than buf
+		n, _, err := unix.Recvfrom(fd, buf, unix.MSG_TRUNC)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return fmt.Errorf("failed to read from interface %v, %w", c.IfName, err)
+		}
+		data := buf[:min(n, len(buf))]
+		if c.FileSizeLimit > 0 && pw.written+epbSize(len(data)) > c.FileSizeLimit {
+			return ErrFileSizeLimit
+		}
+		if err := pw.writePacket(time.Now(), data, n); err != nil {
+			return fmt.Errorf("failed to write packet, %w", err)
+		}
+		c.bytes.Add(int64(n))
+		if c.packets.Add(1) >= c.PacketLimit && c.PacketLimit > 0 {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,547 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// offsets in an ethernet frame without vlan tag
+const (
+	offEtherDst   = 0
+	offEtherSrc   = 6
+	offEtherType  = 12
+	offIPv4Frag   = 20
+	offIPv4Proto  = 23
+	offIPv4Src    = 26
+	offIPv4Dst    = 30
+	offIPv6NxtHdr = 20
+	offIPv6Src    = 22
+	offIPv6Dst    = 38
+	// ports of an ipv4 packet are loaded relative to its header length in X
+	offIPv4L4    = 14
+	offIPv6SPort = 54
+	offIPv6DPort = 56
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeARP  = 0x0806
+	etherTypeIPv6 = 0x86dd
+	protoICMP     = 1
+	protoTCP      = 6
+	protoUDP      = 17
+	protoICMPv6   = 58
+	protoSCTP     = 132
+)
+
+// filter expression is parsed into a tree of these nodes
+type (
+	andNode struct{ l, r any }
+	orNode  struct{ l, r any }
+	notNode struct{ x any }
+	// cmpNode is true if the value at off, masked by mask if not zero, equals val;
+	// off is relative to the ipv4 header length if indirect
+	cmpNode struct {
+		off      uint32
+		size     int
+		indirect bool
+		mask     uint32
+		val      uint32
+	}
+	// lenNode compares the packet length with val
+	lenNode struct {
+		greater bool
+		val     uint32
+	}
+)
+
+func cmp(off uint32, size int, val uint32) cmpNode {
+	return cmpNode{off: off, size: size, val: val}
+}
+
+func and(nodes ...any) any {
+	r := nodes[0]
+	for _, n := range nodes[1:] {
+		r = andNode{l: r, r: n}
+	}
+	return r
+}
+
+func or(nodes ...any) any {
+	r := nodes[0]
+	for _, n := range nodes[1:] {
+		r = orNode{l: r, r: n}
+	}
+	return r
+}
+
+// CompileFilter compiles a pcap filter expression to classic BPF, accepted packets are truncated to snapLen.
+// a subset of the pcap-filter syntax is supported, frames are assumed to be untagged ethernet:
+//   - ip, ip6, arp, tcp, udp, sctp, icmp, icmp6
+//   - [src|dst] host <ip address>, [src|dst] net <prefix>, for both ipv4 and ipv6
+//   - [tcp|udp|sctp] [src|dst] port <number>
+//   - ether [src|dst|host] <mac>, ether proto <number>, [ether] broadcast, [ether] multicast
+//   - ip proto <number>, ip6 proto <number>, proto <number>
+//   - less <length>, greater <length>
+//   - and (&&), or (||), not (!) and parentheses
+func CompileFilter(expr string, snapLen int) ([]unix.SockFilter, error) {
+	p := &filterParser{tokens: tokenize(expr)}
+	if len(p.tokens) == 0 {
+		return nil, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != "" {
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	c := &filterCompiler{}
+	accept, reject := c.newLabel(), c.newLabel()
+	c.gen(root, accept, reject)
+	c.place(accept)
+	c.insts = append(c.insts, bpf.RetConstant{Val: uint32(snapLen)})
+	c.place(reject)
+	c.insts = append(c.insts, bpf.RetConstant{Val: 0})
+	if err := c.resolve(); err != nil {
+		return nil, err
+	}
+	if len(c.insts) > maxFilterLen {
+		return nil, fmt.Errorf("filter has %d instructions, exceeding %d", len(c.insts), maxFilterLen)
+	}
+	raw, err := bpf.Assemble(c.insts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble filter, %w", err)
+	}
+	r := make([]unix.SockFilter, len(raw))
+	for i, inst := range raw {
+		r[i] = unix.SockFilter{Code: inst.Op, Jt: inst.Jt, Jf: inst.Jf, K: inst.K}
+	}
+	return r, nil
+}
+
+// tokenize splits expr into words, parentheses and operators
+func tokenize(expr string) []string {
+	for _, op := range []string{"(", ")", "&&", "||"} {
+		expr = strings.ReplaceAll(expr, op, " "+op+" ")
+	}
+	r := []string{}
+	for _, f := range strings.Fields(expr) {
+		//"!" could be attached to the next word, e.g. !arp
+		for len(f) > 1 && strings.HasPrefix(f, "!") {
+			r = append(r, "!")
+			f = f[1:]
+		}
+		r = append(r, f)
+	}
+	return r
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() (string, error) {
+	tok := p.peek()
+	if tok == "" {
+		return "", fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	return tok, nil
+}
+
+func (p *filterParser) parseOr() (any, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" || p.peek() == "||" {
+		p.pos++
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseAnd() (any, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" || p.peek() == "&&" {
+		p.pos++
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseNot() (any, error) {
+	switch p.peek() {
+	case "not", "!":
+		p.pos++
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x: x}, nil
+	case "(":
+		p.pos++
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, err := p.next(); err != nil || tok != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return x, nil
+	}
+	return p.parsePrimitive()
+}
+
+func (p *filterParser) number(maxVal uint64) (uint32, error) {
+	tok, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(tok, 0, 32)
+	if err != nil || v > maxVal {
+		return 0, fmt.Errorf("invalid number %q", tok)
+	}
+	return uint32(v), nil
+}
+
+func (p *filterParser) parsePrimitive() (any, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case "ether":
+		return p.parseEther()
+	case "broadcast", "multicast":
+		p.pos--
+		return p.parseEther()
+	case "ip", "ip6":
+		if p.peek() != "proto" {
+			return ipNode(tok == "ip", tok == "ip6"), nil
+		}
+		p.pos++
+		proto, err := p.number(0xff)
+		if err != nil {
+			return nil, err
+		}
+		if tok == "ip" {
+			return ipProto(proto, true, false), nil
+		}
+		return ipProto(proto, false, true), nil
+	case "proto":
+		proto, err := p.number(0xff)
+		if err != nil {
+			return nil, err
+		}
+		return ipProto(proto, true, true), nil
+	case "arp":
+		return cmp(offEtherType, 2, etherTypeARP), nil
+	case "icmp":
+		return ipProto(protoICMP, true, false), nil
+	case "icmp6":
+		return ipProto(protoICMPv6, false, true), nil
+	case "tcp", "udp", "sctp":
+		proto := map[string]uint32{"tcp": protoTCP, "udp": protoUDP, "sctp": protoSCTP}[tok]
+		switch p.peek() {
+		case "src", "dst", "port":
+			return p.parseDir([]uint32{proto})
+		}
+		return ipProto(proto, true, true), nil
+	case "src", "dst", "host", "net", "port":
+		p.pos--
+		return p.parseDir(nil)
+	case "less", "greater":
+		n, err := p.number(0xffffffff)
+		if err != nil {
+			return nil, err
+		}
+		return lenNode{greater: tok == "greater", val: n}, nil
+	}
+	return nil, fmt.Errorf("unsupported primitive %q", tok)
+}
+
+// parseDir parses [src|dst] host|net|port, port is limited to protos if not empty
+func (p *filterParser) parseDir(protos []uint32) (any, error) {
+	src, dst := true, true
+	switch p.peek() {
+	case "src":
+		dst = false
+		p.pos++
+	case "dst":
+		src = false
+		p.pos++
+	}
+	kind := p.peek()
+	switch {
+	case kind == "host" || kind == "net" || kind == "port":
+		p.pos++
+	case src != dst && protos == nil:
+		//src/dst followed by an address directly means host
+		kind = "host"
+	default:
+		return nil, fmt.Errorf("expect host, net or port, got %q", kind)
+	}
+	if protos != nil && kind != "port" {
+		return nil, fmt.Errorf("expect port, got %q", kind)
+	}
+	switch kind {
+	case "host":
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		addr, err := netip.ParseAddr(tok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host %q, %w", tok, err)
+		}
+		return netNode(netip.PrefixFrom(addr, addr.BitLen()), src, dst), nil
+	case "net":
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := netip.ParsePrefix(tok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid net %q, %w", tok, err)
+		}
+		return netNode(prefix.Masked(), src, dst), nil
+	case "port":
+		port, err := p.number(0xffff)
+		if err != nil {
+			return nil, err
+		}
+		if protos == nil {
+			protos = []uint32{protoTCP, protoUDP, protoSCTP}
+		}
+		return portNode(protos, port, src, dst), nil
+	}
+	return nil, fmt.Errorf("unsupported primitive %q", kind)
+}
+
+func (p *filterParser) parseEther() (any, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case "proto":
+		v, err := p.number(0xffff)
+		if err != nil {
+			return nil, err
+		}
+		return cmp(offEtherType, 2, v), nil
+	case "broadcast":
+		return macNode(net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, offEtherDst), nil
+	case "multicast":
+		return cmpNode{off: offEtherDst, size: 1, mask: 1, val: 1}, nil
+	case "src", "dst", "host":
+		addr, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		mac, err := net.ParseMAC(addr)
+		if err != nil || len(mac) != 6 {
+			return nil, fmt.Errorf("invalid mac address %q", addr)
+		}
+		switch tok {
+		case "src":
+			return macNode(mac, offEtherSrc), nil
+		case "dst":
+			return macNode(mac, offEtherDst), nil
+		}
+		return or(macNode(mac, offEtherSrc), macNode(mac, offEtherDst)), nil
+	}
+	return nil, fmt.Errorf("unsupported ether primitive %q", tok)
+}
+
+func macNode(mac net.HardwareAddr, off uint32) any {
+	return and(cmp(off, 4, binary.BigEndian.Uint32(mac)), cmp(off+4, 2, uint32(binary.BigEndian.Uint16(mac[4:]))))
+}
+
+func ipNode(v4, v6 bool) any {
+	switch {
+	case v4 && v6:
+		return or(cmp(offEtherType, 2, etherTypeIPv4), cmp(offEtherType, 2, etherTypeIPv6))
+	case v4:
+		return cmp(offEtherType, 2, etherTypeIPv4)
+	}
+	return cmp(offEtherType, 2, etherTypeIPv6)
+}
+
+// ipProto matches ipv4 and/or ipv6 packets of proto, ipv6 extension headers are not followed
+func ipProto(proto uint32, v4, v6 bool) any {
+	nodes := []any{}
+	if v4 {
+		nodes = append(nodes, and(ipNode(true, false), cmp(offIPv4Proto, 1, proto)))
+	}
+	if v6 {
+		nodes = append(nodes, and(ipNode(false, true), cmp(offIPv6NxtHdr, 1, proto)))
+	}
+	return or(nodes...)
+}
+
+// netNode matches ip packets whose src and/or dst address is in prefix
+func netNode(prefix netip.Prefix, src, dst bool) any {
+	addrAt := func(off uint32) any {
+		b := prefix.Addr().AsSlice()
+		nodes := []any{}
+		for i := 0; i < len(b); i += 4 {
+			bits := min(max(prefix.Bits()-i*8, 0), 32)
+			if bits == 0 {
+				break
+			}
+			nodes = append(nodes, cmpNode{
+				off:  off + uint32(i),
+				size: 4,
+				mask: ^uint32(0) << (32 - bits),
+				val:  binary.BigEndian.Uint32(b[i:]),
+			})
+		}
+		if len(nodes) == 0 {
+			//0.0.0.0/0 or ::/0
+			return ipNode(prefix.Addr().Is4(), prefix.Addr().Is6())
+		}
+		return and(nodes...)
+	}
+	srcOff, dstOff := uint32(offIPv4Src), uint32(offIPv4Dst)
+	if prefix.Addr().Is6() {
+		srcOff, dstOff = offIPv6Src, offIPv6Dst
+	}
+	dirs := []any{}
+	if src {
+		dirs = append(dirs, addrAt(srcOff))
+	}
+	if dst {
+		dirs = append(dirs, addrAt(dstOff))
+	}
+	return and(ipNode(prefix.Addr().Is4(), prefix.Addr().Is6()), or(dirs...))
+}
+
+// portNode matches non-fragmented ipv4 and ipv6 packets of one of protos, whose src and/or dst port is port
+func portNode(protos []uint32, port uint32, src, dst bool) any {
+	v4Protos, v6Protos := []any{}, []any{}
+	for _, proto := range protos {
+		v4Protos = append(v4Protos, cmp(offIPv4Proto, 1, proto))
+		v6Protos = append(v6Protos, cmp(offIPv6NxtHdr, 1, proto))
+	}
+	v4Ports, v6Ports := []any{}, []any{}
+	if src {
+		v4Ports = append(v4Ports, cmpNode{off: offIPv4L4, size: 2, indirect: true, val: port})
+		v6Ports = append(v6Ports, cmp(offIPv6SPort, 2, port))
+	}
+	if dst {
+		v4Ports = append(v4Ports, cmpNode{off: offIPv4L4 + 2, size: 2, indirect: true, val: port})
+		v6Ports = append(v6Ports, cmp(offIPv6DPort, 2, port))
+	}
+	return or(
+		and(ipNode(true, false), or(v4Protos...), cmpNode{off: offIPv4Frag, size: 2, mask: 0x1fff, val: 0}, or(v4Ports...)),
+		and(ipNode(false, true), or(v6Protos...), or(v6Ports...)),
+	)
+}
+
+// filterCompiler generates instructions jumping to true/false labels, labels are resolved to skips at the end
+type filterCompiler struct {
+	insts []bpf.Instruction
+	// instruction index of each label
+	labels []int
+	// jump instructions and their target labels
+	jumps map[int][2]int
+}
+
+func (c *filterCompiler) newLabel() int {
+	c.labels = append(c.labels, -1)
+	return len(c.labels) - 1
+}
+
+func (c *filterCompiler) place(label int) {
+	c.labels[label] = len(c.insts)
+}
+
+func (c *filterCompiler) jump(inst bpf.JumpIf, t, f int) {
+	if c.jumps == nil {
+		c.jumps = map[int][2]int{}
+	}
+	c.jumps[len(c.insts)] = [2]int{t, f}
+	c.insts = append(c.insts, inst)
+}
+
+func (c *filterCompiler) gen(n any, t, f int) {
+	switch n := n.(type) {
+	case andNode:
+		m := c.newLabel()
+		c.gen(n.l, m, f)
+		c.place(m)
+		c.gen(n.r, t, f)
+	case orNode:
+		m := c.newLabel()
+		c.gen(n.l, t, m)
+		c.place(m)
+		c.gen(n.r, t, f)
+	case notNode:
+		c.gen(n.x, f, t)
+	case cmpNode:
+		if n.indirect {
+			c.insts = append(c.insts,
+				bpf.LoadMemShift{Off: offIPv4L4},
+				bpf.LoadIndirect{Off: n.off, Size: n.size})
+		} else {
+			c.insts = append(c.insts, bpf.LoadAbsolute{Off: n.off, Size: n.size})
+		}
+		if n.mask != 0 {
+			c.insts = append(c.insts, bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: n.mask})
+		}
+		c.jump(bpf.JumpIf{Cond: bpf.JumpEqual, Val: n.val}, t, f)
+	case lenNode:
+		c.insts = append(c.insts, bpf.LoadExtension{Num: bpf.ExtLen})
+		cond := bpf.JumpLessOrEqual
+		if n.greater {
+			cond = bpf.JumpGreaterOrEqual
+		}
+		c.jump(bpf.JumpIf{Cond: cond, Val: n.val}, t, f)
+	}
+}
+
+// resolve sets the skips of jump instructions
+func (c *filterCompiler) resolve() error {
+	for i, targets := range c.jumps {
+		skips := [2]int{}
+		for j, label := range targets {
+			skips[j] = c.labels[label] - i - 1
+			if skips[j] < 0 || skips[j] > 0xff {
+				return fmt.Errorf("filter is too complex")
+			}
+		}
+		inst := c.insts[i].(bpf.JumpIf)
+		inst.SkipTrue, inst.SkipFalse = uint8(skips[0]), uint8(skips[1])
+		c.insts[i] = inst
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package capture
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+
+	"golang.org/x/net/bpf"
+)
+
+var (
+	testSrcMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	testDstMAC = net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+)
+
+// newTestFrame returns an untagged ethernet frame with an ip header of proto from src to dst,
+// followed by the src and dst ports; the ipv4 header has options if v4Opts is true
+func newTestFrame(src, dst string, proto byte, sport, dport uint16, v4Opts bool) []byte {
+	s, d := netip.MustParseAddr(src), netip.MustParseAddr(dst)
+	frame := append(append([]byte{}, testDstMAC...), testSrcMAC...)
+	if s.Is4() {
+		hdrLen := 20
+		if v4Opts {
+			hdrLen = 24
+		}
+		frame = binary.BigEndian.AppendUint16(frame, etherTypeIPv4)
+		hdr := make([]byte, hdrLen)
+		hdr[0] = 0x40 | byte(hdrLen/4)
+		hdr[9] = proto
+		copy(hdr[12:], s.AsSlice())
+		copy(hdr[16:], d.AsSlice())
+		frame = append(frame, hdr...)
+	} else {
+		frame = binary.BigEndian.AppendUint16(frame, etherTypeIPv6)
+		hdr := make([]byte, 40)
+		hdr[0] = 0x60
+		hdr[6] = proto
+		copy(hdr[8:], s.AsSlice())
+		copy(hdr[24:], d.AsSlice())
+		frame = append(frame, hdr...)
+	}
+	frame = binary.BigEndian.AppendUint16(frame, sport)
+	frame = binary.BigEndian.AppendUint16(frame, dport)
+	return append(frame, make([]byte, 16)...)
+}
+
+func TestCompileFilter(t *testing.T) {
+	tcp4 := newTestFrame("10.1.1.1", "10.2.2.2", protoTCP, 1234, 80, false)
+	udp4Opts := newTestFrame("10.1.1.1", "10.2.2.2", protoUDP, 53, 4000, true)
+	tcp6 := newTestFrame("2001:db8::1", "2001:db8:1::2", protoTCP, 1234, 443, false)
+	icmp6 := newTestFrame("2001:db8::1", "ff02::1", protoICMPv6, 0, 0, false)
+	arp := append(append(append([]byte{}, testDstMAC...), testSrcMAC...), 0x08, 0x06)
+	arp = append(arp, make([]byte, 28)...)
+	bcast := append(net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, arp[6:]...)
+	frames := map[string][]byte{"tcp4": tcp4, "udp4Opts": udp4Opts, "tcp6": tcp6, "icmp6": icmp6, "arp": arp, "bcast": bcast}
+	cases := []struct {
+		expr    string
+		matches []string
+	}{
+		{expr: "ip", matches: []string{"tcp4", "udp4Opts"}},
+		{expr: "ip6", matches: []string{"tcp6", "icmp6"}},
+		{expr: "arp", matches: []string{"arp", "bcast"}},
+		{expr: "tcp", matches: []string{"tcp4", "tcp6"}},
+		{expr: "icmp6", matches: []string{"icmp6"}},
+		{expr: "ip proto 17", matches: []string{"udp4Opts"}},
+		{expr: "host 10.2.2.2", matches: []string{"tcp4", "udp4Opts"}},
+		{expr: "src host 10.2.2.2", matches: nil},
+		{expr: "dst 2001:db8:1::2", matches: []string{"tcp6"}},
+		{expr: "net 2001:db8::/32", matches: []string{"tcp6", "icmp6"}},
+		{expr: "src net 10.1.0.0/16 and dst net 10.2.0.0/16", matches: []string{"tcp4", "udp4Opts"}},
+		{expr: "port 80 or port 443", matches: []string{"tcp4", "tcp6"}},
+		//port is found after the ipv4 options
+		{expr: "udp src port 53", matches: []string{"udp4Opts"}},
+		{expr: "tcp dst port 53", matches: nil},
+		{expr: "ether src 02:00:00:00:00:01 && !ip", matches: []string{"tcp6", "icmp6", "arp", "bcast"}},
+		{expr: "ether dst 02:00:00:00:00:01", matches: nil},
+		{expr: "broadcast", matches: []string{"bcast"}},
+		{expr: "ether proto 0x806 and not broadcast", matches: []string{"arp"}},
+		{expr: "not (tcp or udp) and greater 60", matches: []string{"icmp6"}},
+		{expr: "less 50", matches: []string{"arp", "bcast"}},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			filter, err := CompileFilter(c.expr, 1500)
+			if err != nil {
+				t.Fatal(err)
+			}
+			insts := []bpf.Instruction{}
+			for _, f := range filter {
+				insts = append(insts, bpf.RawInstruction{Op: f.Code, Jt: f.Jt, Jf: f.Jf, K: f.K}.Disassemble())
+			}
+			vm, err := bpf.NewVM(insts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := map[string]bool{}
+			for _, name := range c.matches {
+				expected[name] = true
+			}
+			for name, frame := range frames {
+				n, err := vm.Run(frame)
+				if err != nil {
+					t.Fatalf("%v: %v", name, err)
+				}
+				if (n > 0) != expected[name] {
+					t.Errorf("%v: matched is %v, expect %v", name, n > 0, expected[name])
+				}
+			}
+		})
+	}
+}
+
+func TestCompileFilterError(t *testing.T) {
+	for _, expr := range []string{"tcp port", "host 10.1.1", "(ip", "ip)", "vlan 100", "tcp host 10.1.1.1", "port 70000", "ether src 1"} {
+		if _, err := CompileFilter(expr, 1500); err == nil {
+			t.Errorf("%q is accepted", expr)
+		}
+	}
+}
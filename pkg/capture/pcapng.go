@@ -0,0 +1,95 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// pcapng block types and options, see https://datatracker.ietf.org/doc/draft-ietf-opsawg-pcapng/
+const (
+	blockTypeSHB      = 0x0A0D0D0A
+	blockTypeIDB      = 0x00000001
+	blockTypeEPB      = 0x00000006
+	byteOrderMagic    = 0x1A2B3C4D
+	linkTypeEthernet  = 1
+	optCodeEndOfOpt   = 0
+	optCodeIfName     = 2
+	optCodeShbUserApp = 4
+)
+
+// pcapngWriter writes a single section pcapng file with a single interface,
+// timestamps are in microseconds
+type pcapngWriter struct {
+	w io.Writer
+	// number of bytes written so far
+	written int64
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
+
+// appendOption appends a pcapng option to buf
+func appendOption(buf []byte, code uint16, val []byte) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, code)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(val)))
+	buf = append(buf, val...)
+	return append(buf, make([]byte, pad4(len(val)))...)
+}
+
+// writeBlock writes a block with body, adding block type and lengths
+func (pw *pcapngWriter) writeBlock(blockType uint32, body []byte) error {
+	total := uint32(12 + len(body))
+	buf := make([]byte, 0, total)
+	buf = binary.LittleEndian.AppendUint32(buf, blockType)
+	buf = binary.LittleEndian.AppendUint32(buf, total)
+	buf = append(buf, body...)
+	buf = binary.LittleEndian.AppendUint32(buf, total)
+	n, err := pw.w.Write(buf)
+	pw.written += int64(n)
+	return err
+}
+
+// newPcapngWriter writes section header and interface description block to w
+func newPcapngWriter(w io.Writer, ifName string, snapLen int) (*pcapngWriter, error) {
+	pw := &pcapngWriter{w: w}
+	//section header block
+	shb := binary.LittleEndian.AppendUint32(nil, byteOrderMagic)
+	shb = binary.LittleEndian.AppendUint16(shb, 1) //major version
+	shb = binary.LittleEndian.AppendUint16(shb, 0) //minor version
+	shb = binary.LittleEndian.AppendUint64(shb, 0xFFFFFFFFFFFFFFFF)
+	shb = appendOption(shb, optCodeShbUserApp, []byte("k8slan"))
+	shb = appendOption(shb, optCodeEndOfOpt, nil)
+	if err := pw.writeBlock(blockTypeSHB, shb); err != nil {
+		return nil, err
+	}
+	//interface description block
+	idb := binary.LittleEndian.AppendUint16(nil, linkTypeEthernet)
+	idb = binary.LittleEndian.AppendUint16(idb, 0)
+	idb = binary.LittleEndian.AppendUint32(idb, uint32(snapLen))
+	idb = appendOption(idb, optCodeIfName, []byte(ifName))
+	idb = appendOption(idb, optCodeEndOfOpt, nil)
+	if err := pw.writeBlock(blockTypeIDB, idb); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// epbSize returns the size of the enhanced packet block of a packet with dataLen captured bytes
+func epbSize(dataLen int) int64 {
+	return int64(12 + 20 + dataLen + pad4(dataLen))
+}
+
+// writePacket writes an enhanced packet block, origLen is the length of the packet on the wire
+func (pw *pcapngWriter) writePacket(ts time.Time, data []byte, origLen int) error {
+	us := uint64(ts.UnixMicro())
+	epb := binary.LittleEndian.AppendUint32(nil, 0) //interface id
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(us>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(us))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(origLen))
+	epb = append(epb, data...)
+	epb = append(epb, make([]byte, pad4(len(data)))...)
+	return pw.writeBlock(blockTypeEPB, epb)
+}
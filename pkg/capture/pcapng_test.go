@@ -0,0 +1,30 @@
+package capture
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEPBSize(t *testing.T) {
+	buf := &bytes.Buffer{}
+	pw, err := newPcapngWriter(buf, "eth0", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pw.written != int64(buf.Len()) {
+		t.Fatalf("written is %d, expect %d", pw.written, buf.Len())
+	}
+	for _, n := range []int{0, 1, 60, 63, 1500} {
+		before := pw.written
+		if err := pw.writePacket(time.Now(), make([]byte, n), n); err != nil {
+			t.Fatal(err)
+		}
+		if got := pw.written - before; got != epbSize(n) {
+			t.Errorf("packet of %d bytes takes %d bytes, epbSize returns %d", n, got, epbSize(n))
+		}
+	}
+	if pw.written != int64(buf.Len()) {
+		t.Errorf("written is %d, expect %d", pw.written, buf.Len())
+	}
+}
@@ -0,0 +1,522 @@
+// Package clab converts a containerlab topology file into LANs, and pods or kubevirt VMs attached to them
+package clab
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// LabelTopology labels every generated object with the topology name
+	LabelTopology = "clab.k8slan.io/topology"
+	// LabelNode labels the pod or VM of a node with the node name
+	LabelNode = "clab.k8slan.io/node"
+
+	// VNIs of a topology are allocated from a block of VNIBlockSize VNIs after DefaultVNIBase,
+	// chosen by the hash of namespace and topology, see DeriveVNIBase
+	DefaultVNIBase  = 10000
+	VNIBlockSize    = 1000
+	DefaultVxLANGrp = "239.1.1.1"
+	DefaultVxDev    = "eth0"
+)
+
+// Topology is a containerlab topology file, only the fields used by the conversion are parsed
+type Topology struct {
+	Name     string      `json:"name"`
+	Topology TopologyDef `json:"topology"`
+}
+
+// TopologyDef is the topology section of a containerlab topology file
+type TopologyDef struct {
+	Defaults NodeDef            `json:"defaults,omitempty"`
+	Kinds    map[string]NodeDef `json:"kinds,omitempty"`
+	Nodes    map[string]NodeDef `json:"nodes"`
+	Links    []Link             `json:"links,omitempty"`
+}
+
+// NodeDef is a node, or the defaults of a kind or all nodes
+type NodeDef struct {
+	Kind   string            `json:"kind,omitempty"`
+	Image  string            `json:"image,omitempty"`
+	Cmd    string            `json:"cmd,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Link is a link between two endpoints
+type Link struct {
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Endpoint is an interface of a node, in the brief format "<node>:<interface>" or the extended format
+type Endpoint struct {
+	Node      string `json:"node"`
+	Interface string `json:"interface"`
+}
+
+func (ep *Endpoint) UnmarshalJSON(data []byte) error {
+	var brief string
+	if err := json.Unmarshal(data, &brief); err != nil {
+		type extended Endpoint
+		return json.Unmarshal(data, (*extended)(ep))
+	}
+	node, ifname, ok := strings.Cut(brief, ":")
+	if !ok {
+		return fmt.Errorf("invalid endpoint %v, expect <node>:<interface>", brief)
+	}
+	ep.Node, ep.Interface = node, ifname
+	return nil
+}
+
+func (ep Endpoint) String() string {
+	return ep.Node + ":" + ep.Interface
+}
+
+// Parse parses a containerlab topology file
+func Parse(data []byte) (*Topology, error) {
+	topo := &Topology{}
+	if err := yaml.Unmarshal(data, topo); err != nil {
+		return nil, fmt.Errorf("failed to parse topology, %w", err)
+	}
+	if topo.Name == "" {
+		return nil, fmt.Errorf("topology name is not specified")
+	}
+	for _, link := range topo.Topology.Links {
+		if len(link.Endpoints) != 2 {
+			return nil, fmt.Errorf("link %v doesn't have exactly 2 endpoints", link.Endpoints)
+		}
+	}
+	return topo, nil
+}
+
+// Options of the conversion
+type Options struct {
+	Namespace string
+	// VNIs are allocated from VNIBase, DeriveVNIBase(Namespace, topology) if 0
+	VNIBase int32
+	// UsedVNIs are skipped by the allocation, e.g. VNIs of existing LANs
+	UsedVNIs     []int32
+	VxLANGrp     string
+	DefaultVxDev string
+	// Presets overrides the built-in Presets
+	Presets map[string]Preset
+}
+
+// Result is the objects converted from a topology
+type Result struct {
+	LANs []*v1beta1.LAN
+	Pods []*corev1.Pod
+	// kubevirt VirtualMachines
+	VMs []*unstructured.Unstructured
+	// endpoints not converted, e.g. host and mgmt-net
+	Warnings []string
+}
+
+// converter keeps the state of a conversion
+type converter struct {
+	topo   *Topology
+	opts   Options
+	result *Result
+	// spoke names in use
+	spokes map[string]bool
+	// spoke of each endpoint
+	endpointSpokes map[Endpoint]string
+	nextVNI        int32
+	usedVNIs       map[int32]bool
+}
+
+// maxVNI is the largest 24-bit VNI
+const maxVNI = 0xFFFFFF
+
+// DeriveVNIBase returns the first VNI of topology in namespace, so that topologies imported into
+// different namespaces, or with different names, are unlikely to share VNIs
+func DeriveVNIBase(namespace, topology string) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(namespace + "/" + topology))
+	blocks := uint32(maxVNI-DefaultVNIBase)/VNIBlockSize - 1
+	return DefaultVNIBase + int32(h.Sum32()%blocks)*VNIBlockSize
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// sanitize returns s as a DNS label
+func sanitize(s string) string {
+	return strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// allocSpoke returns the spoke name of ep, it is unique across topologies and namespaces like v1beta1.GetLabSpokeName:
+// a prefix of <node>-<interface> with a hash suffix of namespace, topology and ep, within v1beta1.MaxSpokeNameLen
+func (c *converter) allocSpoke(ep Endpoint) string {
+	const suffixLen = 6
+	prefix := sanitize(ep.Node) + "-" + sanitize(ep.Interface)
+	prefix = strings.TrimRight(prefix[:min(len(prefix), v1beta1.MaxSpokeNameLen-suffixLen)], "-")
+	key := c.opts.Namespace + "/" + c.topo.Name + "/" + ep.String()
+	name := ""
+	for i := 0; name == "" || c.spokes[name]; i++ {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		if i > 0 {
+			//hash collision within the topology
+			fmt.Fprintf(h, "/%d", i)
+		}
+		name = fmt.Sprintf("%v-%05x", prefix, h.Sum32()&0xfffff)
+	}
+	c.spokes[name] = true
+	c.endpointSpokes[ep] = name
+	return name
+}
+
+// kind returns the kind of node, with aliases resolved
+func (c *converter) kind(node string) string {
+	k := c.topo.Topology.Nodes[node].Kind
+	if k == "" {
+		k = c.topo.Topology.Defaults.Kind
+	}
+	if alias, ok := kindAliases[k]; ok {
+		return alias
+	}
+	return k
+}
+
+func (c *converter) isBridge(node string) bool {
+	return slices.Contains(bridgeKinds, c.kind(node))
+}
+
+// allocVNI returns the next VNI not in Options.UsedVNIs
+func (c *converter) allocVNI() (int32, error) {
+	for c.usedVNIs[c.nextVNI] {
+		c.nextVNI++
+	}
+	if c.nextVNI > maxVNI {
+		return 0, fmt.Errorf("no VNI left, VNIs must be within %d", maxVNI)
+	}
+	c.nextVNI++
+	return c.nextVNI - 1, nil
+}
+
+// addLAN adds a LAN of name with spokes
+func (c *converter) addLAN(name string, spokes []string, p2p bool) error {
+	vni, err := c.allocVNI()
+	if err != nil {
+		return fmt.Errorf("failed to allocate VNI of LAN %v, %w", name, err)
+	}
+	str := func(s string) *string { return &s }
+	lan := &v1beta1.LAN{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1beta1.GroupVersion.String(), Kind: "LAN"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.opts.Namespace,
+			Labels:    map[string]string{LabelTopology: c.topo.Name},
+		},
+		Spec: v1beta1.LANSpec{
+			NS:           str(fmt.Sprintf("%v-%v", c.opts.Namespace, name)),
+			BridgeName:   str(fmt.Sprintf("br%d", vni)),
+			VxLANName:    str(fmt.Sprintf("vx%d", vni)),
+			VNI:          &vni,
+			VxLANGrp:     str(c.opts.VxLANGrp),
+			DefaultVxDev: c.opts.DefaultVxDev,
+			SpokeList:    spokes,
+		},
+	}
+	if p2p {
+		lan.Spec.Mode = v1beta1.LANModeP2P
+	}
+	c.result.LANs = append(c.result.LANs, lan)
+	return nil
+}
+
+// isSpecialNode returns true if node is a containerlab special endpoint, e.g. host:eth1
+func isSpecialNode(node string) bool {
+	switch node {
+	case "host", "mgmt-net", "macvlan":
+		return true
+	}
+	return false
+}
+
+// convertLinks converts the links to LANs, a link between two nodes is a p2p LAN,
+// links to a bridge node are a bridge mode LAN named after the bridge
+func (c *converter) convertLinks() error {
+	bridges := map[string][]string{}
+	bridgeOrder := []string{}
+	for i, link := range c.topo.Topology.Links {
+		eps := link.Endpoints
+		if isSpecialNode(eps[0].Node) || isSpecialNode(eps[1].Node) {
+			c.result.Warnings = append(c.result.Warnings, fmt.Sprintf("link %v-%v is skipped, %v and %v endpoints are not supported",
+				eps[0], eps[1], eps[0].Node, eps[1].Node))
+			continue
+		}
+		for _, ep := range eps {
+			if _, ok := c.topo.Topology.Nodes[ep.Node]; !ok {
+				return fmt.Errorf("link %v-%v refers to unknown node %v", eps[0], eps[1], ep.Node)
+			}
+			if _, ok := c.endpointSpokes[ep]; ok {
+				return fmt.Errorf("endpoint %v is used by more than one link", ep)
+			}
+		}
+		switch {
+		case c.isBridge(eps[0].Node) && c.isBridge(eps[1].Node):
+			return fmt.Errorf("link %v-%v between two bridges is not supported", eps[0], eps[1])
+		case c.isBridge(eps[0].Node) || c.isBridge(eps[1].Node):
+			br, ep := eps[0], eps[1]
+			if c.isBridge(ep.Node) {
+				br, ep = ep, br
+			}
+			if _, ok := bridges[br.Node]; !ok {
+				bridgeOrder = append(bridgeOrder, br.Node)
+			}
+			bridges[br.Node] = append(bridges[br.Node], c.allocSpoke(ep))
+		default:
+			spokes := []string{c.allocSpoke(eps[0]), c.allocSpoke(eps[1])}
+			if err := c.addLAN(fmt.Sprintf("%v-link%d", sanitize(c.topo.Name), i+1), spokes, true); err != nil {
+				return err
+			}
+		}
+	}
+	for _, br := range bridgeOrder {
+		if err := c.addLAN(fmt.Sprintf("%v-%v", sanitize(c.topo.Name), sanitize(br)), bridges[br], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeDef returns the node with the defaults of its kind and all nodes applied
+func (c *converter) nodeDef(name string) NodeDef {
+	def := c.topo.Topology.Nodes[name]
+	kindDef := c.topo.Topology.Kinds[c.kind(name)]
+	r := NodeDef{Kind: c.kind(name), Env: map[string]string{}, Labels: map[string]string{}}
+	for _, d := range []NodeDef{c.topo.Topology.Defaults, kindDef, def} {
+		if d.Image != "" {
+			r.Image = d.Image
+		}
+		if d.Cmd != "" {
+			r.Cmd = d.Cmd
+		}
+		maps.Copy(r.Env, d.Env)
+		maps.Copy(r.Labels, d.Labels)
+	}
+	return r
+}
+
+// nodeEndpoints returns the endpoints of node with a spoke, sorted by interface name
+func (c *converter) nodeEndpoints(node string) []Endpoint {
+	r := []Endpoint{}
+	for ep := range c.endpointSpokes {
+		if ep.Node == node {
+			r = append(r, ep)
+		}
+	}
+	slices.SortFunc(r, func(a, b Endpoint) int { return strings.Compare(a.Interface, b.Interface) })
+	return r
+}
+
+// convertNodes converts nodes other than bridges to pods or VMs
+func (c *converter) convertNodes() error {
+	for _, name := range slices.Sorted(maps.Keys(c.topo.Topology.Nodes)) {
+		if c.isBridge(name) {
+			continue
+		}
+		def := c.nodeDef(name)
+		preset, ok := c.opts.Presets[def.Kind]
+		if !ok {
+			if preset, ok = Presets[def.Kind]; !ok {
+				return fmt.Errorf("no preset for kind %v of node %v", def.Kind, name)
+			}
+		}
+		if def.Image == "" {
+			def.Image = preset.Image
+		}
+		if def.Image == "" {
+			return fmt.Errorf("image of node %v is not specified", name)
+		}
+		labels := map[string]string{LabelTopology: c.topo.Name, LabelNode: name}
+		maps.Copy(labels, def.Labels)
+		if preset.VM {
+			c.result.VMs = append(c.result.VMs, c.vm(name, def, preset, labels))
+		} else {
+			c.result.Pods = append(c.result.Pods, c.pod(name, def, preset, labels))
+		}
+	}
+	return nil
+}
+
+// pod returns the pod of node, each endpoint is attached via the veth NAD of its spoke
+func (c *converter) pod(node string, def NodeDef, preset Preset, labels map[string]string) *corev1.Pod {
+	networks := []string{}
+	resources := corev1.ResourceRequirements{Limits: corev1.ResourceList{}, Requests: corev1.ResourceList{}}
+	for _, ep := range c.nodeEndpoints(node) {
+		nad := v1beta1.GetNADName(c.endpointSpokes[ep], true)
+		networks = append(networks, fmt.Sprintf("%v@%v", nad, ep.Interface))
+		resources.Limits[corev1.ResourceName(v1beta1.ResourceNamespace+"/"+nad)] = resource.MustParse("1")
+	}
+	if preset.CPU != "" {
+		resources.Requests[corev1.ResourceCPU] = resource.MustParse(preset.CPU)
+		resources.Limits[corev1.ResourceCPU] = resource.MustParse(preset.CPU)
+	}
+	if preset.Memory != "" {
+		resources.Requests[corev1.ResourceMemory] = resource.MustParse(preset.Memory)
+		resources.Limits[corev1.ResourceMemory] = resource.MustParse(preset.Memory)
+	}
+	env := maps.Clone(preset.Env)
+	if env == nil {
+		env = map[string]string{}
+	}
+	maps.Copy(env, def.Env)
+	container := corev1.Container{
+		Name:      "main",
+		Image:     def.Image,
+		Command:   preset.Command,
+		Resources: resources,
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &preset.Privileged,
+		},
+	}
+	if def.Cmd != "" {
+		container.Args = strings.Fields(def.Cmd)
+	}
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		container.Env = append(container.Env, corev1.EnvVar{Name: k, Value: env[k]})
+	}
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sanitize(node),
+			Namespace: c.opts.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{container}},
+	}
+	if len(networks) > 0 {
+		pod.Annotations = map[string]string{ncv1.NetworkAttachmentAnnot: strings.Join(networks, ",")}
+	}
+	return pod
+}
+
+// vm returns the kubevirt VirtualMachine of node, each endpoint is attached via the macvtap NAD of its spoke
+func (c *converter) vm(node string, def NodeDef, preset Preset, labels map[string]string) *unstructured.Unstructured {
+	name := sanitize(node)
+	interfaces := []any{map[string]any{"name": "default", "masquerade": map[string]any{}}}
+	networks := []any{map[string]any{"name": "default", "pod": map[string]any{}}}
+	for _, ep := range c.nodeEndpoints(node) {
+		ifName := sanitize(ep.Interface)
+		interfaces = append(interfaces, map[string]any{"name": ifName, "binding": map[string]any{"name": "macvtap"}})
+		networks = append(networks, map[string]any{
+			"name":   ifName,
+			"multus": map[string]any{"networkName": v1beta1.GetNADName(c.endpointSpokes[ep], false)},
+		})
+	}
+	domain := map[string]any{
+		"devices": map[string]any{
+			"disks":      []any{map[string]any{"name": "containerdisk", "disk": map[string]any{"bus": "virtio"}}},
+			"interfaces": interfaces,
+		},
+	}
+	requests := map[string]any{}
+	if preset.Memory != "" {
+		requests["memory"] = preset.Memory
+	}
+	if preset.CPU != "" {
+		requests["cpu"] = preset.CPU
+	}
+	domain["resources"] = map[string]any{"requests": requests}
+	templateLabels := map[string]any{"kubevirt.io/domain": name}
+	for k, v := range labels {
+		templateLabels[k] = v
+	}
+	vm := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "kubevirt.io/v1",
+		"kind":       "VirtualMachine",
+		"spec": map[string]any{
+			"runStrategy": "Always",
+			"template": map[string]any{
+				"metadata": map[string]any{"labels": templateLabels},
+				"spec": map[string]any{
+					"domain":   domain,
+					"networks": networks,
+					"volumes": []any{map[string]any{
+						"name":          "containerdisk",
+						"containerDisk": map[string]any{"image": def.Image},
+					}},
+				},
+			},
+		},
+	}}
+	vm.SetName(name)
+	vm.SetNamespace(c.opts.Namespace)
+	vm.SetLabels(labels)
+	return vm
+}
+
+// Convert converts topo to LANs, pods and VMs in opts.Namespace
+func Convert(topo *Topology, opts Options) (*Result, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = "default"
+	}
+	if opts.VNIBase == 0 {
+		opts.VNIBase = DeriveVNIBase(opts.Namespace, topo.Name)
+	}
+	if opts.VxLANGrp == "" {
+		opts.VxLANGrp = DefaultVxLANGrp
+	}
+	if opts.DefaultVxDev == "" {
+		opts.DefaultVxDev = DefaultVxDev
+	}
+	c := &converter{
+		topo:           topo,
+		opts:           opts,
+		result:         &Result{},
+		spokes:         map[string]bool{},
+		endpointSpokes: map[Endpoint]string{},
+		nextVNI:        opts.VNIBase,
+		usedVNIs:       map[int32]bool{},
+	}
+	for _, vni := range opts.UsedVNIs {
+		c.usedVNIs[vni] = true
+	}
+	if err := c.convertLinks(); err != nil {
+		return nil, err
+	}
+	if err := c.convertNodes(); err != nil {
+		return nil, err
+	}
+	return c.result, nil
+}
+
+// Write writes objects of r to w as a multi-document YAML
+func (r *Result) Write(w io.Writer) error {
+	objs := []any{}
+	for _, lan := range r.LANs {
+		objs = append(objs, lan)
+	}
+	for _, pod := range r.Pods {
+		objs = append(objs, pod)
+	}
+	for _, vm := range r.VMs {
+		objs = append(objs, vm.Object)
+	}
+	for _, obj := range objs {
+		buf, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %T, %w", obj, err)
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package clab
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	ncv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+)
+
+const testTopology = `
+name: lab1
+topology:
+  kinds:
+    nokia_srlinux:
+      image: ghcr.io/nokia/srlinux:24.10
+  nodes:
+    srl1:
+      kind: srl
+    srl2:
+      kind: nokia_srlinux
+    client-with-a-long-name:
+      kind: linux
+    vm1:
+      kind: generic_vm
+      image: quay.io/containerdisks/fedora:latest
+    sw:
+      kind: bridge
+  links:
+    - endpoints: ["srl1:e1-1", "srl2:e1-1"]
+    - endpoints:
+        - node: srl1
+          interface: e1-2
+        - node: sw
+          interface: p1
+    - endpoints: ["client-with-a-long-name:eth1", "sw:p2"]
+    - endpoints: ["vm1:eth1", "sw:p3"]
+    - endpoints: ["srl2:e1-2", "host:srl2-e1-2"]
+`
+
+func TestConvert(t *testing.T) {
+	topo, err := Parse([]byte(testTopology))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Convert(topo, Options{Namespace: "lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.LANs) != 2 || len(r.Pods) != 3 || len(r.VMs) != 1 || len(r.Warnings) != 1 {
+		t.Fatalf("unexpected result: %d LANs, %d pods, %d VMs, warnings %v", len(r.LANs), len(r.Pods), len(r.VMs), r.Warnings)
+	}
+	base := DeriveVNIBase("lab", "lab1")
+	p2p := r.LANs[0]
+	if p2p.Name != "lab1-link1" || !p2p.Spec.IsP2P() || *p2p.Spec.VNI != base || len(p2p.Spec.SpokeList) != 2 ||
+		!strings.HasPrefix(p2p.Spec.SpokeList[0], "srl1-e1-") || !strings.HasPrefix(p2p.Spec.SpokeList[1], "srl2-e1-") {
+		t.Fatalf("unexpected p2p LAN %+v", p2p.Spec)
+	}
+	br := r.LANs[1]
+	if br.Name != "lab1-sw" || br.Spec.IsP2P() || *br.Spec.VNI != base+1 || len(br.Spec.SpokeList) != 3 {
+		t.Fatalf("unexpected bridge LAN %+v", br.Spec)
+	}
+	for _, lan := range r.LANs {
+		for _, spoke := range lan.Spec.SpokeList {
+			if len(spoke) > v1beta1.MaxSpokeNameLen {
+				t.Fatalf("spoke %v is too long", spoke)
+			}
+		}
+	}
+	for _, pod := range r.Pods {
+		if pod.Name == "srl1" {
+			if pod.Spec.Containers[0].Image != "ghcr.io/nokia/srlinux:24.10" {
+				t.Fatalf("image of kind is not used, %v", pod.Spec.Containers[0].Image)
+			}
+			expected := fmt.Sprintf("%v@e1-1,%v@e1-2", v1beta1.GetNADName(p2p.Spec.SpokeList[0], true), v1beta1.GetNADName(br.Spec.SpokeList[0], true))
+			if pod.Annotations[ncv1.NetworkAttachmentAnnot] != expected {
+				t.Fatalf("unexpected networks %v", pod.Annotations[ncv1.NetworkAttachmentAnnot])
+			}
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "---\n") != 6 || !strings.Contains(buf.String(), "k8slan-mac-vm1-eth") {
+		t.Fatalf("unexpected output:\n%v", buf.String())
+	}
+}
+
+func TestConvertNamespaces(t *testing.T) {
+	topo, err := Parse([]byte(testTopology))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1, err := Convert(topo, Options{Namespace: "ns1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the VNIs of r1 are in use, e.g. imported with the same base
+	used := []int32{*r1.LANs[0].Spec.VNI, *r1.LANs[1].Spec.VNI}
+	r2, err := Convert(topo, Options{Namespace: "ns2", VNIBase: used[0], UsedVNIs: used})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, lan := range r2.LANs {
+		if slices.Contains(used, *lan.Spec.VNI) {
+			t.Errorf("VNI %d of %v is already used", *lan.Spec.VNI, lan.Name)
+		}
+		for _, spoke := range lan.Spec.SpokeList {
+			if slices.Contains(r1.LANs[i].Spec.SpokeList, spoke) {
+				t.Errorf("spoke %v is used in both namespaces", spoke)
+			}
+		}
+	}
+	if DeriveVNIBase("ns1", "lab1") == DeriveVNIBase("ns2", "lab1") {
+		t.Error("same VNI base is derived for different namespaces")
+	}
+	if _, err := Convert(topo, Options{Namespace: "ns3", VNIBase: maxVNI}); err == nil {
+		t.Error("expect error for VNI out of range")
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	for _, topo := range []string{
+		"topology: {nodes: {a: {kind: linux}}}",
+		"name: x\ntopology: {nodes: {a: {kind: linux}}, links: [{endpoints: [\"a:e1\"]}]}",
+		"name: x\ntopology: {nodes: {a: {kind: linux}}, links: [{endpoints: [\"a:e1\", \"b:e1\"]}]}",
+		"name: x\ntopology: {nodes: {a: {kind: unknown, image: x}}}",
+	} {
+		t.Run(topo, func(t *testing.T) {
+			parsed, err := Parse([]byte(topo))
+			if err == nil {
+				_, err = Convert(parsed, Options{})
+			}
+			if err == nil {
+				t.Fatal("expect error")
+			}
+		})
+	}
+}
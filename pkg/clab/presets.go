@@ -0,0 +1,69 @@
+package clab
+
+// Preset is the workload of a containerlab node kind
+type Preset struct {
+	// Image is used if the node doesn't specify one
+	Image   string
+	Command []string
+	Env     map[string]string
+	// Privileged runs the container in privileged mode
+	Privileged bool
+	// CPU and Memory are requests and limits of the workload, e.g. "2" and "4Gi"
+	CPU    string
+	Memory string
+	// VM is true if the node is a kubevirt VirtualMachine with Image as its container disk, otherwise a pod
+	VM bool
+}
+
+// Presets is the preset of each node kind, key is the kind in the topology file
+var Presets = map[string]Preset{
+	"nokia_srlinux": {
+		Image: "ghcr.io/nokia/srlinux:latest",
+		Command: []string{"/tini", "--", "/usr/local/bin/fixuid", "-q", "/entrypoint.sh",
+			"sudo", "-E", "bash", "-c", "touch /.dockerenv && /opt/srlinux/bin/sr_linux"},
+		Privileged: true,
+		CPU:        "2",
+		Memory:     "4Gi",
+	},
+	"arista_ceos": {
+		Image:   "ceos:latest",
+		Command: []string{"/sbin/init"},
+		Env: map[string]string{
+			"CEOS":                                "1",
+			"EOS_PLATFORM":                        "ceoslab",
+			"container":                           "docker",
+			"ETBA":                                "1",
+			"SKIP_ZEROTOUCH_BARRIER_IN_SYSDBINIT": "1",
+			"INTFTYPE":                            "eth",
+			"MAPETH0":                             "1",
+			"MGMT_INTF":                           "eth0",
+		},
+		Privileged: true,
+		CPU:        "1",
+		Memory:     "2Gi",
+	},
+	"juniper_crpd": {
+		Image:      "crpd:latest",
+		Privileged: true,
+		CPU:        "1",
+		Memory:     "1Gi",
+	},
+	"linux": {
+		Image:      "ghcr.io/hellt/network-multitool:latest",
+		Privileged: true,
+	},
+	"generic_vm": {
+		Memory: "1Gi",
+		VM:     true,
+	},
+}
+
+// kind aliases accepted by containerlab
+var kindAliases = map[string]string{
+	"srl":  "nokia_srlinux",
+	"ceos": "arista_ceos",
+	"crpd": "juniper_crpd",
+}
+
+// bridge kinds, links to a node of these kinds are merged into one bridge mode LAN
+var bridgeKinds = []string{"bridge", "ovs-bridge"}
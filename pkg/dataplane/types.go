@@ -0,0 +1,61 @@
+// Package dataplane defines the dataplane state of LANs served by the LAN daemonset
+package dataplane
+
+import "fmt"
+
+const (
+	// dataplane state of LANs are served under this path of the daemonset metrics server,
+	// e.g. /lans/<namespace>/<name>/fdb
+	URLPathPrefix = "/lans/"
+	// path suffix for the bridge FDB
+	FDBPath = "fdb"
+	// path suffix for the interface state
+	StatePath = "state"
+	// path suffix for the steps needed to repair drift of the LAN, as a list of strings, empty if there is no drift
+	PlanPath = "plan"
+	// TokenHeader carries the bearer token of requests via the pods/proxy subresource,
+	// since the API server doesn't forward the Authorization header to the pod
+	TokenHeader = "X-K8slan-Token"
+)
+
+// GetURLPath returns the url path of the state of the LAN, kind is one of FDBPath, StatePath and PlanPath
+func GetURLPath(namespace, name, kind string) string {
+	return fmt.Sprintf("%v%v/%v/%v", URLPathPrefix, namespace, name, kind)
+}
+
+// roles of interfaces in the LAN NS
+const (
+	RoleBridge = "bridge"
+	RoleVxLAN  = "vxlan"
+	RolePeer   = "peer"
+)
+
+// FDBEntry is an entry of the bridge FDB of a LAN
+type FDBEntry struct {
+	MAC string `json:"mac"`
+	// the bridge port
+	Port string `json:"port"`
+	// spoke name if the port is a spoke's peer veth
+	Spoke   string `json:"spoke,omitempty"`
+	VLAN    int    `json:"vlan,omitempty"`
+	Learned bool   `json:"learned"`
+}
+
+// LinkState is the state of an interface in the LAN NS
+type LinkState struct {
+	Name string `json:"name"`
+	// one of RoleBridge, RoleVxLAN or RolePeer
+	Role  string `json:"role"`
+	Spoke string `json:"spoke,omitempty"`
+	// false if the interface doesn't exist
+	Exists bool `json:"exists"`
+	Up     bool `json:"up"`
+	MTU    int  `json:"mtu,omitempty"`
+}
+
+// LANState is the dataplane state of a LAN on a node
+type LANState struct {
+	Node     string      `json:"node"`
+	NSExists bool        `json:"nsExists"`
+	Links    []LinkState `json:"links,omitempty"`
+}
@@ -0,0 +1,64 @@
+package deviceplugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/interfaces"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// findRequestingPods returns the pending pods on this node requesting the resource of the plugin,
+// kubelet doesn't tell which pod an allocation is for
+func (mdp *macvtapDevicePlugin) findRequestingPods(ctx context.Context) []*corev1.Pod {
+	if mdp.lister == nil || mdp.lister.podReader == nil {
+		return nil
+	}
+	log := ctrl.Log.WithName("deviceplugin")
+	podList := &corev1.PodList{}
+	err := mdp.lister.podReader.List(ctx, podList, client.MatchingFields{"spec.nodeName": mdp.hostName})
+	if err != nil {
+		log.Error(err, "failed to list pods", "node", mdp.hostName)
+		return nil
+	}
+	resName := corev1.ResourceName(fmt.Sprintf("%v/%v", v1beta1.ResourceNamespace, mdp.resName))
+	r := []*corev1.Pod{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			if _, ok := c.Resources.Limits[resName]; ok {
+				r = append(r, pod)
+				break
+			}
+			if _, ok := c.Resources.Requests[resName]; ok {
+				r = append(r, pod)
+				break
+			}
+		}
+	}
+	return r
+}
+
+// eventFunc returns an interfaces.EventFunc records events on lan and the requesting pods,
+// nil if there is no event recorder
+func (mdp *macvtapDevicePlugin) eventFunc(ctx context.Context, lan *v1beta1.LAN) interfaces.EventFunc {
+	if mdp.lister == nil || mdp.lister.recorder == nil {
+		return nil
+	}
+	objs := []runtime.Object{lan}
+	for _, pod := range mdp.findRequestingPods(ctx) {
+		objs = append(objs, pod)
+	}
+	return func(eventType, reason, message string) {
+		for _, obj := range objs {
+			mdp.lister.recorder.Eventf(obj, eventType, reason, "node %v: %v", mdp.hostName, message)
+		}
+	}
+}
@@ -3,21 +3,36 @@ package deviceplugin
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/hujun-open/k8slan/api/v1beta1"
 	"github.com/kubevirt/device-plugin-manager/pkg/dpm"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type macvtapLister struct {
-	DeviceList map[string]*v1beta1.LANSpec //key is the vlan name in the LAN
-	// lock   *sync.RWMutex
+	DeviceList map[string]*v1beta1.LAN //key is the vlan name in the LAN
+	lock       *sync.RWMutex
 	// NetNsPath is the path to the network namespace the lister operates in.
-	AddChan   chan *v1beta1.LANSpec
-	RemovChan chan *v1beta1.LANSpec
+	AddChan   chan *v1beta1.LAN
+	RemovChan chan *v1beta1.LAN
+	// recorder and podReader are used to report events on the LAN and the requesting pod
+	recorder  record.EventRecorder
+	podReader client.Reader
+}
+
+// getLAN returns the latest LAN of the specified resource, nil if not found
+func (ml *macvtapLister) getLAN(name string) *v1beta1.LAN {
+	ml.lock.RLock()
+	defer ml.lock.RUnlock()
+	return ml.DeviceList[name]
 }
 
 func (ml *macvtapLister) getCurrentPlugins() dpm.PluginNameList {
+	ml.lock.RLock()
+	defer ml.lock.RUnlock()
 	r := make(dpm.PluginNameList, 0)
 	for name := range ml.DeviceList {
 		r = append(r, name)
@@ -25,11 +40,16 @@ func (ml *macvtapLister) getCurrentPlugins() dpm.PluginNameList {
 	return r
 }
 
-func NewMacvtapLister(netNsPath string, add, remove chan *v1beta1.LANSpec) *macvtapLister {
+// NewMacvtapLister returns a lister of spokes of LANs received from add/remove,
+// recorder and podReader could be nil if events are not needed
+func NewMacvtapLister(netNsPath string, add, remove chan *v1beta1.LAN, recorder record.EventRecorder, podReader client.Reader) *macvtapLister {
 	return &macvtapLister{
 		AddChan:    add,
 		RemovChan:  remove,
-		DeviceList: make(map[string]*v1beta1.LANSpec),
+		DeviceList: make(map[string]*v1beta1.LAN),
+		lock:       new(sync.RWMutex),
+		recorder:   recorder,
+		podReader:  podReader,
 	}
 }
 
@@ -47,17 +67,21 @@ func (ml *macvtapLister) Discover(pluginListCh chan dpm.PluginNameList) {
 	for {
 		select {
 		case lan := <-ml.AddChan:
-			for _, spokeName := range lan.SpokeList {
+			ml.lock.Lock()
+			for _, spokeName := range lan.Spec.SpokeList {
 				ml.DeviceList[v1beta1.GetDPResouceName(spokeName, true)] = lan
 				ml.DeviceList[v1beta1.GetDPResouceName(spokeName, false)] = lan
 			}
+			ml.lock.Unlock()
 			ml.report(pluginListCh)
 
 		case lan := <-ml.RemovChan:
-			for _, vlanName := range lan.SpokeList {
+			ml.lock.Lock()
+			for _, vlanName := range lan.Spec.SpokeList {
 				delete(ml.DeviceList, v1beta1.GetDPResouceName(vlanName, true))
 				delete(ml.DeviceList, v1beta1.GetDPResouceName(vlanName, false))
 			}
+			ml.lock.Unlock()
 			ml.report(pluginListCh)
 
 		}
@@ -68,13 +92,13 @@ func (ml *macvtapLister) Discover(pluginListCh chan dpm.PluginNameList) {
 // also vlanName in k8slan case
 func (ml *macvtapLister) NewPlugin(name string) dpm.PluginInterface {
 	log := ctrl.Log.WithName("deviceplugin")
-	lan, ok := ml.DeviceList[name]
-	if !ok {
+	lan := ml.getLAN(name)
+	if lan == nil {
 		return nil
 	}
 
-	log.Info("Creating device plugin", "name", name, "config", lan)
-	return NewMacvtapDevicePlugin(name, lan)
+	log.Info("Creating device plugin", "name", name, "config", lan.Spec)
+	return NewMacvtapDevicePlugin(name, lan, ml)
 }
 
 // GetMainThreadNetNsPath returns the path of the main thread's namespace
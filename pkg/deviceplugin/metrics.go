@@ -0,0 +1,26 @@
+package deviceplugin
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	allocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "k8slan_allocations_total",
+		Help: "Number of device plugin allocations",
+	}, []string{"spoke"})
+	ensureFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "k8slan_ensure_failures_total",
+		Help: "Number of failures creating the interfaces of a spoke",
+	}, []string{"spoke"})
+	ensureDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "k8slan_ensure_duration_seconds",
+		Help:    "Time spent creating the interfaces of a spoke",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+	}, []string{"spoke"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(allocations, ensureFailures, ensureDuration)
+}
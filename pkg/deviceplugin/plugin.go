@@ -9,6 +9,7 @@ import (
 	"github.com/hujun-open/k8slan/api/v1beta1"
 	"github.com/hujun-open/k8slan/pkg/interfaces"
 	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
@@ -26,8 +27,10 @@ const (
 
 type macvtapDevicePlugin struct {
 	Name         string
+	resName      string
 	hostName     string
-	lan          *v1beta1.LANSpec
+	lan          *v1beta1.LAN
+	lister       *macvtapLister
 	Capacity     int
 	Mode         string
 	stopWatcher  chan struct{}
@@ -35,21 +38,33 @@ type macvtapDevicePlugin struct {
 	pluginapi.UnimplementedDevicePluginServer
 }
 
-func NewMacvtapDevicePlugin(name string, lan *v1beta1.LANSpec) *macvtapDevicePlugin {
+func NewMacvtapDevicePlugin(name string, lan *v1beta1.LAN, lister *macvtapLister) *macvtapDevicePlugin {
 	hname, err := os.Hostname()
 	if err != nil {
 		panic(err)
 	}
 	return &macvtapDevicePlugin{
 		Name:         v1beta1.GetSpokeNameFromResourceName(name),
+		resName:      name,
 		Mode:         DefaultMode,
 		lan:          lan,
+		lister:       lister,
 		stopWatcher:  make(chan struct{}),
 		hostName:     hname,
 		dummyMACVTAP: strings.HasPrefix(name, v1beta1.VETHPreffix),
 	}
 }
 
+// currentLAN returns the latest LAN, since some fields could be changed on a live LAN
+func (mdp *macvtapDevicePlugin) currentLAN() *v1beta1.LAN {
+	if mdp.lister != nil {
+		if lan := mdp.lister.getLAN(mdp.resName); lan != nil {
+			return lan
+		}
+	}
+	return mdp.lan
+}
+
 func (mdp *macvtapDevicePlugin) generateMacvtapDevices() []*pluginapi.Device {
 	var macvtapDevs []*pluginapi.Device
 
@@ -99,8 +114,18 @@ func (mdp *macvtapDevicePlugin) Allocate(ctx context.Context, r *pluginapi.Alloc
 			var index int
 			var err error
 			// index, err = util.RecreateMacvtap(name, mdp.LowerDevice, mdp.Mode)
-			index, err = interfaces.Ensure(macVtapName, mdp.Name, mdp.lan, mdp.hostName, mdp.Mode, mdp.dummyMACVTAP)
+			allocations.WithLabelValues(mdp.Name).Inc()
+			lan := mdp.currentLAN()
+			event := mdp.eventFunc(ctx, lan)
+			start := time.Now()
+			index, err = interfaces.Ensure(macVtapName, mdp.Name, lan, mdp.hostName, mdp.Mode, mdp.dummyMACVTAP, event)
+			ensureDuration.WithLabelValues(mdp.Name).Observe(time.Since(start).Seconds())
 			if err != nil {
+				ensureFailures.WithLabelValues(mdp.Name).Inc()
+				if event != nil {
+					event(corev1.EventTypeWarning, interfaces.ReasonEnsureFailed,
+						fmt.Sprintf("failed to create interfaces of spoke %v, %v", mdp.Name, err))
+				}
 				return nil, err
 			}
 
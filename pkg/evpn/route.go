@@ -0,0 +1,119 @@
+// Package evpn implements a BGP EVPN speaker (RFC 7432, RFC 8365) for vxlan LANs on top of GoBGP,
+// it exchanges MAC/IP advertisement (type-2) and inclusive multicast Ethernet tag (type-3) routes over iBGP sessions,
+// and could act as a route reflector (RFC 4456), so workers could distribute routes without external routers
+package evpn
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/apiutil"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+)
+
+// EVPN route types
+const (
+	RouteTypeMACIP = bgp.EVPN_ROUTE_TYPE_MAC_IP_ADVERTISEMENT
+	RouteTypeIMET  = bgp.EVPN_INCLUSIVE_MULTICAST_ETHERNET_TAG
+)
+
+var familyEVPN = &api.Family{Afi: api.Family_AFI_L2VPN, Safi: api.Family_SAFI_EVPN}
+
+// Route is an EVPN route of the vxlan network VNI
+type Route struct {
+	Type uint8
+	VNI  uint32
+	// VTEP is the next hop of a type-2 route, and the originating router of a type-3 route
+	VTEP netip.Addr
+	// MAC and the optional IP of a type-2 route
+	MAC net.HardwareAddr
+	IP  netip.Addr
+}
+
+func (r Route) String() string {
+	if r.Type == RouteTypeIMET {
+		return fmt.Sprintf("imet vni %d vtep %v", r.VNI, r.VTEP)
+	}
+	if r.IP.IsValid() {
+		return fmt.Sprintf("macip vni %d %v %v vtep %v", r.VNI, r.MAC, r.IP, r.VTEP)
+	}
+	return fmt.Sprintf("macip vni %d %v vtep %v", r.VNI, r.MAC, r.VTEP)
+}
+
+// nlri returns the EVPN NLRI of r originated by routerID, the RD is type 1 of the router ID and the lower 16 bits of VNI,
+// the Ethernet tag and the label are the VNI
+func (r Route) nlri(routerID netip.Addr) *bgp.EVPNNLRI {
+	rd := bgp.NewRouteDistinguisherIPAddressAS(routerID.String(), uint16(r.VNI))
+	if r.Type == RouteTypeIMET {
+		return bgp.NewEVPNMulticastEthernetTagRoute(rd, r.VNI, r.VTEP.String())
+	}
+	ip := ""
+	if r.IP.IsValid() {
+		ip = r.IP.String()
+	}
+	return bgp.NewEVPNMacIPAdvertisementRoute(rd, bgp.EthernetSegmentIdentifier{}, r.VNI, r.MAC.String(), ip, []uint32{r.VNI})
+}
+
+// toPath returns the path advertising r originated by routerID in as, with the route target of as and VNI,
+// the vxlan encapsulation, and the ingress replication PMSI tunnel of a type-3 route
+func (r Route) toPath(routerID netip.Addr, as uint32) (*api.Path, error) {
+	var rt bgp.ExtendedCommunityInterface
+	if as <= 0xffff {
+		rt = bgp.NewTwoOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, uint16(as), r.VNI, true)
+	} else {
+		rt = bgp.NewFourOctetAsSpecificExtended(bgp.EC_SUBTYPE_ROUTE_TARGET, as, uint16(r.VNI), true)
+	}
+	nlri := r.nlri(routerID)
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(bgp.BGP_ORIGIN_ATTR_TYPE_IGP),
+		bgp.NewPathAttributeMpReachNLRI(r.VTEP.String(), []bgp.AddrPrefixInterface{nlri}),
+		bgp.NewPathAttributeExtendedCommunities([]bgp.ExtendedCommunityInterface{rt, bgp.NewEncapExtended(bgp.TUNNEL_TYPE_VXLAN)}),
+	}
+	if r.Type == RouteTypeIMET {
+		attrs = append(attrs, bgp.NewPathAttributePmsiTunnel(bgp.PMSI_TUNNEL_TYPE_INGRESS_REPL, false, r.VNI, bgp.NewIngressReplTunnelID(r.VTEP.String())))
+	}
+	return apiutil.NewPath(nlri, false, attrs, time.Now())
+}
+
+// parsePath returns the route of p, ok is false if p is not a supported EVPN route;
+// VNI of a route is its Ethernet tag, or the label of a type-2 route if the tag is 0
+func parsePath(p *api.Path) (r Route, ok bool) {
+	n, err := apiutil.GetNativeNlri(p)
+	if err != nil {
+		return r, false
+	}
+	nlri, ok := n.(*bgp.EVPNNLRI)
+	if !ok {
+		return r, false
+	}
+	switch data := nlri.RouteTypeData.(type) {
+	case *bgp.EVPNMacIPAdvertisementRoute:
+		r = Route{Type: RouteTypeMACIP, VNI: data.ETag, MAC: data.MacAddress}
+		if r.VNI == 0 && len(data.Labels) > 0 {
+			r.VNI = data.Labels[0]
+		}
+		if ip, ok := netip.AddrFromSlice(data.IPAddress); ok {
+			r.IP = ip.Unmap()
+		}
+		attrs, err := apiutil.GetNativePathAttributes(p)
+		if err != nil {
+			return r, false
+		}
+		for _, a := range attrs {
+			if reach, ok := a.(*bgp.PathAttributeMpReachNLRI); ok {
+				r.VTEP, _ = netip.AddrFromSlice(reach.Nexthop)
+				r.VTEP = r.VTEP.Unmap()
+			}
+		}
+	case *bgp.EVPNMulticastEthernetTagRoute:
+		r = Route{Type: RouteTypeIMET, VNI: data.ETag}
+		r.VTEP, _ = netip.AddrFromSlice(data.IPAddress)
+		r.VTEP = r.VTEP.Unmap()
+	default:
+		return r, false
+	}
+	return r, r.VTEP.IsValid()
+}
@@ -0,0 +1,28 @@
+package evpn
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestRoutePath(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	routes := []Route{
+		{Type: RouteTypeIMET, VNI: 100, VTEP: netip.MustParseAddr("192.0.2.1")},
+		{Type: RouteTypeMACIP, VNI: 100, VTEP: netip.MustParseAddr("192.0.2.1"), MAC: mac},
+		{Type: RouteTypeMACIP, VNI: 100, VTEP: netip.MustParseAddr("2001:db8::1"), MAC: mac, IP: netip.MustParseAddr("2001:db8:1::1")},
+	}
+	for _, as := range []uint32{DefaultAS, 4200000000} {
+		for _, r := range routes {
+			p, err := r.toPath(netip.MustParseAddr("10.0.0.1"), as)
+			if err != nil {
+				t.Fatalf("%v: %v", r, err)
+			}
+			got, ok := parsePath(p)
+			if !ok || got.String() != r.String() {
+				t.Errorf("parsed %v, expect %v", got, r)
+			}
+		}
+	}
+}
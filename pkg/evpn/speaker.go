@@ -0,0 +1,473 @@
+package evpn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	api "github.com/osrg/gobgp/v3/api"
+	gobgplog "github.com/osrg/gobgp/v3/pkg/log"
+	"github.com/osrg/gobgp/v3/pkg/server"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// DefaultAS is the private AS of iBGP sessions between workers
+	DefaultAS = 64512
+	// DefaultPort is the BGP port
+	DefaultPort     = 179
+	defaultHoldTime = 90 * time.Second
+	minHoldTime     = 3 * time.Second
+	retryInterval   = 5 * time.Second
+	// clientGroup is the peer group of route reflector clients
+	clientGroup = "clients"
+)
+
+// Config is the configuration of a Speaker
+type Config struct {
+	// RouterID is the BGP identifier, it must be an IPv4 address
+	RouterID netip.Addr
+	// AS is the AS of the speaker and all its neighbors, only iBGP is supported
+	AS uint32
+	// Neighbors are the addresses as host:port the speaker connects to, e.g. route reflectors
+	Neighbors []string
+	// LocalAddr is the optional source address of sessions to Neighbors
+	LocalAddr netip.Addr
+	// RouteReflector enables route reflection, the speaker accepts sessions on ListenAddr and treats their peers as clients,
+	// routes of a client are reflected to all other peers, routes of a neighbor are reflected to clients
+	RouteReflector bool
+	// ListenAddr is the address as host:port sessions of clients are accepted on, only used by a route reflector
+	ListenAddr string
+	// HoldTime is the proposed BGP hold time, default 90s
+	HoldTime time.Duration
+	// OnChange is called with the VNI whose routes of other speakers changed, it must not block
+	OnChange func(vni uint32)
+}
+
+// MACIP is a MAC address behind a VTEP, along with its IP addresses if known
+type MACIP struct {
+	MAC  net.HardwareAddr
+	VTEP netip.Addr
+	IPs  []netip.Addr
+}
+
+// Speaker is a BGP speaker exchanging EVPN routes of vxlan networks with its neighbors, backed by an embedded GoBGP server
+type Speaker struct {
+	cfg Config
+	srv *server.BgpServer
+	// listenAddr is the resolved ListenAddr of a route reflector
+	listenAddr *net.TCPAddr
+	lock       *sync.Mutex
+	// started is true while the GoBGP server runs, routes are only added to it then
+	started bool
+	// local routes by VNI, then by route key
+	local map[uint32]map[string]Route
+	// uuids of the local paths added to the GoBGP server, by route key
+	uuids map[string][]byte
+}
+
+// NewSpeaker returns a speaker of cfg, a route reflector listening on port 0 gets a free port here so that its address is known before Start
+func NewSpeaker(cfg Config) (*Speaker, error) {
+	if !cfg.RouterID.Is4() {
+		return nil, fmt.Errorf("router id %v is not an IPv4 address", cfg.RouterID)
+	}
+	if cfg.AS == 0 {
+		cfg.AS = DefaultAS
+	}
+	if cfg.HoldTime == 0 {
+		cfg.HoldTime = defaultHoldTime
+	}
+	if cfg.HoldTime < minHoldTime {
+		return nil, fmt.Errorf("hold time %v is less than %v", cfg.HoldTime, minHoldTime)
+	}
+	s := &Speaker{
+		cfg:   cfg,
+		srv:   server.NewBgpServer(server.LoggerOption(&logger{log: ctrl.Log.WithName("gobgp"), level: gobgplog.InfoLevel})),
+		lock:  new(sync.Mutex),
+		local: map[uint32]map[string]Route{},
+		uuids: map[string][]byte{},
+	}
+	if cfg.RouteReflector {
+		addr, err := net.ResolveTCPAddr("tcp", cfg.ListenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listen address %v, %w", cfg.ListenAddr, err)
+		}
+		if addr.Port == 0 {
+			l, err := net.ListenTCP("tcp", addr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to listen on %v, %w", cfg.ListenAddr, err)
+			}
+			addr = l.Addr().(*net.TCPAddr)
+			l.Close()
+		}
+		s.listenAddr = addr
+	}
+	go s.srv.Serve()
+	return s, nil
+}
+
+// Addr returns the address sessions are accepted on, nil if the speaker is not a route reflector
+func (s *Speaker) Addr() net.Addr {
+	if s.listenAddr == nil {
+		return nil
+	}
+	return s.listenAddr
+}
+
+// Start runs the speaker until ctx is done; it connects to the neighbors, retrying on failure,
+// and accepts sessions of clients if it is a route reflector
+func (s *Speaker) Start(ctx context.Context) error {
+	global := &api.Global{Asn: s.cfg.AS, RouterId: s.cfg.RouterID.String(), ListenPort: -1}
+	if s.listenAddr != nil {
+		global.ListenPort = int32(s.listenAddr.Port)
+		if s.listenAddr.IP != nil {
+			global.ListenAddresses = []string{s.listenAddr.IP.String()}
+		}
+	}
+	if err := s.srv.StartBgp(ctx, &api.StartBgpRequest{Global: global}); err != nil {
+		return fmt.Errorf("failed to start bgp, %w", err)
+	}
+	defer s.stop()
+	err := s.srv.WatchEvent(ctx, &api.WatchEventRequest{
+		Table: &api.WatchEventRequest_Table{Filters: []*api.WatchEventRequest_Table_Filter{{Type: api.WatchEventRequest_Table_Filter_BEST}}},
+		Peer:  &api.WatchEventRequest_Peer{},
+	}, s.onEvent)
+	if err != nil {
+		return fmt.Errorf("failed to watch bgp events, %w", err)
+	}
+	if s.cfg.RouteReflector {
+		if err := s.addClientGroup(ctx); err != nil {
+			return err
+		}
+	}
+	s.lock.Lock()
+	s.started = true
+	for _, routes := range s.local {
+		for k, r := range routes {
+			s.addPath(k, r)
+		}
+	}
+	s.lock.Unlock()
+	for _, n := range s.cfg.Neighbors {
+		go s.addNeighbor(ctx, n)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// stop stops the GoBGP server, the sessions are closed and the local routes are kept for the next Start
+func (s *Speaker) stop() {
+	s.lock.Lock()
+	s.started = false
+	clear(s.uuids)
+	s.lock.Unlock()
+	s.srv.StopBgp(context.Background(), &api.StopBgpRequest{})
+}
+
+func (s *Speaker) afiSafis() []*api.AfiSafi {
+	return []*api.AfiSafi{{Config: &api.AfiSafiConfig{Family: familyEVPN, Enabled: true}}}
+}
+
+func (s *Speaker) timers() *api.Timers {
+	hold := uint64(s.cfg.HoldTime / time.Second)
+	return &api.Timers{Config: &api.TimersConfig{
+		HoldTime:          hold,
+		KeepaliveInterval: max(hold/3, 1),
+		ConnectRetry:      uint64(retryInterval / time.Second),
+	}}
+}
+
+// addClientGroup accepts sessions of route reflector clients from any address, as dynamic neighbors
+func (s *Speaker) addClientGroup(ctx context.Context) error {
+	err := s.srv.AddPeerGroup(ctx, &api.AddPeerGroupRequest{PeerGroup: &api.PeerGroup{
+		Conf:   &api.PeerGroupConf{PeerGroupName: clientGroup, PeerAsn: s.cfg.AS},
+		Timers: s.timers(),
+		RouteReflector: &api.RouteReflector{
+			RouteReflectorClient:    true,
+			RouteReflectorClusterId: s.cfg.RouterID.String(),
+		},
+		AfiSafis: s.afiSafis(),
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to add bgp peer group of route reflector clients, %w", err)
+	}
+	for _, prefix := range []string{"0.0.0.0/0", "::/0"} {
+		err := s.srv.AddDynamicNeighbor(ctx, &api.AddDynamicNeighborRequest{DynamicNeighbor: &api.DynamicNeighbor{Prefix: prefix, PeerGroup: clientGroup}})
+		if err != nil {
+			return fmt.Errorf("failed to add bgp dynamic neighbor %v, %w", prefix, err)
+		}
+	}
+	return nil
+}
+
+// addNeighbor adds neighbor as host:port to the GoBGP server, which connects to it; the host is resolved, retrying on failure
+func (s *Speaker) addNeighbor(ctx context.Context, neighbor string) {
+	host, portStr, err := net.SplitHostPort(neighbor)
+	if err != nil {
+		ctrl.Log.Error(err, "invalid bgp neighbor", "neighbor", neighbor)
+		return
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		ctrl.Log.Error(err, "invalid bgp neighbor port", "neighbor", neighbor)
+		return
+	}
+	for {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err == nil {
+			peer := &api.Peer{
+				Conf:      &api.PeerConf{NeighborAddress: addrs[0].Unmap().String(), PeerAsn: s.cfg.AS},
+				Transport: &api.Transport{RemotePort: uint32(port)},
+				Timers:    s.timers(),
+				AfiSafis:  s.afiSafis(),
+			}
+			if s.cfg.LocalAddr.IsValid() {
+				peer.Transport.LocalAddress = s.cfg.LocalAddr.String()
+			}
+			if err = s.srv.AddPeer(ctx, &api.AddPeerRequest{Peer: peer}); err == nil {
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		ctrl.Log.Error(err, "failed to add bgp neighbor", "neighbor", neighbor)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// onEvent logs session changes, and notifies changes of the best routes of other speakers
+func (s *Speaker) onEvent(ev *api.WatchEventResponse) {
+	if p := ev.GetPeer(); p != nil {
+		if p.Type == api.WatchEventResponse_PeerEvent_STATE {
+			ctrl.Log.Info("bgp session state changed", "neighbor", p.Peer.State.NeighborAddress,
+				"peer", p.Peer.State.RouterId, "state", p.Peer.State.SessionState)
+		}
+		return
+	}
+	changed := map[uint32]bool{}
+	for _, p := range ev.GetTable().GetPaths() {
+		if isLocal(p) {
+			continue
+		}
+		if r, ok := parsePath(p); ok {
+			changed[r.VNI] = true
+		}
+	}
+	if s.cfg.OnChange == nil {
+		return
+	}
+	for vni := range changed {
+		s.cfg.OnChange(vni)
+	}
+}
+
+// isLocal returns true if p is advertised by this speaker, i.e. it is not received from a neighbor
+func isLocal(p *api.Path) bool {
+	_, err := netip.ParseAddr(p.NeighborIp)
+	return err != nil
+}
+
+// SetRoutes replaces the local routes of vni: a type-3 route of vtep and type-2 routes of macs,
+// with the MAC only and with each of its IP addresses; nil macs and an invalid vtep withdraw all routes of vni
+func (s *Speaker) SetRoutes(vni uint32, vtep netip.Addr, macs []MACIP) {
+	desired := map[string]Route{}
+	if vtep.IsValid() {
+		add := func(r Route) {
+			r.VNI = vni
+			r.VTEP = vtep
+			desired[r.nlri(s.cfg.RouterID).String()] = r
+		}
+		add(Route{Type: RouteTypeIMET})
+		for _, m := range macs {
+			add(Route{Type: RouteTypeMACIP, MAC: m.MAC})
+			for _, ip := range m.IPs {
+				add(Route{Type: RouteTypeMACIP, MAC: m.MAC, IP: ip})
+			}
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	existing := s.local[vni]
+	for k := range existing {
+		if _, ok := desired[k]; !ok {
+			s.deletePath(k)
+		}
+	}
+	for k, r := range desired {
+		if old, ok := existing[k]; !ok || old.VTEP != r.VTEP {
+			s.addPath(k, r)
+		}
+	}
+	if len(desired) == 0 {
+		delete(s.local, vni)
+	} else {
+		s.local[vni] = desired
+	}
+}
+
+// addPath advertises the local route r with key k if the GoBGP server runs, s.lock must be held
+func (s *Speaker) addPath(k string, r Route) {
+	if !s.started {
+		return
+	}
+	path, err := r.toPath(s.cfg.RouterID, s.cfg.AS)
+	if err == nil {
+		var resp *api.AddPathResponse
+		if resp, err = s.srv.AddPath(context.Background(), &api.AddPathRequest{TableType: api.TableType_GLOBAL, Path: path}); err == nil {
+			s.uuids[k] = resp.Uuid
+			return
+		}
+	}
+	ctrl.Log.Error(err, "failed to advertise evpn route", "route", r)
+}
+
+// deletePath withdraws the local route with key k if it is advertised, s.lock must be held
+func (s *Speaker) deletePath(k string) {
+	id, ok := s.uuids[k]
+	if !s.started || !ok {
+		return
+	}
+	delete(s.uuids, k)
+	if err := s.srv.DeletePath(context.Background(), &api.DeletePathRequest{TableType: api.TableType_GLOBAL, Family: familyEVPN, Uuid: id}); err != nil {
+		ctrl.Log.Error(err, "failed to withdraw evpn route", "route", k)
+	}
+}
+
+// Remote returns the best routes of vni advertised by other speakers: the VTEPs of type-3 routes BUM traffic is replicated to,
+// and the MAC addresses of type-2 routes
+func (s *Speaker) Remote(vni uint32) ([]netip.Addr, []MACIP) {
+	vteps := []netip.Addr{}
+	macs := map[string]*MACIP{}
+	s.lock.Lock()
+	started := s.started
+	s.lock.Unlock()
+	if started {
+		err := s.srv.ListPath(context.Background(), &api.ListPathRequest{TableType: api.TableType_GLOBAL, Family: familyEVPN}, func(d *api.Destination) {
+			for _, p := range d.Paths {
+				if !p.Best || isLocal(p) {
+					continue
+				}
+				r, ok := parsePath(p)
+				if !ok || r.VNI != vni {
+					continue
+				}
+				switch r.Type {
+				case RouteTypeIMET:
+					if !slices.Contains(vteps, r.VTEP) {
+						vteps = append(vteps, r.VTEP)
+					}
+				case RouteTypeMACIP:
+					m, ok := macs[r.MAC.String()]
+					if !ok {
+						m = &MACIP{MAC: r.MAC, VTEP: r.VTEP}
+						macs[r.MAC.String()] = m
+					}
+					if r.IP.IsValid() && m.VTEP == r.VTEP && !slices.Contains(m.IPs, r.IP) {
+						m.IPs = append(m.IPs, r.IP)
+					}
+				}
+			}
+		})
+		if err != nil {
+			ctrl.Log.Error(err, "failed to list evpn routes")
+		}
+	}
+	slices.SortFunc(vteps, netip.Addr.Compare)
+	r := []MACIP{}
+	for _, m := range macs {
+		slices.SortFunc(m.IPs, netip.Addr.Compare)
+		r = append(r, *m)
+	}
+	slices.SortFunc(r, func(a, b MACIP) int { return strings.Compare(a.MAC.String(), b.MAC.String()) })
+	return vteps, r
+}
+
+// Peers returns the router IDs of the neighbors with an established session
+func (s *Speaker) Peers() []netip.Addr {
+	r := []netip.Addr{}
+	s.lock.Lock()
+	started := s.started
+	s.lock.Unlock()
+	if !started {
+		return r
+	}
+	s.srv.ListPeer(context.Background(), &api.ListPeerRequest{}, func(p *api.Peer) {
+		if p.State.GetSessionState() != api.PeerState_ESTABLISHED {
+			return
+		}
+		if id, err := netip.ParseAddr(p.State.RouterId); err == nil {
+			r = append(r, id)
+		}
+	})
+	slices.SortFunc(r, netip.Addr.Compare)
+	return r
+}
+
+// logger is the GoBGP logger writing to a logr.Logger, debug messages are written at verbosity 1
+type logger struct {
+	log   logr.Logger
+	level gobgplog.LogLevel
+}
+
+func fieldsToKV(fields gobgplog.Fields) []any {
+	kv := []any{}
+	for k, v := range fields {
+		kv = append(kv, k, v)
+	}
+	return kv
+}
+
+func (l *logger) Panic(msg string, fields gobgplog.Fields) {
+	l.log.Error(nil, msg, fieldsToKV(fields)...)
+	panic(msg)
+}
+
+func (l *logger) Fatal(msg string, fields gobgplog.Fields) {
+	l.log.Error(nil, msg, fieldsToKV(fields)...)
+	os.Exit(1)
+}
+
+func (l *logger) Error(msg string, fields gobgplog.Fields) {
+	l.log.Error(nil, msg, fieldsToKV(fields)...)
+}
+
+func (l *logger) Warn(msg string, fields gobgplog.Fields) {
+	if l.level >= gobgplog.WarnLevel {
+		l.log.Info(msg, fieldsToKV(fields)...)
+	}
+}
+
+func (l *logger) Info(msg string, fields gobgplog.Fields) {
+	if l.level >= gobgplog.InfoLevel {
+		l.log.Info(msg, fieldsToKV(fields)...)
+	}
+}
+
+func (l *logger) Debug(msg string, fields gobgplog.Fields) {
+	if l.level >= gobgplog.DebugLevel {
+		l.log.V(1).Info(msg, fieldsToKV(fields)...)
+	}
+}
+
+func (l *logger) SetLevel(level gobgplog.LogLevel) {
+	l.level = level
+}
+
+func (l *logger) GetLevel() gobgplog.LogLevel {
+	return l.level
+}
+
+var _ gobgplog.Logger = &logger{}
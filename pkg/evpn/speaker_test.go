@@ -0,0 +1,100 @@
+package evpn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func startTestSpeaker(t *testing.T, cfg Config) (*Speaker, context.CancelFunc) {
+	t.Helper()
+	cfg.HoldTime = minHoldTime
+	s, err := NewSpeaker(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return s, cancel
+}
+
+// checkRemote waits until the remote routes of vni of s are expected, as printed by fmt
+func checkRemote(t *testing.T, s *Speaker, vni uint32, expectedVTEPs, expectedMACs string) {
+	t.Helper()
+	var vteps, macs string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		v, m := s.Remote(vni)
+		vteps, macs = fmt.Sprint(v), fmt.Sprint(m)
+		if vteps == expectedVTEPs && macs == expectedMACs {
+			return
+		}
+	}
+	t.Errorf("speaker %v has vteps %v and macs %v of vni %d, expect %v and %v", s.cfg.RouterID, vteps, macs, vni, expectedVTEPs, expectedMACs)
+}
+
+func TestRouteReflector(t *testing.T) {
+	rr, _ := startTestSpeaker(t, Config{
+		RouterID:       netip.MustParseAddr("10.0.0.1"),
+		RouteReflector: true,
+		ListenAddr:     "127.0.0.1:0",
+	})
+	neighbors := []string{rr.Addr().String()}
+	//clients connect from different addresses, a neighbor is identified by its address
+	a, stopA := startTestSpeaker(t, Config{RouterID: netip.MustParseAddr("10.0.0.2"), Neighbors: neighbors, LocalAddr: netip.MustParseAddr("127.0.0.2")})
+	mac, _ := net.ParseMAC("02:00:00:00:00:02")
+	a.SetRoutes(100, netip.MustParseAddr("192.0.2.2"), []MACIP{{MAC: mac, IPs: []netip.Addr{netip.MustParseAddr("10.1.1.2")}}})
+	a.SetRoutes(200, netip.MustParseAddr("192.0.2.2"), nil)
+	rr.SetRoutes(100, netip.MustParseAddr("192.0.2.1"), nil)
+	checkRemote(t, rr, 100, "[192.0.2.2]", "[{02:00:00:00:00:02 192.0.2.2 [10.1.1.2]}]")
+	//routes of a are reflected to a client connected later
+	changed := make(chan uint32, 16)
+	b, _ := startTestSpeaker(t, Config{
+		RouterID:  netip.MustParseAddr("10.0.0.3"),
+		Neighbors: neighbors,
+		LocalAddr: netip.MustParseAddr("127.0.0.3"),
+		OnChange:  func(vni uint32) { changed <- vni },
+	})
+	checkRemote(t, b, 100, "[192.0.2.1 192.0.2.2]", "[{02:00:00:00:00:02 192.0.2.2 [10.1.1.2]}]")
+	checkRemote(t, b, 200, "[192.0.2.2]", "[]")
+	select {
+	case <-changed:
+	default:
+		t.Error("change of routes is not notified")
+	}
+	//and routes of b to a, but not routes of a back to a
+	b.SetRoutes(100, netip.MustParseAddr("192.0.2.3"), nil)
+	checkRemote(t, a, 100, "[192.0.2.1 192.0.2.3]", "[]")
+	//withdrawals are reflected
+	a.SetRoutes(100, netip.MustParseAddr("192.0.2.2"), nil)
+	checkRemote(t, b, 100, "[192.0.2.1 192.0.2.2]", "[]")
+	a.SetRoutes(100, netip.Addr{}, nil)
+	checkRemote(t, b, 100, "[192.0.2.1]", "[]")
+	//routes of a closed session are withdrawn
+	stopA()
+	checkRemote(t, b, 200, "[]", "[]")
+	checkRemote(t, rr, 100, "[192.0.2.3]", "[]")
+}
+
+func TestSpeakerRejectsOtherAS(t *testing.T) {
+	rr, _ := startTestSpeaker(t, Config{
+		RouterID:       netip.MustParseAddr("10.0.0.1"),
+		RouteReflector: true,
+		ListenAddr:     "127.0.0.1:0",
+	})
+	a, _ := startTestSpeaker(t, Config{RouterID: netip.MustParseAddr("10.0.0.2"), AS: 65000, Neighbors: []string{rr.Addr().String()}})
+	time.Sleep(200 * time.Millisecond)
+	if peers := a.Peers(); len(peers) != 0 {
+		t.Errorf("session with a peer of another AS is established, peers are %v", peers)
+	}
+}
@@ -2,274 +2,75 @@ package interfaces
 
 import (
 	"fmt"
-	"net/netip"
-	"os"
-	"path/filepath"
-	"time"
 
-	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/hujun-open/k8slan/api/v1beta1"
 	"github.com/vishvananda/netlink"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
-// ensure creates all objs to match lan's spec
-func Ensure(macName, spokeName string, lan *v1beta1.LANSpec, hostname, macvtapMode string, dummyMacvtap bool) (int, error) {
+// Ensure creates all objs to match lanCR's spec for allocating spokeName, and returns the ifindex of the macvtap;
+// notable changes are reported via event, which could be nil;
+// every created link is marked with an owner alias of lanCR
+func Ensure(macName, spokeName string, lanCR *v1beta1.LAN, hostname, macvtapMode string, dummyMacvtap bool, event EventFunc) (int, error) {
 	log := ctrl.Log.WithName("deviceplugin")
-	var err error
-	var lanNS ns.NetNS
-	//make sure NS exists
-	nsPath := filepath.Join(getNsRunDir(), *lan.NS)
-	_, err = os.Stat(nsPath)
-	if err != nil {
-		//no exists
-		lanNS, err = NewNS(*lan.NS)
-		if err != nil {
-			return -1, fmt.Errorf("failed to create ns %v, %w", *lan.NS, err)
-		}
-	} else {
-		//exists
-		lanNS, err = ns.GetNS(nsPath)
-		if err != nil {
-			//failed to open existing ns mount, remove it and recreate it
-			DeleteNamed(*lan.NS)
-			lanNS, err = NewNS(*lan.NS)
-			if err != nil {
-				return -1, fmt.Errorf("failed to recreate ns %v, %w", *lan.NS, err)
-			}
-		}
-	}
-	//bring lo interface in NS up
-	err = lanNS.Do(func(hostNs ns.NetNS) error {
-		l, ierr := netlink.LinkByName("lo")
-		if ierr != nil {
-			return ierr
-		}
-		return netlink.LinkSetUp(l)
-	})
-	if err != nil {
-		return -1, fmt.Errorf("failed to bring lo up in ns, %w", err)
-	}
-
-	//get underly link name
-	vxDevName := lan.DefaultVxDev
-	if _, ok := lan.VxDevMap[hostname]; ok {
-		vxDevName = lan.VxDevMap[hostname]
-	}
-
-	//check it exists
-	var vxDevLink netlink.Link
-	vxDevLink, err = netlink.LinkByName(vxDevName)
-	if err != nil {
-		return -1, fmt.Errorf("vxlan dev %v not found, %w", vxDevName, err)
-	}
-	needToAdd := false
-	mtu := vxDevLink.Attrs().MTU - maxVxLANEncapOverhead
-	err = lanNS.Do(func(hostNs ns.NetNS) error {
-		//bridge
-		var br netlink.Link
-		br, err = netlink.LinkByName(*lan.BridgeName)
-		if err != nil {
-			needToAdd = true
-		} else {
-			if br.Type() != "bridge" {
-				return fmt.Errorf("interface %v already exists but not a bridge", lan.BridgeName)
-			}
-		}
-		if needToAdd {
-			//create bridge interface
-			la := netlink.NewLinkAttrs()
-			la.Name = *lan.BridgeName
-			la.MTU = mtu
-			la.TxQLen = -1 //this is important, otherwise the interface only accept broadcast traffic
-			br = &netlink.Bridge{
-				LinkAttrs: la,
-			}
-			if err := netlink.LinkAdd(br); err != nil {
-				return fmt.Errorf("failed to create bridge %v: %v", lan.BridgeName, err)
-			}
-			// Bring the bridge up
-			if err := netlink.LinkSetUp(br); err != nil {
-				return fmt.Errorf("failed to bring bridge %v up, %w", lan.BridgeName, err)
-			}
-			br, _ = netlink.LinkByName(*lan.BridgeName)
-		}
-		//vxlan
-		var vxLink netlink.Link
-		needToAdd = false
-		grpSpec := netip.MustParseAddr(*lan.VxLANGrp)
-
-		vxLink, err = netlink.LinkByName(*lan.VxLANName)
-		if err != nil {
-			needToAdd = true
-		} else {
-			if vxLink.Type() != "vxlan" {
-				return fmt.Errorf("interface %v already exists but not a vxlink", lan.VxLANName)
-			} else {
-				//check vxlink config
-				vx := vxLink.(*netlink.Vxlan)
-				if grpSpec.Compare(netip.MustParseAddr(vx.Group.String())) != 0 {
-					log.Error(fmt.Errorf("existing vxlan interface has a different group addr: %v", vx.Group.String()), fmt.Sprintf("existing vlan interface %v has different config", *lan.VxLANName))
-					needToAdd = true
-
-				}
-				if vx.VxlanId != int(*lan.VNI) {
-					// err = fmt.Errorf("existing vxlan interface has a different vni: %v", vx.VxlanId)
-					log.Error(fmt.Errorf("existing vxlan interface has a different vni: %v", vx.VxlanId), fmt.Sprintf("existing vlan interface %v has different config", *lan.VxLANName))
-					needToAdd = true
-				}
-				if vx.VtepDevIndex != vxDevLink.Attrs().Index {
-					// err = fmt.Errorf("existing vxlan interface has a different dev index: %v", vx.VtepDevIndex)
-					log.Error(fmt.Errorf("existing vxlan interface has a different dev index: %v", vx.VtepDevIndex), fmt.Sprintf("existing vlan interface %v has different config", *lan.VxLANName))
-					needToAdd = true
-				}
-				if vx.Port != int(*lan.VxPort) {
-					log.Error(fmt.Errorf("existing vxlan interface has a different port: %v", vx.Port), fmt.Sprintf("existing vlan interface %v has different config", *lan.VxLANName))
-					needToAdd = true
-				}
-
-			}
-		}
-
-		if needToAdd {
-			LinkDelete(*lan.VxLANName)
-
-			// vxLink, err = CreateVXLANIF(lan, vxDevLink.Attrs().Index, mtu, int(*lan.VxPort))
-			// if err != nil {
-			// 	return fmt.Errorf("failed to create vxlan interface, %w", err)
-			// }
-
-		}
-		// //attach vxlan to br
-		// err = netlink.LinkSetMaster(vxLink, br)
-		// if err != nil {
-
-		// 	return fmt.Errorf("failed to set master of vxlan interface, %w", err)
-		// }
-		// //set grp_fwd_mask
-		// err = netlink.LinkSetBRSlaveGroupFwdMask(vxLink, BRSlaveGrpFwdMask)
-		// if err != nil {
-		// 	return fmt.Errorf("failed to set vxlan slave grp fwd mask, %w", err)
-		// }
-		//creating veth interfaces
-		//remove existing vlan interface with same name
-		peerName := getPeerVethName(spokeName)
-		LinkDelete(peerName)
-		time.Sleep(time.Second)
-		la := netlink.LinkAttrs{
-			ParentIndex: br.Attrs().Index,
-			Name:        spokeName,
-			TxQLen:      -1,
-			MTU:         mtu,
-		}
-
-		vlink := &netlink.Veth{
-			PeerName:  peerName,
-			LinkAttrs: la,
-		}
-		if err := netlink.LinkAdd(vlink); err != nil {
-			return fmt.Errorf("failed to create veth interface %v: %v", spokeName, err)
-		}
-		if err := netlink.LinkSetUp(vlink); err != nil {
-			return fmt.Errorf("failed to veth %v up, %w", spokeName, err)
-		}
-		peerLink, err := netlink.LinkByName(peerName)
-		if err != nil {
-			return fmt.Errorf("failed to find peer veth %v, %w", peerName, err)
-		}
-		if err = netlink.LinkSetMaster(peerLink, br); err != nil {
-			return fmt.Errorf("failed to set veth %v to master %v, %w", peerName, br.Attrs().Name, err)
-		}
-		//set grp_fwd_mask
-		err = netlink.LinkSetBRSlaveGroupFwdMask(peerLink, BRSlaveGrpFwdMask)
-		if err != nil {
-			return fmt.Errorf("failed to set veth slave grp fwd mask, %w", err)
-		}
-		if err := netlink.LinkSetUp(peerLink); err != nil {
-			return fmt.Errorf("failed to peer veth %v up, %w", peerName, err)
-		}
-		//move spoke link back to host ns
-		return netlink.LinkSetNsFd(vlink, int(hostNs.Fd()))
-	})
+	unlock := lockNS(*lanCR.Spec.NS)
+	defer unlock()
+	desired, err := DesiredLAN(lanCR, hostname)
 	if err != nil {
 		return -1, err
 	}
-	//add vxlan interface if needed
-	if needToAdd {
-		err := CreateVXLANIF(lan, vxDevLink.Attrs().Index, int(lanNS.Fd()), mtu, int(*lan.VxPort))
-		if err != nil {
-			return -1, fmt.Errorf("failed to create vxlan interface, %w", err)
-		}
-		//bring it up
-		err = lanNS.Do(func(hostNs ns.NetNS) error {
-			vxLink, err := netlink.LinkByName(*lan.VxLANName)
-			if err != nil {
-				return err
-			}
-			br, err := netlink.LinkByName(*lan.BridgeName)
-			if err != nil {
-				return err
-			}
-			//attach vxlan to br
-			err = netlink.LinkSetMaster(vxLink, br)
-			if err != nil {
-				return fmt.Errorf("failed to set master of vxlan interface, %w", err)
-			}
-			//set grp_fwd_mask
-			err = netlink.LinkSetBRSlaveGroupFwdMask(vxLink, BRSlaveGrpFwdMask)
-			if err != nil {
-				return fmt.Errorf("failed to set vxlan slave grp fwd mask, %w", err)
-			}
-			//bring link up
-			return netlink.LinkSetUp(vxLink)
-		})
+	desired.AddLocalSpokes(spokeName)
+	if err := desired.AddSpoke(spokeName, macName, macvtapMode, dummyMacvtap); err != nil {
+		return -1, err
 	}
+	plan, err := desired.Plan()
 	if err != nil {
-		return -1, fmt.Errorf("failed to create vxlink interface in the ns, %w", err)
+		return -1, err
 	}
-
-	//bring up spoke link in host ns
-	vlink, err := netlink.LinkByName(spokeName)
-	if err != nil {
-		return -1, fmt.Errorf("failed to get the created spoke link %v in host ns, %w", spokeName, err)
+	log.Info("applying plan", "ns", plan.NS, "spoke", spokeName, "steps", plan.Strings())
+	if err = plan.Apply(event); err != nil {
+		return -1, err
 	}
-	err = netlink.LinkSetUp(vlink)
+	mac, err := nl.LinkByName(macName)
 	if err != nil {
-		return -1, fmt.Errorf("failed to bring up spoke link %v in host ns, %w", spokeName, err)
-	}
-	//create macvtap interface
-	if !dummyMacvtap {
-		return RecreateMacvtap(macName, spokeName, macvtapMode)
-	} else {
-		//create dummy one
-		dummyLink, err := netlink.LinkByName(dummyIfName)
-		if err != nil {
-			//create dummy link
-			dummyLink = &netlink.Dummy{
-				LinkAttrs: netlink.LinkAttrs{
-					Name: dummyIfName,
-				},
-			}
-			err = netlink.LinkAdd(dummyLink)
-			if err != nil {
-				return -1, fmt.Errorf("failed to create dummy link %v, %w", dummyIfName, err)
-			}
-			dummyLink, err = netlink.LinkByName(dummyIfName)
-			if err != nil {
-				return -1, fmt.Errorf("failed to get newly created dummy link %v, %w", dummyIfName, err)
-			}
-		}
-		return RecreateMacvtap(macName, dummyIfName, "private")
-
+		return -1, fmt.Errorf("failed to find the created macvtap %v, %w", macName, err)
 	}
+	return mac.Attrs().Index, nil
+}
 
+// RepairDrift repairs the objects of lanCR on this node if they don't match the spec,
+// which also applies changes of impairments, mirrors and transparency on a live LAN; it does nothing if the LAN NS doesn't exist on this node; the applied plan is returned
+func RepairDrift(lanCR *v1beta1.LAN, hostname string, event EventFunc) (*Plan, error) {
+	unlock := lockNS(*lanCR.Spec.NS)
+	defer unlock()
+	plan, err := PlanDrift(lanCR, hostname)
+	if err != nil || plan == nil || plan.Empty() {
+		return plan, err
+	}
+	ctrl.Log.WithName("drift").Info("applying plan", "ns", plan.NS, "steps", plan.Strings())
+	return plan, plan.Apply(event)
 }
 
 const (
 	dummyIfName = "k8slan-dummy"
 )
 
-func getPeerVethName(name string) string {
+// getVxDev returns the vxlan underlying interface of lan on the host
+func getVxDev(lan *v1beta1.LANSpec, hostname string) (netlink.Link, error) {
+	vxDevName := lan.DefaultVxDev
+	if _, ok := lan.VxDevMap[hostname]; ok {
+		vxDevName = lan.VxDevMap[hostname]
+	}
+	//check it exists
+	link, err := nl.LinkByName(vxDevName)
+	if err != nil {
+		return nil, fmt.Errorf("vxlan dev %v not found, %w", vxDevName, err)
+	}
+	return link, nil
+}
+
+// GetPeerVethName returns name of the bridge side veth of the spoke in the LAN NS
+func GetPeerVethName(name string) string {
 	return name + "p"
 }
 
@@ -291,7 +92,7 @@ func ModeFromString(s string) (netlink.MacvlanMode, error) {
 func CreateMacvtap(name string, lowerDevice string, mode string) (int, error) {
 	ifindex := 0
 
-	m, err := netlink.LinkByName(lowerDevice)
+	m, err := nl.LinkByName(lowerDevice)
 	if err != nil {
 		return ifindex, fmt.Errorf("failed to lookup lowerDevice %q: %v", lowerDevice, err)
 	}
@@ -313,11 +114,11 @@ func CreateMacvtap(name string, lowerDevice string, mode string) (int, error) {
 		},
 	}
 
-	if err := netlink.LinkAdd(mv); err != nil {
+	if err := nl.LinkAdd(mv); err != nil {
 		return ifindex, fmt.Errorf("failed to create macvtap: %v", err)
 	}
 
-	if err := netlink.LinkSetUp(mv); err != nil {
+	if err := nl.LinkSetUp(mv); err != nil {
 		return ifindex, fmt.Errorf("failed to set %q UP: %v", name, err)
 	}
 
@@ -332,14 +133,25 @@ func RecreateMacvtap(name string, lowerDevice string, mode string) (int, error)
 	}
 	return CreateMacvtap(name, lowerDevice, mode)
 }
+
+// LinkDelete removes the named link in current NS if it exists,
+// it refuses to remove a link not created by k8slan
 func LinkDelete(link string) error {
-	l, err := netlink.LinkByName(link)
-	if _, ok := err.(netlink.LinkNotFoundError); ok {
+	return linkDelete(link, false)
+}
+
+// linkDelete is LinkDelete, lanLink is true if link is a bridge, tunnel interface or spoke peer veth expected by the LAN of current NS
+func linkDelete(link string, lanLink bool) error {
+	l, err := nl.LinkByName(link)
+	if isLinkNotFound(err) {
 		return nil
 	}
 	if err != nil {
 		return err
 	}
-	err = netlink.LinkDel(l)
+	if !isOwned(l, lanLink) {
+		return fmt.Errorf("refuse to remove interface %v, %w", link, ErrNotOwned)
+	}
+	err = nl.LinkDel(l)
 	return err
 }
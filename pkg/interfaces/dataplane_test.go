@@ -0,0 +1,548 @@
+//go:build dataplane
+
+package interfaces
+
+// dataplane tests simulate workers as network namespaces joined by an underlay bridge,
+// and run the real plan engine against the kernel on each worker; they must run as root,
+// e.g. `sudo unshare --net --mount go test -tags=dataplane ./pkg/interfaces/ -run Dataplane`
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/containernetworking/plugins/pkg/utils/sysctl"
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	dpPrefix      = "k8slan-dp-"
+	dpUnderlayBr  = "br0"
+	dpUnderlayDev = "eth0"
+	dpUDPPort     = 9999
+	dpTimeout     = 5 * time.Second
+)
+
+// dpHarness is a set of simulated workers
+type dpHarness struct {
+	t        *testing.T
+	underlay string
+	workers  []*dpWorker
+	// names of all created NS, removed at cleanup
+	created []string
+}
+
+// dpWorker is a simulated worker, its host NS is a named NS with eth0 attached to the underlay bridge
+type dpWorker struct {
+	name string
+	path string
+	// key is LAN name, value is the LAN NS on this worker
+	lanNS map[string]string
+}
+
+// dpPod is a pod NS with the macvtap of a spoke
+type dpPod struct {
+	name    string
+	path    string
+	ip      net.IP
+	macvtap string
+}
+
+func newDPHarness(t *testing.T, n int) *dpHarness {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("dataplane tests require root")
+	}
+	h := &dpHarness{t: t}
+	t.Cleanup(h.cleanup)
+	h.underlay = h.newNS("underlay")
+	err := ns.WithNetNSPath(h.underlay, func(_ ns.NetNS) error {
+		snooping := false
+		br := &netlink.Bridge{
+			LinkAttrs:         netlink.LinkAttrs{Name: dpUnderlayBr},
+			MulticastSnooping: &snooping,
+		}
+		if err := netlink.LinkAdd(br); err != nil {
+			return err
+		}
+		return netlink.LinkSetUp(br)
+	})
+	if err != nil {
+		t.Fatalf("failed to create underlay bridge, %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		w := &dpWorker{name: fmt.Sprintf("w%d", i), lanNS: map[string]string{}}
+		w.path = h.newNS(w.name)
+		if err := h.attach(w); err != nil {
+			t.Fatalf("failed to attach %v to underlay, %v", w.name, err)
+		}
+		h.workers = append(h.workers, w)
+	}
+	return h
+}
+
+// newNS creates a named NS with the test prefix and returns its path
+func (h *dpHarness) newNS(name string) string {
+	h.t.Helper()
+	name = dpPrefix + name
+	netns, err := NewNS(name)
+	if err != nil {
+		h.t.Fatalf("failed to create ns %v, %v", name, err)
+	}
+	netns.Close()
+	h.created = append(h.created, name)
+	return GetNSPath(name)
+}
+
+// attach connects w to the underlay bridge with a veth, which is eth0 in w
+func (h *dpHarness) attach(w *dpWorker) error {
+	workerNS, err := ns.GetNS(w.path)
+	if err != nil {
+		return err
+	}
+	defer workerNS.Close()
+	err = workerNS.Do(func(_ ns.NetNS) error {
+		//skip DAD so that the link local address used by vxlan is usable right away
+		for _, conf := range []string{"all", "default"} {
+			if _, err := sysctl.Sysctl(fmt.Sprintf("net/ipv6/conf/%v/accept_dad", conf), "0"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = ns.WithNetNSPath(h.underlay, func(_ ns.NetNS) error {
+		br, err := netlink.LinkByName(dpUnderlayBr)
+		if err != nil {
+			return err
+		}
+		la := netlink.NewLinkAttrs()
+		la.Name = w.name
+		la.MasterIndex = br.Attrs().Index
+		peerName := w.name + "-" + dpUnderlayDev
+		if err := netlink.LinkAdd(&netlink.Veth{LinkAttrs: la, PeerName: peerName}); err != nil {
+			return err
+		}
+		link, err := netlink.LinkByName(w.name)
+		if err != nil {
+			return err
+		}
+		if err := netlink.LinkSetUp(link); err != nil {
+			return err
+		}
+		peer, err := netlink.LinkByName(peerName)
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetNsFd(peer, int(workerNS.Fd()))
+	})
+	if err != nil {
+		return err
+	}
+	return workerNS.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(w.name + "-" + dpUnderlayDev)
+		if err != nil {
+			return err
+		}
+		if err := netlink.LinkSetName(link, dpUnderlayDev); err != nil {
+			return err
+		}
+		return netlink.LinkSetUp(link)
+	})
+}
+
+func (h *dpHarness) cleanup() {
+	for _, w := range h.workers {
+		for _, nsname := range w.lanNS {
+			if _, err := os.Stat(GetNSPath(nsname)); err == nil {
+				DeleteNamed(nsname)
+			}
+		}
+	}
+	for _, name := range h.created {
+		if err := DeleteNamed(name); err != nil {
+			h.t.Logf("failed to remove ns %v, %v", name, err)
+		}
+	}
+}
+
+// do runs f with w as the host NS
+func (w *dpWorker) do(f func() error) error {
+	origNL := nl
+	nl = kernelNetlinker{hostNS: w.path}
+	defer func() { nl = origNL }()
+	return ns.WithNetNSPath(w.path, func(_ ns.NetNS) error {
+		return f()
+	})
+}
+
+// ensure allocates spoke of lan on w like the device plugin does, and moves its macvtap into a new pod NS with ip
+func (h *dpHarness) ensure(w *dpWorker, lan *v1beta1.LAN, spoke, ip string) *dpPod {
+	h.t.Helper()
+	//the run dir is shared by all workers, so LAN NS names are made unique per worker
+	lanCopy := lan.DeepCopy()
+	*lanCopy.Spec.NS = dpPrefix + lan.Name + "-" + w.name
+	w.lanNS[lan.Name] = *lanCopy.Spec.NS
+	pod := &dpPod{
+		name:    w.name + "-" + spoke,
+		ip:      net.ParseIP(ip),
+		macvtap: "m" + spoke,
+	}
+	pod.path = h.newNS(pod.name)
+	err := w.do(func() error {
+		if _, err := Ensure(pod.macvtap, spoke, lanCopy, w.name, "passthru", false, nil); err != nil {
+			return err
+		}
+		podNS, err := ns.GetNS(pod.path)
+		if err != nil {
+			return err
+		}
+		defer podNS.Close()
+		mac, err := netlink.LinkByName(pod.macvtap)
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetNsFd(mac, int(podNS.Fd()))
+	})
+	if err != nil {
+		h.t.Fatalf("failed to ensure spoke %v of %v on %v, %v", spoke, lan.Name, w.name, err)
+	}
+	err = pod.do(func() error {
+		mac, err := netlink.LinkByName(pod.macvtap)
+		if err != nil {
+			return err
+		}
+		addr := &netlink.Addr{IPNet: &net.IPNet{IP: pod.ip, Mask: net.CIDRMask(24, 32)}}
+		if err := netlink.AddrAdd(mac, addr); err != nil {
+			return err
+		}
+		return netlink.LinkSetUp(mac)
+	})
+	if err != nil {
+		h.t.Fatalf("failed to setup pod %v, %v", pod.name, err)
+	}
+	return pod
+}
+
+func (p *dpPod) do(f func() error) error {
+	return ns.WithNetNSPath(p.path, func(_ ns.NetNS) error {
+		return f()
+	})
+}
+
+// reachable returns true if a UDP datagram sent from src is received by dst within timeout
+func (h *dpHarness) reachable(src, dst *dpPod, timeout time.Duration) bool {
+	h.t.Helper()
+	var listener, sender *net.UDPConn
+	err := dst.do(func() error {
+		var err error
+		listener, err = net.ListenUDP("udp4", &net.UDPAddr{IP: dst.ip, Port: dpUDPPort})
+		return err
+	})
+	if err != nil {
+		h.t.Fatalf("failed to listen in %v, %v", dst.name, err)
+	}
+	defer listener.Close()
+	err = src.do(func() error {
+		var err error
+		sender, err = net.DialUDP("udp4", &net.UDPAddr{IP: src.ip}, &net.UDPAddr{IP: dst.ip, Port: dpUDPPort})
+		return err
+	})
+	if err != nil {
+		h.t.Fatalf("failed to dial from %v, %v", src.name, err)
+	}
+	defer sender.Close()
+	buf := make([]byte, 64)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		//the first few datagrams could be lost before ARP is resolved and the vxlan FDB is learned
+		sender.Write([]byte(src.name))
+		listener.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		if n, err := listener.Read(buf); err == nil && string(buf[:n]) == src.name {
+			return true
+		}
+	}
+	return false
+}
+
+func newDPLAN(name string, vni int32) *v1beta1.LAN {
+	str := func(s string) *string { return &s }
+	port := int32(4789)
+	lan := &v1beta1.LAN{
+		Spec: v1beta1.LANSpec{
+			NS:           str(name),
+			BridgeName:   str("br-" + name),
+			VxLANName:    str("vx-" + name),
+			VNI:          &vni,
+			VxLANGrp:     str("ff02::14"),
+			DefaultVxDev: dpUnderlayDev,
+			VxPort:       &port,
+		},
+	}
+	lan.Name = name
+	lan.UID = types.UID("uid-" + name)
+	return lan
+}
+
+func TestDataplane(t *testing.T) {
+	h := newDPHarness(t, 3)
+	w1, w2, w3 := h.workers[0], h.workers[1], h.workers[2]
+	lanA := newDPLAN("lana", 100)
+	lanA.Spec.SpokeList = []string{"a1", "a2", "a3"}
+	lanB := newDPLAN("lanb", 200)
+	lanB.Spec.SpokeList = []string{"b1", "b2"}
+	//both LANs use the same subnet, so only VNI isolates them
+	a1 := h.ensure(w1, lanA, "a1", "10.0.0.1")
+	a2 := h.ensure(w2, lanA, "a2", "10.0.0.2")
+	a3 := h.ensure(w3, lanA, "a3", "10.0.0.3")
+	b1 := h.ensure(w1, lanB, "b1", "10.0.0.11")
+	b2 := h.ensure(w2, lanB, "b2", "10.0.0.12")
+
+	t.Run("reachability", func(t *testing.T) {
+		for _, pair := range [][2]*dpPod{{a1, a2}, {a2, a3}, {a3, a1}, {b1, b2}, {b2, b1}} {
+			if !h.reachable(pair[0], pair[1], dpTimeout) {
+				t.Errorf("%v can't reach %v", pair[0].name, pair[1].name)
+			}
+		}
+	})
+
+	t.Run("isolation", func(t *testing.T) {
+		for _, pair := range [][2]*dpPod{{a1, b2}, {b1, a2}, {a2, b2}} {
+			if h.reachable(pair[0], pair[1], time.Second) {
+				t.Errorf("%v reaches %v in another LAN", pair[0].name, pair[1].name)
+			}
+		}
+	})
+
+	t.Run("idempotent", func(t *testing.T) {
+		for _, w := range h.workers {
+			for _, lan := range []*v1beta1.LAN{lanA, lanB} {
+				nsname, ok := w.lanNS[lan.Name]
+				if !ok {
+					continue
+				}
+				lanCopy := lan.DeepCopy()
+				*lanCopy.Spec.NS = nsname
+				err := w.do(func() error {
+					plan, err := PlanDrift(lanCopy, w.name)
+					if err == nil && !plan.Empty() {
+						err = fmt.Errorf("unexpected drift %v", plan.Strings())
+					}
+					return err
+				})
+				if err != nil {
+					t.Errorf("%v on %v: %v", lan.Name, w.name, err)
+				}
+			}
+		}
+	})
+
+	t.Run("teardown", func(t *testing.T) {
+		for _, w := range h.workers {
+			err := w.do(func() error {
+				for _, nsname := range w.lanNS {
+					if _, err := Remove(nsname); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to remove LANs on %v, %v", w.name, err)
+			}
+		}
+		for _, w := range h.workers {
+			for _, nsname := range w.lanNS {
+				if _, err := os.Stat(GetNSPath(nsname)); err == nil {
+					t.Errorf("ns %v is not removed", nsname)
+				}
+			}
+			//interfaces in a removed NS are destroyed asynchronously by the kernel
+			err := waitFor(dpTimeout, func() error {
+				return w.do(checkClean)
+			})
+			if err != nil {
+				t.Errorf("%v is not clean, %v", w.name, err)
+			}
+		}
+		for _, pod := range []*dpPod{a1, a2, a3, b1, b2} {
+			err := pod.do(func() error {
+				if _, err := netlink.LinkByName(pod.macvtap); err == nil {
+					return fmt.Errorf("macvtap %v is not removed", pod.macvtap)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
+
+// requireMatchAll skips the test if the kernel doesn't support the tc matchall filter, used by p2p wiring
+func (h *dpHarness) requireMatchAll(w *dpWorker) {
+	h.t.Helper()
+	err := w.do(func() error {
+		la := netlink.NewLinkAttrs()
+		la.Name = "matchall0"
+		if err := netlink.LinkAdd(&netlink.Dummy{LinkAttrs: la}); err != nil {
+			return err
+		}
+		link, err := netlink.LinkByName(la.Name)
+		if err != nil {
+			return err
+		}
+		defer netlink.LinkDel(link)
+		if err := ensureClsact(link); err != nil {
+			return err
+		}
+		return netlink.FilterAdd(newMatchAll(link, netlink.HANDLE_MIN_INGRESS, redirectFilterPrio,
+			netlink.NewMirredAction(link.Attrs().Index)))
+	})
+	if err != nil {
+		h.t.Skipf("tc matchall filter is not supported, %v", err)
+	}
+}
+
+// waitFor calls f until it succeeds or timeout, the last error is returned
+func waitFor(timeout time.Duration, f func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := f()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// checkClean returns error if there is any orphan or owned interface in current NS
+func checkClean() error {
+	orphans, err := FindOrphans(func() ([]*v1beta1.LAN, error) { return nil, nil })
+	if err != nil {
+		return err
+	}
+	if len(orphans) > 0 {
+		return fmt.Errorf("orphans left %v", orphans)
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if _, ok := GetOwner(link); ok {
+			return fmt.Errorf("owned interface %v left", link.Attrs().Name)
+		}
+	}
+	return nil
+}
+
+func TestDataplaneP2P(t *testing.T) {
+	h := newDPHarness(t, 2)
+	w1, w2 := h.workers[0], h.workers[1]
+	h.requireMatchAll(w1)
+	//both spokes on the same worker
+	local := newDPLAN("p2pl", 300)
+	local.Spec.Mode = v1beta1.LANModeP2P
+	local.Spec.SpokeList = []string{"l1", "l2"}
+	//one spoke on each worker
+	remote := newDPLAN("p2pr", 301)
+	remote.Spec.Mode = v1beta1.LANModeP2P
+	remote.Spec.SpokeList = []string{"r1", "r2"}
+	l1 := h.ensure(w1, local, "l1", "10.0.1.1")
+	l2 := h.ensure(w1, local, "l2", "10.0.1.2")
+	r1 := h.ensure(w1, remote, "r1", "10.0.1.1")
+	r2 := h.ensure(w2, remote, "r2", "10.0.1.2")
+	for _, pair := range [][2]*dpPod{{l1, l2}, {l2, l1}, {r1, r2}, {r2, r1}} {
+		if !h.reachable(pair[0], pair[1], dpTimeout) {
+			t.Errorf("%v can't reach %v", pair[0].name, pair[1].name)
+		}
+	}
+	//the local LAN doesn't leak to the vxlan interface
+	for _, pair := range [][2]*dpPod{{l1, r2}, {r2, l2}} {
+		if h.reachable(pair[0], pair[1], time.Second) {
+			t.Errorf("%v reaches %v in another LAN", pair[0].name, pair[1].name)
+		}
+	}
+}
+
+func TestDataplanePeering(t *testing.T) {
+	h := newDPHarness(t, 3)
+	//w1 and w3 are in cluster A with w1 as the gateway, w2 is the gateway of cluster B;
+	//the LANs of the two clusters use different VNIs so that they are only connected by the peering
+	w1, w2, w3 := h.workers[0], h.workers[1], h.workers[2]
+	lanA := newDPLAN("peera", 400)
+	lanA.Spec.SpokeList = []string{"pa1", "pa3"}
+	lanB := newDPLAN("peerb", 401)
+	lanB.Spec.SpokeList = []string{"pb2"}
+	a1 := h.ensure(w1, lanA, "pa1", "10.0.2.1")
+	a3 := h.ensure(w3, lanA, "pa3", "10.0.2.3")
+	b2 := h.ensure(w2, lanB, "pb2", "10.0.2.2")
+	pairs := [][2]*dpPod{{a1, b2}, {b2, a1}, {a3, b2}, {b2, a3}}
+	for _, pair := range pairs {
+		if h.reachable(pair[0], pair[1], time.Second) {
+			t.Fatalf("%v reaches %v before peering", pair[0].name, pair[1].name)
+		}
+	}
+	//gateway is the LAN as seen on w, with its LAN NS
+	gateway := func(w *dpWorker, lan *v1beta1.LAN) *v1beta1.LAN {
+		lanCopy := lan.DeepCopy()
+		*lanCopy.Spec.NS = w.lanNS[lan.Name]
+		return lanCopy
+	}
+	gwA, gwB := gateway(w1, lanA), gateway(w2, lanB)
+	//the underlay only has link local addresses, which can't be the remote of a unicast vxlan without a local address
+	addrA, addrB := netip.MustParseAddr("fd00::1"), netip.MustParseAddr("fd00::2")
+	for w, addr := range map[*dpWorker]netip.Addr{w1: addrA, w2: addrB} {
+		err := w.do(func() error {
+			link, err := netlink.LinkByName(dpUnderlayDev)
+			if err != nil {
+				return err
+			}
+			return netlink.AddrAdd(link, &netlink.Addr{IPNet: &net.IPNet{IP: addr.AsSlice(), Mask: net.CIDRMask(64, 128)}})
+		})
+		if err != nil {
+			t.Fatalf("failed to add underlay address to %v, %v", w.name, err)
+		}
+	}
+	update := func(w *dpWorker, lan *v1beta1.LAN, peers []PeerLink) {
+		t.Helper()
+		if err := w.do(func() error { return UpdatePeerings(lan, w.name, peers, nil) }); err != nil {
+			t.Fatalf("failed to update peerings on %v, %v", w.name, err)
+		}
+	}
+	update(w1, gwA, []PeerLink{{Peering: "to-b", VNI: 500, Port: 8472, Remote: addrB}})
+	update(w2, gwB, []PeerLink{{Peering: "to-a", VNI: 500, Port: 8472, Remote: addrA}})
+	//a3 reaches b2 via the LAN vxlan to w1 then the peering vxlan to w2
+	for _, pair := range pairs {
+		if !h.reachable(pair[0], pair[1], dpTimeout) {
+			t.Errorf("%v can't reach %v via peering", pair[0].name, pair[1].name)
+		}
+	}
+	update(w1, gwA, nil)
+	update(w2, gwB, nil)
+	for _, gw := range []*v1beta1.LAN{gwA, gwB} {
+		err := ns.WithNetNSPath(GetNSPath(*gw.Spec.NS), func(_ ns.NetNS) error {
+			if _, err := netlink.LinkByName(getPeerLinkName(500)); err == nil {
+				return fmt.Errorf("peering interface is not removed")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%v: %v", *gw.Spec.NS, err)
+		}
+	}
+	for _, pair := range pairs {
+		if h.reachable(pair[0], pair[1], time.Second) {
+			t.Errorf("%v reaches %v after peering is removed", pair[0].name, pair[1].name)
+		}
+	}
+}
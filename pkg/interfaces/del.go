@@ -1,35 +1,15 @@
 package interfaces
 
-func Remove(nsname string) {
-	DeleteNamed(nsname)
-	// nsPath := filepath.Join(getNsRunDir(), *lan.Spec.NS)
-
-	// //exists
-	// lanNS, err := ns.GetNS(nsPath)
-	// if err != nil {
-	// 	//can't open the ns, return
-	// 	return
-	// }
-	// lanNS.Do(func(hostNs ns.NetNS) error {
-	// 	//veth
-	// 	for _, spoke := range lan.Spec.SpokeList {
-	// 		vethLink, err := netlink.LinkByName(getPeerVethName(spoke))
-	// 		if err == nil {
-	// 			netlink.LinkDel(vethLink)
-	// 		}
-	// 	}
-	// 	//vxlan
-	// 	vxlink, err := netlink.LinkByName(*lan.Spec.VxLANName)
-	// 	if err == nil {
-	// 		netlink.LinkDel(vxlink)
-	// 	}
-
-	// 	//bridge
-	// 	brlink, err := netlink.LinkByName(*lan.Spec.BridgeName)
-	// 	if err == nil {
-	// 		netlink.LinkDel(brlink)
-	// 	}
-	// 	return nil
-	// })
-
+// Remove removes the named LAN NS and all interfaces in it,
+// removed is false if the NS doesn't exist on this node;
+// it refuses to remove a NS not marked as a LAN NS
+func Remove(nsname string) (removed bool, err error) {
+	unlock := lockNS(nsname)
+	defer unlock()
+	plan, err := PlanRemove(nsname)
+	if err != nil || plan.Empty() {
+		return false, err
+	}
+	forgetFDBVias(nsname)
+	return true, plan.Apply(nil)
 }
@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reasons of events reported via EventFunc
+const (
+	ReasonNSCreated         = "NamespaceCreated"
+	ReasonVxLANCreated      = "VxLANCreated"
+	ReasonVxLANRecreated    = "VxLANRecreated"
+	ReasonVethCreated       = "VethCreated"
+	ReasonMacvtapCreated    = "MacvtapCreated"
+	ReasonEnsureFailed      = "EnsureFailed"
+	ReasonRemoved           = "Removed"
+	ReasonRemoveFailed      = "RemoveFailed"
+	ReasonOrphanFound       = "OrphanFound"
+	ReasonOrphanRemoved     = "OrphanRemoved"
+	ReasonOrphanGCFailed    = "OrphanGCFailed"
+	ReasonDriftRepaired     = "DriftRepaired"
+	ReasonDriftRepairFailed = "DriftRepairFailed"
+	ReasonKeyRotated        = "KeyRotated"
+	ReasonEncryptionFailed  = "EncryptionFailed"
+	ReasonStaticFDBFailed   = "StaticFDBFailed"
+	ReasonPeeringRemoved    = "PeeringRemoved"
+	ReasonPeeringFailed     = "PeeringFailed"
+	ReasonSpokeMoved        = "SpokeMoved"
+	ReasonSpokeBindFailed   = "SpokeBindFailed"
+	ReasonSpokeDown         = "SpokeDown"
+	ReasonSpokeUp           = "SpokeUp"
+	ReasonLinkStateFailed   = "LinkStateFailed"
+)
+
+// EventFunc is called on notable changes of the interfaces of a LAN,
+// eventType is either corev1.EventTypeNormal or corev1.EventTypeWarning
+type EventFunc func(eventType, reason, message string)
+
+func (f EventFunc) normal(reason, msgFmt string, args ...any) {
+	if f != nil {
+		f(corev1.EventTypeNormal, reason, fmt.Sprintf(msgFmt, args...))
+	}
+}
@@ -0,0 +1,712 @@
+package interfaces
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// FakeNetlinker is an in-memory Netlinker for tests, it doesn't require root;
+// links are kept as the netlink.Link objects passed to LinkAdd, indexes are unique across NS
+type FakeNetlinker struct {
+	lock *sync.Mutex
+	// key is NS path, "" is the host NS
+	nsList map[string]*fakeNS
+	// NS path stack of InNS, the last one is the current NS
+	current   []string
+	nextIndex int
+	// key is index of a veth, value is index of its peer
+	peers map[int]int
+	// tc objects and bridge attributes, key is link index
+	qdiscs  map[int][]netlink.Qdisc
+	filters map[int][]netlink.Filter
+	bridges map[int]BridgeOptions
+}
+
+type fakeNS struct {
+	links  []netlink.Link
+	neighs []netlink.Neigh
+	addrs  []netlink.Addr
+	// via interface index of FDB entries, key is "<mac> <ip>"
+	vias map[string]int
+}
+
+// NewFakeNetlinker returns a FakeNetlinker with an empty host NS
+func NewFakeNetlinker() *FakeNetlinker {
+	f := &FakeNetlinker{
+		lock:      new(sync.Mutex),
+		nsList:    map[string]*fakeNS{},
+		nextIndex: 1,
+		peers:     map[int]int{},
+		qdiscs:    map[int][]netlink.Qdisc{},
+		filters:   map[int][]netlink.Filter{},
+		bridges:   map[int]BridgeOptions{},
+	}
+	f.AddNS("")
+	return f
+}
+
+// AddNS adds a NS of path with a lo interface, e.g. a pod NS
+func (f *FakeNetlinker) AddNS(path string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.addNS(path)
+}
+
+func (f *FakeNetlinker) addNS(path string) {
+	lo := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "lo", Index: f.nextIndex, MTU: 65536}}
+	f.nextIndex++
+	f.nsList[path] = &fakeNS{links: []netlink.Link{lo}, vias: map[string]int{}}
+}
+
+// AddAddr adds an address to the link with index in the NS of path
+func (f *FakeNetlinker) AddAddr(path string, index int, addr netlink.Addr) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	addr.LinkIndex = index
+	f.nsList[path].addrs = append(f.nsList[path].addrs, addr)
+}
+
+// AddNeigh adds a neighbor or FDB entry to the NS of path
+func (f *FakeNetlinker) AddNeigh(path string, n netlink.Neigh) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if ns, ok := f.nsList[path]; ok {
+		ns.neighs = append(ns.neighs, n)
+	}
+}
+
+// FDBVia returns the via interface index the FDB entry n in the NS of path is added with
+func (f *FakeNetlinker) FDBVia(path string, n netlink.Neigh) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if ns, ok := f.nsList[path]; ok {
+		return ns.vias[fmt.Sprintf("%v %v", n.HardwareAddr, n.IP)]
+	}
+	return 0
+}
+
+// VethPeer returns the index of the peer of the veth with index, 0 if it is not a veth
+func (f *FakeNetlinker) VethPeer(index int) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.peers[index]
+}
+
+// Links returns links in the NS of path
+func (f *FakeNetlinker) Links(path string) []netlink.Link {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if ns, ok := f.nsList[path]; ok {
+		return slices.Clone(ns.links)
+	}
+	return nil
+}
+
+func (f *FakeNetlinker) currentNS() *fakeNS {
+	if len(f.current) == 0 {
+		return f.nsList[""]
+	}
+	return f.nsList[f.current[len(f.current)-1]]
+}
+
+// find returns the link with index in any NS, along with path of the NS
+func (f *FakeNetlinker) find(index int) (netlink.Link, string) {
+	for p, ns := range f.nsList {
+		for _, l := range ns.links {
+			if l.Attrs().Index == index {
+				return l, p
+			}
+		}
+	}
+	return nil, ""
+}
+
+func notFound(name any) error {
+	return fmt.Errorf("link %v not found, %w", name, syscall.ENODEV)
+}
+
+func (f *FakeNetlinker) NSExists(path string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	_, ok := f.nsList[path]
+	return ok
+}
+
+func (f *FakeNetlinker) NewNS(name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	p := GetNSPath(name)
+	if _, ok := f.nsList[p]; ok {
+		return fmt.Errorf("ns %v already exists, %w", name, syscall.EEXIST)
+	}
+	f.addNS(p)
+	return nil
+}
+
+func (f *FakeNetlinker) DeleteNS(name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	p := GetNSPath(name)
+	ns, ok := f.nsList[p]
+	if !ok {
+		return fmt.Errorf("ns %v not found, %w", name, syscall.ENOENT)
+	}
+	for len(ns.links) > 0 {
+		f.del(ns.links[0].Attrs().Index)
+	}
+	delete(f.nsList, p)
+	return nil
+}
+
+func (f *FakeNetlinker) ListNS() ([]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	r := []string{}
+	for p := range f.nsList {
+		if p != "" && path.Dir(p) == getNsRunDir() {
+			r = append(r, path.Base(p))
+		}
+	}
+	sort.Strings(r)
+	return r, nil
+}
+
+func (f *FakeNetlinker) InNS(path string, fn func() error) error {
+	f.lock.Lock()
+	if _, ok := f.nsList[path]; !ok {
+		f.lock.Unlock()
+		return fmt.Errorf("%w %v, %w", ErrNSOpen, path, syscall.ENOENT)
+	}
+	f.current = append(f.current, path)
+	f.lock.Unlock()
+	defer func() {
+		f.lock.Lock()
+		f.current = f.current[:len(f.current)-1]
+		f.lock.Unlock()
+	}()
+	return fn()
+}
+
+func (f *FakeNetlinker) LinkByName(name string) (netlink.Link, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, l := range f.currentNS().links {
+		if l.Attrs().Name == name {
+			return l, nil
+		}
+	}
+	return nil, notFound(name)
+}
+
+func (f *FakeNetlinker) LinkByIndex(index int) (netlink.Link, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, l := range f.currentNS().links {
+		if l.Attrs().Index == index {
+			return l, nil
+		}
+	}
+	return nil, notFound(index)
+}
+
+func (f *FakeNetlinker) LinkList() ([]netlink.Link, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return slices.Clone(f.currentNS().links), nil
+}
+
+func (f *FakeNetlinker) LinkAdd(link netlink.Link) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.add(f.currentNS(), link)
+}
+
+func (f *FakeNetlinker) LinkAddToNS(link netlink.Link, path string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	ns, ok := f.nsList[path]
+	if !ok {
+		return fmt.Errorf("%w %v, %w", ErrNSOpen, path, syscall.ENOENT)
+	}
+	return f.add(ns, link)
+}
+
+func hasLink(ns *fakeNS, name string) bool {
+	return slices.ContainsFunc(ns.links, func(l netlink.Link) bool { return l.Attrs().Name == name })
+}
+
+func (f *FakeNetlinker) add(ns *fakeNS, link netlink.Link) error {
+	attrs := link.Attrs()
+	if hasLink(ns, attrs.Name) {
+		return fmt.Errorf("link %v already exists, %w", attrs.Name, syscall.EEXIST)
+	}
+	switch l := link.(type) {
+	case *netlink.Macvtap, *netlink.Macvlan:
+		if lower, _ := f.find(attrs.ParentIndex); lower == nil {
+			return notFound(attrs.ParentIndex)
+		}
+	case *netlink.Veth:
+		if hasLink(ns, l.PeerName) || l.PeerName == attrs.Name {
+			return fmt.Errorf("link %v already exists, %w", l.PeerName, syscall.EEXIST)
+		}
+	}
+	attrs.Index = f.nextIndex
+	f.nextIndex++
+	if attrs.HardwareAddr == nil {
+		attrs.HardwareAddr = net.HardwareAddr{0x02, 0, 0, 0, byte(attrs.Index >> 8), byte(attrs.Index)}
+	}
+	if attrs.Statistics == nil {
+		attrs.Statistics = &netlink.LinkStatistics{}
+	}
+	ns.links = append(ns.links, link)
+	if link.Type() == "bridge" {
+		f.bridges[attrs.Index] = BridgeOptions{MulticastSnooping: true}
+	}
+	if veth, ok := link.(*netlink.Veth); ok {
+		peer := &netlink.Veth{
+			LinkAttrs: netlink.LinkAttrs{Name: veth.PeerName, MTU: attrs.MTU, Index: f.nextIndex},
+			PeerName:  attrs.Name,
+		}
+		peer.Statistics = &netlink.LinkStatistics{}
+		f.nextIndex++
+		peer.HardwareAddr = net.HardwareAddr{0x02, 0, 0, 0, byte(peer.Index >> 8), byte(peer.Index)}
+		ns.links = append(ns.links, peer)
+		f.peers[attrs.Index] = peer.Index
+		f.peers[peer.Index] = attrs.Index
+	}
+	return nil
+}
+
+// del removes the link with index, along with its veth peer and links on top of it
+func (f *FakeNetlinker) del(index int) {
+	for _, ns := range f.nsList {
+		ns.links = slices.DeleteFunc(ns.links, func(l netlink.Link) bool { return l.Attrs().Index == index })
+		ns.neighs = slices.DeleteFunc(ns.neighs, func(n netlink.Neigh) bool { return n.LinkIndex == index })
+	}
+	f.clearTC(index)
+	delete(f.bridges, index)
+	if peer, ok := f.peers[index]; ok {
+		delete(f.peers, index)
+		delete(f.peers, peer)
+		f.del(peer)
+	}
+	for _, ns := range f.nsList {
+		for _, l := range slices.Clone(ns.links) {
+			if l.Attrs().ParentIndex == index && (l.Type() == "macvtap" || l.Type() == "macvlan") {
+				f.del(l.Attrs().Index)
+			}
+			if l.Attrs().MasterIndex == index {
+				l.Attrs().MasterIndex = 0
+			}
+		}
+	}
+}
+
+// get returns the link in current NS with the same index as link
+func (f *FakeNetlinker) get(link netlink.Link) (netlink.Link, error) {
+	for _, l := range f.currentNS().links {
+		if l.Attrs().Index == link.Attrs().Index {
+			return l, nil
+		}
+	}
+	return nil, notFound(link.Attrs().Name)
+}
+
+func (f *FakeNetlinker) LinkDel(link netlink.Link) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	f.del(l.Attrs().Index)
+	return nil
+}
+
+func (f *FakeNetlinker) LinkSetUp(link netlink.Link) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	l.Attrs().Flags |= net.FlagUp
+	return nil
+}
+
+func (f *FakeNetlinker) LinkSetDown(link netlink.Link) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	l.Attrs().Flags &^= net.FlagUp
+	return nil
+}
+
+func (f *FakeNetlinker) LinkSetMTU(link netlink.Link, mtu int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	l.Attrs().MTU = mtu
+	return nil
+}
+
+func (f *FakeNetlinker) LinkSetName(link netlink.Link, name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	if hasLink(f.currentNS(), name) {
+		return fmt.Errorf("link %v already exists, %w", name, syscall.EEXIST)
+	}
+	l.Attrs().Name = name
+	return nil
+}
+
+func (f *FakeNetlinker) LinkSetAlias(link netlink.Link, alias string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	l.Attrs().Alias = alias
+	return nil
+}
+
+func (f *FakeNetlinker) LinkSetMaster(link, master netlink.Link) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	m, err := f.get(master)
+	if err != nil {
+		return err
+	}
+	if m.Type() != "bridge" {
+		return fmt.Errorf("%v is not a bridge, %w", m.Attrs().Name, syscall.EINVAL)
+	}
+	l.Attrs().MasterIndex = m.Attrs().Index
+	return nil
+}
+
+func (f *FakeNetlinker) LinkSetNS(link netlink.Link, path string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	dst, ok := f.nsList[path]
+	if !ok {
+		return fmt.Errorf("%w %v, %w", ErrNSOpen, path, syscall.ENOENT)
+	}
+	if hasLink(dst, l.Attrs().Name) {
+		return fmt.Errorf("link %v already exists, %w", l.Attrs().Name, syscall.EEXIST)
+	}
+	cur := f.currentNS()
+	cur.links = slices.DeleteFunc(cur.links, func(x netlink.Link) bool { return x == l })
+	//moving to another NS detaches the link from its master, brings it down and removes its qdiscs
+	l.Attrs().MasterIndex = 0
+	f.clearTC(l.Attrs().Index)
+	l.Attrs().Flags &^= net.FlagUp
+	dst.links = append(dst.links, l)
+	return nil
+}
+
+func (f *FakeNetlinker) LinkSetBRSlaveGroupFwdMask(link netlink.Link, mask uint16) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return err
+	}
+	if l.Attrs().MasterIndex == 0 {
+		return fmt.Errorf("%v is not a bridge port, %w", l.Attrs().Name, syscall.EINVAL)
+	}
+	return nil
+}
+
+func (f *FakeNetlinker) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	r := []netlink.Neigh{}
+	for _, n := range f.currentNS().neighs {
+		if (linkIndex == 0 || n.LinkIndex == linkIndex) && (family == 0 || n.Family == family) {
+			r = append(r, n)
+		}
+	}
+	return r, nil
+}
+
+func (f *FakeNetlinker) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	r := []netlink.Addr{}
+	for _, a := range f.currentNS().addrs {
+		if link != nil && a.LinkIndex != link.Attrs().Index {
+			continue
+		}
+		if family == netlink.FAMILY_V4 && a.IP.To4() == nil || family == netlink.FAMILY_V6 && a.IP.To4() != nil {
+			continue
+		}
+		r = append(r, a)
+	}
+	return r, nil
+}
+
+// sameNeigh returns true if a and b are the same entry, FDB entries are identified by MAC and destination
+func sameNeigh(a, b *netlink.Neigh) bool {
+	if a.LinkIndex != b.LinkIndex || a.Family != b.Family {
+		return false
+	}
+	if a.Family == unix.AF_BRIDGE {
+		return a.HardwareAddr.String() == b.HardwareAddr.String() && a.IP.Equal(b.IP)
+	}
+	return a.IP.Equal(b.IP)
+}
+
+func (f *FakeNetlinker) NeighSet(neigh *netlink.Neigh) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	ns := f.currentNS()
+	if _, err := f.get(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: neigh.LinkIndex}}); err != nil {
+		return err
+	}
+	ns.neighs = slices.DeleteFunc(ns.neighs, func(n netlink.Neigh) bool {
+		//an FDB entry is replaced regardless of its destination
+		if neigh.Family == unix.AF_BRIDGE {
+			return n.LinkIndex == neigh.LinkIndex && n.Family == neigh.Family && n.HardwareAddr.String() == neigh.HardwareAddr.String()
+		}
+		return sameNeigh(&n, neigh)
+	})
+	ns.neighs = append(ns.neighs, *neigh)
+	return nil
+}
+
+func (f *FakeNetlinker) FDBAdd(neigh *netlink.Neigh, via int, appendEntry bool) error {
+	if !appendEntry {
+		if err := f.NeighSet(neigh); err != nil {
+			return err
+		}
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.currentNS().vias[fmt.Sprintf("%v %v", neigh.HardwareAddr, neigh.IP)] = via
+		return nil
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	ns := f.currentNS()
+	if _, err := f.get(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: neigh.LinkIndex}}); err != nil {
+		return err
+	}
+	if slices.ContainsFunc(ns.neighs, func(n netlink.Neigh) bool { return sameNeigh(&n, neigh) }) {
+		return fmt.Errorf("neigh %v already exists, %w", neigh, syscall.EEXIST)
+	}
+	ns.neighs = append(ns.neighs, *neigh)
+	ns.vias[fmt.Sprintf("%v %v", neigh.HardwareAddr, neigh.IP)] = via
+	return nil
+}
+
+func (f *FakeNetlinker) NeighDel(neigh *netlink.Neigh) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	ns := f.currentNS()
+	i := slices.IndexFunc(ns.neighs, func(n netlink.Neigh) bool { return sameNeigh(&n, neigh) })
+	if i < 0 {
+		return fmt.Errorf("neigh %v not found, %w", neigh, syscall.ENOENT)
+	}
+	ns.neighs = slices.Delete(ns.neighs, i, i+1)
+	return nil
+}
+
+// clearTC removes qdiscs and filters of the link with index
+func (f *FakeNetlinker) clearTC(index int) {
+	delete(f.qdiscs, index)
+	delete(f.filters, index)
+}
+
+func (f *FakeNetlinker) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(link)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.qdiscs[l.Attrs().Index]), nil
+}
+
+func sameQdisc(a, b netlink.Qdisc) bool {
+	return a.Attrs().Parent == b.Attrs().Parent && a.Attrs().Handle == b.Attrs().Handle
+}
+
+func (f *FakeNetlinker) QdiscAdd(qdisc netlink.Qdisc) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: qdisc.Attrs().LinkIndex}})
+	if err != nil {
+		return err
+	}
+	index := l.Attrs().Index
+	if slices.ContainsFunc(f.qdiscs[index], func(q netlink.Qdisc) bool { return sameQdisc(q, qdisc) }) {
+		return fmt.Errorf("qdisc %v already exists, %w", qdisc, syscall.EEXIST)
+	}
+	f.qdiscs[index] = append(f.qdiscs[index], qdisc)
+	return nil
+}
+
+func (f *FakeNetlinker) QdiscReplace(qdisc netlink.Qdisc) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: qdisc.Attrs().LinkIndex}})
+	if err != nil {
+		return err
+	}
+	index := l.Attrs().Index
+	f.qdiscs[index] = slices.DeleteFunc(f.qdiscs[index], func(q netlink.Qdisc) bool { return q.Attrs().Parent == qdisc.Attrs().Parent })
+	f.qdiscs[index] = append(f.qdiscs[index], qdisc)
+	return nil
+}
+
+func (f *FakeNetlinker) QdiscDel(qdisc netlink.Qdisc) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: qdisc.Attrs().LinkIndex}})
+	if err != nil {
+		return err
+	}
+	index := l.Attrs().Index
+	if !slices.ContainsFunc(f.qdiscs[index], func(q netlink.Qdisc) bool { return sameQdisc(q, qdisc) }) {
+		return fmt.Errorf("qdisc %v not found, %w", qdisc, syscall.ENOENT)
+	}
+	//children are removed along with their parent
+	major, _ := netlink.MajorMinor(qdisc.Attrs().Handle)
+	f.qdiscs[index] = slices.DeleteFunc(f.qdiscs[index], func(q netlink.Qdisc) bool {
+		parent, _ := netlink.MajorMinor(q.Attrs().Parent)
+		return sameQdisc(q, qdisc) || q.Attrs().Parent != netlink.HANDLE_ROOT && parent == major
+	})
+	return nil
+}
+
+// hasClsact returns true if the link with index has a clsact qdisc
+func (f *FakeNetlinker) hasClsact(index int) bool {
+	return slices.ContainsFunc(f.qdiscs[index], func(q netlink.Qdisc) bool { return q.Attrs().Parent == netlink.HANDLE_CLSACT })
+}
+
+func sameFilter(a, b netlink.Filter) bool {
+	return a.Attrs().Parent == b.Attrs().Parent && a.Attrs().Priority == b.Attrs().Priority && a.Attrs().Protocol == b.Attrs().Protocol
+}
+
+// getClsact returns index of the link of filter in current NS, error if it has no clsact qdisc
+func (f *FakeNetlinker) getClsact(filter netlink.Filter) (int, error) {
+	l, err := f.get(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Index: filter.Attrs().LinkIndex}})
+	if err != nil {
+		return 0, err
+	}
+	if !f.hasClsact(l.Attrs().Index) {
+		return 0, fmt.Errorf("%v has no clsact qdisc, %w", l.Attrs().Name, syscall.EINVAL)
+	}
+	return l.Attrs().Index, nil
+}
+
+func (f *FakeNetlinker) FilterList(link netlink.Link, parent uint32) ([]netlink.Filter, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	index, err := f.getClsact(&netlink.MatchAll{FilterAttrs: netlink.FilterAttrs{LinkIndex: link.Attrs().Index}})
+	if err != nil {
+		return nil, err
+	}
+	r := []netlink.Filter{}
+	for _, filter := range f.filters[index] {
+		if filter.Attrs().Parent == parent {
+			r = append(r, filter)
+		}
+	}
+	return r, nil
+}
+
+func (f *FakeNetlinker) FilterAdd(filter netlink.Filter) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	index, err := f.getClsact(filter)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(f.filters[index], func(x netlink.Filter) bool { return sameFilter(x, filter) }) {
+		return fmt.Errorf("filter %v already exists, %w", filter, syscall.EEXIST)
+	}
+	f.filters[index] = append(f.filters[index], filter)
+	return nil
+}
+
+func (f *FakeNetlinker) FilterReplace(filter netlink.Filter) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	index, err := f.getClsact(filter)
+	if err != nil {
+		return err
+	}
+	f.filters[index] = slices.DeleteFunc(f.filters[index], func(x netlink.Filter) bool { return sameFilter(x, filter) })
+	f.filters[index] = append(f.filters[index], filter)
+	return nil
+}
+
+func (f *FakeNetlinker) FilterDel(filter netlink.Filter) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	index, err := f.getClsact(filter)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(f.filters[index], func(x netlink.Filter) bool { return sameFilter(x, filter) })
+	if i < 0 {
+		return fmt.Errorf("filter %v not found, %w", filter, syscall.ENOENT)
+	}
+	f.filters[index] = slices.Delete(f.filters[index], i, i+1)
+	return nil
+}
+
+func (f *FakeNetlinker) BridgeOptions(br netlink.Link) (BridgeOptions, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(br)
+	if err != nil {
+		return BridgeOptions{}, err
+	}
+	opts, ok := f.bridges[l.Attrs().Index]
+	if !ok {
+		return BridgeOptions{}, fmt.Errorf("%v is not a bridge, %w", l.Attrs().Name, syscall.EINVAL)
+	}
+	return opts, nil
+}
+
+func (f *FakeNetlinker) BridgeSetOptions(br netlink.Link, opts BridgeOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	l, err := f.get(br)
+	if err != nil {
+		return err
+	}
+	if _, ok := f.bridges[l.Attrs().Index]; !ok {
+		return fmt.Errorf("%v is not a bridge, %w", l.Attrs().Name, syscall.EINVAL)
+	}
+	f.bridges[l.Attrs().Index] = opts
+	return nil
+}
@@ -0,0 +1,242 @@
+package interfaces
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// zeroMAC is the MAC of vxlan FDB entries BUM traffic is replicated to
+var zeroMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+
+// RemoteMAC is a MAC address behind a remote VTEP, along with its IP addresses if known
+type RemoteMAC struct {
+	MAC  net.HardwareAddr
+	VTEP netip.Addr
+	IPs  []netip.Addr
+}
+
+// RemoteFDB is the forwarding state of the vxlan interface of a LAN distributed by a control plane,
+// instead of being learned from data traffic
+type RemoteFDB struct {
+	// BUM traffic is replicated to each of them, in addition to the multicast group
+	FloodVTEPs []netip.Addr
+	MACs       []RemoteMAC
+	// Sources is the source address of traffic to a VTEP, key is the VTEP;
+	// the underlying interface owning the source address is used to reach the VTEP instead of the vxlan device
+	Sources map[netip.Addr]netip.Addr
+	// NoGroup removes the default entry of the multicast group, so BUM traffic is only replicated to FloodVTEPs
+	NoGroup bool
+}
+
+var (
+	fdbViasLock = new(sync.Mutex)
+	// the via interface index FDB entries are added with, since it is not returned by the kernel;
+	// key is fdbViaKey of the entry
+	fdbVias = map[string]int{}
+)
+
+func fdbViaKey(nsname string, n *netlink.Neigh) string {
+	return fmt.Sprintf("%v/%v/%v", nsname, n.HardwareAddr, n.IP)
+}
+
+// forgetFDBVias removes the recorded via interfaces of entries in the LAN NS nsname
+func forgetFDBVias(nsname string) {
+	fdbViasLock.Lock()
+	defer fdbViasLock.Unlock()
+	for key := range fdbVias {
+		if strings.HasPrefix(key, nsname+"/") {
+			delete(fdbVias, key)
+		}
+	}
+}
+
+// linkByAddr returns the link in host NS owning addr
+func linkByAddr(addr netip.Addr) (netlink.Link, error) {
+	var r netlink.Link
+	err := nl.InNS("", func() error {
+		links, err := nl.LinkList()
+		if err != nil {
+			return fmt.Errorf("failed to list interfaces, %w", err)
+		}
+		for _, link := range links {
+			addrs, err := nl.AddrList(link, netlink.FAMILY_ALL)
+			if err != nil {
+				continue
+			}
+			for _, a := range addrs {
+				if sameAddr(a.IP, addr) {
+					r = link
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("no interface has address %v", addr)
+	})
+	return r, err
+}
+
+// desired returns the static FDB and neighbor entries on the vxlan interface with index
+func (fdb *RemoteFDB) desired(index int) []netlink.Neigh {
+	r := []netlink.Neigh{}
+	for _, vtep := range fdb.FloodVTEPs {
+		r = append(r, newRemoteFDBEntry(index, zeroMAC, vtep))
+	}
+	for _, m := range fdb.MACs {
+		r = append(r, newRemoteFDBEntry(index, m.MAC, m.VTEP))
+		for _, ip := range m.IPs {
+			family := netlink.FAMILY_V4
+			if ip.Is6() {
+				family = netlink.FAMILY_V6
+			}
+			r = append(r, netlink.Neigh{
+				LinkIndex:    index,
+				Family:       family,
+				State:        netlink.NUD_PERMANENT,
+				IP:           ip.AsSlice(),
+				HardwareAddr: m.MAC,
+			})
+		}
+	}
+	return r
+}
+
+func newRemoteFDBEntry(index int, mac net.HardwareAddr, vtep netip.Addr) netlink.Neigh {
+	return netlink.Neigh{
+		LinkIndex:    index,
+		Family:       unix.AF_BRIDGE,
+		Flags:        netlink.NTF_SELF,
+		State:        netlink.NUD_PERMANENT,
+		IP:           vtep.AsSlice(),
+		HardwareAddr: mac,
+	}
+}
+
+// isRemoteEntry returns true if n is a static entry could be added by SyncRemoteFDB,
+// the default entry of the multicast group grp is not
+func isRemoteEntry(n *netlink.Neigh, grp netip.Addr) bool {
+	if n.State&netlink.NUD_PERMANENT == 0 || n.IP == nil {
+		return false
+	}
+	return n.Family != unix.AF_BRIDGE || !sameAddr(n.IP, grp)
+}
+
+func sameRemoteEntry(a, b *netlink.Neigh) bool {
+	return a.Family == b.Family && a.IP.Equal(b.IP) && a.HardwareAddr.String() == b.HardwareAddr.String()
+}
+
+// listRemoteEntries returns the entries on the vxlan interface with index could be added by SyncRemoteFDB
+func listRemoteEntries(index int, grp netip.Addr) ([]netlink.Neigh, error) {
+	r := []netlink.Neigh{}
+	for _, family := range []int{unix.AF_BRIDGE, netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		neighs, err := nl.NeighList(index, family)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range neighs {
+			if isRemoteEntry(&n, grp) {
+				r = append(r, n)
+			}
+		}
+	}
+	return r, nil
+}
+
+// SyncRemoteFDB makes static FDB and neighbor entries on the vxlan interface of lan on this node match fdb;
+// other static entries with a destination are removed, except the default one of the multicast group unless fdb.NoGroup
+func SyncRemoteFDB(lan *v1beta1.LANSpec, hostname string, fdb *RemoteFDB) error {
+	if lan.GetEncapsulation() != v1beta1.EncapVxLAN {
+		return fmt.Errorf("static fdb requires %v encapsulation", v1beta1.EncapVxLAN)
+	}
+	grp := netip.MustParseAddr(*lan.VxLANGrp)
+	if fdb.NoGroup {
+		//no entry is the default one
+		grp = netip.Addr{}
+	}
+	vxDev, err := getVxDev(lan, hostname)
+	if err != nil {
+		return err
+	}
+	vias := map[netip.Addr]int{}
+	for vtep, src := range fdb.Sources {
+		link, err := linkByAddr(src)
+		if err != nil {
+			return fmt.Errorf("failed to find source %v of %v, %w", src, vtep, err)
+		}
+		vias[vtep] = link.Attrs().Index
+	}
+	//a link-local VTEP is reached via the underlying interface
+	defaultVia := func(ip net.IP) int {
+		if ip.IsLinkLocalUnicast() {
+			return vxDev.Attrs().Index
+		}
+		return 0
+	}
+	getVia := func(ip net.IP) int {
+		if vtep, ok := netip.AddrFromSlice(ip); ok {
+			if via, ok := vias[vtep.Unmap()]; ok {
+				return via
+			}
+		}
+		return defaultVia(ip)
+	}
+	fdbViasLock.Lock()
+	defer fdbViasLock.Unlock()
+	return nl.InNS(GetNSPath(*lan.NS), func() error {
+		vx, err := nl.LinkByName(*lan.VxLANName)
+		if err != nil {
+			return fmt.Errorf("failed to find vxlan interface %v, %w", *lan.VxLANName, err)
+		}
+		desired := fdb.desired(vx.Attrs().Index)
+		existing, err := listRemoteEntries(vx.Attrs().Index, grp)
+		if err != nil {
+			return fmt.Errorf("failed to list neighbors of %v, %w", *lan.VxLANName, err)
+		}
+		kept := []netlink.Neigh{}
+		for _, e := range existing {
+			keep := slices.ContainsFunc(desired, func(n netlink.Neigh) bool { return sameRemoteEntry(&n, &e) })
+			//an FDB entry is re-added if it should be reached via another interface
+			if keep && e.Family == unix.AF_BRIDGE {
+				via, ok := fdbVias[fdbViaKey(*lan.NS, &e)]
+				if !ok {
+					//added before a restart
+					via = defaultVia(e.IP)
+				}
+				keep = via == getVia(e.IP)
+			}
+			if keep {
+				kept = append(kept, e)
+				continue
+			}
+			if err := nl.NeighDel(&e); err != nil {
+				return fmt.Errorf("failed to remove %v %v of %v, %w", e.HardwareAddr, e.IP, *lan.VxLANName, err)
+			}
+			delete(fdbVias, fdbViaKey(*lan.NS, &e))
+		}
+		for _, n := range desired {
+			if slices.ContainsFunc(kept, func(e netlink.Neigh) bool { return sameRemoteEntry(&n, &e) }) {
+				continue
+			}
+			if n.Family == unix.AF_BRIDGE {
+				via := getVia(n.IP)
+				//there are multiple all-zero entries
+				if err = nl.FDBAdd(&n, via, slices.Equal(n.HardwareAddr, zeroMAC)); err == nil {
+					fdbVias[fdbViaKey(*lan.NS, &n)] = via
+				}
+			} else {
+				err = nl.NeighSet(&n)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to add %v %v to %v, %w", n.HardwareAddr, n.IP, *lan.VxLANName, err)
+			}
+		}
+		return nil
+	})
+}
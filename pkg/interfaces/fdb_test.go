@@ -0,0 +1,128 @@
+package interfaces
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// neighStrings returns the entries on the link with index in the NS of path, as "<mac> <ip>"
+func neighStrings(fake *FakeNetlinker, path string, index int) []string {
+	r := []string{}
+	fake.InNS(path, func() error {
+		neighs, _ := fake.NeighList(index, 0)
+		for _, n := range neighs {
+			r = append(r, n.HardwareAddr.String()+" "+n.IP.String())
+		}
+		return nil
+	})
+	slices.Sort(r)
+	return r
+}
+
+func TestSyncRemoteFDB(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	nsPath := GetNSPath("lan1")
+	vx := findLink(fake, nsPath, "vx-lan1")
+	mac1, _ := net.ParseMAC("02:00:00:00:01:01")
+	mac2, _ := net.ParseMAC("02:00:00:00:01:02")
+	//the default entry of the group and a learned one are not touched
+	fake.AddNeigh(nsPath, newRemoteFDBEntry(vx.Attrs().Index, zeroMAC, netip.MustParseAddr("ff02::14")))
+	fake.AddNeigh(nsPath, netlink.Neigh{LinkIndex: vx.Attrs().Index, Family: unix.AF_BRIDGE, State: netlink.NUD_REACHABLE,
+		HardwareAddr: mac2, IP: net.ParseIP("fe80::4")})
+	fdb := &RemoteFDB{
+		FloodVTEPs: []netip.Addr{netip.MustParseAddr("fe80::2"), netip.MustParseAddr("fe80::3")},
+		MACs: []RemoteMAC{
+			{MAC: mac1, VTEP: netip.MustParseAddr("fe80::2"), IPs: []netip.Addr{netip.MustParseAddr("10.1.1.2")}},
+		},
+	}
+	for range 2 {
+		if err := SyncRemoteFDB(&lan.Spec, testHost, fdb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"00:00:00:00:00:00 fe80::2",
+		"00:00:00:00:00:00 fe80::3",
+		"00:00:00:00:00:00 ff02::14",
+		"02:00:00:00:01:01 10.1.1.2",
+		"02:00:00:00:01:01 fe80::2",
+		"02:00:00:00:01:02 fe80::4",
+	}
+	if got := neighStrings(fake, nsPath, vx.Attrs().Index); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("entries are %v, expect %v", got, expected)
+	}
+	//mac1 moves to another VTEP, fe80::3 is gone
+	fdb.FloodVTEPs = fdb.FloodVTEPs[:1]
+	fdb.MACs[0].VTEP = netip.MustParseAddr("fe80::5")
+	fdb.MACs[0].IPs = nil
+	if err := SyncRemoteFDB(&lan.Spec, testHost, fdb); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"00:00:00:00:00:00 fe80::2",
+		"00:00:00:00:00:00 ff02::14",
+		"02:00:00:00:01:01 fe80::5",
+		"02:00:00:00:01:02 fe80::4",
+	}
+	if got := neighStrings(fake, nsPath, vx.Attrs().Index); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("entries are %v, expect %v", got, expected)
+	}
+	//BUM traffic is only replicated to the flood VTEPs
+	fdb.NoGroup = true
+	if err := SyncRemoteFDB(&lan.Spec, testHost, fdb); err != nil {
+		t.Fatal(err)
+	}
+	expected = slices.DeleteFunc(expected, func(e string) bool { return e == "00:00:00:00:00:00 ff02::14" })
+	if got := neighStrings(fake, nsPath, vx.Attrs().Index); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("entries are %v, expect %v", got, expected)
+	}
+}
+
+func TestSyncRemoteFDBSources(t *testing.T) {
+	fake := setupFake(t)
+	if err := fake.LinkAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "eth1", MTU: 1500}}); err != nil {
+		t.Fatal(err)
+	}
+	eth1 := findLink(fake, "", "eth1")
+	fake.AddAddr("", eth1.Attrs().Index, netlink.Addr{IPNet: &net.IPNet{IP: net.ParseIP("2001:db8::9"), Mask: net.CIDRMask(64, 128)}})
+	lan := newTestLAN("lan1", "uid1", 100)
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	nsPath := GetNSPath("lan1")
+	vx := findLink(fake, nsPath, "vx-lan1")
+	vtep := netip.MustParseAddr("2001:db8:1::1")
+	flood := newRemoteFDBEntry(vx.Attrs().Index, zeroMAC, vtep)
+	fdb := &RemoteFDB{FloodVTEPs: []netip.Addr{vtep}}
+	if err := SyncRemoteFDB(&lan.Spec, testHost, fdb); err != nil {
+		t.Fatal(err)
+	}
+	if via := fake.FDBVia(nsPath, flood); via != 0 {
+		t.Errorf("expect no via interface, got %d", via)
+	}
+	//the entry is re-added via the interface owning the source
+	fdb.Sources = map[netip.Addr]netip.Addr{vtep: netip.MustParseAddr("2001:db8::9")}
+	if err := SyncRemoteFDB(&lan.Spec, testHost, fdb); err != nil {
+		t.Fatal(err)
+	}
+	if via := fake.FDBVia(nsPath, flood); via != eth1.Attrs().Index {
+		t.Errorf("expect via %d, got %d", eth1.Attrs().Index, via)
+	}
+	if got := neighStrings(fake, nsPath, vx.Attrs().Index); len(got) != 1 || got[0] != "00:00:00:00:00:00 2001:db8:1::1" {
+		t.Errorf("unexpected entries %v", got)
+	}
+	fdb.Sources = map[netip.Addr]netip.Addr{vtep: netip.MustParseAddr("2001:db8::10")}
+	if err := SyncRemoteFDB(&lan.Spec, testHost, fdb); err == nil {
+		t.Error("expect error for a source not owned by any interface")
+	}
+}
@@ -0,0 +1,154 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// kinds of Orphan
+const (
+	OrphanNamespace = "namespace"
+	OrphanLink      = "link"
+)
+
+// ErrNotOrphan is returned by RemoveOrphan if the NS or interface is taken by a LAN since it was found
+var ErrNotOrphan = errors.New("no longer an orphan")
+
+// Orphan is a k8slan managed NS or host interface that is not backed by any LAN
+type Orphan struct {
+	Kind string
+	Name string
+	// UID of the LAN owning the NS or interface when it was found, empty for k8slan-dummy
+	UID types.UID
+	// index of the interface
+	index int
+}
+
+func (o Orphan) String() string {
+	return o.Kind + " " + o.Name
+}
+
+// markNS marks the current NS as the LAN NS of the LAN with uid,
+// by setting the owner alias on lo; it tells LAN NS apart from other named NS in the same run dir
+func markNS(uid types.UID) error {
+	return setOwner("lo", Owner{LANUID: uid, Role: RoleNamespace})
+}
+
+// getLANNSOwner returns the owner of the named NS if it is marked as a LAN NS
+func getLANNSOwner(nsname string) (Owner, bool) {
+	var o Owner
+	isLAN := false
+	nl.InNS(GetNSPath(nsname), func() error {
+		o, isLAN = getNSOwner()
+		return nil
+	})
+	return o, isLAN
+}
+
+// FindOrphans returns LAN NS and host interfaces created by k8slan on this node that are not backed by any LAN returned by listLANs:
+//   - marked NS under the run dir not used by any LAN
+//   - host interfaces with an owner alias of a LAN not in the list
+//   - k8slan-dummy if nothing other than orphans is on top of it
+//
+// NS and interfaces are scanned before listLANs is called, so those created for a LAN after the list are never orphans
+func FindOrphans(listLANs func() ([]*v1beta1.LAN, error)) ([]Orphan, error) {
+	nsNames, err := nl.ListNS()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ns run dir, %w", err)
+	}
+	//key is NS name, value is the owner LAN
+	lanNS := map[string]types.UID{}
+	for _, name := range nsNames {
+		if o, ok := getLANNSOwner(name); ok {
+			lanNS[name] = o.LANUID
+		}
+	}
+	links, err := nl.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces, %w", err)
+	}
+	lans, err := listLANs()
+	if err != nil {
+		return nil, err
+	}
+	r := []Orphan{}
+	usedNS := []string{}
+	uids := []types.UID{}
+	for _, lan := range lans {
+		usedNS = append(usedNS, *lan.Spec.NS)
+		uids = append(uids, lan.UID)
+	}
+	for _, name := range nsNames {
+		if uid, ok := lanNS[name]; ok && !slices.Contains(usedNS, name) {
+			r = append(r, Orphan{Kind: OrphanNamespace, Name: name, UID: uid})
+		}
+	}
+	var dummy netlink.Link
+	orphanIndexes := []int{}
+	for _, link := range links {
+		o, ok := GetOwner(link)
+		if !ok {
+			continue
+		}
+		if o.Role == RoleDummy {
+			dummy = link
+			continue
+		}
+		if o.LANUID != "" && !slices.Contains(uids, o.LANUID) {
+			r = append(r, Orphan{Kind: OrphanLink, Name: link.Attrs().Name, UID: o.LANUID, index: link.Attrs().Index})
+			orphanIndexes = append(orphanIndexes, link.Attrs().Index)
+		}
+	}
+	if dummy != nil {
+		inUse := slices.ContainsFunc(links, func(link netlink.Link) bool {
+			return link.Attrs().ParentIndex == dummy.Attrs().Index && !slices.Contains(orphanIndexes, link.Attrs().Index)
+		})
+		if !inUse {
+			r = append(r, Orphan{Kind: OrphanLink, Name: dummy.Attrs().Name, index: dummy.Attrs().Index})
+		}
+	}
+	return r, nil
+}
+
+// RemoveOrphan removes the orphaned NS or host interface, after checking it is still owned by the same LAN,
+// ErrNotOrphan is returned if not; the NS is checked and removed with the NS locked, so it never races with Ensure
+func RemoveOrphan(o Orphan) error {
+	switch o.Kind {
+	case OrphanNamespace:
+		unlock := lockNS(o.Name)
+		defer unlock()
+		if owner, ok := getLANNSOwner(o.Name); !ok || owner.LANUID != o.UID {
+			return fmt.Errorf("%w, ns %v", ErrNotOrphan, o.Name)
+		}
+		return nl.DeleteNS(o.Name)
+	case OrphanLink:
+		link, err := nl.LinkByName(o.Name)
+		if isLinkNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		owner, ok := GetOwner(link)
+		if !ok || owner.LANUID != o.UID || link.Attrs().Index != o.index {
+			return fmt.Errorf("%w, interface %v", ErrNotOrphan, o.Name)
+		}
+		if owner.Role == RoleDummy {
+			//a spoke might be allocated on it since it was found, orphans on top of it are removed before it
+			links, err := nl.LinkList()
+			if err != nil {
+				return fmt.Errorf("failed to list interfaces, %w", err)
+			}
+			if slices.ContainsFunc(links, func(l netlink.Link) bool { return l.Attrs().ParentIndex == o.index }) {
+				return fmt.Errorf("%w, interface %v is in use", ErrNotOrphan, o.Name)
+			}
+		}
+		return LinkDelete(o.Name)
+	}
+	return fmt.Errorf("unknown orphan kind %v", o.Kind)
+}
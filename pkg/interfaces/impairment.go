@@ -0,0 +1,162 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+)
+
+var (
+	// handle of the root netem qdisc
+	netemHandle = netlink.MakeHandle(1, 0)
+	// handle of the tbf qdisc for rate limiting, child of the netem qdisc
+	tbfHandle = netlink.MakeHandle(10, 0)
+)
+
+const (
+	// max queuing delay allowed by the tbf qdisc, in ms
+	tbfLatencyMs = 50
+	// min burst size of the tbf qdisc, in bytes
+	tbfMinBurst = 32 * 1024
+)
+
+// newImpairmentQdiscs returns the netem and tbf qdisc on link for imp, tbf is nil if imp has no rate
+func newImpairmentQdiscs(link netlink.Link, imp *v1beta1.Impairment) (*netlink.Netem, *netlink.Tbf, error) {
+	nattrs := netlink.NetemQdiscAttrs{}
+	if imp.Delay != nil {
+		nattrs.Latency = uint32(imp.Delay.Microseconds())
+	}
+	if imp.Jitter != nil {
+		nattrs.Jitter = uint32(imp.Jitter.Microseconds())
+	}
+	var err error
+	if nattrs.Loss, err = v1beta1.ParsePercentage(imp.Loss); err != nil {
+		return nil, nil, err
+	}
+	if nattrs.CorruptProb, err = v1beta1.ParsePercentage(imp.Corrupt); err != nil {
+		return nil, nil, err
+	}
+	if nattrs.Duplicate, err = v1beta1.ParsePercentage(imp.Duplicate); err != nil {
+		return nil, nil, err
+	}
+	if nattrs.ReorderProb, err = v1beta1.ParsePercentage(imp.Reorder); err != nil {
+		return nil, nil, err
+	}
+	netem := netlink.NewNetem(netlink.QdiscAttrs{
+		LinkIndex: link.Attrs().Index,
+		Handle:    netemHandle,
+		Parent:    netlink.HANDLE_ROOT,
+	}, nattrs)
+	if imp.Rate == nil {
+		return netem, nil, nil
+	}
+	rate := uint64(imp.Rate.Value() / 8)
+	burst := max(uint32(rate/100), tbfMinBurst)
+	tbf := &netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    tbfHandle,
+			Parent:    netlink.MakeHandle(1, 1),
+		},
+		Rate:   rate,
+		Buffer: netlink.Xmittime(rate, burst),
+		Limit:  burst + uint32(rate*tbfLatencyMs/1000),
+	}
+	return netem, tbf, nil
+}
+
+// getImpairmentQdiscs returns the netem and tbf qdisc created by applyImpairment on link, nil if not found
+func getImpairmentQdiscs(link netlink.Link) (*netlink.Netem, *netlink.Tbf, error) {
+	qdiscs, err := nl.QdiscList(link)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list qdisc of %v, %w", link.Attrs().Name, err)
+	}
+	var netem *netlink.Netem
+	var tbf *netlink.Tbf
+	for _, q := range qdiscs {
+		switch q := q.(type) {
+		case *netlink.Netem:
+			if q.Parent == netlink.HANDLE_ROOT && q.Handle == netemHandle {
+				netem = q
+			}
+		case *netlink.Tbf:
+			if q.Handle == tbfHandle {
+				tbf = q
+			}
+		}
+	}
+	return netem, tbf, nil
+}
+
+// diffImpairment adds the step to make the qdiscs of link match the impairment of l
+func (p *Plan) diffImpairment(link netlink.Link, l *LinkSpec) error {
+	netem, tbf, err := getImpairmentQdiscs(link)
+	if err != nil {
+		return err
+	}
+	if l.TC.Impairment == nil {
+		if netem != nil {
+			p.add(OpSetImpairment, l, "the impairment is removed")
+		}
+		return nil
+	}
+	wantNetem, wantTbf, err := newImpairmentQdiscs(link, l.TC.Impairment)
+	if err != nil {
+		return err
+	}
+	switch {
+	case netem == nil:
+		p.add(OpSetImpairment, l, "there is no netem qdisc")
+	case netem.Latency != wantNetem.Latency || netem.Jitter != wantNetem.Jitter || netem.Loss != wantNetem.Loss ||
+		netem.Duplicate != wantNetem.Duplicate || netem.ReorderProb != wantNetem.ReorderProb || netem.CorruptProb != wantNetem.CorruptProb:
+		p.add(OpSetImpairment, l, "the existing netem qdisc has different parameters")
+	case (tbf == nil) != (wantTbf == nil) || tbf != nil && tbf.Rate != wantTbf.Rate:
+		p.add(OpSetImpairment, l, "the existing tbf qdisc has a different rate")
+	}
+	return nil
+}
+
+// applyImpairment sets netem and tbf qdisc on link according to imp,
+// existing qdiscs are removed if imp is nil
+func applyImpairment(link netlink.Link, imp *v1beta1.Impairment) error {
+	if imp == nil {
+		return clearImpairment(link)
+	}
+	netem, tbf, err := newImpairmentQdiscs(link, imp)
+	if err != nil {
+		return err
+	}
+	if err := nl.QdiscReplace(netem); err != nil {
+		return fmt.Errorf("failed to set netem qdisc on %v, %w", link.Attrs().Name, err)
+	}
+	if tbf == nil {
+		err = nl.QdiscDel(&netlink.Tbf{QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    tbfHandle,
+			Parent:    netlink.MakeHandle(1, 1),
+		}})
+		if err != nil && !errors.Is(err, syscall.ENOENT) && !errors.Is(err, syscall.EINVAL) {
+			return fmt.Errorf("failed to remove tbf qdisc on %v, %w", link.Attrs().Name, err)
+		}
+		return nil
+	}
+	if err := nl.QdiscReplace(tbf); err != nil {
+		return fmt.Errorf("failed to set tbf qdisc on %v, %w", link.Attrs().Name, err)
+	}
+	return nil
+}
+
+// clearImpairment removes the root netem qdisc created by applyImpairment on link, if any
+func clearImpairment(link netlink.Link) error {
+	netem, _, err := getImpairmentQdiscs(link)
+	if err != nil || netem == nil {
+		return err
+	}
+	if err := nl.QdiscDel(netem); err != nil {
+		return fmt.Errorf("failed to remove netem qdisc on %v, %w", link.Attrs().Name, err)
+	}
+	return nil
+}
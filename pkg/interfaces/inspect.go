@@ -0,0 +1,105 @@
+package interfaces
+
+import (
+	"fmt"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// GetFDB returns the bridge FDB of lan on this node, nil if the LAN NS doesn't exist on this node
+func GetFDB(lan *v1beta1.LANSpec) ([]dataplane.FDBEntry, error) {
+	nsPath := GetNSPath(*lan.NS)
+	if !nl.NSExists(nsPath) {
+		return nil, nil
+	}
+	if lan.IsP2P() {
+		return nil, fmt.Errorf("there is no bridge in p2p mode")
+	}
+	r := []dataplane.FDBEntry{}
+	err := nl.InNS(nsPath, func() error {
+		br, err := nl.LinkByName(*lan.BridgeName)
+		if err != nil {
+			return fmt.Errorf("failed to find bridge %v, %w", *lan.BridgeName, err)
+		}
+		neighs, err := listBridgeFDB(br)
+		if err != nil {
+			return err
+		}
+		spokes := map[string]string{}
+		for _, spoke := range lan.SpokeList {
+			spokes[GetPeerVethName(spoke)] = spoke
+		}
+		for _, n := range neighs {
+			entry := dataplane.FDBEntry{
+				MAC:     n.HardwareAddr.String(),
+				VLAN:    n.Vlan,
+				Learned: isLearnedFDB(n),
+			}
+			if port, err := nl.LinkByIndex(n.LinkIndex); err == nil {
+				entry.Port = port.Attrs().Name
+				entry.Spoke = spokes[entry.Port]
+			}
+			r = append(r, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// listBridgeFDB returns FDB entries of bridge br, it must be called in the NS of br
+func listBridgeFDB(br netlink.Link) ([]netlink.Neigh, error) {
+	neighs, err := nl.NeighList(0, unix.AF_BRIDGE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fdb, %w", err)
+	}
+	r := []netlink.Neigh{}
+	for _, n := range neighs {
+		if n.MasterIndex == br.Attrs().Index {
+			r = append(r, n)
+		}
+	}
+	return r, nil
+}
+
+func isLearnedFDB(n netlink.Neigh) bool {
+	return n.State&(netlink.NUD_PERMANENT|netlink.NUD_NOARP) == 0
+}
+
+// GetLANState returns the state of the interfaces of lan on this node
+func GetLANState(lan *v1beta1.LANSpec, hostname string) (*dataplane.LANState, error) {
+	r := &dataplane.LANState{Node: hostname}
+	nsPath := GetNSPath(*lan.NS)
+	if !nl.NSExists(nsPath) {
+		return r, nil
+	}
+	r.NSExists = true
+	err := nl.InNS(nsPath, func() error {
+		add := func(name, role, spoke string) {
+			state := dataplane.LinkState{Name: name, Role: role, Spoke: spoke}
+			if link, err := nl.LinkByName(name); err == nil {
+				state.Exists = true
+				state.Up = link.Attrs().Flags&unix.IFF_UP != 0
+				state.MTU = link.Attrs().MTU
+			}
+			r.Links = append(r.Links, state)
+		}
+		if !lan.IsP2P() {
+			add(*lan.BridgeName, dataplane.RoleBridge, "")
+		}
+		add(*lan.VxLANName, dataplane.RoleVxLAN, "")
+		for _, spoke := range lan.SpokeList {
+			add(GetPeerVethName(spoke), dataplane.RolePeer, spoke)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
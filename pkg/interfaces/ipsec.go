@@ -0,0 +1,282 @@
+package interfaces
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/netip"
+	"slices"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ESP in transport mode with AES-GCM: 8 bytes header, 8 bytes IV, 16 bytes ICV, 2 bytes trailer and up to 3 bytes padding
+	maxESPOverhead = 40
+	ipsecAEADAlgo  = "rfc4106(gcm(aes))"
+	// AES-128 key plus 4 bytes salt
+	ipsecKeyLen = 20
+	ipsecICVLen = 128
+)
+
+var (
+	// ErrIPsecConflict is returned if the vxlan port of a LAN is already encrypted for another LAN
+	ErrIPsecConflict = errors.New("vxlan port is encrypted for another LAN")
+	// ErrIPsecPortShared is returned if the vxlan port of an encrypted LAN is also used by another LAN or peering on this node
+	ErrIPsecPortShared = errors.New("vxlan port of an encrypted LAN is shared")
+)
+
+// IPsecKey is the ESP key a worker encrypts vxlan traffic of a LAN with, identified by SPI
+type IPsecKey struct {
+	SPI uint32
+	Key []byte
+}
+
+// NewIPsecKey returns a random key
+func NewIPsecKey() (IPsecKey, error) {
+	k := IPsecKey{Key: make([]byte, ipsecKeyLen)}
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return k, fmt.Errorf("failed to generate spi, %w", err)
+	}
+	//SPI 0-255 are reserved
+	k.SPI = binary.BigEndian.Uint32(buf) | 0x100
+	if _, err := rand.Read(k.Key); err != nil {
+		return k, fmt.Errorf("failed to generate key, %w", err)
+	}
+	return k, nil
+}
+
+// IPsecPeer is another worker of the LAN
+type IPsecPeer struct {
+	Node string
+	// VTEP address of the peer
+	Addr netip.Addr
+	// keys the peer may encrypt with, i.e. the current and the next one
+	Keys []IPsecKey
+}
+
+// IPsecReqID returns the XFRM reqid of the LAN with uid, which tags all its states and policies
+func IPsecReqID(uid types.UID) int {
+	h := fnv.New32a()
+	h.Write([]byte(uid))
+	//0 means any reqid
+	return int(h.Sum32()&0x7fffffff | 1)
+}
+
+// GetVTEPAddr returns the source address of vxlan traffic of lan on this worker,
+// which is a link-local address of the underlying interface if the group is link-local, a global one otherwise
+func GetVTEPAddr(lan *v1beta1.LANSpec, hostname string) (netip.Addr, error) {
+	dev, err := getVxDev(lan, hostname)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	grp := netip.MustParseAddr(*lan.VxLANGrp)
+	family := netlink.FAMILY_V4
+	if grp.Is6() {
+		family = netlink.FAMILY_V6
+	}
+	addrs, err := nl.AddrList(dev, family)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("failed to list addresses of %v, %w", dev.Attrs().Name, err)
+	}
+	for _, a := range addrs {
+		addr, ok := netip.AddrFromSlice(a.IP)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap()
+		if grp.IsLinkLocalMulticast() == addr.IsLinkLocalUnicast() && !addr.IsLoopback() {
+			return addr, nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("no suitable address on %v for group %v", dev.Attrs().Name, grp)
+}
+
+// hostPrefix returns addr as a host prefix
+func hostPrefix(addr netip.Addr) *net.IPNet {
+	return &net.IPNet{IP: addr.AsSlice(), Mask: net.CIDRMask(addr.BitLen(), addr.BitLen())}
+}
+
+// anyPrefix returns the prefix matches any address of the same family as addr
+func anyPrefix(addr netip.Addr) *net.IPNet {
+	zero := netip.IPv4Unspecified()
+	if addr.Is6() {
+		zero = netip.IPv6Unspecified()
+	}
+	return &net.IPNet{IP: zero.AsSlice(), Mask: net.CIDRMask(0, addr.BitLen())}
+}
+
+func newESPState(dst netip.Addr, key IPsecKey, reqid int) netlink.XfrmState {
+	return netlink.XfrmState{
+		Dst:   dst.AsSlice(),
+		Src:   anyPrefix(dst).IP,
+		Proto: netlink.XFRM_PROTO_ESP,
+		Mode:  netlink.XFRM_MODE_TRANSPORT,
+		Spi:   int(key.SPI),
+		Reqid: reqid,
+		Aead: &netlink.XfrmStateAlgo{
+			Name:   ipsecAEADAlgo,
+			Key:    key.Key,
+			ICVLen: ipsecICVLen,
+		},
+		ReplayWindow: 32,
+	}
+}
+
+// newVxLANPolicy returns the policy requires ESP for vxlan traffic from src to dst of port,
+// outbound traffic is encrypted with key of spi, inbound traffic must be encrypted with any key;
+// policies only match VTEPs of the LAN, so that other users of the port on the host are not affected
+func newVxLANPolicy(src, dst netip.Addr, port int, dir netlink.Dir, spi uint32, reqid int) netlink.XfrmPolicy {
+	return netlink.XfrmPolicy{
+		Dst:     hostPrefix(dst),
+		Src:     hostPrefix(src),
+		Proto:   netlink.Proto(unix.IPPROTO_UDP),
+		DstPort: port,
+		Dir:     dir,
+		Tmpls: []netlink.XfrmPolicyTmpl{
+			{
+				Dst:   dst.AsSlice(),
+				Src:   anyPrefix(dst).IP,
+				Proto: netlink.XFRM_PROTO_ESP,
+				Mode:  netlink.XFRM_MODE_TRANSPORT,
+				Spi:   int(spi),
+				Reqid: reqid,
+			},
+		},
+	}
+}
+
+// desiredIPsec returns the XFRM states and policies of a LAN on this worker:
+//   - outbound: traffic from local to the group and every peer is encrypted with key
+//   - inbound: traffic to the group and local from each peer must be encrypted with any key of the peer
+func desiredIPsec(lan *v1beta1.LANSpec, reqid int, local netip.Addr, key IPsecKey, peers []IPsecPeer) ([]netlink.XfrmState, []netlink.XfrmPolicy) {
+	grp := netip.MustParseAddr(*lan.VxLANGrp)
+	port := int(*lan.VxPort)
+	states := []netlink.XfrmState{}
+	policies := []netlink.XfrmPolicy{}
+	dsts := []netip.Addr{grp}
+	for _, p := range peers {
+		dsts = append(dsts, p.Addr)
+	}
+	for _, dst := range dsts {
+		states = append(states, newESPState(dst, key, reqid))
+		policies = append(policies, newVxLANPolicy(local, dst, port, netlink.XFRM_DIR_OUT, key.SPI, reqid))
+	}
+	for _, dst := range []netip.Addr{grp, local} {
+		for _, p := range peers {
+			for _, k := range p.Keys {
+				states = append(states, newESPState(dst, k, reqid))
+			}
+			policies = append(policies, newVxLANPolicy(p.Addr, dst, port, netlink.XFRM_DIR_IN, 0, reqid))
+		}
+	}
+	return states, policies
+}
+
+func sameState(a, b *netlink.XfrmState) bool {
+	return a.Dst.Equal(b.Dst) && a.Spi == b.Spi && a.Proto == b.Proto
+}
+
+func samePolicy(a, b *netlink.XfrmPolicy) bool {
+	return a.Dir == b.Dir && a.Dst.String() == b.Dst.String() && a.Src.String() == b.Src.String() &&
+		a.DstPort == b.DstPort && a.Proto == b.Proto
+}
+
+func policyReqID(p *netlink.XfrmPolicy) int {
+	if len(p.Tmpls) == 0 {
+		return 0
+	}
+	return p.Tmpls[0].Reqid
+}
+
+func groupFamily(lan *v1beta1.LANSpec) int {
+	if netip.MustParseAddr(*lan.VxLANGrp).Is6() {
+		return netlink.FAMILY_V6
+	}
+	return netlink.FAMILY_V4
+}
+
+// EnsureIPsec makes XFRM states and policies in host NS for lan match the local key and peers,
+// states and policies of the LAN are tagged with reqid; other existing ones of the LAN are removed
+func EnsureIPsec(lan *v1beta1.LANSpec, reqid int, local netip.Addr, key IPsecKey, peers []IPsecPeer) error {
+	family := groupFamily(lan)
+	states, policies := desiredIPsec(lan, reqid, local, key, peers)
+	existingPolicies, err := netlink.XfrmPolicyList(family)
+	if err != nil {
+		return fmt.Errorf("failed to list xfrm policies, %w", err)
+	}
+	for _, p := range policies {
+		for _, e := range existingPolicies {
+			if samePolicy(&p, &e) && policyReqID(&e) != reqid {
+				return fmt.Errorf("%w, port %v from %v to %v", ErrIPsecConflict, p.DstPort, p.Src, p.Dst)
+			}
+		}
+	}
+	existingStates, err := netlink.XfrmStateList(family)
+	if err != nil {
+		return fmt.Errorf("failed to list xfrm states, %w", err)
+	}
+	//add new states first, so that policies never refer to a missing state
+	for _, s := range states {
+		if !slices.ContainsFunc(existingStates, func(e netlink.XfrmState) bool { return sameState(&s, &e) }) {
+			if err := netlink.XfrmStateAdd(&s); err != nil {
+				return fmt.Errorf("failed to add xfrm state to %v spi %#x, %w", s.Dst, s.Spi, err)
+			}
+		}
+	}
+	for _, p := range policies {
+		if err := netlink.XfrmPolicyUpdate(&p); err != nil {
+			return fmt.Errorf("failed to update xfrm policy to %v, %w", p.Dst, err)
+		}
+	}
+	for _, e := range existingPolicies {
+		if policyReqID(&e) == reqid && !slices.ContainsFunc(policies, func(p netlink.XfrmPolicy) bool { return samePolicy(&p, &e) }) {
+			if err := netlink.XfrmPolicyDel(&e); err != nil {
+				return fmt.Errorf("failed to remove xfrm policy to %v, %w", e.Dst, err)
+			}
+		}
+	}
+	for _, e := range existingStates {
+		if e.Reqid == reqid && !slices.ContainsFunc(states, func(s netlink.XfrmState) bool { return sameState(&s, &e) }) {
+			if err := netlink.XfrmStateDel(&e); err != nil {
+				return fmt.Errorf("failed to remove xfrm state to %v spi %#x, %w", e.Dst, e.Spi, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveIPsec removes all XFRM states and policies in host NS of lan tagged with reqid
+func RemoveIPsec(lan *v1beta1.LANSpec, reqid int) error {
+	family := groupFamily(lan)
+	policies, err := netlink.XfrmPolicyList(family)
+	if err != nil {
+		return fmt.Errorf("failed to list xfrm policies, %w", err)
+	}
+	for _, p := range policies {
+		if policyReqID(&p) == reqid {
+			if err := netlink.XfrmPolicyDel(&p); err != nil {
+				return fmt.Errorf("failed to remove xfrm policy to %v, %w", p.Dst, err)
+			}
+		}
+	}
+	states, err := netlink.XfrmStateList(family)
+	if err != nil {
+		return fmt.Errorf("failed to list xfrm states, %w", err)
+	}
+	for _, s := range states {
+		if s.Reqid == reqid {
+			if err := netlink.XfrmStateDel(&s); err != nil {
+				return fmt.Errorf("failed to remove xfrm state to %v spi %#x, %w", s.Dst, s.Spi, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package interfaces
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+)
+
+func TestDesiredIPsec(t *testing.T) {
+	lan := newTestLAN("lan1", "uid1", 100)
+	if mtu := tunnelMTU(&lan.Spec, 1500); mtu != 1500-maxVxLANEncapOverhead {
+		t.Errorf("mtu of an unencrypted LAN is %v", mtu)
+	}
+	lan.Spec.Encryption = &v1beta1.Encryption{Mode: v1beta1.EncryptionModeIPsec}
+	if mtu := tunnelMTU(&lan.Spec, 1500); mtu != 1500-maxVxLANEncapOverhead-maxESPOverhead {
+		t.Errorf("mtu of an encrypted LAN is %v", mtu)
+	}
+	local := netip.MustParseAddr("fe80::1")
+	key := IPsecKey{SPI: 0x1001, Key: make([]byte, ipsecKeyLen)}
+	peers := []IPsecPeer{
+		{Node: "worker2", Addr: netip.MustParseAddr("fe80::2"), Keys: []IPsecKey{{SPI: 0x2001}, {SPI: 0x2002}}},
+		{Node: "worker3", Addr: netip.MustParseAddr("fe80::3"), Keys: []IPsecKey{{SPI: 0x3001}, {SPI: 0x3002}}},
+	}
+	reqid := IPsecReqID(lan.UID)
+	states, policies := desiredIPsec(&lan.Spec, reqid, local, key, peers)
+	//outbound to group and 2 peers, inbound 4 peer keys to group and local, from each of 2 peers
+	if len(states) != 3+8 {
+		t.Errorf("got %v states", len(states))
+	}
+	if len(policies) != 3+4 {
+		t.Errorf("got %v policies", len(policies))
+	}
+	for _, s := range states {
+		if s.Reqid != reqid {
+			t.Errorf("state %v has reqid %v", s.Spi, s.Reqid)
+		}
+	}
+	for _, p := range policies {
+		if p.DstPort != 4789 {
+			t.Errorf("policy to %v has port %v", p.Dst, p.DstPort)
+		}
+		if p.Dir == netlink.XFRM_DIR_OUT && p.Tmpls[0].Spi != int(key.SPI) {
+			t.Errorf("outbound policy to %v uses spi %#x", p.Dst, p.Tmpls[0].Spi)
+		}
+		//other users of the port, e.g. vxlan of the CNI, are not matched
+		src := netip.MustParsePrefix(p.Src.String())
+		if p.Dir == netlink.XFRM_DIR_OUT && src != netip.PrefixFrom(local, 128) {
+			t.Errorf("outbound policy to %v is from %v", p.Dst, p.Src)
+		}
+		if p.Dir == netlink.XFRM_DIR_IN && src != netip.PrefixFrom(peers[0].Addr, 128) && src != netip.PrefixFrom(peers[1].Addr, 128) {
+			t.Errorf("inbound policy to %v is from %v", p.Dst, p.Src)
+		}
+	}
+}
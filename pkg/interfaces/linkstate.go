@@ -0,0 +1,130 @@
+package interfaces
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// LinkStateReasonAdmin is the reason of a spoke brought down or up by the spokeAdminStates of its LAN
+const LinkStateReasonAdmin = "adminState"
+
+type spokeKey struct {
+	uid   types.UID
+	spoke string
+}
+
+var (
+	faultsLock = new(sync.Mutex)
+	// sources holding each spoke down, e.g. FaultSchedule/<name>
+	faults = map[spokeKey]map[string]bool{}
+)
+
+// SetSpokeFault holds the spoke of the LAN with uid down on behalf of source, or releases it if down is false;
+// the kernel state is only changed by UpdateLinkStates, and by the plan engine when the spoke is allocated
+func SetSpokeFault(uid types.UID, spoke, source string, down bool) {
+	faultsLock.Lock()
+	defer faultsLock.Unlock()
+	key := spokeKey{uid: uid, spoke: spoke}
+	if !down {
+		delete(faults[key], source)
+		if len(faults[key]) == 0 {
+			delete(faults, key)
+		}
+		return
+	}
+	if faults[key] == nil {
+		faults[key] = map[string]bool{}
+	}
+	faults[key][source] = true
+}
+
+// spokeDownReason returns what holds the spoke down, the admin state first, then the fault sources in order;
+// empty if the spoke should be up
+func spokeDownReason(uid types.UID, lan *v1beta1.LANSpec, spoke string) string {
+	if lan.GetAdminState(spoke) == v1beta1.AdminStateDown {
+		return LinkStateReasonAdmin
+	}
+	faultsLock.Lock()
+	defer faultsLock.Unlock()
+	sources := []string{}
+	for src := range faults[spokeKey{uid: uid, spoke: spoke}] {
+		sources = append(sources, src)
+	}
+	if len(sources) == 0 {
+		return ""
+	}
+	slices.Sort(sources)
+	return sources[0]
+}
+
+// LinkTransition is a state change of the bridge side veth of a spoke
+type LinkTransition struct {
+	Spoke  string
+	State  v1beta1.AdminState
+	Reason string
+}
+
+// UpdateLinkStates brings the bridge side veth of each spoke of lanCR on this node down or up,
+// according to its admin state and the faults holding it down; the veth of a bound spoke is found in the LAN NS it is bound to.
+// cause is the reason of a spoke brought up, the one of a spoke brought down is what holds it down
+func UpdateLinkStates(lanCR *v1beta1.LAN, cause string, event EventFunc) ([]LinkTransition, error) {
+	r := []LinkTransition{}
+	for _, spoke := range lanCR.Spec.SpokeList {
+		nsname, err := findPeerNS(lanCR.UID, spoke, *lanCR.Spec.NS)
+		if err != nil {
+			return r, err
+		}
+		if nsname == "" {
+			//spoke is not on this node
+			continue
+		}
+		t, err := setLinkState(lanCR, nsname, spoke, cause)
+		if err != nil {
+			return r, fmt.Errorf("failed to set link state of spoke %v, %w", spoke, err)
+		}
+		if t == nil {
+			continue
+		}
+		if t.State == v1beta1.AdminStateDown {
+			event.normal(ReasonSpokeDown, "brought spoke %v down, held by %v", spoke, t.Reason)
+		} else {
+			event.normal(ReasonSpokeUp, "brought spoke %v up, by %v", spoke, t.Reason)
+		}
+		r = append(r, *t)
+	}
+	return r, nil
+}
+
+// setLinkState brings the bridge side veth of spoke in the LAN NS nsname down or up, nil is returned if it is unchanged
+func setLinkState(lanCR *v1beta1.LAN, nsname, spoke, cause string) (*LinkTransition, error) {
+	defer lockNS(nsname)()
+	var t *LinkTransition
+	err := nl.InNS(GetNSPath(nsname), func() error {
+		link, err := nl.LinkByName(GetPeerVethName(spoke))
+		if err != nil {
+			//moved away in the meantime
+			return nil
+		}
+		isUp := link.Attrs().Flags&net.FlagUp != 0
+		reason := spokeDownReason(lanCR.UID, &lanCR.Spec, spoke)
+		switch {
+		case reason != "" && isUp:
+			if err := nl.LinkSetDown(link); err != nil {
+				return err
+			}
+			t = &LinkTransition{Spoke: spoke, State: v1beta1.AdminStateDown, Reason: reason}
+		case reason == "" && !isUp:
+			if err := nl.LinkSetUp(link); err != nil {
+				return err
+			}
+			t = &LinkTransition{Spoke: spoke, State: v1beta1.AdminStateUp, Reason: cause}
+		}
+		return nil
+	})
+	return t, err
+}
@@ -0,0 +1,91 @@
+package interfaces
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+)
+
+func TestUpdateLinkStates(t *testing.T) {
+	fake := setupFake(t)
+	lan1 := newTestLAN("lan1", "uid1", 100)
+	lan2 := newTestLAN("lan2", "uid2", 200)
+	if _, err := Ensure("mac1", "lan1s1", lan1, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	isUp := func(nsname string) bool {
+		t.Helper()
+		peer := findLink(fake, GetNSPath(nsname), "lan1s1p")
+		if peer == nil {
+			t.Fatalf("lan1s1p is not in namespace %v", nsname)
+		}
+		return peer.Attrs().Flags&net.FlagUp != 0
+	}
+	checkTransitions := func(got []LinkTransition, expected ...LinkTransition) {
+		t.Helper()
+		if len(got) != len(expected) {
+			t.Fatalf("expect transitions %v, got %v", expected, got)
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Errorf("expect transitions %v, got %v", expected, got)
+			}
+		}
+	}
+	//nothing to do by default
+	transitions, err := UpdateLinkStates(lan1, LinkStateReasonAdmin, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTransitions(transitions)
+
+	//admin down
+	lan1.Spec.SpokeAdminStates = map[string]v1beta1.AdminState{"lan1s1": v1beta1.AdminStateDown}
+	if transitions, err = UpdateLinkStates(lan1, LinkStateReasonAdmin, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkTransitions(transitions, LinkTransition{Spoke: "lan1s1", State: v1beta1.AdminStateDown, Reason: LinkStateReasonAdmin})
+	if isUp("lan1") {
+		t.Error("lan1s1p is not brought down")
+	}
+	//drift repair and reallocation keep it down
+	if _, err := RepairDrift(lan1, testHost, nil); err != nil {
+		t.Fatal(err)
+	}
+	if isUp("lan1") {
+		t.Error("lan1s1p is brought up by drift repair")
+	}
+	if _, err := Ensure("mac1", "lan1s1", lan1, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if isUp("lan1") {
+		t.Error("lan1s1p is brought up by reallocation")
+	}
+
+	//a fault holds it down after admin up
+	SetSpokeFault("uid1", "lan1s1", "FaultSchedule/f1", true)
+	lan1.Spec.SpokeAdminStates = nil
+	if transitions, err = UpdateLinkStates(lan1, LinkStateReasonAdmin, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkTransitions(transitions)
+	if isUp("lan1") {
+		t.Error("lan1s1p is brought up while held down by a fault")
+	}
+	//a bound spoke is held down too
+	if _, _, err := BindSpoke(lan1, lan2, testHost, "lan1s1", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if isUp("lan2") {
+		t.Error("bound lan1s1p is brought up while held down by a fault")
+	}
+	SetSpokeFault("uid1", "lan1s1", "FaultSchedule/f1", false)
+	if transitions, err = UpdateLinkStates(lan1, "FaultSchedule/f1", nil); err != nil {
+		t.Fatal(err)
+	}
+	checkTransitions(transitions, LinkTransition{Spoke: "lan1s1", State: v1beta1.AdminStateUp, Reason: "FaultSchedule/f1"})
+	if !isUp("lan2") {
+		t.Error("bound lan1s1p is not brought up")
+	}
+}
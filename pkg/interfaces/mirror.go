@@ -0,0 +1,203 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// name prefix of the vxlan interfaces carrying mirrored traffic between workers
+	mirrorVxLANPrefix = "mirror"
+	// max number of mirrors, each one uses a tc filter priority
+	maxMirrorFilters = 64
+)
+
+func getMirrorVxLANName(m *v1beta1.Mirror) string {
+	return fmt.Sprintf("%v%d", mirrorVxLANPrefix, m.VNI)
+}
+
+// getMirrorSourceIfName returns the interface in the LAN NS of a mirror source
+func getMirrorSourceIfName(lan *v1beta1.LANSpec, src string) string {
+	if src == v1beta1.MirrorSourceUplink {
+		return *lan.VxLANName
+	}
+	return GetPeerVethName(src)
+}
+
+// MirrorFilter mirrors the traffic of a link in one direction to Target
+type MirrorFilter struct {
+	Prio   uint16
+	Egress bool
+	// name of the link in the same NS
+	Target string
+}
+
+// addMirrors adds the mirror vxlan interfaces needed on this node and the mirror filters on the sources,
+// local is the desired links in the LAN NS keyed by name:
+//   - traffic of a local source is mirrored to the destination's peer veth if the destination is local,
+//     otherwise to the mirror vxlan interface
+//   - traffic received on the mirror vxlan interface is redirected to the destination's peer veth if it is local
+func (d *DesiredState) addMirrors(local map[string]*LinkSpec, tunnel *LinkSpec) {
+	lan := d.lan
+	for i := range lan.Mirrors {
+		m := &lan.Mirrors[i]
+		dst := local[GetPeerVethName(m.Destination)]
+		sources := []*LinkSpec{}
+		for _, src := range m.Sources {
+			if l := local[getMirrorSourceIfName(lan, src)]; l != nil {
+				sources = append(sources, l)
+			}
+		}
+		if dst == nil && len(sources) == 0 {
+			continue
+		}
+		mirrorLink := &LinkSpec{
+			Name:         getMirrorVxLANName(m),
+			Type:         linkTypeVxLAN,
+			InLANNS:      true,
+			MTU:          tunnel.MTU,
+			Owner:        Owner{LANUID: d.UID, Role: RoleMirror, Spoke: m.Name},
+			VNI:          int(m.VNI),
+			Remote:       netip.MustParseAddr(*lan.VxLANGrp),
+			Port:         int(*lan.VxPort),
+			TTL:          lan.GetTTL(),
+			VtepDev:      tunnel.VtepDev,
+			VtepDevIndex: tunnel.VtepDevIndex,
+			TC:           &TCSpec{},
+		}
+		d.Links = append(d.Links, mirrorLink)
+		target := mirrorLink.Name
+		if dst != nil {
+			//destination is local, mirrored traffic from other workers goes to it as well
+			target = dst.Name
+			mirrorLink.TC.Redirect = dst.Name
+		}
+		prio := uint16(mirrorFilterPrioBase + i)
+		for _, src := range sources {
+			if m.MirrorIngress() {
+				src.TC.Mirrors = append(src.TC.Mirrors, MirrorFilter{Prio: prio, Target: target})
+			}
+			if m.MirrorEgress() {
+				src.TC.Mirrors = append(src.TC.Mirrors, MirrorFilter{Prio: prio, Egress: true, Target: target})
+			}
+		}
+	}
+}
+
+// getMirrorFilters returns the mirror filters on link
+func getMirrorFilters(link netlink.Link) []netlink.Filter {
+	r := []netlink.Filter{}
+	for _, parent := range []uint32{netlink.HANDLE_MIN_INGRESS, netlink.HANDLE_MIN_EGRESS} {
+		filters, err := nl.FilterList(link, parent)
+		if err != nil {
+			//no clsact qdisc
+			continue
+		}
+		for _, f := range filters {
+			prio := f.Attrs().Priority
+			if prio >= mirrorFilterPrioBase && prio < mirrorFilterPrioBase+maxMirrorFilters {
+				r = append(r, f)
+			}
+		}
+	}
+	return r
+}
+
+// mirrorFilterKey identifies a mirror filter by direction, priority and target index
+func mirrorFilterKey(egress bool, prio uint16, target int) string {
+	dir := "ingress"
+	if egress {
+		dir = "egress"
+	}
+	return fmt.Sprintf("%v %d %d", dir, prio, target)
+}
+
+// diffMirrors adds the step to make the mirror filters on link match l
+func (p *Plan) diffMirrors(link netlink.Link, l *LinkSpec) {
+	actual := []string{}
+	for _, f := range getMirrorFilters(link) {
+		if m, ok := f.(*netlink.MatchAll); ok {
+			for _, a := range m.Actions {
+				if mirred, ok := a.(*netlink.MirredAction); ok {
+					actual = append(actual, mirrorFilterKey(f.Attrs().Parent == netlink.HANDLE_MIN_EGRESS, f.Attrs().Priority, mirred.Ifindex))
+				}
+			}
+		}
+	}
+	desired := []string{}
+	for _, m := range l.TC.Mirrors {
+		target, err := nl.LinkByName(m.Target)
+		if err != nil || p.creates(m.Target) {
+			p.add(OpSetMirrors, l, "%v is not mirrored to %v", l.Name, m.Target)
+			return
+		}
+		desired = append(desired, mirrorFilterKey(m.Egress, m.Prio, target.Attrs().Index))
+	}
+	slices.Sort(actual)
+	slices.Sort(desired)
+	if !slices.Equal(actual, desired) {
+		p.add(OpSetMirrors, l, "the existing mirror filters are different")
+	}
+}
+
+// setMirrors replaces the mirror filters on link with the ones of l, it must be called in the NS of l
+func setMirrors(link netlink.Link, l *LinkSpec) error {
+	for _, f := range getMirrorFilters(link) {
+		err := nl.FilterDel(f)
+		if err != nil && !errors.Is(err, syscall.ENOENT) {
+			return fmt.Errorf("failed to remove mirror filter on %v, %w", l.Name, err)
+		}
+	}
+	if len(l.TC.Mirrors) == 0 {
+		return nil
+	}
+	if err := ensureClsact(link); err != nil {
+		return err
+	}
+	for _, m := range l.TC.Mirrors {
+		target, err := nl.LinkByName(m.Target)
+		if err != nil {
+			return fmt.Errorf("failed to find %v, %w", m.Target, err)
+		}
+		action := netlink.NewMirredAction(target.Attrs().Index)
+		action.MirredAction = netlink.TCA_EGRESS_MIRROR
+		action.Action = netlink.TC_ACT_PIPE
+		parent := uint32(netlink.HANDLE_MIN_INGRESS)
+		if m.Egress {
+			parent = netlink.HANDLE_MIN_EGRESS
+		}
+		if err := nl.FilterAdd(newMatchAll(link, parent, m.Prio, action)); err != nil {
+			return fmt.Errorf("failed to add mirror filter on %v, %w", l.Name, err)
+		}
+	}
+	return nil
+}
+
+// planObsoleteMirrors adds steps to remove the mirror vxlan interfaces in current NS not in links,
+// i.e. the mirror is removed or has neither sources nor destination on this node
+func (p *Plan) planObsoleteMirrors(links []*LinkSpec, tunnel string) error {
+	existing, err := nl.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to list interfaces, %w", err)
+	}
+	for _, link := range existing {
+		name := link.Attrs().Name
+		if link.Type() != linkTypeVxLAN || name == tunnel || !strings.HasPrefix(name, mirrorVxLANPrefix) {
+			continue
+		}
+		if o, ok := GetOwner(link); !ok || o.Role != RoleMirror {
+			continue
+		}
+		if !slices.ContainsFunc(links, func(l *LinkSpec) bool { return l.Name == name }) {
+			p.add(OpDelete, &LinkSpec{Name: name, Type: linkTypeVxLAN, InLANNS: true}, "it is no longer needed")
+		}
+	}
+	return nil
+}
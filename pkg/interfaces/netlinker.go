@@ -0,0 +1,334 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	nlattr "github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// ErrNSOpen is returned by Netlinker.InNS if the NS exists but can't be opened
+var ErrNSOpen = errors.New("failed to open ns")
+
+// Netlinker is the link, namespace, bridge, FDB and tc operations k8slan uses;
+// link operations work in the current NS, which is the host NS unless inside Netlinker.InNS.
+// a NS is identified by its path, empty path is the host NS
+type Netlinker interface {
+	// NSExists returns true if the NS of path exists
+	NSExists(path string) bool
+	// NewNS creates a named NS under the run dir
+	NewNS(name string) error
+	// DeleteNS removes a named NS under the run dir, along with all links in it
+	DeleteNS(name string) error
+	// ListNS returns names of NS under the run dir
+	ListNS() ([]string, error)
+	// InNS runs f in the NS of path, ErrNSOpen is returned if the NS can't be opened
+	InNS(path string, f func() error) error
+
+	LinkByName(name string) (netlink.Link, error)
+	LinkByIndex(index int) (netlink.Link, error)
+	LinkList() ([]netlink.Link, error)
+	LinkAdd(link netlink.Link) error
+	// LinkAddToNS creates link in current NS and moves it to the NS of path atomically,
+	// e.g. a vxlan interface using an underlying interface in host NS
+	LinkAddToNS(link netlink.Link, path string) error
+	LinkDel(link netlink.Link) error
+	LinkSetUp(link netlink.Link) error
+	LinkSetDown(link netlink.Link) error
+	LinkSetMTU(link netlink.Link, mtu int) error
+	LinkSetName(link netlink.Link, name string) error
+	LinkSetAlias(link netlink.Link, alias string) error
+	LinkSetMaster(link, master netlink.Link) error
+	// LinkSetNS moves link to the NS of path
+	LinkSetNS(link netlink.Link, path string) error
+	LinkSetBRSlaveGroupFwdMask(link netlink.Link, mask uint16) error
+	NeighList(linkIndex, family int) ([]netlink.Neigh, error)
+	// NeighSet adds or replaces a neighbor or FDB entry
+	NeighSet(neigh *netlink.Neigh) error
+	// FDBAdd adds a vxlan FDB entry whose destination is reached via the interface with index via in the link NS of the vxlan interface,
+	// 0 means not specified; the entry is added even if another one with the same MAC exists if appendEntry is true,
+	// e.g. multiple all-zero entries, otherwise the existing one is replaced
+	FDBAdd(neigh *netlink.Neigh, via int, appendEntry bool) error
+	NeighDel(neigh *netlink.Neigh) error
+	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
+
+	QdiscList(link netlink.Link) ([]netlink.Qdisc, error)
+	QdiscAdd(qdisc netlink.Qdisc) error
+	QdiscReplace(qdisc netlink.Qdisc) error
+	// QdiscDel removes qdisc along with its children
+	QdiscDel(qdisc netlink.Qdisc) error
+	// FilterList returns filters of link under parent, error is returned if link has no such qdisc, e.g. clsact
+	FilterList(link netlink.Link, parent uint32) ([]netlink.Filter, error)
+	FilterAdd(filter netlink.Filter) error
+	FilterReplace(filter netlink.Filter) error
+	FilterDel(filter netlink.Filter) error
+	// BridgeOptions returns the bridge level attributes of bridge br
+	BridgeOptions(br netlink.Link) (BridgeOptions, error)
+	BridgeSetOptions(br netlink.Link, opts BridgeOptions) error
+}
+
+// BridgeOptions is the bridge level attributes k8slan manages
+type BridgeOptions struct {
+	MulticastSnooping bool
+	GroupFwdMask      uint16
+	STP               bool
+}
+
+// nl is used for all link and NS operations of the plan engine, replaced by a fake in tests
+var nl Netlinker = NewKernelNetlinker()
+
+// isLinkNotFound returns true if err is returned by Netlinker because the link doesn't exist
+func isLinkNotFound(err error) bool {
+	var nf netlink.LinkNotFoundError
+	return errors.As(err, &nf) || errors.Is(err, syscall.ENODEV)
+}
+
+// kernelNetlinker implements Netlinker with netlink and NS of the kernel
+type kernelNetlinker struct {
+	// path of the host NS, empty means the NS of the main thread
+	hostNS string
+}
+
+// NewKernelNetlinker returns a Netlinker operating on the kernel
+func NewKernelNetlinker() Netlinker {
+	return kernelNetlinker{}
+}
+
+// nsPath returns path of the NS, empty path is the host NS
+func (k kernelNetlinker) nsPath(path string) string {
+	switch {
+	case path != "":
+		return path
+	case k.hostNS != "":
+		return k.hostNS
+	}
+	return fmt.Sprintf("/proc/%d/ns/net", os.Getpid())
+}
+
+func (k kernelNetlinker) NSExists(path string) bool {
+	_, err := os.Stat(k.nsPath(path))
+	return err == nil
+}
+
+func (kernelNetlinker) NewNS(name string) error {
+	netns, err := NewNS(name)
+	if err != nil {
+		return err
+	}
+	return netns.Close()
+}
+
+func (kernelNetlinker) DeleteNS(name string) error {
+	return DeleteNamed(name)
+}
+
+func (kernelNetlinker) ListNS() ([]string, error) {
+	entries, err := os.ReadDir(getNsRunDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	r := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			r = append(r, entry.Name())
+		}
+	}
+	return r, nil
+}
+
+func (k kernelNetlinker) InNS(path string, f func() error) error {
+	netns, err := ns.GetNS(k.nsPath(path))
+	if err != nil {
+		return fmt.Errorf("%w %v, %w", ErrNSOpen, path, err)
+	}
+	defer netns.Close()
+	return netns.Do(func(_ ns.NetNS) error {
+		return f()
+	})
+}
+
+func (kernelNetlinker) LinkByName(name string) (netlink.Link, error) {
+	return netlink.LinkByName(name)
+}
+
+func (kernelNetlinker) LinkByIndex(index int) (netlink.Link, error) {
+	return netlink.LinkByIndex(index)
+}
+
+func (kernelNetlinker) LinkList() ([]netlink.Link, error) {
+	return netlink.LinkList()
+}
+
+func (kernelNetlinker) LinkAdd(link netlink.Link) error {
+	return netlink.LinkAdd(link)
+}
+
+func (k kernelNetlinker) LinkAddToNS(link netlink.Link, path string) error {
+	netns, err := ns.GetNS(k.nsPath(path))
+	if err != nil {
+		return fmt.Errorf("%w %v, %w", ErrNSOpen, path, err)
+	}
+	defer netns.Close()
+	link.Attrs().Namespace = netlink.NsFd(int(netns.Fd()))
+	return netlink.LinkAdd(link)
+}
+
+func (kernelNetlinker) LinkDel(link netlink.Link) error {
+	return netlink.LinkDel(link)
+}
+
+func (kernelNetlinker) LinkSetUp(link netlink.Link) error {
+	return netlink.LinkSetUp(link)
+}
+
+func (kernelNetlinker) LinkSetDown(link netlink.Link) error {
+	return netlink.LinkSetDown(link)
+}
+
+func (kernelNetlinker) LinkSetMTU(link netlink.Link, mtu int) error {
+	return netlink.LinkSetMTU(link, mtu)
+}
+
+func (kernelNetlinker) LinkSetName(link netlink.Link, name string) error {
+	return netlink.LinkSetName(link, name)
+}
+
+func (kernelNetlinker) LinkSetAlias(link netlink.Link, alias string) error {
+	return netlink.LinkSetAlias(link, alias)
+}
+
+func (kernelNetlinker) LinkSetMaster(link, master netlink.Link) error {
+	return netlink.LinkSetMaster(link, master)
+}
+
+func (k kernelNetlinker) LinkSetNS(link netlink.Link, path string) error {
+	netns, err := ns.GetNS(k.nsPath(path))
+	if err != nil {
+		return fmt.Errorf("%w %v, %w", ErrNSOpen, path, err)
+	}
+	defer netns.Close()
+	return netlink.LinkSetNsFd(link, int(netns.Fd()))
+}
+
+func (kernelNetlinker) LinkSetBRSlaveGroupFwdMask(link netlink.Link, mask uint16) error {
+	return netlink.LinkSetBRSlaveGroupFwdMask(link, mask)
+}
+
+func (kernelNetlinker) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	return netlink.NeighList(linkIndex, family)
+}
+
+func (kernelNetlinker) NeighSet(neigh *netlink.Neigh) error {
+	return netlink.NeighSet(neigh)
+}
+
+// FDBAdd is implemented with a raw request, since netlink.Neigh has no NDA_IFINDEX, which a link-local destination requires
+func (kernelNetlinker) FDBAdd(neigh *netlink.Neigh, via int, appendEntry bool) error {
+	flags := unix.NLM_F_CREATE | unix.NLM_F_ACK
+	if appendEntry {
+		flags |= unix.NLM_F_APPEND
+	} else {
+		flags |= unix.NLM_F_REPLACE
+	}
+	req := nlattr.NewNetlinkRequest(unix.RTM_NEWNEIGH, flags)
+	req.AddData(&netlink.Ndmsg{
+		Family: uint8(neigh.Family),
+		Index:  uint32(neigh.LinkIndex),
+		State:  uint16(neigh.State),
+		Flags:  uint8(neigh.Flags),
+	})
+	dst := neigh.IP.To4()
+	if dst == nil {
+		dst = neigh.IP.To16()
+	}
+	req.AddData(nlattr.NewRtAttr(netlink.NDA_DST, dst))
+	req.AddData(nlattr.NewRtAttr(netlink.NDA_LLADDR, neigh.HardwareAddr))
+	if via != 0 {
+		req.AddData(nlattr.NewRtAttr(netlink.NDA_IFINDEX, nlattr.Uint32Attr(uint32(via))))
+	}
+	_, err := req.Execute(unix.NETLINK_ROUTE, 0)
+	return err
+}
+
+func (kernelNetlinker) NeighDel(neigh *netlink.Neigh) error {
+	return netlink.NeighDel(neigh)
+}
+
+func (kernelNetlinker) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	return netlink.AddrList(link, family)
+}
+
+func (kernelNetlinker) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	return netlink.QdiscList(link)
+}
+
+func (kernelNetlinker) QdiscAdd(qdisc netlink.Qdisc) error {
+	return netlink.QdiscAdd(qdisc)
+}
+
+func (kernelNetlinker) QdiscReplace(qdisc netlink.Qdisc) error {
+	return netlink.QdiscReplace(qdisc)
+}
+
+func (kernelNetlinker) QdiscDel(qdisc netlink.Qdisc) error {
+	return netlink.QdiscDel(qdisc)
+}
+
+func (kernelNetlinker) FilterList(link netlink.Link, parent uint32) ([]netlink.Filter, error) {
+	return netlink.FilterList(link, parent)
+}
+
+func (kernelNetlinker) FilterAdd(filter netlink.Filter) error {
+	return netlink.FilterAdd(filter)
+}
+
+func (kernelNetlinker) FilterReplace(filter netlink.Filter) error {
+	return netlink.FilterReplace(filter)
+}
+
+func (kernelNetlinker) FilterDel(filter netlink.Filter) error {
+	return netlink.FilterDel(filter)
+}
+
+func (kernelNetlinker) BridgeOptions(br netlink.Link) (BridgeOptions, error) {
+	link, err := netlink.LinkByIndex(br.Attrs().Index)
+	if err != nil {
+		return BridgeOptions{}, err
+	}
+	bridge, ok := link.(*netlink.Bridge)
+	if !ok {
+		return BridgeOptions{}, fmt.Errorf("%v is not a bridge, %w", br.Attrs().Name, syscall.EINVAL)
+	}
+	opts := BridgeOptions{MulticastSnooping: true}
+	if bridge.MulticastSnooping != nil {
+		opts.MulticastSnooping = *bridge.MulticastSnooping
+	}
+	if bridge.GroupFwdMask != nil {
+		opts.GroupFwdMask = *bridge.GroupFwdMask
+	}
+	opts.STP, err = getBridgeSTP(br)
+	return opts, err
+}
+
+func (kernelNetlinker) BridgeSetOptions(br netlink.Link, opts BridgeOptions) error {
+	la := netlink.NewLinkAttrs()
+	la.Index = br.Attrs().Index
+	la.Name = br.Attrs().Name
+	err := netlink.LinkModify(&netlink.Bridge{
+		LinkAttrs:         la,
+		MulticastSnooping: &opts.MulticastSnooping,
+		GroupFwdMask:      &opts.GroupFwdMask,
+	})
+	if err != nil {
+		return err
+	}
+	return setBridgeSTP(br, opts.STP)
+}
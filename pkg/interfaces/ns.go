@@ -27,22 +27,45 @@ import (
 	"github.com/containernetworking/plugins/pkg/ns"
 )
 
+const (
+	// DefaultNSRunDir is the default dir where LAN NS are mounted, it is separated from /run/netns of ip netns
+	DefaultNSRunDir = "/run/k8slan/netns"
+	// NSRunDirEnv is the env var to override DefaultNSRunDir
+	NSRunDirEnv = "K8SLAN_NETNS_DIR"
+)
+
+var nsRunDir = DefaultNSRunDirFromEnv()
+
+// DefaultNSRunDirFromEnv returns the run dir specified by NSRunDirEnv, DefaultNSRunDir if not set
+func DefaultNSRunDirFromEnv() string {
+	if dir := os.Getenv(NSRunDirEnv); dir != "" {
+		return dir
+	}
+	return DefaultNSRunDir
+}
+
+// SetNSRunDir sets the dir where LAN NS are created, removed and looked up,
+// it should be called before any NS operation
+func SetNSRunDir(dir string) {
+	nsRunDir = dir
+}
+
 func getNsRunDir() string {
-	// xdgRuntimeDir := os.Getenv("XDG_RUNTIME_DIR")
-
-	/// If XDG_RUNTIME_DIR is set, check if the current user owns /var/run.  If
-	// the owner is different, we are most likely running in a user namespace.
-	// In that case use $XDG_RUNTIME_DIR/netns as runtime dir.
-	// if xdgRuntimeDir != "" {
-	// 	if s, err := os.Stat("/var/run"); err == nil {
-	// 		st, ok := s.Sys().(*syscall.Stat_t)
-	// 		if ok && int(st.Uid) != os.Geteuid() {
-	// 			return path.Join(xdgRuntimeDir, "netns")
-	// 		}
-	// 	}
-	// }
+	return nsRunDir
+}
 
-	return "/var/run/netns"
+// GetNSPath returns the path of the named NS
+func GetNSPath(nsname string) string {
+	return path.Join(getNsRunDir(), nsname)
+}
+
+// LinkExistsInNS returns true if the named NS exists and contains interface ifname
+func LinkExistsInNS(nsname, ifname string) bool {
+	err := nl.InNS(GetNSPath(nsname), func() error {
+		_, err := nl.LinkByName(ifname)
+		return err
+	})
+	return err == nil
 }
 
 // Creates a new persistent (bind-mounted) network namespace and returns an object
@@ -0,0 +1,138 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// every link created by k8slan carries an alias (IFLA_IFALIAS) recording its owner,
+// in the format of k8slan:<LAN UID>:<role>:<spoke>
+const (
+	ownerAliasPrefix = "k8slan"
+	ownerAliasSep    = ":"
+)
+
+// roles of k8slan links besides the ones in dataplane
+const (
+	// lo of the LAN NS, its alias marks the NS as a LAN NS
+	RoleNamespace = "namespace"
+	// spoke veth in host NS
+	RoleSpoke   = "spoke"
+	RoleMacvtap = "macvtap"
+	// k8slan-dummy, shared by all LANs
+	RoleDummy  = "dummy"
+	RoleMirror = "mirror"
+	RoleProbe  = "probe"
+	// vxlan to the gateway of a peered cluster, Spoke of its owner is the LANPeering name
+	RolePeering = "peering"
+)
+
+// ErrNotOwned is returned when trying to remove a link not created by k8slan
+var ErrNotOwned = errors.New("not owned by k8slan")
+
+// Owner is the owner of a k8slan link
+type Owner struct {
+	// empty for links shared by all LANs
+	LANUID types.UID
+	Role   string
+	Spoke  string
+}
+
+// Alias returns the owner alias of o
+func (o Owner) Alias() string {
+	return strings.Join([]string{ownerAliasPrefix, string(o.LANUID), o.Role, o.Spoke}, ownerAliasSep)
+}
+
+// parseOwner returns the owner encoded in alias, false if alias is not a k8slan owner alias
+func parseOwner(alias string) (Owner, bool) {
+	fields := strings.Split(alias, ownerAliasSep)
+	if len(fields) != 4 || fields[0] != ownerAliasPrefix {
+		return Owner{}, false
+	}
+	return Owner{LANUID: types.UID(fields[1]), Role: fields[2], Spoke: fields[3]}, true
+}
+
+// GetOwner returns the owner of link, false if link is not created by k8slan
+func GetOwner(link netlink.Link) (Owner, bool) {
+	return parseOwner(link.Attrs().Alias)
+}
+
+// setOwner sets the owner alias on the named link in current NS
+func setOwner(name string, o Owner) error {
+	link, err := nl.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("failed to find %v, %w", name, err)
+	}
+	if link.Attrs().Alias == o.Alias() {
+		return nil
+	}
+	if err := nl.LinkSetAlias(link, o.Alias()); err != nil {
+		return fmt.Errorf("failed to set alias of %v, %w", name, err)
+	}
+	return nil
+}
+
+// setLANOwner sets the owner alias on the named link in current NS, which must be a LAN NS,
+// the LAN UID is the one of the NS
+func setLANOwner(name, role, spoke string) error {
+	nsOwner, ok := getNSOwner()
+	if !ok {
+		return fmt.Errorf("current ns is not a LAN ns")
+	}
+	return setOwner(name, Owner{LANUID: nsOwner.LANUID, Role: role, Spoke: spoke})
+}
+
+// getNSOwner returns the owner of current NS, false if it is not a LAN NS
+func getNSOwner() (Owner, bool) {
+	lo, err := nl.LinkByName("lo")
+	if err != nil {
+		return Owner{}, false
+	}
+	o, ok := GetOwner(lo)
+	if !ok || o.Role != RoleNamespace {
+		return Owner{}, false
+	}
+	return o, true
+}
+
+// isOwned returns true if link is created by k8slan:
+//   - link has an owner alias
+//   - lanLink is true, i.e. link is a bridge, tunnel interface or spoke peer veth expected by the LAN,
+//     and it is in a LAN NS; this covers the ones created before owner alias is introduced
+//   - link is a macvtap on top of a link with an owner alias
+func isOwned(link netlink.Link, lanLink bool) bool {
+	if _, ok := GetOwner(link); ok {
+		return true
+	}
+	if lanLink {
+		if _, ok := getNSOwner(); ok {
+			return true
+		}
+	}
+	if link.Type() == "macvtap" && link.Attrs().ParentIndex > 0 {
+		parent, err := nl.LinkByIndex(link.Attrs().ParentIndex)
+		if err == nil {
+			_, ok := GetOwner(parent)
+			return ok
+		}
+	}
+	return false
+}
+
+// hasLinks returns true if current NS has any link other than lo
+func hasLinks() (bool, error) {
+	links, err := nl.LinkList()
+	if err != nil {
+		return false, fmt.Errorf("failed to list interfaces, %w", err)
+	}
+	for _, l := range links {
+		if l.Attrs().Name != "lo" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,153 @@
+package interfaces
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// p2pRedirect is a tc redirect of all traffic received on from to egress of to,
+// an empty to means from must not be redirected
+type p2pRedirect struct {
+	from string
+	to   string
+}
+
+// getP2PRedirects returns the redirects wiring the local ends of a p2p LAN, exists tells if an interface is in the LAN NS:
+//   - 0 local spoke: the vxlan interface is not redirected
+//   - 1 local spoke: its peer is redirected to/from the vxlan interface
+//   - 2 local spokes: their peers are redirected to each other, the vxlan interface is not redirected
+//
+// there is no bridge in p2p mode, the bridge side peers are wired with tc.
+// two spokes on the same node are normally joined by a single veth pair instead, see getP2PJoin,
+// so both peers are only in the LAN NS if a spoke needs its bridge side veth, e.g. for impairment
+func getP2PRedirects(lan *v1beta1.LANSpec, exists func(name string) bool) []p2pRedirect {
+	localPeers := []string{}
+	for _, spoke := range lan.SpokeList {
+		if exists(GetPeerVethName(spoke)) {
+			localPeers = append(localPeers, GetPeerVethName(spoke))
+		}
+	}
+	switch len(localPeers) {
+	case 0:
+		return []p2pRedirect{{from: *lan.VxLANName}}
+	case 1:
+		return []p2pRedirect{
+			{from: localPeers[0], to: *lan.VxLANName},
+			{from: *lan.VxLANName, to: localPeers[0]},
+		}
+	default:
+		return []p2pRedirect{
+			{from: localPeers[0], to: localPeers[1]},
+			{from: localPeers[1], to: localPeers[0]},
+			{from: *lan.VxLANName},
+		}
+	}
+}
+
+// needsBridgeSide returns true if spoke of lan needs its bridge side veth in the LAN NS,
+// i.e. it is impaired, mirrored or held down
+func needsBridgeSide(uid types.UID, lan *v1beta1.LANSpec, spoke string) bool {
+	if lan.GetImpairment(spoke) != nil || spokeDownReason(uid, lan, spoke) != "" {
+		return true
+	}
+	return slices.ContainsFunc(lan.Mirrors, func(m v1beta1.Mirror) bool {
+		return m.Destination == spoke || slices.Contains(m.Sources, spoke)
+	})
+}
+
+// getP2PJoin returns how spoke being allocated is joined with the other spoke of a p2p LAN on this node:
+//   - join is the bridge side veth of the other spoke in the LAN NS, it becomes the spoke side of spoke,
+//     unless either spoke needs its bridge side veth
+//   - keep is true if spoke is already joined, i.e. its spoke side is in host NS but neither bridge side veth is on this node,
+//     e.g. a VM is restarted; recreating it would take the link of the other spoke down
+//
+// the pod side of a joined veth pair is moved back to the LAN NS by the k8slanveth CNI when the pod is removed,
+// as the bridge side veth of the other spoke
+func (d *DesiredState) getP2PJoin(spoke string) (join string, keep bool, err error) {
+	if !d.lan.IsP2P() || !slices.Contains(d.lan.SpokeList, spoke) {
+		return "", false, nil
+	}
+	other := d.lan.SpokeList[0]
+	if other == spoke {
+		other = d.lan.SpokeList[1]
+	}
+	otherNS, err := findPeerNS(d.UID, other, d.NS)
+	if err != nil {
+		return "", false, err
+	}
+	if otherNS == d.NS {
+		if needsBridgeSide(d.UID, d.lan, spoke) || needsBridgeSide(d.UID, d.lan, other) {
+			return "", false, nil
+		}
+		return GetPeerVethName(other), false, nil
+	}
+	if otherNS != "" {
+		//bound to another LAN
+		return "", false, nil
+	}
+	peerNS, err := findPeerNS(d.UID, spoke, d.NS)
+	if err != nil || peerNS != "" {
+		return "", false, err
+	}
+	link, err := nl.LinkByName(spoke)
+	if err != nil {
+		return "", false, nil
+	}
+	o, ok := GetOwner(link)
+	return "", ok && link.Type() == linkTypeVeth && o == Owner{LANUID: d.UID, Role: RoleSpoke, Spoke: spoke}, nil
+}
+
+// addJoinedSpoke adds the spoke side in host NS of spoke joined with the other spoke of a p2p LAN,
+// made of the bridge side veth join of the other spoke; an empty join means spoke is already joined
+func (d *DesiredState) addJoinedSpoke(spoke, join string) {
+	d.Links = append(d.Links, &LinkSpec{
+		Name:     spoke,
+		Type:     linkTypeVeth,
+		MTU:      d.getMTU(),
+		Owner:    Owner{LANUID: d.UID, Role: RoleSpoke, Spoke: spoke},
+		Recreate: join != "",
+		JoinFrom: join,
+	})
+}
+
+// joinVeth moves the bridge side veth l.JoinFrom out of the LAN NS of nsPath, and renames it to l in host NS,
+// the existing l is removed first if recreate is true
+func joinVeth(l *LinkSpec, nsPath string, recreate bool) error {
+	if recreate {
+		if err := nl.InNS("", func() error { return linkDelete(l.Name, false) }); err != nil {
+			return fmt.Errorf("failed to remove existing %v, %w", l, err)
+		}
+	}
+	err := nl.InNS(nsPath, func() error {
+		link, err := nl.LinkByName(l.JoinFrom)
+		if err != nil {
+			return fmt.Errorf("failed to find veth %v, %w", l.JoinFrom, err)
+		}
+		if err := nl.LinkSetNS(link, ""); err != nil {
+			return fmt.Errorf("failed to move veth %v to host ns, %w", l.JoinFrom, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return nl.InNS("", func() error {
+		link, err := nl.LinkByName(l.JoinFrom)
+		if err != nil {
+			return fmt.Errorf("failed to find veth %v in host ns, %w", l.JoinFrom, err)
+		}
+		if err := nl.LinkSetName(link, l.Name); err != nil {
+			return fmt.Errorf("failed to rename veth %v to %v, %w", l.JoinFrom, l.Name, err)
+		}
+		if err := setOwner(l.Name, l.Owner); err != nil {
+			return err
+		}
+		if err := nl.LinkSetUp(link); err != nil {
+			return fmt.Errorf("failed to bring up spoke link %v in host ns, %w", l.Name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package interfaces
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGetP2PRedirects(t *testing.T) {
+	lan := newTestLAN("lan1", "uid1", 100)
+	lan.Spec.Mode = "p2p"
+	cases := []struct {
+		name     string
+		local    []string
+		expected []p2pRedirect
+	}{
+		{
+			name:     "no local peer",
+			local:    []string{"vx-lan1"},
+			expected: []p2pRedirect{{from: "vx-lan1"}},
+		},
+		{
+			name:  "one local peer",
+			local: []string{"vx-lan1", "lan1s2p"},
+			expected: []p2pRedirect{
+				{from: "lan1s2p", to: "vx-lan1"},
+				{from: "vx-lan1", to: "lan1s2p"},
+			},
+		},
+		{
+			name:  "two local peers",
+			local: []string{"vx-lan1", "lan1s1p", "lan1s2p"},
+			expected: []p2pRedirect{
+				{from: "lan1s1p", to: "lan1s2p"},
+				{from: "lan1s2p", to: "lan1s1p"},
+				{from: "vx-lan1"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := getP2PRedirects(&lan.Spec, func(name string) bool {
+				return slices.Contains(c.local, name)
+			})
+			if !slices.Equal(got, c.expected) {
+				t.Errorf("expect %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
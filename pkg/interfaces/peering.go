@@ -0,0 +1,94 @@
+package interfaces
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+)
+
+// PeerLink is a unicast vxlan tunnel in the LAN NS to the gateway of a peered cluster,
+// it is attached to the bridge of the LAN
+type PeerLink struct {
+	// name of the LANPeering
+	Peering string
+	VNI     int
+	Port    int
+	// underlay address of the remote gateway
+	Remote netip.Addr
+}
+
+// getPeerLinkName returns the name of the vxlan interface of a peering
+func getPeerLinkName(vni int) string {
+	return fmt.Sprintf("kpeer%d", vni)
+}
+
+// UpdatePeerings makes the peering vxlan interfaces in the LAN NS match peers,
+// stale ones are removed; nothing is done if the LAN NS doesn't exist on this node
+func UpdatePeerings(lanCR *v1beta1.LAN, hostname string, peers []PeerLink, event EventFunc) error {
+	lan := &lanCR.Spec
+	nsPath := GetNSPath(*lan.NS)
+	if !nl.NSExists(nsPath) {
+		return nil
+	}
+	if len(peers) > 0 && lan.IsP2P() {
+		return fmt.Errorf("p2p LAN could not be peered")
+	}
+	unlock := lockNS(*lan.NS)
+	defer unlock()
+	vxDevLink, err := getVxDev(lan, hostname)
+	if err != nil {
+		return err
+	}
+	p := &Plan{NS: *lan.NS, UID: lanCR.UID}
+	desired := []string{}
+	stale := []string{}
+	err = nl.InNS(nsPath, func() error {
+		for _, peer := range peers {
+			l := &LinkSpec{
+				Name:         getPeerLinkName(peer.VNI),
+				Type:         linkTypeVxLAN,
+				InLANNS:      true,
+				MTU:          tunnelMTU(lan, vxDevLink.Attrs().MTU),
+				Master:       *lan.BridgeName,
+				Owner:        Owner{LANUID: lanCR.UID, Role: RolePeering, Spoke: peer.Peering},
+				VNI:          peer.VNI,
+				Remote:       peer.Remote,
+				Port:         peer.Port,
+				TTL:          lan.GetTTL(),
+				Learning:     true,
+				VtepDev:      vxDevLink.Attrs().Name,
+				VtepDevIndex: vxDevLink.Attrs().Index,
+			}
+			if slices.Contains(desired, l.Name) {
+				return fmt.Errorf("vni %d of peering %v is already used by another peering", peer.VNI, peer.Peering)
+			}
+			desired = append(desired, l.Name)
+			if err := p.diffLink(l); err != nil {
+				return err
+			}
+		}
+		links, err := nl.LinkList()
+		if err != nil {
+			return fmt.Errorf("failed to list interfaces, %w", err)
+		}
+		for _, link := range links {
+			o, ok := GetOwner(link)
+			if ok && o.Role == RolePeering && !slices.Contains(desired, link.Attrs().Name) {
+				stale = append(stale, link.Attrs().Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		if err := nl.InNS(nsPath, func() error { return LinkDelete(name) }); err != nil {
+			return fmt.Errorf("failed to remove peering interface %v, %w", name, err)
+		}
+		event.normal(ReasonPeeringRemoved, "removed peering interface %v", name)
+	}
+	return p.Apply(event)
+}
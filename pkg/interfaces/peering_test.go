@@ -0,0 +1,55 @@
+package interfaces
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestUpdatePeerings(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	peers := []PeerLink{{Peering: "p1", VNI: 100, Port: 8472, Remote: netip.MustParseAddr("192.0.2.1")}}
+	//nothing to do without the LAN NS
+	if err := UpdatePeerings(lan, testHost, peers, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	nsPath := GetNSPath("lan1")
+	if err := UpdatePeerings(lan, testHost, peers, nil); err != nil {
+		t.Fatal(err)
+	}
+	link := findLink(fake, nsPath, "kpeer100")
+	if link == nil {
+		t.Fatal("peering interface is not created")
+	}
+	vx := link.(*netlink.Vxlan)
+	if vx.Group.String() != "192.0.2.1" || vx.VxlanId != 100 || vx.Port != 8472 || !vx.Learning || vx.MasterIndex == 0 {
+		t.Errorf("unexpected peering interface %+v", vx)
+	}
+	checkOwner(t, link, Owner{LANUID: "uid1", Role: RolePeering, Spoke: "p1"})
+	//the remote gateway is changed
+	peers[0].Remote = netip.MustParseAddr("192.0.2.2")
+	if err := UpdatePeerings(lan, testHost, peers, nil); err != nil {
+		t.Fatal(err)
+	}
+	if vx := findLink(fake, nsPath, "kpeer100").(*netlink.Vxlan); vx.Group.String() != "192.0.2.2" {
+		t.Errorf("peering interface is not recreated, remote is %v", vx.Group)
+	}
+	//peering with the same vni
+	if err := UpdatePeerings(lan, testHost, append(peers, PeerLink{Peering: "p2", VNI: 100, Port: 8472, Remote: peers[0].Remote}), nil); err == nil {
+		t.Error("expect error for duplicate vni")
+	}
+	if err := UpdatePeerings(lan, testHost, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if findLink(fake, nsPath, "kpeer100") != nil {
+		t.Error("stale peering interface is not removed")
+	}
+	if findLink(fake, nsPath, "vx-lan1") == nil {
+		t.Error("vxlan interface of the LAN is removed")
+	}
+}
@@ -0,0 +1,800 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// types of links managed by the plan engine, same as netlink.Link.Type()
+const (
+	linkTypeBridge  = "bridge"
+	linkTypeVxLAN   = "vxlan"
+	linkTypeVeth    = "veth"
+	linkTypeMacvtap = "macvtap"
+	linkTypeDummy   = "dummy"
+)
+
+const (
+	// max time to wait for the host side of a removed veth to disappear
+	vethGoneTimeout = 2 * time.Second
+)
+
+// LinkSpec is the desired state of a link
+type LinkSpec struct {
+	Name string
+	Type string
+	// InLANNS is true if the link is in the LAN NS, otherwise it is in host NS
+	InLANNS bool
+	// 0 means not managed
+	MTU int
+	// name of the master bridge in the same NS, if any
+	Master string
+	Owner  Owner
+	// Recreate is true if the link is always recreated to reset its state,
+	// e.g. the veth and macvtap of a spoke being allocated
+	Recreate bool
+
+	// veth only, the link is the bridge side one in the LAN NS, its peer is moved to host NS after creation
+	PeerName  string
+	PeerOwner Owner
+	// veth only, the link is held down, e.g. by the admin state of the spoke; it is created down,
+	// and brought down by UpdateLinkStates instead of the plan
+	Down bool
+	// veth only, the link is the spoke side in host NS made of the bridge side veth JoinFrom of the other spoke of a p2p LAN,
+	// which is moved out of the LAN NS, so that the two spokes are joined by a single veth pair
+	JoinFrom string
+
+	// tunnel only, Type is the encapsulation; VNI is the key of GRE
+	VNI int
+	// multicast group, or the unicast remote of geneve and ip6gretap
+	Remote netip.Addr
+	// local address of GRE, unset for other encapsulations
+	Local netip.Addr
+	// UDP port of vxlan and geneve
+	Port     int
+	TTL      int
+	Learning bool
+	// vxlan only, answers ARP/ND with neighbor entries of the vxlan interface
+	Proxy bool
+	// name and index of the underlying interface in host NS
+	VtepDev      string
+	VtepDevIndex int
+
+	// macvtap only, the lower device in host NS
+	Lower string
+	Mode  string
+
+	// tc and bridge attributes of a link in the LAN NS, nil means not managed; set by Plan
+	TC *TCSpec
+}
+
+func (l *LinkSpec) String() string {
+	return l.Type + " " + l.Name
+}
+
+// TCSpec is the desired tc and bridge attributes of a link
+type TCSpec struct {
+	// veth only, netem and tbf qdiscs on the bridge side
+	Impairment *v1beta1.Impairment
+	// name of the link all traffic received is redirected to, empty means not redirected
+	Redirect string
+	// filters mirroring traffic of the link
+	Mirrors []MirrorFilter
+	// bridge only, forward all link-local control frames
+	FullTransparency bool
+}
+
+// DesiredState is the desired state of a LAN on this node
+type DesiredState struct {
+	NS  string
+	UID types.UID
+	// in creation order
+	Links []*LinkSpec
+	lan   *v1beta1.LANSpec
+}
+
+// DesiredLAN returns the desired state of the objects shared by all spokes of lanCR on this node:
+// the LAN NS, the bridge (except in p2p mode) and the tunnel interface, a vxlan one by default
+func DesiredLAN(lanCR *v1beta1.LAN, hostname string) (*DesiredState, error) {
+	lan := &lanCR.Spec
+	vxDevLink, err := getVxDev(lan, hostname)
+	if err != nil {
+		return nil, err
+	}
+	mtu := tunnelMTU(lan, vxDevLink.Attrs().MTU)
+	d := &DesiredState{
+		NS:  *lan.NS,
+		UID: lanCR.UID,
+		lan: lan,
+	}
+	master := ""
+	if !lan.IsP2P() {
+		master = *lan.BridgeName
+		d.Links = append(d.Links, &LinkSpec{
+			Name:    *lan.BridgeName,
+			Type:    linkTypeBridge,
+			InLANNS: true,
+			MTU:     mtu,
+			Owner:   Owner{LANUID: lanCR.UID, Role: dataplane.RoleBridge},
+		})
+	}
+	tunnel, err := desiredTunnel(lanCR, hostname, vxDevLink, mtu, master)
+	if err != nil {
+		return nil, err
+	}
+	d.Links = append(d.Links, tunnel)
+	return d, nil
+}
+
+// getMTU returns the MTU of spoke links, which is the same as the tunnel interface
+func (d *DesiredState) getMTU() int {
+	for _, l := range d.Links {
+		if isTunnelType(l.Type) {
+			return l.MTU
+		}
+	}
+	return 0
+}
+
+// AddSpokeVeth adds the veth pair of spoke, it is recreated if recreate is true
+func (d *DesiredState) AddSpokeVeth(spoke string, recreate bool) {
+	master := ""
+	if !d.lan.IsP2P() {
+		master = *d.lan.BridgeName
+	}
+	d.Links = append(d.Links, &LinkSpec{
+		Name:      GetPeerVethName(spoke),
+		Type:      linkTypeVeth,
+		InLANNS:   true,
+		MTU:       d.getMTU(),
+		Master:    master,
+		Owner:     Owner{LANUID: d.UID, Role: dataplane.RolePeer, Spoke: spoke},
+		Recreate:  recreate,
+		PeerName:  spoke,
+		PeerOwner: Owner{LANUID: d.UID, Role: RoleSpoke, Spoke: spoke},
+		Down:      spokeDownReason(d.UID, d.lan, spoke) != "",
+	})
+}
+
+// AddLocalSpokes adds the veth pairs of spokes already on this node, except the ones in skip
+func (d *DesiredState) AddLocalSpokes(skip ...string) {
+	for _, spoke := range d.lan.SpokeList {
+		if !slices.Contains(skip, spoke) && LinkExistsInNS(d.NS, GetPeerVethName(spoke)) {
+			d.AddSpokeVeth(spoke, false)
+		}
+	}
+}
+
+// AddSpoke adds the veth pair and the freshly created macvtap of spoke being allocated,
+// the macvtap is on top of k8slan-dummy instead of the spoke veth if dummyMacvtap is true;
+// the veth pair is freshly created too, unless spoke is joined with the other spoke of a p2p LAN, see getP2PJoin
+func (d *DesiredState) AddSpoke(spoke, macName, macvtapMode string, dummyMacvtap bool) error {
+	join, keep, err := d.getP2PJoin(spoke)
+	if err != nil {
+		return err
+	}
+	switch {
+	case join != "":
+		//the bridge side veth of the other spoke is moved out of the LAN NS
+		d.Links = slices.DeleteFunc(d.Links, func(l *LinkSpec) bool { return l.InLANNS && l.Name == join })
+		d.addJoinedSpoke(spoke, join)
+	case keep:
+		d.addJoinedSpoke(spoke, "")
+	default:
+		d.AddSpokeVeth(spoke, true)
+	}
+	lower := spoke
+	if dummyMacvtap {
+		lower = dummyIfName
+		macvtapMode = "private"
+		d.Links = append(d.Links, &LinkSpec{
+			Name:  dummyIfName,
+			Type:  linkTypeDummy,
+			Owner: Owner{Role: RoleDummy},
+		})
+	}
+	d.Links = append(d.Links, &LinkSpec{
+		Name:     macName,
+		Type:     linkTypeMacvtap,
+		Owner:    Owner{LANUID: d.UID, Role: RoleMacvtap, Spoke: spoke},
+		Recreate: true,
+		Lower:    lower,
+		Mode:     macvtapMode,
+	})
+	return nil
+}
+
+// addTC sets the tc and bridge attributes of links in the LAN NS, and adds the mirror vxlan interfaces needed on this node
+func (d *DesiredState) addTC() {
+	d.Links = slices.DeleteFunc(d.Links, func(l *LinkSpec) bool { return l.Owner.Role == RoleMirror })
+	local := map[string]*LinkSpec{}
+	var tunnel *LinkSpec
+	for _, l := range d.Links {
+		if !l.InLANNS {
+			continue
+		}
+		local[l.Name] = l
+		l.TC = &TCSpec{}
+		switch {
+		case l.Type == linkTypeVeth:
+			l.TC.Impairment = d.lan.GetImpairment(l.PeerName)
+		case l.Type == linkTypeBridge:
+			l.TC.FullTransparency = d.lan.Transparency == v1beta1.TransparencyFull
+		case isTunnelType(l.Type):
+			tunnel = l
+		}
+	}
+	if d.lan.IsP2P() {
+		for _, rd := range getP2PRedirects(d.lan, func(name string) bool { return local[name] != nil }) {
+			local[rd.from].TC.Redirect = rd.to
+		}
+	}
+	d.addMirrors(local, tunnel)
+}
+
+// Op is the operation of a Step
+type Op string
+
+// operations on both NS and links
+const (
+	OpCreate   Op = "create"
+	OpRecreate Op = "recreate"
+	OpDelete   Op = "delete"
+)
+
+// operations on NS only
+const (
+	// bring lo up and mark the NS as a LAN NS
+	OpPrepare Op = "prepare"
+)
+
+// operations on links only
+const (
+	OpSetOwner  Op = "set owner of"
+	OpSetMTU    Op = "set MTU of"
+	OpSetMaster Op = "set master of"
+	OpSetUp     Op = "bring up"
+	// tc and bridge attributes in TCSpec
+	OpSetImpairment   Op = "set impairment of"
+	OpSetRedirect     Op = "set redirect of"
+	OpSetMirrors      Op = "set mirrors of"
+	OpSetTransparency Op = "set transparency of"
+)
+
+// Step is an idempotent operation of a Plan, Link is nil for operations on the NS
+type Step struct {
+	Op     Op
+	Link   *LinkSpec
+	Reason string
+}
+
+// Plan is an ordered list of steps to make the kernel state of a LAN on this node match the desired state
+type Plan struct {
+	NS    string
+	UID   types.UID
+	Steps []Step
+}
+
+// Empty returns true if nothing needs to be done
+func (p *Plan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Strings returns the description of every step
+func (p *Plan) Strings() []string {
+	r := []string{}
+	for _, s := range p.Steps {
+		var desc string
+		if s.Link == nil {
+			desc = fmt.Sprintf("%v namespace %v", s.Op, p.NS)
+		} else {
+			desc = fmt.Sprintf("%v %v", s.Op, s.Link)
+			if s.Link.InLANNS {
+				desc += " in namespace " + p.NS
+			}
+		}
+		if s.Reason != "" {
+			desc += ": " + s.Reason
+		}
+		r = append(r, desc)
+	}
+	return r
+}
+
+func (p *Plan) String() string {
+	return strings.Join(p.Strings(), "; ")
+}
+
+func (p *Plan) add(op Op, link *LinkSpec, reasonFmt string, args ...any) {
+	p.Steps = append(p.Steps, Step{Op: op, Link: link, Reason: fmt.Sprintf(reasonFmt, args...)})
+}
+
+// creates returns true if the named link in the LAN NS is created or recreated by p
+func (p *Plan) creates(name string) bool {
+	for _, s := range p.Steps {
+		if (s.Op == OpCreate || s.Op == OpRecreate) && (s.Link == nil || s.Link.InLANNS && s.Link.Name == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan diffs d against the kernel state, and returns the plan to make them match,
+// nothing is changed in the kernel; tc and bridge attributes are diffed after all links in the LAN NS
+func (d *DesiredState) Plan() (*Plan, error) {
+	d.addTC()
+	p := &Plan{NS: d.NS, UID: d.UID}
+	nsPath := GetNSPath(d.NS)
+	//everything in a new NS needs to be created
+	createAll := func() error {
+		p.add(OpPrepare, nil, "")
+		for _, l := range d.Links {
+			if l.InLANNS {
+				p.add(OpCreate, l, "")
+			}
+		}
+		return p.diffAllTC(d.Links)
+	}
+	if !nl.NSExists(nsPath) {
+		p.add(OpCreate, nil, "")
+		if err := createAll(); err != nil {
+			return nil, err
+		}
+	} else {
+		err := nl.InNS(nsPath, func() error {
+			lo, err := nl.LinkByName("lo")
+			if err != nil {
+				return fmt.Errorf("failed to find lo, %w", err)
+			}
+			if o, ok := GetOwner(lo); !ok || o.LANUID != d.UID || lo.Attrs().Flags&net.FlagUp == 0 {
+				if err := checkPreparable(d.NS); err != nil {
+					return err
+				}
+				p.add(OpPrepare, nil, "")
+			}
+			for _, l := range d.Links {
+				if l.InLANNS {
+					if err := p.diffLink(l); err != nil {
+						return err
+					}
+				}
+			}
+			if err := p.diffAllTC(d.Links); err != nil {
+				return err
+			}
+			return p.planObsoleteMirrors(d.Links, *d.lan.VxLANName)
+		})
+		if errors.Is(err, ErrNSOpen) {
+			p.Steps = nil
+			p.add(OpRecreate, nil, "the existing one can't be opened")
+			err = createAll()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range d.Links {
+		if !l.InLANNS {
+			if err := p.diffLink(l); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
+
+// diffLink adds steps to make the link match l, it must be called in the NS of l
+func (p *Plan) diffLink(l *LinkSpec) error {
+	link, err := nl.LinkByName(l.Name)
+	if err != nil {
+		p.add(OpCreate, l, "")
+		return nil
+	}
+	if link.Type() != l.Type {
+		//the encapsulation is changed
+		if isTunnelType(l.Type) && isTunnelType(link.Type()) {
+			p.add(OpRecreate, l, "the existing one is a %v interface", link.Type())
+			return nil
+		}
+		return fmt.Errorf("interface %v already exists but not a %v", l.Name, l.Type)
+	}
+	if l.Recreate {
+		p.add(OpRecreate, l, "reset its state")
+		return nil
+	}
+	if isTunnelType(l.Type) {
+		if mismatches := tunnelMismatches(link, l); len(mismatches) > 0 {
+			p.add(OpRecreate, l, "the existing one has a different %v", strings.Join(mismatches, ", "))
+			return nil
+		}
+	}
+	if o, ok := GetOwner(link); !ok || o != l.Owner {
+		p.add(OpSetOwner, l, "")
+	}
+	if l.MTU != 0 && link.Attrs().MTU != l.MTU {
+		p.add(OpSetMTU, l, "%d is changed to %d", link.Attrs().MTU, l.MTU)
+	}
+	if l.Master != "" {
+		master, err := nl.LinkByIndex(link.Attrs().MasterIndex)
+		if link.Attrs().MasterIndex == 0 || err != nil || master.Attrs().Name != l.Master {
+			p.add(OpSetMaster, l, "")
+		}
+	}
+	if link.Attrs().Flags&net.FlagUp == 0 && !l.Down {
+		p.add(OpSetUp, l, "")
+	}
+	return nil
+}
+
+// diffAllTC adds steps to make the tc and bridge attributes of links in the LAN NS match,
+// it must be called in the LAN NS unless the NS is created by p
+func (p *Plan) diffAllTC(links []*LinkSpec) error {
+	for _, l := range links {
+		if !l.InLANNS || l.TC == nil {
+			continue
+		}
+		if p.creates(l.Name) {
+			//a new link has no tc and default bridge attributes
+			if l.TC.Impairment != nil {
+				p.add(OpSetImpairment, l, "")
+			}
+			if l.TC.Redirect != "" {
+				p.add(OpSetRedirect, l, "")
+			}
+			if len(l.TC.Mirrors) > 0 {
+				p.add(OpSetMirrors, l, "")
+			}
+			if l.TC.FullTransparency {
+				p.add(OpSetTransparency, l, "")
+			}
+			continue
+		}
+		link, err := nl.LinkByName(l.Name)
+		if err != nil {
+			return fmt.Errorf("failed to find %v, %w", l.Name, err)
+		}
+		if err := p.diffImpairment(link, l); err != nil {
+			return err
+		}
+		p.diffRedirect(link, l)
+		p.diffMirrors(link, l)
+		if l.Type == linkTypeBridge {
+			ports := []string{}
+			for _, port := range links {
+				if port.InLANNS && port.Master == l.Name {
+					ports = append(ports, port.Name)
+				}
+			}
+			if err := p.diffTransparency(link, l, ports); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkPreparable returns error if current NS could not be marked as the LAN NS nsname,
+// i.e. it is not a LAN NS but already contains links
+func checkPreparable(nsname string) error {
+	if _, ok := getNSOwner(); ok {
+		return nil
+	}
+	found, err := hasLinks()
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("namespace %v already exists with interfaces but is not a LAN namespace, %w", nsname, ErrNotOwned)
+	}
+	return nil
+}
+
+// Apply executes the steps of p in order, notable changes are reported via event, which could be nil
+func (p *Plan) Apply(event EventFunc) error {
+	for _, s := range p.Steps {
+		var err error
+		switch {
+		case s.Link == nil:
+			err = p.applyNSStep(s, event)
+		case s.Link.InLANNS:
+			err = p.applyLinkStep(s, GetNSPath(p.NS), event)
+		default:
+			err = p.applyLinkStep(s, "", event)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Plan) applyNSStep(s Step, event EventFunc) error {
+	switch s.Op {
+	case OpCreate, OpRecreate:
+		if s.Op == OpRecreate {
+			nl.DeleteNS(p.NS)
+		}
+		if err := nl.NewNS(p.NS); err != nil {
+			return fmt.Errorf("failed to %v ns %v, %w", s.Op, p.NS, err)
+		}
+		if s.Op == OpRecreate {
+			event.normal(ReasonNSCreated, "recreated namespace %v since %v", p.NS, s.Reason)
+		} else {
+			event.normal(ReasonNSCreated, "created namespace %v", p.NS)
+		}
+		return nil
+	case OpPrepare:
+		err := nl.InNS(GetNSPath(p.NS), func() error {
+			lo, err := nl.LinkByName("lo")
+			if err != nil {
+				return err
+			}
+			if err := checkPreparable(p.NS); err != nil {
+				return err
+			}
+			if err := markNS(p.UID); err != nil {
+				return err
+			}
+			return nl.LinkSetUp(lo)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to bring lo up in ns, %w", err)
+		}
+		return nil
+	case OpDelete:
+		if err := nl.DeleteNS(p.NS); err != nil {
+			return fmt.Errorf("failed to remove ns %v, %w", p.NS, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown operation %v on ns", s.Op)
+}
+
+// applyLinkStep applies a step on a link in the NS of path
+func (p *Plan) applyLinkStep(s Step, path string, event EventFunc) error {
+	l := s.Link
+	switch s.Op {
+	case OpCreate, OpRecreate:
+		if l.JoinFrom != "" {
+			if err := joinVeth(l, GetNSPath(p.NS), s.Op == OpRecreate); err != nil {
+				return err
+			}
+			event.normal(ReasonVethCreated, "joined spoke %v with the other spoke by moving veth %v out of namespace %v", l.Name, l.JoinFrom, p.NS)
+			return nil
+		}
+		if err := createLink(l, path, s.Op == OpRecreate); err != nil {
+			return err
+		}
+		switch {
+		case isTunnelType(l.Type):
+			if s.Op == OpRecreate {
+				event.normal(ReasonVxLANRecreated, "recreated %v interface %v since %v", l.Type, l.Name, s.Reason)
+			} else {
+				event.normal(ReasonVxLANCreated, "created %v interface %v", l.Type, l.Name)
+			}
+		case l.Type == linkTypeVeth:
+			event.normal(ReasonVethCreated, "created veth %v with peer %v in namespace %v", l.PeerName, l.Name, p.NS)
+		case l.Type == linkTypeMacvtap:
+			event.normal(ReasonMacvtapCreated, "created macvtap %v on top of %v", l.Name, l.Lower)
+		}
+		return nil
+	case OpDelete:
+		return nl.InNS(path, func() error { return linkDelete(l.Name, false) })
+	}
+	return nl.InNS(path, func() error {
+		link, err := nl.LinkByName(l.Name)
+		if err != nil {
+			return fmt.Errorf("failed to find %v, %w", l.Name, err)
+		}
+		switch s.Op {
+		case OpSetOwner:
+			return setOwner(l.Name, l.Owner)
+		case OpSetMTU:
+			if err := nl.LinkSetMTU(link, l.MTU); err != nil {
+				return fmt.Errorf("failed to set MTU of %v, %w", l.Name, err)
+			}
+			return nil
+		case OpSetMaster:
+			return setMaster(link, l.Master)
+		case OpSetUp:
+			if err := nl.LinkSetUp(link); err != nil {
+				return fmt.Errorf("failed to bring %v up, %w", l.Name, err)
+			}
+			return nil
+		case OpSetImpairment:
+			return applyImpairment(link, l.TC.Impairment)
+		case OpSetRedirect:
+			return setRedirect(link, l)
+		case OpSetMirrors:
+			return setMirrors(link, l)
+		case OpSetTransparency:
+			return setTransparency(link, l.TC.FullTransparency)
+		}
+		return fmt.Errorf("unknown operation %v on %v", s.Op, l)
+	})
+}
+
+// setMaster attaches link to the named bridge in current NS
+func setMaster(link netlink.Link, masterName string) error {
+	master, err := nl.LinkByName(masterName)
+	if err != nil {
+		return fmt.Errorf("failed to find master %v, %w", masterName, err)
+	}
+	if err := nl.LinkSetMaster(link, master); err != nil {
+		return fmt.Errorf("failed to set master of %v to %v, %w", link.Attrs().Name, masterName, err)
+	}
+	if err := nl.LinkSetBRSlaveGroupFwdMask(link, BRSlaveGrpFwdMask); err != nil {
+		return fmt.Errorf("failed to set slave grp fwd mask of %v, %w", link.Attrs().Name, err)
+	}
+	return nil
+}
+
+// createLink creates the link of l, removes the existing one first if recreate is true;
+// the created link is owned, attached to its master and up, unless l.Down
+func createLink(l *LinkSpec, path string, recreate bool) error {
+	if recreate {
+		if err := nl.InNS(path, func() error { return linkDelete(l.Name, l.InLANNS) }); err != nil {
+			return fmt.Errorf("failed to remove existing %v, %w", l, err)
+		}
+		if l.Type == linkTypeVeth {
+			//the host side is removed along with the LAN NS side asynchronously
+			if err := waitLinkGone(l.PeerName, vethGoneTimeout); err != nil {
+				return err
+			}
+		}
+	}
+	switch {
+	case isTunnelType(l.Type):
+		//tunnel interface is created in host NS to use the underlying interface there
+		if err := ensureTunnelIf(l, path); err != nil {
+			return fmt.Errorf("failed to create %v interface, %w", l.Type, err)
+		}
+	case l.Type == linkTypeMacvtap:
+		if _, err := CreateMacvtap(l.Name, l.Lower, l.Mode); err != nil {
+			return err
+		}
+	}
+	//the spoke side of a veth is moved to host NS after creation
+	if l.Type == linkTypeVeth && path == "" {
+		return fmt.Errorf("veth %v must be created in the LAN ns", l.Name)
+	}
+	return nl.InNS(path, func() error {
+		la := netlink.NewLinkAttrs()
+		la.Name = l.Name
+		la.MTU = l.MTU
+		switch l.Type {
+		case linkTypeBridge:
+			la.TxQLen = -1 //this is important, otherwise the interface only accept broadcast traffic
+			if err := nl.LinkAdd(&netlink.Bridge{LinkAttrs: la}); err != nil {
+				return fmt.Errorf("failed to create bridge %v: %v", l.Name, err)
+			}
+		case linkTypeDummy:
+			if err := nl.LinkAdd(&netlink.Dummy{LinkAttrs: la}); err != nil {
+				return fmt.Errorf("failed to create dummy link %v, %w", l.Name, err)
+			}
+		case linkTypeVeth:
+			la.TxQLen = -1
+			if err := nl.LinkAdd(&netlink.Veth{LinkAttrs: la, PeerName: l.PeerName}); err != nil {
+				return fmt.Errorf("failed to create veth interface %v: %v", l.PeerName, err)
+			}
+			if err := setOwner(l.PeerName, l.PeerOwner); err != nil {
+				return err
+			}
+		}
+		if err := setOwner(l.Name, l.Owner); err != nil {
+			return err
+		}
+		link, err := nl.LinkByName(l.Name)
+		if err != nil {
+			return fmt.Errorf("failed to find the created %v, %w", l, err)
+		}
+		if l.Master != "" {
+			if err := setMaster(link, l.Master); err != nil {
+				return err
+			}
+		}
+		if !l.Down {
+			if err := nl.LinkSetUp(link); err != nil {
+				return fmt.Errorf("failed to bring %v up, %w", l, err)
+			}
+		}
+		if l.Type != linkTypeVeth {
+			return nil
+		}
+		//move the spoke side to host NS, and bring it up there
+		peer, err := nl.LinkByName(l.PeerName)
+		if err != nil {
+			return fmt.Errorf("failed to find veth %v, %w", l.PeerName, err)
+		}
+		if err := nl.LinkSetNS(peer, ""); err != nil {
+			return fmt.Errorf("failed to move veth %v to host ns, %w", l.PeerName, err)
+		}
+		return nl.InNS("", func() error {
+			peer, err := nl.LinkByName(l.PeerName)
+			if err != nil {
+				return fmt.Errorf("failed to get the created spoke link %v in host ns, %w", l.PeerName, err)
+			}
+			if err := nl.LinkSetUp(peer); err != nil {
+				return fmt.Errorf("failed to bring up spoke link %v in host ns, %w", l.PeerName, err)
+			}
+			return nil
+		})
+	})
+}
+
+// waitLinkGone waits until the named link in current NS disappears
+func waitLinkGone(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := nl.LinkByName(name); err != nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("interface %v is not removed after %v", name, timeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// PlanRemove returns the plan to remove the named LAN NS and all interfaces in it,
+// the plan is empty if the NS doesn't exist on this node;
+// it refuses to remove a NS not marked as a LAN NS
+func PlanRemove(nsname string) (*Plan, error) {
+	p := &Plan{NS: nsname}
+	if !nl.NSExists(GetNSPath(nsname)) {
+		return p, nil
+	}
+	if _, ok := getLANNSOwner(nsname); !ok {
+		return nil, fmt.Errorf("refuse to remove namespace %v, %w", nsname, ErrNotOwned)
+	}
+	p.add(OpDelete, nil, "")
+	return p, nil
+}
+
+// PlanDrift returns the plan to repair the objects of lanCR on this node,
+// i.e. the NS, bridge, tunnel interface, existing spoke veths, mirror vxlan interfaces and their tc and bridge attributes,
+// without recreating anything up-to-date;
+// nil is returned if the LAN NS doesn't exist on this node
+func PlanDrift(lanCR *v1beta1.LAN, hostname string) (*Plan, error) {
+	lan := &lanCR.Spec
+	if !nl.NSExists(GetNSPath(*lan.NS)) {
+		return nil, nil
+	}
+	d, err := DesiredLAN(lanCR, hostname)
+	if err != nil {
+		return nil, err
+	}
+	d.AddLocalSpokes()
+	return d.Plan()
+}
+
+var (
+	nsLocksLock = new(sync.Mutex)
+	// key is the LAN NS name
+	nsLocks = map[string]*sync.Mutex{}
+)
+
+// lockNS serializes planning and applying of the same LAN NS,
+// e.g. between allocating a spoke and repairing drift; the returned func unlocks it
+func lockNS(nsname string) func() {
+	nsLocksLock.Lock()
+	l, ok := nsLocks[nsname]
+	if !ok {
+		l = new(sync.Mutex)
+		nsLocks[nsname] = l
+	}
+	nsLocksLock.Unlock()
+	l.Lock()
+	return l.Unlock
+}
@@ -0,0 +1,444 @@
+package interfaces
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const testHost = "worker1"
+
+// setupFake replaces nl with a FakeNetlinker whose host NS has eth0 as the vxlan underlying interface
+func setupFake(t *testing.T) *FakeNetlinker {
+	t.Helper()
+	fake := NewFakeNetlinker()
+	if err := fake.LinkAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "eth0", MTU: 1500}}); err != nil {
+		t.Fatal(err)
+	}
+	origNL := nl
+	nl = fake
+	t.Cleanup(func() {
+		nl = origNL
+	})
+	return fake
+}
+
+func newTestLAN(name string, uid types.UID, vni int32) *v1beta1.LAN {
+	str := func(s string) *string { return &s }
+	lan := &v1beta1.LAN{
+		Spec: v1beta1.LANSpec{
+			NS:           str(name),
+			BridgeName:   str("br-" + name),
+			VxLANName:    str("vx-" + name),
+			VNI:          &vni,
+			VxLANGrp:     str("ff02::14"),
+			DefaultVxDev: "eth0",
+			VxPort:       new(int32),
+			SpokeList:    []string{name + "s1", name + "s2"},
+		},
+	}
+	*lan.Spec.VxPort = 4789
+	lan.Name = name
+	lan.UID = uid
+	return lan
+}
+
+// findLink returns the named link in the NS of path, nil if not found
+func findLink(fake *FakeNetlinker, path, name string) netlink.Link {
+	for _, l := range fake.Links(path) {
+		if l.Attrs().Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+func checkOwner(t *testing.T, link netlink.Link, expected Owner) {
+	t.Helper()
+	o, ok := GetOwner(link)
+	if !ok || o != expected {
+		t.Errorf("owner of %v is %+v, expect %+v", link.Attrs().Name, o, expected)
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	index, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nsPath := GetNSPath("lan1")
+	if !fake.NSExists(nsPath) {
+		t.Fatalf("ns %v is not created", nsPath)
+	}
+	lo := findLink(fake, nsPath, "lo")
+	checkOwner(t, lo, Owner{LANUID: "uid1", Role: RoleNamespace})
+	if lo.Attrs().Flags&net.FlagUp == 0 {
+		t.Error("lo is not up")
+	}
+	br := findLink(fake, nsPath, "br-lan1")
+	if br == nil {
+		t.Fatal("bridge is not created")
+	}
+	if br.Attrs().MTU != 1500-maxVxLANEncapOverhead {
+		t.Errorf("bridge mtu is %v", br.Attrs().MTU)
+	}
+	vx, ok := findLink(fake, nsPath, "vx-lan1").(*netlink.Vxlan)
+	if !ok {
+		t.Fatal("vxlan interface is not created")
+	}
+	if vx.VxlanId != 100 || vx.Port != 4789 || !vx.Learning || vx.MasterIndex != br.Attrs().Index {
+		t.Errorf("unexpected vxlan interface %+v", vx)
+	}
+	peer := findLink(fake, nsPath, "lan1s1p")
+	if peer == nil || peer.Attrs().MasterIndex != br.Attrs().Index || peer.Attrs().Flags&net.FlagUp == 0 {
+		t.Fatalf("unexpected bridge side veth %+v", peer)
+	}
+	spoke := findLink(fake, "", "lan1s1")
+	if spoke == nil || spoke.Attrs().Flags&net.FlagUp == 0 {
+		t.Fatalf("unexpected spoke veth %+v", spoke)
+	}
+	checkOwner(t, spoke, Owner{LANUID: "uid1", Role: RoleSpoke, Spoke: "lan1s1"})
+	mac := findLink(fake, "", "mac1")
+	if mac == nil || mac.Attrs().Index != index || mac.Attrs().ParentIndex != spoke.Attrs().Index {
+		t.Fatalf("unexpected macvtap %+v, returned index %v", mac, index)
+	}
+	checkOwner(t, mac, Owner{LANUID: "uid1", Role: RoleMacvtap, Spoke: "lan1s1"})
+
+	//nothing but the spoke needs to be created again
+	desired, err := DesiredLAN(lan, testHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := desired.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Errorf("plan of an up-to-date LAN is not empty: %v", plan.Strings())
+	}
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if findLink(fake, nsPath, "br-lan1").Attrs().Index != br.Attrs().Index {
+		t.Error("bridge is recreated")
+	}
+	if findLink(fake, nsPath, "vx-lan1").Attrs().Index != vx.Attrs().Index {
+		t.Error("vxlan interface is recreated")
+	}
+	if findLink(fake, "", "lan1s1").Attrs().Index == spoke.Attrs().Index {
+		t.Error("spoke veth is not recreated")
+	}
+}
+
+func TestEnsureDummyMacvtap(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", true, nil); err != nil {
+		t.Fatal(err)
+	}
+	dummy := findLink(fake, "", dummyIfName)
+	if dummy == nil {
+		t.Fatal("dummy interface is not created")
+	}
+	checkOwner(t, dummy, Owner{Role: RoleDummy})
+	mac, ok := findLink(fake, "", "mac1").(*netlink.Macvtap)
+	if !ok || mac.ParentIndex != dummy.Attrs().Index || mac.Mode != netlink.MACVLAN_MODE_PRIVATE {
+		t.Errorf("unexpected macvtap %+v", mac)
+	}
+}
+
+func TestPlanDrift(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	if plan, err := PlanDrift(lan, testHost); err != nil || plan != nil {
+		t.Fatalf("expect no plan for a LAN not on this node, got %v, %v", plan, err)
+	}
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	*lan.Spec.VNI = 200
+	plan, err := RepairDrift(lan, testHost, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 1 || plan.Steps[0].Op != OpRecreate || plan.Steps[0].Link.Name != "vx-lan1" {
+		t.Fatalf("unexpected plan %v", plan.Strings())
+	}
+	vx := findLink(fake, GetNSPath("lan1"), "vx-lan1").(*netlink.Vxlan)
+	if vx.VxlanId != 200 || vx.MasterIndex == 0 {
+		t.Errorf("vxlan interface is not repaired, %+v", vx)
+	}
+	//the existing spoke is kept
+	if findLink(fake, "", "lan1s1") == nil || findLink(fake, "", "mac1") == nil {
+		t.Error("spoke is removed by drift repair")
+	}
+	if plan, err := PlanDrift(lan, testHost); err != nil || !plan.Empty() {
+		t.Errorf("expect empty plan after repair, got %v, %v", plan.Strings(), err)
+	}
+}
+
+func TestPlanEncapsulationChange(t *testing.T) {
+	fake := setupFake(t)
+	eth0 := findLink(fake, "", "eth0")
+	fake.AddAddr("", eth0.Attrs().Index, netlink.Addr{IPNet: &net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(24, 32)}})
+	lan := newTestLAN("lan1", "uid1", 100)
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	nsPath := GetNSPath("lan1")
+	repair := func(expectedType string) netlink.Link {
+		t.Helper()
+		plan, err := RepairDrift(lan, testHost, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.ContainsFunc(plan.Steps, func(s Step) bool { return s.Op == OpRecreate && s.Link.Name == "vx-lan1" }) {
+			t.Fatalf("unexpected plan %v", plan.Strings())
+		}
+		link := findLink(fake, nsPath, "vx-lan1")
+		if link == nil || link.Type() != expectedType || link.Attrs().MasterIndex == 0 {
+			t.Fatalf("tunnel interface is not recreated as %v, %+v", expectedType, link)
+		}
+		if plan, err := PlanDrift(lan, testHost); err != nil || !plan.Empty() {
+			t.Errorf("expect empty plan after repair, got %v, %v", plan.Strings(), err)
+		}
+		return link
+	}
+	lan.Spec.Encapsulation = v1beta1.EncapGeneve
+	lan.Spec.TunnelEndpoints = map[string]string{testHost: "2001:db8::1", "worker2": "2001:db8::2"}
+	*lan.Spec.VxPort = 6081
+	geneve := repair(linkTypeGeneve).(*netlink.Geneve)
+	if geneve.Remote.String() != "2001:db8::2" || geneve.ID != 100 || geneve.Dport != 6081 || geneve.MTU != 1500-maxGeneveEncapOverhead {
+		t.Errorf("unexpected geneve interface %+v", geneve)
+	}
+	if findLink(fake, nsPath, "lan1s1p").Attrs().MTU != geneve.MTU {
+		t.Error("mtu of the spoke veth is not changed")
+	}
+
+	lan.Spec.Encapsulation = v1beta1.EncapGRETap
+	*lan.Spec.VxLANGrp = "239.1.1.1"
+	gretap := repair(linkTypeGRETap).(*netlink.Gretap)
+	if gretap.Local.String() != "10.0.0.1" || gretap.Remote.String() != "239.1.1.1" || gretap.IKey != 100 || int(gretap.Link) != eth0.Attrs().Index {
+		t.Errorf("unexpected gretap interface %+v", gretap)
+	}
+	ttl := int32(8)
+	lan.Spec.TTL = &ttl
+	if gretap = repair(linkTypeGRETap).(*netlink.Gretap); gretap.Ttl != 8 {
+		t.Errorf("ttl of gretap interface is %v", gretap.Ttl)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := Remove("lan1")
+	if err != nil || !removed {
+		t.Fatalf("failed to remove, %v, %v", removed, err)
+	}
+	if fake.NSExists(GetNSPath("lan1")) {
+		t.Error("ns is not removed")
+	}
+	if findLink(fake, "", "lan1s1") != nil || findLink(fake, "", "mac1") != nil {
+		t.Error("spoke is not removed along with the ns")
+	}
+	if removed, err := Remove("lan1"); err != nil || removed {
+		t.Errorf("removing a non-existing ns returns %v, %v", removed, err)
+	}
+	//a NS not created by k8slan
+	if err := fake.NewNS("other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Remove("other"); !errors.Is(err, ErrNotOwned) {
+		t.Errorf("expect ErrNotOwned when removing an unmarked ns, got %v", err)
+	}
+}
+
+func TestLinkDelete(t *testing.T) {
+	fake := setupFake(t)
+	if err := LinkDelete("eth0"); !errors.Is(err, ErrNotOwned) {
+		t.Errorf("expect ErrNotOwned when removing eth0, got %v", err)
+	}
+	if findLink(fake, "", "eth0") == nil {
+		t.Error("eth0 is removed")
+	}
+	if err := LinkDelete("nonexist"); err != nil {
+		t.Errorf("removing a non-existing link returns %v", err)
+	}
+}
+
+func TestLANNSOwnership(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	//an existing NS with interfaces is not taken over
+	if err := fake.NewNS("lan1"); err != nil {
+		t.Fatal(err)
+	}
+	nsPath := GetNSPath("lan1")
+	fake.InNS(nsPath, func() error {
+		return fake.LinkAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "other"}})
+	})
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); !errors.Is(err, ErrNotOwned) {
+		t.Errorf("expect ErrNotOwned when allocating in an unmarked ns with interfaces, got %v", err)
+	}
+	if _, ok := getLANNSOwner("lan1"); ok {
+		t.Error("unmarked ns with interfaces is marked as a LAN ns")
+	}
+	fake.DeleteNS("lan1")
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	//in a LAN NS, only expected LAN links without owner alias are considered owned
+	err := fake.InNS(nsPath, func() error {
+		if err := fake.LinkAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "other"}}); err != nil {
+			return err
+		}
+		if err := LinkDelete("other"); !errors.Is(err, ErrNotOwned) {
+			t.Errorf("expect ErrNotOwned when removing an unexpected link in a LAN ns, got %v", err)
+		}
+		br, err := fake.LinkByName("br-lan1")
+		if err != nil {
+			return err
+		}
+		//created before owner alias is introduced
+		if err := fake.LinkSetAlias(br, ""); err != nil {
+			return err
+		}
+		if err := LinkDelete("br-lan1"); !errors.Is(err, ErrNotOwned) {
+			t.Errorf("expect ErrNotOwned when removing a bridge without alias, got %v", err)
+		}
+		return linkDelete("br-lan1", true)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findLink(fake, nsPath, "br-lan1") != nil {
+		t.Error("legacy bridge is not removed")
+	}
+	if findLink(fake, nsPath, "other") == nil {
+		t.Error("unexpected link is removed")
+	}
+}
+
+func TestCreateVXLANIF(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	if err := fake.NewNS("lan1"); err != nil {
+		t.Fatal(err)
+	}
+	eth0 := findLink(fake, "", "eth0")
+	nsPath := GetNSPath("lan1")
+	for range 2 {
+		//creating an existing one is not an error
+		if err := CreateVXLANIF(&lan.Spec, eth0.Attrs().Index, nsPath, 1450, 4789); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vx, ok := findLink(fake, nsPath, "vx-lan1").(*netlink.Vxlan)
+	if !ok || vx.MTU != 1450 || vx.VtepDevIndex != eth0.Attrs().Index || vx.VxlanId != 100 {
+		t.Fatalf("unexpected vxlan interface %+v", vx)
+	}
+	if findLink(fake, "", "vx-lan1") != nil {
+		t.Error("vxlan interface is created in host ns")
+	}
+	*lan.Spec.VxLANGrp = "2001:db8::1"
+	if err := CreateVXLANIF(&lan.Spec, eth0.Attrs().Index, nsPath, 1450, 4789); err == nil {
+		t.Error("expect error for a non-multicast group")
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	fake := setupFake(t)
+	lan1 := newTestLAN("lan1", "uid1", 100)
+	lan2 := newTestLAN("lan2", "uid2", 200)
+	for _, lan := range []*v1beta1.LAN{lan1, lan2} {
+		if _, err := Ensure("mac-"+lan.Name, lan.Spec.SpokeList[0], lan, testHost, "passthru", false, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fake.NewNS("other"); err != nil {
+		t.Fatal(err)
+	}
+	orphans, err := FindOrphans(func() ([]*v1beta1.LAN, error) { return []*v1beta1.LAN{lan1}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, o := range orphans {
+		names = append(names, o.String())
+	}
+	slices.Sort(names)
+	expected := []string{
+		OrphanLink + " lan2s1",
+		OrphanLink + " mac-lan2",
+		OrphanNamespace + " lan2",
+	}
+	slices.Sort(expected)
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("orphans are %v, expect %v", names, expected)
+	}
+	//lan3 is created after the scan, while the LANs are listed
+	lan3 := newTestLAN("lan3", "uid3", 300)
+	orphans, err = FindOrphans(func() ([]*v1beta1.LAN, error) {
+		_, err := Ensure("mac-lan3", lan3.Spec.SpokeList[0], lan3, testHost, "passthru", false, nil)
+		return []*v1beta1.LAN{lan1, lan2}, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("interfaces created after the scan are orphans %v", orphans)
+	}
+}
+
+func TestRemoveOrphan(t *testing.T) {
+	fake := setupFake(t)
+	lan1 := newTestLAN("lan1", "uid1", 100)
+	if _, err := Ensure("mac1", lan1.Spec.SpokeList[0], lan1, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	orphans, err := FindOrphans(func() ([]*v1beta1.LAN, error) { return nil, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the LAN NS is taken by another LAN with the same NS since it was found
+	lan2 := newTestLAN("lan1", "uid2", 100)
+	if _, err := Remove("lan1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Ensure("mac2", lan2.Spec.SpokeList[0], lan2, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orphans {
+		if o.Kind == OrphanNamespace || o.Name == "lan1s1" {
+			if err := RemoveOrphan(o); !errors.Is(err, ErrNotOrphan) {
+				t.Errorf("expect ErrNotOrphan removing %v, got %v", o, err)
+			}
+		}
+	}
+	if !fake.NSExists(GetNSPath("lan1")) || findLink(fake, "", "lan1s1") == nil {
+		t.Error("NS or interface of a live LAN is removed")
+	}
+	orphans, err = FindOrphans(func() ([]*v1beta1.LAN, error) { return nil, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orphans {
+		if err := RemoveOrphan(o); err != nil {
+			t.Errorf("failed to remove %v, %v", o, err)
+		}
+	}
+	if fake.NSExists(GetNSPath("lan1")) || findLink(fake, "", "lan1s1") != nil {
+		t.Error("orphans are not removed")
+	}
+}
@@ -0,0 +1,92 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/probe"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// ProbeIfName is the hidden veth in the LAN NS for sending/receiving probe frames,
+	// its peer is wired to the vxlan interface with tc, not attached to the bridge
+	ProbeIfName     = "k8slanprobe"
+	probePeerIfName = ProbeIfName + "p"
+)
+
+// EnsureProbeIf creates the probe veth in the LAN NS if it doesn't exist,
+// frames sent on the probe veth go to the vxlan interface directly,
+// and probe frames received on the vxlan interface go to the probe veth only
+func EnsureProbeIf(lan *v1beta1.LANSpec) error {
+	unlock := lockNS(*lan.NS)
+	defer unlock()
+	return nl.InNS(GetNSPath(*lan.NS), func() error {
+		vxLink, err := nl.LinkByName(*lan.VxLANName)
+		if err != nil {
+			return fmt.Errorf("failed to find vxlan interface %v, %w", *lan.VxLANName, err)
+		}
+		if _, err := nl.LinkByName(ProbeIfName); err != nil {
+			la := netlink.NewLinkAttrs()
+			la.Name = ProbeIfName
+			la.MTU = vxLink.Attrs().MTU
+			if err := nl.LinkAdd(&netlink.Veth{LinkAttrs: la, PeerName: probePeerIfName}); err != nil {
+				return fmt.Errorf("failed to create probe veth, %w", err)
+			}
+		}
+		for _, name := range []string{ProbeIfName, probePeerIfName} {
+			if err := setLANOwner(name, RoleProbe, ""); err != nil {
+				return err
+			}
+			link, err := nl.LinkByName(name)
+			if err != nil {
+				return fmt.Errorf("failed to find %v, %w", name, err)
+			}
+			if err := nl.LinkSetUp(link); err != nil {
+				return fmt.Errorf("failed to bring %v up, %w", name, err)
+			}
+		}
+		peer, err := nl.LinkByName(probePeerIfName)
+		if err != nil {
+			return fmt.Errorf("failed to find %v, %w", probePeerIfName, err)
+		}
+		if err := redirectIngress(peer, vxLink); err != nil {
+			return err
+		}
+		if err := ensureClsact(vxLink); err != nil {
+			return err
+		}
+		if err := nl.FilterReplace(newProbeFilter(vxLink, netlink.NewMirredAction(peer.Attrs().Index))); err != nil {
+			return fmt.Errorf("failed to redirect probe frames on %v, %w", vxLink.Attrs().Name, err)
+		}
+		return nil
+	})
+}
+
+// newProbeFilter returns the filter on the ingress of the vxlan interface for probe frames
+func newProbeFilter(vxLink netlink.Link, actions ...netlink.Action) *netlink.MatchAll {
+	filter := newMatchAll(vxLink, netlink.HANDLE_MIN_INGRESS, probeFilterPrio, actions...)
+	filter.Protocol = probe.EtherType
+	return filter
+}
+
+// RemoveProbeIf removes the probe veth and its filter in the LAN NS, if any
+func RemoveProbeIf(lan *v1beta1.LANSpec) error {
+	nsPath := GetNSPath(*lan.NS)
+	if !nl.NSExists(nsPath) {
+		return nil
+	}
+	unlock := lockNS(*lan.NS)
+	defer unlock()
+	return nl.InNS(nsPath, func() error {
+		if vxLink, err := nl.LinkByName(*lan.VxLANName); err == nil {
+			err = nl.FilterDel(newProbeFilter(vxLink))
+			if err != nil && !errors.Is(err, syscall.ENOENT) && !errors.Is(err, syscall.EINVAL) {
+				return fmt.Errorf("failed to remove probe filter on %v, %w", vxLink.Attrs().Name, err)
+			}
+		}
+		return LinkDelete(ProbeIfName)
+	})
+}
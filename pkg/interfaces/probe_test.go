@@ -0,0 +1,78 @@
+package interfaces
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hujun-open/k8slan/pkg/probe"
+	"github.com/vishvananda/netlink"
+)
+
+func TestProbeIf(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	//no-op without the LAN NS
+	if err := RemoveProbeIf(&lan.Spec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	getProbeFilter := func(vxLink netlink.Link) *netlink.MatchAll {
+		t.Helper()
+		filters, err := fake.FilterList(vxLink, netlink.HANDLE_MIN_INGRESS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range filters {
+			if f.Attrs().Protocol == probe.EtherType {
+				return f.(*netlink.MatchAll)
+			}
+		}
+		return nil
+	}
+	//idempotent
+	for range 2 {
+		if err := EnsureProbeIf(&lan.Spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+		for _, name := range []string{ProbeIfName, probePeerIfName} {
+			link, ok := links[name]
+			if !ok {
+				t.Fatalf("%v is not created", name)
+			}
+			checkOwner(t, link, Owner{LANUID: "uid1", Role: RoleProbe})
+			if link.Attrs().Flags&net.FlagUp == 0 {
+				t.Errorf("%v is not up", name)
+			}
+			if link.Attrs().MasterIndex != 0 {
+				t.Errorf("%v is attached to a bridge", name)
+			}
+		}
+		vxLink := links["vx-lan1"]
+		if got := getRedirectTarget(links[probePeerIfName]); got != vxLink.Attrs().Index {
+			t.Errorf("%v is redirected to %d, expect vx-lan1", probePeerIfName, got)
+		}
+		filter := getProbeFilter(vxLink)
+		if filter == nil || len(filter.Actions) != 1 || filter.Actions[0].(*netlink.MirredAction).Ifindex != links[probePeerIfName].Attrs().Index {
+			t.Errorf("probe frames on vx-lan1 are not redirected to %v, filter is %+v", probePeerIfName, filter)
+		}
+	})
+	for range 2 {
+		if err := RemoveProbeIf(&lan.Spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+		for _, name := range []string{ProbeIfName, probePeerIfName} {
+			if _, ok := links[name]; ok {
+				t.Errorf("%v is not removed", name)
+			}
+		}
+		if filter := getProbeFilter(links["vx-lan1"]); filter != nil {
+			t.Errorf("probe filter %+v is not removed", filter)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package interfaces
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// findPeerNS returns the name of the LAN NS the bridge side veth of spoke of the LAN with uid is in,
+// the NS in hints are checked before all the others; empty if the spoke is not on this node
+func findPeerNS(uid types.UID, spoke string, hints ...string) (string, error) {
+	all, err := nl.ListNS()
+	if err != nil {
+		return "", fmt.Errorf("failed to list ns run dir, %w", err)
+	}
+	peerName := GetPeerVethName(spoke)
+	for _, nsname := range slices.Concat(hints, all) {
+		found := false
+		nl.InNS(GetNSPath(nsname), func() error {
+			link, err := nl.LinkByName(peerName)
+			if err != nil {
+				return nil
+			}
+			o, ok := GetOwner(link)
+			found = ok && o.LANUID == uid && o.Spoke == spoke
+			return nil
+		})
+		if found {
+			return nsname, nil
+		}
+	}
+	return "", nil
+}
+
+// BindSpoke connects the bridge side veth of spoke of home to the bridge of target,
+// or back to home if target is nil; the veth is moved to the LAN NS of target if it is elsewhere,
+// and held down for flap before brought up to signal the change to the pod, it stays down if the spoke is held down;
+// the LAN NS of target is created if it doesn't exist on this node yet.
+// found is false if the spoke is not on this node, moved is true if the veth is moved
+func BindSpoke(home, target *v1beta1.LAN, hostname, spoke string, flap time.Duration, event EventFunc) (found, moved bool, err error) {
+	dst := home
+	if target != nil {
+		dst = target
+	}
+	src, err := findPeerNS(home.UID, spoke, *dst.Spec.NS, *home.Spec.NS)
+	if err != nil || src == "" {
+		return false, false, err
+	}
+	if err := bindSpoke(src, home, dst, hostname, spoke, flap, event); err != nil {
+		return true, false, err
+	}
+	return true, src != *dst.Spec.NS, nil
+}
+
+// bindSpoke moves the bridge side veth of spoke of home from the LAN NS src to the one of dst,
+// if it is already in dst, its master and up state are repaired
+func bindSpoke(src string, home, dst *v1beta1.LAN, hostname, spoke string, flap time.Duration, event EventFunc) error {
+	dstNS := *dst.Spec.NS
+	peerName := GetPeerVethName(spoke)
+	master := ""
+	if !dst.Spec.IsP2P() {
+		master = *dst.Spec.BridgeName
+	}
+	//lock both NS in the same order to avoid deadlock
+	for _, nsname := range slices.Compact(slices.Sorted(slices.Values([]string{src, dstNS}))) {
+		defer lockNS(nsname)()
+	}
+	if src != dstNS {
+		if !nl.NSExists(GetNSPath(dstNS)) {
+			//the target LAN has no spoke on this node yet
+			desired, err := DesiredLAN(dst, hostname)
+			if err != nil {
+				return err
+			}
+			plan, err := desired.Plan()
+			if err != nil {
+				return err
+			}
+			if err := plan.Apply(event); err != nil {
+				return err
+			}
+		}
+		err := nl.InNS(GetNSPath(src), func() error {
+			link, err := nl.LinkByName(peerName)
+			if err != nil {
+				return fmt.Errorf("failed to find %v, %w", peerName, err)
+			}
+			if err := nl.LinkSetNS(link, GetNSPath(dstNS)); err != nil {
+				return fmt.Errorf("failed to move %v to namespace %v, %w", peerName, dstNS, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err := nl.InNS(GetNSPath(dstNS), func() error {
+		link, err := nl.LinkByName(peerName)
+		if err != nil {
+			return fmt.Errorf("failed to find %v, %w", peerName, err)
+		}
+		if master != "" {
+			m, err := nl.LinkByIndex(link.Attrs().MasterIndex)
+			if link.Attrs().MasterIndex == 0 || err != nil || m.Attrs().Name != master {
+				if err := setMaster(link, master); err != nil {
+					return err
+				}
+			}
+		}
+		isUp := link.Attrs().Flags&net.FlagUp != 0
+		if spokeDownReason(home.UID, &home.Spec, spoke) != "" {
+			//held down by its admin state or a fault
+			if isUp {
+				if err := nl.LinkSetDown(link); err != nil {
+					return fmt.Errorf("failed to bring %v down, %w", peerName, err)
+				}
+			}
+			return nil
+		}
+		if isUp {
+			return nil
+		}
+		if src != dstNS && flap > 0 {
+			time.Sleep(flap)
+		}
+		if err := nl.LinkSetUp(link); err != nil {
+			return fmt.Errorf("failed to bring %v up, %w", peerName, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if src == dstNS {
+		return nil
+	}
+	event.normal(ReasonSpokeMoved, "moved spoke %v from namespace %v to %v", spoke, src, dstNS)
+	//the tc wiring depends on the local ports, e.g. PAUSE frame filters of the target bridge,
+	//and the local ends of the home LAN in p2p mode
+	lans := []*v1beta1.LAN{dst}
+	if home != dst && home.Spec.IsP2P() {
+		lans = append(lans, home)
+	}
+	for _, lan := range lans {
+		plan, err := PlanDrift(lan, hostname)
+		if err != nil {
+			return err
+		}
+		if plan == nil {
+			continue
+		}
+		if err := plan.Apply(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseSpokes moves the bridge side veths of spokes bound to lan back to their home LANs,
+// it is called before the LAN NS of lan is removed; homes is all LANs keyed by UID,
+// a veth of a spoke whose home LAN is unknown or not on this node is left in place
+func ReleaseSpokes(lan *v1beta1.LAN, hostname string, homes map[types.UID]*v1beta1.LAN, event EventFunc) error {
+	nsPath := GetNSPath(*lan.Spec.NS)
+	if !nl.NSExists(nsPath) {
+		return nil
+	}
+	guests := []Owner{}
+	err := nl.InNS(nsPath, func() error {
+		links, err := nl.LinkList()
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			if o, ok := GetOwner(link); ok && o.Role == dataplane.RolePeer && o.LANUID != lan.UID && link.Attrs().Name == GetPeerVethName(o.Spoke) {
+				guests = append(guests, o)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list links in namespace %v, %w", *lan.Spec.NS, err)
+	}
+	for _, o := range guests {
+		home, ok := homes[o.LANUID]
+		if !ok || !home.DeletionTimestamp.IsZero() || !nl.NSExists(GetNSPath(*home.Spec.NS)) {
+			continue
+		}
+		if err := bindSpoke(*lan.Spec.NS, home, home, hostname, o.Spoke, 0, event); err != nil {
+			return fmt.Errorf("failed to move spoke %v back to LAN %v, %w", o.Spoke, home.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package interfaces
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestBindSpoke(t *testing.T) {
+	fake := setupFake(t)
+	lan1 := newTestLAN("lan1", "uid1", 100)
+	lan2 := newTestLAN("lan2", "uid2", 200)
+	if _, err := Ensure("mac1", "lan1s1", lan1, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Ensure("mac2", "lan2s1", lan2, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkAttached := func(nsname, bridge string) {
+		t.Helper()
+		peer := findLink(fake, GetNSPath(nsname), "lan1s1p")
+		if peer == nil {
+			t.Fatalf("lan1s1p is not in namespace %v", nsname)
+		}
+		br := findLink(fake, GetNSPath(nsname), bridge)
+		if peer.Attrs().MasterIndex != br.Attrs().Index || peer.Attrs().Flags&net.FlagUp == 0 {
+			t.Errorf("lan1s1p is not up or attached to %v", bridge)
+		}
+		checkOwner(t, peer, Owner{LANUID: "uid1", Role: dataplane.RolePeer, Spoke: "lan1s1"})
+	}
+	//a spoke not on this node
+	if found, _, err := BindSpoke(lan1, lan2, testHost, "lan1s2", 0, nil); err != nil || found {
+		t.Fatalf("unexpected result of binding a spoke not on this node, %v %v", found, err)
+	}
+	found, moved, err := BindSpoke(lan1, lan2, testHost, "lan1s1", 0, nil)
+	if err != nil || !found || !moved {
+		t.Fatalf("failed to bind spoke, %v %v %v", found, moved, err)
+	}
+	checkAttached("lan2", "br-lan2")
+	if findLink(fake, GetNSPath("lan1"), "lan1s1p") != nil {
+		t.Error("lan1s1p is left in lan1")
+	}
+	if findLink(fake, "", "lan1s1") == nil {
+		t.Error("spoke side of the veth is changed")
+	}
+	//nothing to do once bound
+	if found, moved, err := BindSpoke(lan1, lan2, testHost, "lan1s1", 0, nil); err != nil || !found || moved {
+		t.Fatalf("unexpected result of binding a bound spoke, %v %v %v", found, moved, err)
+	}
+	//drift repair of the home LAN leaves the bound spoke alone
+	if _, err := RepairDrift(lan1, testHost, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkAttached("lan2", "br-lan2")
+
+	//released before lan2 is removed
+	homes := map[types.UID]*v1beta1.LAN{"uid1": lan1, "uid2": lan2}
+	if err := ReleaseSpokes(lan2, testHost, homes, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkAttached("lan1", "br-lan1")
+	if findLink(fake, GetNSPath("lan2"), "lan2s1p") == nil {
+		t.Error("spoke of lan2 is released")
+	}
+
+	//unbind
+	if _, _, err := BindSpoke(lan1, lan2, testHost, "lan1s1", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, moved, err := BindSpoke(lan1, nil, testHost, "lan1s1", 0, nil); err != nil || !moved {
+		t.Fatalf("failed to unbind spoke, %v %v", moved, err)
+	}
+	checkAttached("lan1", "br-lan1")
+
+	//the LAN NS of the target is created on demand
+	lan3 := newTestLAN("lan3", "uid3", 300)
+	if _, _, err := BindSpoke(lan1, lan3, testHost, "lan1s1", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkAttached("lan3", "br-lan3")
+}
@@ -0,0 +1,76 @@
+package interfaces
+
+import (
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"github.com/vishvananda/netlink"
+)
+
+// LinkStats is the counters of an interface in the LAN NS
+type LinkStats struct {
+	Name string
+	// one of dataplane.RoleBridge, dataplane.RoleVxLAN or dataplane.RolePeer
+	Role string
+	// the spoke name if Role is RolePeer
+	Spoke string
+	netlink.LinkStatistics
+}
+
+// LANStats is the statistics of a LAN on this node
+type LANStats struct {
+	Links []LinkStats
+	// number of entries in the bridge FDB
+	FDBEntries int
+	// number of dynamically learned MAC in the bridge FDB
+	LearnedMACs int
+}
+
+// GetLANStats returns statistics of lan on this node, nil if the LAN NS doesn't exist on this node
+func GetLANStats(lan *v1beta1.LANSpec) (*LANStats, error) {
+	nsPath := GetNSPath(*lan.NS)
+	if !nl.NSExists(nsPath) {
+		return nil, nil
+	}
+	r := &LANStats{}
+	err := nl.InNS(nsPath, func() error {
+		add := func(name, role, spoke string) netlink.Link {
+			link, err := nl.LinkByName(name)
+			if err != nil || link.Attrs().Statistics == nil {
+				return nil
+			}
+			r.Links = append(r.Links, LinkStats{
+				Name:           name,
+				Role:           role,
+				Spoke:          spoke,
+				LinkStatistics: *link.Attrs().Statistics,
+			})
+			return link
+		}
+		add(*lan.VxLANName, dataplane.RoleVxLAN, "")
+		for _, spoke := range lan.SpokeList {
+			add(GetPeerVethName(spoke), dataplane.RolePeer, spoke)
+		}
+		if lan.IsP2P() {
+			return nil
+		}
+		br := add(*lan.BridgeName, dataplane.RoleBridge, "")
+		if br == nil {
+			return nil
+		}
+		neighs, err := listBridgeFDB(br)
+		if err != nil {
+			return err
+		}
+		r.FDBEntries = len(neighs)
+		for _, n := range neighs {
+			if isLearnedFDB(n) {
+				r.LearnedMACs++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
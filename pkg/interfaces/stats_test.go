@@ -0,0 +1,50 @@
+package interfaces
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestGetLANStats(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	if stats, err := GetLANStats(&lan.Spec); stats != nil || err != nil {
+		t.Errorf("statistics of a LAN not on this node are %+v, %v", stats, err)
+	}
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	nsPath := GetNSPath("lan1")
+	findLink(fake, nsPath, "lan1s1p").Attrs().Statistics.RxBytes = 1000
+	br := findLink(fake, nsPath, "br-lan1")
+	mac1, _ := net.ParseMAC("02:00:00:00:01:01")
+	mac2, _ := net.ParseMAC("02:00:00:00:01:02")
+	fake.AddNeigh(nsPath, netlink.Neigh{LinkIndex: br.Attrs().Index, MasterIndex: br.Attrs().Index, Family: unix.AF_BRIDGE,
+		State: netlink.NUD_PERMANENT, HardwareAddr: mac1})
+	fake.AddNeigh(nsPath, netlink.Neigh{LinkIndex: br.Attrs().Index, MasterIndex: br.Attrs().Index, Family: unix.AF_BRIDGE,
+		State: netlink.NUD_REACHABLE, HardwareAddr: mac2})
+	stats, err := GetLANStats(&lan.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//lan1s2 is not on this node
+	expected := map[string]string{"vx-lan1": dataplane.RoleVxLAN, "lan1s1p": dataplane.RolePeer, "br-lan1": dataplane.RoleBridge}
+	if len(stats.Links) != len(expected) {
+		t.Errorf("got statistics of %+v", stats.Links)
+	}
+	for _, s := range stats.Links {
+		if expected[s.Name] != s.Role {
+			t.Errorf("%v has role %v", s.Name, s.Role)
+		}
+		if s.Name == "lan1s1p" && (s.Spoke != "lan1s1" || s.RxBytes != 1000) {
+			t.Errorf("unexpected statistics of lan1s1p %+v", s)
+		}
+	}
+	if stats.FDBEntries != 2 || stats.LearnedMACs != 1 {
+		t.Errorf("fdb has %v entries and %v learned MACs", stats.FDBEntries, stats.LearnedMACs)
+	}
+}
@@ -0,0 +1,123 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// tc filter priorities, filters with lower value are evaluated first
+const (
+	// tc filter priority used for redirecting probe frames
+	probeFilterPrio = 5
+	// port mirroring filters use mirrorFilterPrioBase+<mirror index>
+	mirrorFilterPrioBase = 10
+	// tc filter priority used for redirecting all ingress traffic of a link
+	redirectFilterPrio = 100
+	// tc filter priority used for forwarding PAUSE frames
+	pauseFilterPrio = 101
+)
+
+// ensureClsact adds a clsact qdisc to link if it doesn't have one yet
+func ensureClsact(link netlink.Link) error {
+	qdisc := &netlink.GenericQdisc{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: link.Attrs().Index,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_CLSACT,
+		},
+		QdiscType: "clsact",
+	}
+	err := nl.QdiscAdd(qdisc)
+	if err != nil && !errors.Is(err, syscall.EEXIST) {
+		return fmt.Errorf("failed to add clsact qdisc to %v, %w", link.Attrs().Name, err)
+	}
+	return nil
+}
+
+func newMatchAll(link netlink.Link, parent uint32, prio uint16, actions ...netlink.Action) *netlink.MatchAll {
+	return &netlink.MatchAll{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: link.Attrs().Index,
+			Parent:    parent,
+			Priority:  prio,
+			Protocol:  unix.ETH_P_ALL,
+		},
+		Actions: actions,
+	}
+}
+
+// redirectIngress redirects all traffic received on link to egress of dst,
+// replacing existing redirect on link if any
+func redirectIngress(link, dst netlink.Link) error {
+	if err := ensureClsact(link); err != nil {
+		return err
+	}
+	filter := newMatchAll(link, netlink.HANDLE_MIN_INGRESS, redirectFilterPrio,
+		netlink.NewMirredAction(dst.Attrs().Index))
+	if err := nl.FilterReplace(filter); err != nil {
+		return fmt.Errorf("failed to redirect %v to %v, %w", link.Attrs().Name, dst.Attrs().Name, err)
+	}
+	return nil
+}
+
+// clearIngressRedirect removes the redirect created by redirectIngress on link, if any
+func clearIngressRedirect(link netlink.Link) error {
+	filter := newMatchAll(link, netlink.HANDLE_MIN_INGRESS, redirectFilterPrio)
+	err := nl.FilterDel(filter)
+	if err != nil && !errors.Is(err, syscall.ENOENT) && !errors.Is(err, syscall.EINVAL) {
+		return fmt.Errorf("failed to remove redirect on %v, %w", link.Attrs().Name, err)
+	}
+	return nil
+}
+
+// getRedirectTarget returns the index of the link all traffic received on link is redirected to, 0 if it is not redirected
+func getRedirectTarget(link netlink.Link) int {
+	filters, err := nl.FilterList(link, netlink.HANDLE_MIN_INGRESS)
+	if err != nil {
+		//no clsact qdisc
+		return 0
+	}
+	for _, f := range filters {
+		m, ok := f.(*netlink.MatchAll)
+		if !ok || f.Attrs().Priority != redirectFilterPrio {
+			continue
+		}
+		for _, a := range m.Actions {
+			if mirred, ok := a.(*netlink.MirredAction); ok {
+				return mirred.Ifindex
+			}
+		}
+	}
+	return 0
+}
+
+// diffRedirect adds the step to make the redirect of link match l
+func (p *Plan) diffRedirect(link netlink.Link, l *LinkSpec) {
+	actual := getRedirectTarget(link)
+	if l.TC.Redirect == "" {
+		if actual != 0 {
+			p.add(OpSetRedirect, l, "it is redirected to the link with index %d", actual)
+		}
+		return
+	}
+	target, err := nl.LinkByName(l.TC.Redirect)
+	if err != nil || p.creates(l.TC.Redirect) || target.Attrs().Index != actual {
+		p.add(OpSetRedirect, l, "it is not redirected to %v", l.TC.Redirect)
+	}
+}
+
+// setRedirect makes the redirect of link match l, it must be called in the NS of l
+func setRedirect(link netlink.Link, l *LinkSpec) error {
+	if l.TC.Redirect == "" {
+		return clearIngressRedirect(link)
+	}
+	target, err := nl.LinkByName(l.TC.Redirect)
+	if err != nil {
+		return fmt.Errorf("failed to find %v, %w", l.TC.Redirect, err)
+	}
+	return redirectIngress(link, target)
+}
@@ -0,0 +1,281 @@
+package interfaces
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/vishvananda/netlink"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// driftOps returns the steps of the drift plan of lan, as "<op> <link name>"
+func driftOps(t *testing.T, lan *v1beta1.LAN) []string {
+	t.Helper()
+	plan, err := PlanDrift(lan, testHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := []string{}
+	for _, s := range plan.Steps {
+		r = append(r, string(s.Op)+" "+s.Link.Name)
+	}
+	slices.Sort(r)
+	return r
+}
+
+func checkDriftOps(t *testing.T, lan *v1beta1.LAN, expected ...string) {
+	t.Helper()
+	slices.Sort(expected)
+	if got := driftOps(t, lan); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("drift steps are %v, expect %v", got, expected)
+	}
+}
+
+func repair(t *testing.T, lan *v1beta1.LAN) {
+	t.Helper()
+	if _, err := RepairDrift(lan, testHost, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// inNS runs f with the named links in the LAN NS nsname
+func inNS(t *testing.T, fake *FakeNetlinker, nsname string, f func(links map[string]netlink.Link)) {
+	t.Helper()
+	links := map[string]netlink.Link{}
+	for _, l := range fake.Links(GetNSPath(nsname)) {
+		links[l.Attrs().Name] = l
+	}
+	if err := fake.InNS(GetNSPath(nsname), func() error { f(links); return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanImpairment(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	lan.Spec.Impairment = &v1beta1.Impairment{Delay: &metav1.Duration{Duration: 10 * time.Millisecond}}
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkNetem := func(expected bool) {
+		t.Helper()
+		inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+			netem, _, err := getImpairmentQdiscs(links["lan1s1p"])
+			if err != nil || (netem != nil) != expected {
+				t.Errorf("netem qdisc is %v, %v, expect found %v", netem, err, expected)
+			}
+		})
+	}
+	checkNetem(true)
+	checkDriftOps(t, lan)
+	//live change is shown in the drift plan
+	lan.Spec.Impairment.Loss = "10"
+	checkDriftOps(t, lan, "set impairment of lan1s1p")
+	repair(t, lan)
+	checkDriftOps(t, lan)
+	//so is a qdisc removed out of band
+	inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+		if err := clearImpairment(links["lan1s1p"]); err != nil {
+			t.Fatal(err)
+		}
+	})
+	checkDriftOps(t, lan, "set impairment of lan1s1p")
+	repair(t, lan)
+	checkNetem(true)
+	lan.Spec.Impairment = nil
+	checkDriftOps(t, lan, "set impairment of lan1s1p")
+	repair(t, lan)
+	checkNetem(false)
+	checkDriftOps(t, lan)
+}
+
+func TestPlanP2PRedirects(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	lan.Spec.Mode = "p2p"
+	//an impaired spoke needs its bridge side veth, so the two spokes are not joined
+	lan.Spec.SpokeImpairments = map[string]v1beta1.Impairment{"lan1s2": {Delay: &metav1.Duration{Duration: time.Millisecond}}}
+	checkRedirects := func(expected map[string]string) {
+		t.Helper()
+		inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+			for from, to := range expected {
+				index := 0
+				if to != "" {
+					index = links[to].Attrs().Index
+				}
+				if got := getRedirectTarget(links[from]); got != index {
+					t.Errorf("%v is redirected to %d, expect %v", from, got, to)
+				}
+			}
+		})
+	}
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkRedirects(map[string]string{"lan1s1p": "vx-lan1", "vx-lan1": "lan1s1p"})
+	if _, err := Ensure("mac2", "lan1s2", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkRedirects(map[string]string{"lan1s1p": "lan1s2p", "lan1s2p": "lan1s1p", "vx-lan1": ""})
+	checkDriftOps(t, lan)
+	inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+		if err := clearIngressRedirect(links["lan1s2p"]); err != nil {
+			t.Fatal(err)
+		}
+	})
+	checkDriftOps(t, lan, "set redirect of lan1s2p")
+	repair(t, lan)
+	checkRedirects(map[string]string{"lan1s1p": "lan1s2p", "lan1s2p": "lan1s1p", "vx-lan1": ""})
+}
+
+func TestPlanP2PJoin(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	lan.Spec.Mode = "p2p"
+	nsPath := GetNSPath("lan1")
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkJoined := func() {
+		t.Helper()
+		s1, s2 := findLink(fake, "", "lan1s1"), findLink(fake, "", "lan1s2")
+		if s1 == nil || s2 == nil || fake.VethPeer(s1.Attrs().Index) != s2.Attrs().Index {
+			t.Fatalf("lan1s1 %v and lan1s2 %v are not joined by a veth pair", s1, s2)
+		}
+		checkOwner(t, s2, Owner{LANUID: "uid1", Role: RoleSpoke, Spoke: "lan1s2"})
+		if s2.Attrs().Flags&net.FlagUp == 0 {
+			t.Error("lan1s2 is not up")
+		}
+		for _, name := range []string{"lan1s1p", "lan1s2p"} {
+			if findLink(fake, nsPath, name) != nil {
+				t.Errorf("%v is still in the LAN ns", name)
+			}
+		}
+		inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+			if got := getRedirectTarget(links["vx-lan1"]); got != 0 {
+				t.Errorf("vx-lan1 is redirected to %d", got)
+			}
+		})
+		checkDriftOps(t, lan)
+	}
+	//the bridge side veth of lan1s1 becomes lan1s2
+	peer := findLink(fake, nsPath, "lan1s1p")
+	if _, err := Ensure("mac2", "lan1s2", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkJoined()
+	if s2 := findLink(fake, "", "lan1s2"); s2.Attrs().Index != peer.Attrs().Index {
+		t.Errorf("lan1s2 is not made of lan1s1p")
+	}
+	//reallocating a joined spoke, e.g. for a restarted VM, keeps the veth pair
+	for _, spoke := range []string{"lan1s1", "lan1s2"} {
+		index := findLink(fake, "", spoke).Attrs().Index
+		if _, err := Ensure("mac-"+spoke, spoke, lan, testHost, "passthru", false, nil); err != nil {
+			t.Fatal(err)
+		}
+		if findLink(fake, "", spoke).Attrs().Index != index {
+			t.Errorf("joined %v is recreated", spoke)
+		}
+		checkJoined()
+	}
+}
+
+func TestPlanMirrors(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	lan.Spec.Mirrors = []v1beta1.Mirror{{Name: "m1", Sources: []string{"lan1s1"}, Destination: "lan1s2", VNI: 900}}
+	checkMirrors := func(src string, expected ...string) {
+		t.Helper()
+		inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+			got := []string{}
+			for _, f := range getMirrorFilters(links[src]) {
+				for _, a := range f.(*netlink.MatchAll).Actions {
+					index := a.(*netlink.MirredAction).Ifindex
+					for name, l := range links {
+						if l.Attrs().Index == index {
+							got = append(got, name)
+						}
+					}
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(expected, ",") {
+				t.Errorf("traffic of %v is mirrored to %v, expect %v", src, got, expected)
+			}
+		})
+	}
+	//destination is on another node
+	if _, err := Ensure("mac1", "lan1s1", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	mirrorLink := findLink(fake, GetNSPath("lan1"), "mirror900")
+	if mirrorLink == nil {
+		t.Fatal("mirror vxlan interface is not created")
+	}
+	checkOwner(t, mirrorLink, Owner{LANUID: "uid1", Role: RoleMirror, Spoke: "m1"})
+	checkMirrors("lan1s1p", "mirror900", "mirror900")
+	checkDriftOps(t, lan)
+	//destination is local
+	if _, err := Ensure("mac2", "lan1s2", lan, testHost, "passthru", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkMirrors("lan1s1p", "lan1s2p", "lan1s2p")
+	checkDriftOps(t, lan)
+	lan.Spec.Mirrors[0].Direction = v1beta1.MirrorDirectionEgress
+	checkDriftOps(t, lan, "set mirrors of lan1s1p")
+	repair(t, lan)
+	checkMirrors("lan1s1p", "lan1s2p")
+	//removed mirror
+	lan.Spec.Mirrors = nil
+	checkDriftOps(t, lan, "set mirrors of lan1s1p", "delete mirror900")
+	repair(t, lan)
+	checkMirrors("lan1s1p")
+	if findLink(fake, GetNSPath("lan1"), "mirror900") != nil {
+		t.Error("mirror vxlan interface is not removed")
+	}
+	checkDriftOps(t, lan)
+}
+
+func TestPlanTransparency(t *testing.T) {
+	fake := setupFake(t)
+	lan := newTestLAN("lan1", "uid1", 100)
+	lan.Spec.Transparency = v1beta1.TransparencyFull
+	checkTransparency := func(full bool) {
+		t.Helper()
+		inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+			opts, err := fake.BridgeOptions(links["br-lan1"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts != wantBridgeOptions(full, opts) {
+				t.Errorf("bridge options are %+v, full transparency is %v", opts, full)
+			}
+			for _, name := range []string{"vx-lan1", "lan1s1p", "lan1s2p"} {
+				if (getPauseFilter(links[name]) != nil) != full {
+					t.Errorf("PAUSE frame filter on %v is %v, full transparency is %v", name, getPauseFilter(links[name]), full)
+				}
+			}
+		})
+	}
+	for _, spoke := range []string{"lan1s1", "lan1s2"} {
+		if _, err := Ensure("mac-"+spoke, spoke, lan, testHost, "passthru", false, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkTransparency(true)
+	checkDriftOps(t, lan)
+	//PAUSE frames of an existing port are forwarded to a new port
+	inNS(t, fake, "lan1", func(links map[string]netlink.Link) {
+		if targets := getPauseTargets(links["lan1s1p"]); len(targets) != 2 {
+			t.Errorf("PAUSE frames of lan1s1p are forwarded to %v, expect 2 ports", targets)
+		}
+	})
+	lan.Spec.Transparency = ""
+	checkDriftOps(t, lan, "set transparency of br-lan1")
+	repair(t, lan)
+	checkTransparency(false)
+	checkDriftOps(t, lan)
+}
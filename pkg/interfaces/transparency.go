@@ -0,0 +1,245 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	nlattr "github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// bridge level group_fwd_mask, kernel refuses to set 01:80:C2:00:00:00-02 (BR_GROUPFWD_RESTRICTED)
+	BRGrpFwdMask = 0xfff8
+)
+
+// PAUSE frames (01:80:C2:00:00:01) are always dropped by linux bridge regardless of group_fwd_mask
+var pauseMAC = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x01}
+
+// setBridgeSTP sets STP state of bridge br,
+// netlink lib doesn't support IFLA_BR_STP_STATE so the request is built here
+func setBridgeSTP(br netlink.Link, on bool) error {
+	state := uint32(0)
+	if on {
+		state = 1
+	}
+	req := nlattr.NewNetlinkRequest(unix.RTM_NEWLINK, unix.NLM_F_ACK)
+	msg := nlattr.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Index = int32(br.Attrs().Index)
+	req.AddData(msg)
+	linkInfo := nlattr.NewRtAttr(unix.IFLA_LINKINFO, nil)
+	linkInfo.AddRtAttr(nlattr.IFLA_INFO_KIND, nlattr.NonZeroTerminated("bridge"))
+	data := linkInfo.AddRtAttr(nlattr.IFLA_INFO_DATA, nil)
+	data.AddRtAttr(nlattr.IFLA_BR_STP_STATE, nlattr.Uint32Attr(state))
+	req.AddData(linkInfo)
+	_, err := req.Execute(unix.NETLINK_ROUTE, 0)
+	return err
+}
+
+// getBridgeSTP returns true if STP is enabled on bridge br,
+// netlink lib doesn't parse IFLA_BR_STP_STATE so the response is parsed here
+func getBridgeSTP(br netlink.Link) (bool, error) {
+	req := nlattr.NewNetlinkRequest(unix.RTM_GETLINK, unix.NLM_F_ACK)
+	msg := nlattr.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Index = int32(br.Attrs().Index)
+	req.AddData(msg)
+	msgs, err := req.Execute(unix.NETLINK_ROUTE, unix.RTM_NEWLINK)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range msgs {
+		attrs, err := nlattr.ParseRouteAttr(m[unix.SizeofIfInfomsg:])
+		if err != nil {
+			return false, err
+		}
+		for _, info := range findAttrs(attrs, unix.IFLA_LINKINFO, nlattr.IFLA_INFO_DATA) {
+			for _, a := range info {
+				if a.Attr.Type == nlattr.IFLA_BR_STP_STATE {
+					return nlattr.NativeEndian().Uint32(a.Value) != 0, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// findAttrs returns the nested attributes of attrs following the path of types
+func findAttrs(attrs []syscall.NetlinkRouteAttr, path ...uint16) [][]syscall.NetlinkRouteAttr {
+	if len(path) == 0 {
+		return [][]syscall.NetlinkRouteAttr{attrs}
+	}
+	r := [][]syscall.NetlinkRouteAttr{}
+	for _, a := range attrs {
+		if a.Attr.Type&^unix.NLA_F_NESTED != path[0] {
+			continue
+		}
+		nested, err := nlattr.ParseRouteAttr(a.Value)
+		if err == nil {
+			r = append(r, findAttrs(nested, path[1:]...)...)
+		}
+	}
+	return r
+}
+
+// wantBridgeOptions returns the bridge level attributes of a bridge with full transparency or not,
+// STP is only managed with full transparency
+func wantBridgeOptions(full bool, actual BridgeOptions) BridgeOptions {
+	if full {
+		return BridgeOptions{GroupFwdMask: BRGrpFwdMask}
+	}
+	return BridgeOptions{MulticastSnooping: true, STP: actual.STP}
+}
+
+// getBridgePorts returns links attached to br in current NS
+func getBridgePorts(br netlink.Link) ([]netlink.Link, error) {
+	links, err := nl.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces, %w", err)
+	}
+	ports := []netlink.Link{}
+	for _, l := range links {
+		if l.Attrs().MasterIndex == br.Attrs().Index {
+			ports = append(ports, l)
+		}
+	}
+	return ports, nil
+}
+
+// getPauseFilter returns the PAUSE frame filter on port, nil if there is none
+func getPauseFilter(port netlink.Link) netlink.Filter {
+	filters, err := nl.FilterList(port, netlink.HANDLE_MIN_INGRESS)
+	if err != nil {
+		//no clsact qdisc
+		return nil
+	}
+	for _, f := range filters {
+		if f.Attrs().Priority == pauseFilterPrio {
+			return f
+		}
+	}
+	return nil
+}
+
+// getPauseTargets returns the sorted indexes of links the PAUSE frames received on port are mirrored to
+func getPauseTargets(port netlink.Link) []int {
+	r := []int{}
+	if f, ok := getPauseFilter(port).(*netlink.Flower); ok {
+		for _, a := range f.Actions {
+			if m, ok := a.(*netlink.MirredAction); ok {
+				r = append(r, m.Ifindex)
+			}
+		}
+	}
+	slices.Sort(r)
+	return r
+}
+
+// diffTransparency adds the step to make the bridge attributes and PAUSE frame filters of bridge l match,
+// ports are the desired links attached to l
+func (p *Plan) diffTransparency(br netlink.Link, l *LinkSpec, ports []string) error {
+	full := l.TC.FullTransparency
+	opts, err := nl.BridgeOptions(br)
+	if err != nil {
+		return fmt.Errorf("failed to get options of bridge %v, %w", l.Name, err)
+	}
+	if opts != wantBridgeOptions(full, opts) {
+		p.add(OpSetTransparency, l, "the existing bridge options are %+v", opts)
+		return nil
+	}
+	actual, err := getBridgePorts(br)
+	if err != nil {
+		return err
+	}
+	if !full {
+		for _, port := range actual {
+			if getPauseFilter(port) != nil {
+				p.add(OpSetTransparency, l, "%v has a PAUSE frame filter", port.Attrs().Name)
+				return nil
+			}
+		}
+		return nil
+	}
+	for _, name := range ports {
+		if p.creates(name) || !slices.ContainsFunc(actual, func(port netlink.Link) bool { return port.Attrs().Name == name }) {
+			p.add(OpSetTransparency, l, "%v is a new port", name)
+			return nil
+		}
+	}
+	for _, port := range actual {
+		want := []int{}
+		for _, other := range actual {
+			if other.Attrs().Index != port.Attrs().Index {
+				want = append(want, other.Attrs().Index)
+			}
+		}
+		slices.Sort(want)
+		if !slices.Equal(getPauseTargets(port), want) {
+			p.add(OpSetTransparency, l, "PAUSE frames on %v are not forwarded to all other ports", port.Attrs().Name)
+			return nil
+		}
+	}
+	return nil
+}
+
+// setTransparency sets the bridge attributes of bridge br, and the PAUSE frame filters on all its ports;
+// with full transparency, br forwards all link-local control frames, it must be called after all local ports are attached
+func setTransparency(br netlink.Link, full bool) error {
+	actual, err := nl.BridgeOptions(br)
+	if err != nil {
+		return fmt.Errorf("failed to get options of bridge %v, %w", br.Attrs().Name, err)
+	}
+	if err := nl.BridgeSetOptions(br, wantBridgeOptions(full, actual)); err != nil {
+		return fmt.Errorf("failed to set options of bridge %v, %w", br.Attrs().Name, err)
+	}
+	ports, err := getBridgePorts(br)
+	if err != nil {
+		return err
+	}
+	for _, port := range ports {
+		if !full {
+			f := getPauseFilter(port)
+			if f == nil {
+				continue
+			}
+			if err := nl.FilterDel(f); err != nil && !errors.Is(err, syscall.ENOENT) {
+				return fmt.Errorf("failed to remove PAUSE frame filter on %v, %w", port.Attrs().Name, err)
+			}
+			continue
+		}
+		//the kernel drops PAUSE frames, mirror them to all other ports with tc instead
+		if err := ensureClsact(port); err != nil {
+			return err
+		}
+		actions := []netlink.Action{}
+		for _, other := range ports {
+			if other.Attrs().Index == port.Attrs().Index {
+				continue
+			}
+			mirror := netlink.NewMirredAction(other.Attrs().Index)
+			mirror.MirredAction = netlink.TCA_EGRESS_MIRROR
+			mirror.Action = netlink.TC_ACT_PIPE
+			actions = append(actions, mirror)
+		}
+		actions = append(actions, &netlink.GenericAction{
+			ActionAttrs: netlink.ActionAttrs{Action: netlink.TC_ACT_SHOT},
+		})
+		filter := &netlink.Flower{
+			FilterAttrs: netlink.FilterAttrs{
+				LinkIndex: port.Attrs().Index,
+				Parent:    netlink.HANDLE_MIN_INGRESS,
+				Priority:  pauseFilterPrio,
+				Protocol:  unix.ETH_P_ALL,
+			},
+			DestMac: pauseMAC,
+			Actions: actions,
+		}
+		if err := nl.FilterReplace(filter); err != nil {
+			return fmt.Errorf("failed to add PAUSE frame filter on %v, %w", port.Attrs().Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package interfaces
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/hujun-open/k8slan/api/v1beta1"
+	"github.com/hujun-open/k8slan/pkg/dataplane"
+	"github.com/vishvananda/netlink"
+)
+
+// types of tunnel links between workers, same as netlink.Link.Type()
+const (
+	linkTypeGeneve    = "geneve"
+	linkTypeGRETap    = "gretap"
+	linkTypeIP6GRETap = "ip6gretap"
+)
+
+// max encapsulation overhead of other encapsulations than vxlan, including outer ethernet header with a VLAN tag
+const (
+	// IPv6 40 bytes, UDP 8 bytes, geneve 8 bytes without options
+	maxGeneveEncapOverhead = 74
+	// IPv4 20 bytes, GRE 8 bytes with key
+	maxGRETapEncapOverhead = 46
+	// IPv6 40 bytes, GRE 8 bytes with key
+	maxIP6GRETapEncapOverhead = 66
+)
+
+// isTunnelType returns true if t is the link type of any encapsulation
+func isTunnelType(t string) bool {
+	switch t {
+	case linkTypeVxLAN, linkTypeGeneve, linkTypeGRETap, linkTypeIP6GRETap:
+		return true
+	}
+	return false
+}
+
+// tunnelMTU returns MTU of the tunnel interface and links in the LAN NS, based on MTU of the underlying interface
+func tunnelMTU(lan *v1beta1.LANSpec, devMTU int) int {
+	overhead := maxVxLANEncapOverhead
+	switch lan.GetEncapsulation() {
+	case v1beta1.EncapGeneve:
+		overhead = maxGeneveEncapOverhead
+	case v1beta1.EncapGRETap:
+		overhead = maxGRETapEncapOverhead
+	case v1beta1.EncapIP6GRETap:
+		overhead = maxIP6GRETapEncapOverhead
+	}
+	mtu := devMTU - overhead
+	if lan.Encryption != nil {
+		mtu -= maxESPOverhead
+	}
+	return mtu
+}
+
+// desiredTunnel returns the tunnel link of lan on this node, the link type is the encapsulation
+func desiredTunnel(lanCR *v1beta1.LAN, hostname string, vxDev netlink.Link, mtu int, master string) (*LinkSpec, error) {
+	lan := &lanCR.Spec
+	l := &LinkSpec{
+		Name:         *lan.VxLANName,
+		Type:         lan.GetEncapsulation(),
+		InLANNS:      true,
+		MTU:          mtu,
+		Master:       master,
+		Owner:        Owner{LANUID: lanCR.UID, Role: dataplane.RoleVxLAN},
+		VNI:          int(*lan.VNI),
+		Remote:       netip.MustParseAddr(*lan.VxLANGrp),
+		Port:         int(*lan.VxPort),
+		TTL:          lan.GetTTL(),
+		Learning:     lan.IsLearning(),
+		Proxy:        lan.IsARPProxy(),
+		VtepDev:      vxDev.Attrs().Name,
+		VtepDevIndex: vxDev.Attrs().Index,
+	}
+	var err error
+	switch l.Type {
+	case linkTypeGRETap:
+		//a multicast GRE tunnel requires a source address
+		if l.Local, err = GetVTEPAddr(lan, hostname); err != nil {
+			return nil, err
+		}
+	case linkTypeGeneve, linkTypeIP6GRETap:
+		if l.Local, l.Remote, err = lan.GetTunnelEndpoints(hostname); err != nil {
+			return nil, err
+		}
+	}
+	switch l.Type {
+	case linkTypeGRETap, linkTypeIP6GRETap:
+		l.Port = 0
+	}
+	return l, nil
+}
+
+// ensureTunnelIf creates the tunnel link of l in host NS and moves it to the NS of nsPath,
+// so that the underlying interface in host NS is used
+func ensureTunnelIf(l *LinkSpec, nsPath string) error {
+	la := netlink.LinkAttrs{
+		Name:        l.Name,
+		MTU:         l.MTU,
+		TxQLen:      1024,
+		NumTxQueues: 1,
+		NumRxQueues: 1,
+	}
+	switch l.Type {
+	case linkTypeVxLAN:
+		if !l.Remote.IsMulticast() {
+			//unicast to a single remote, e.g. the gateway of a peered cluster
+			return nl.LinkAddToNS(&netlink.Vxlan{
+				LinkAttrs:    la,
+				VxlanId:      l.VNI,
+				VtepDevIndex: l.VtepDevIndex,
+				Group:        l.Remote.AsSlice(),
+				Learning:     l.Learning,
+				Age:          3600,
+				Port:         l.Port,
+				TTL:          l.TTL,
+			}, nsPath)
+		}
+		return ensureVXLANIf(l.Name, l.VtepDevIndex, nsPath, l.VNI, l.Remote, uint32(l.MTU), l.Port, l.TTL, l.Learning, l.Proxy)
+	case linkTypeGeneve:
+		return nl.LinkAddToNS(&netlink.Geneve{
+			LinkAttrs: la,
+			ID:        uint32(l.VNI),
+			Remote:    l.Remote.AsSlice(),
+			Dport:     uint16(l.Port),
+			Ttl:       uint8(l.TTL),
+		}, nsPath)
+	case linkTypeGRETap, linkTypeIP6GRETap:
+		return nl.LinkAddToNS(&netlink.Gretap{
+			LinkAttrs: la,
+			IKey:      uint32(l.VNI),
+			OKey:      uint32(l.VNI),
+			Local:     l.Local.AsSlice(),
+			Remote:    l.Remote.AsSlice(),
+			Link:      uint32(l.VtepDevIndex),
+			Ttl:       uint8(l.TTL),
+			//a fixed TTL requires path MTU discovery
+			PMtuDisc: 1,
+		}, nsPath)
+	}
+	return fmt.Errorf("unknown tunnel type %v", l.Type)
+}
+
+// sameAddr returns true if ip is addr
+func sameAddr(ip net.IP, addr netip.Addr) bool {
+	a, ok := netip.AddrFromSlice(ip)
+	return ok && a.Unmap() == addr.Unmap()
+}
+
+// tunnelMismatches returns the config of the existing tunnel link not matching l, link is of the same type as l
+func tunnelMismatches(link netlink.Link, l *LinkSpec) []string {
+	r := []string{}
+	check := func(name string, ok bool, actual any) {
+		if !ok {
+			r = append(r, fmt.Sprintf("%v %v", name, actual))
+		}
+	}
+	switch t := link.(type) {
+	case *netlink.Vxlan:
+		check("group addr", sameAddr(t.Group, l.Remote), t.Group)
+		check("vni", t.VxlanId == l.VNI, t.VxlanId)
+		check("dev index", t.VtepDevIndex == l.VtepDevIndex, t.VtepDevIndex)
+		check("port", t.Port == l.Port, t.Port)
+		check("ttl", t.TTL == l.TTL, t.TTL)
+		check("learning setting", t.Learning == l.Learning, t.Learning)
+		check("proxy setting", t.Proxy == l.Proxy, t.Proxy)
+	case *netlink.Geneve:
+		check("remote addr", sameAddr(t.Remote, l.Remote), t.Remote)
+		check("vni", int(t.ID) == l.VNI, t.ID)
+		check("port", int(t.Dport) == l.Port, t.Dport)
+		check("ttl", int(t.Ttl) == l.TTL, t.Ttl)
+	case *netlink.Gretap:
+		check("remote addr", sameAddr(t.Remote, l.Remote), t.Remote)
+		check("local addr", sameAddr(t.Local, l.Local), t.Local)
+		check("key", int(t.IKey) == l.VNI && int(t.OKey) == l.VNI, t.IKey)
+		check("dev index", int(t.Link) == l.VtepDevIndex, t.Link)
+		check("ttl", int(t.Ttl) == l.TTL, t.Ttl)
+	}
+	return r
+}
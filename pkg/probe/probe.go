@@ -0,0 +1,268 @@
+// Package probe implements reachability probe between workers of a LAN,
+// with Ethernet frames sent and received on AF_PACKET socket inside the LAN network namespace
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// EtherType of probe frames, IEEE 802 local experimental ethertype 1
+	EtherType = 0x88B5
+	// socket read timeout, so that cancellation is checked periodically
+	readTimeout = 500 * time.Millisecond
+	// interval between probe frames of a round
+	frameInterval = 100 * time.Millisecond
+	maxFrameLen   = 1514
+	ethHeaderLen  = 14
+	// max length of node name in probe frames
+	maxNodeNameLen = 255
+)
+
+const (
+	msgRequest = 1
+	msgReply   = 2
+)
+
+var (
+	magic        = []byte("KLPR")
+	broadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+// message is the payload of a probe frame
+type message struct {
+	Type uint8
+	// id of the LANProbe, frames of other LANProbes are ignored
+	ProbeID uint32
+	Seq     uint32
+	// send time of the request in unix nano, a reply echos the one of the request
+	Timestamp int64
+	// node sending the frame
+	Node string
+}
+
+// marshal returns the Ethernet frame of m
+func (m *message) marshal(dst, src net.HardwareAddr) []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(dst)
+	buf.Write(src)
+	binary.Write(buf, binary.BigEndian, uint16(EtherType))
+	buf.Write(magic)
+	binary.Write(buf, binary.BigEndian, m.Type)
+	binary.Write(buf, binary.BigEndian, m.ProbeID)
+	binary.Write(buf, binary.BigEndian, m.Seq)
+	binary.Write(buf, binary.BigEndian, m.Timestamp)
+	node := m.Node
+	if len(node) > maxNodeNameLen {
+		node = node[:maxNodeNameLen]
+	}
+	buf.WriteByte(uint8(len(node)))
+	buf.WriteString(node)
+	return buf.Bytes()
+}
+
+// parseMessage parses an Ethernet frame, returns the message and the source MAC
+func parseMessage(frame []byte) (*message, net.HardwareAddr, error) {
+	if len(frame) < ethHeaderLen+len(magic) {
+		return nil, nil, fmt.Errorf("frame too short")
+	}
+	src := net.HardwareAddr(frame[6:12])
+	r := bytes.NewReader(frame[ethHeaderLen:])
+	buf := make([]byte, len(magic))
+	r.Read(buf)
+	if !bytes.Equal(buf, magic) {
+		return nil, nil, fmt.Errorf("not a probe frame")
+	}
+	m := &message{}
+	for _, v := range []any{&m.Type, &m.ProbeID, &m.Seq, &m.Timestamp} {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return nil, nil, fmt.Errorf("invalid probe frame, %w", err)
+		}
+	}
+	nameLen, err := r.ReadByte()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid probe frame, %w", err)
+	}
+	name := make([]byte, nameLen)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, nil, fmt.Errorf("invalid node name in probe frame")
+	}
+	m.Node = string(name)
+	return m, src, nil
+}
+
+// PeerResult is the result of a probe round to a peer node
+type PeerResult struct {
+	Node     string
+	Sent     int
+	Received int
+	// average round trip time
+	RTT time.Duration
+}
+
+// Prober sends probe requests to and replies probe requests from other nodes
+type Prober struct {
+	// path of the network namespace
+	NSPath string
+	IfName string
+	// this node
+	Node    string
+	ProbeID uint32
+	fd      int
+	ifIndex int
+	mac     net.HardwareAddr
+	lock    *sync.Mutex
+	// current round, high 16 bits of request seq
+	round uint16
+	// RTT of replies of the current round, key is the node
+	rtts map[string][]time.Duration
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// Open creates the AF_PACKET socket in the network namespace
+func (p *Prober) Open() error {
+	netns, err := ns.GetNS(p.NSPath)
+	if err != nil {
+		return fmt.Errorf("failed to open ns %v, %w", p.NSPath, err)
+	}
+	defer netns.Close()
+	p.fd = -1
+	p.lock = new(sync.Mutex)
+	p.rtts = map[string][]time.Duration{}
+	err = netns.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(p.IfName)
+		if err != nil {
+			return fmt.Errorf("failed to find interface %v, %w", p.IfName, err)
+		}
+		p.ifIndex = link.Attrs().Index
+		p.mac = link.Attrs().HardwareAddr
+		p.fd, err = unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("failed to create packet socket, %w", err)
+		}
+		tv := unix.NsecToTimeval(readTimeout.Nanoseconds())
+		if err := unix.SetsockoptTimeval(p.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+			return fmt.Errorf("failed to set read timeout, %w", err)
+		}
+		return unix.Bind(p.fd, &unix.SockaddrLinklayer{
+			Protocol: htons(EtherType),
+			Ifindex:  p.ifIndex,
+		})
+	})
+	if err != nil {
+		if p.fd >= 0 {
+			unix.Close(p.fd)
+		}
+		return err
+	}
+	return nil
+}
+
+// Close closes the socket
+func (p *Prober) Close() {
+	unix.Close(p.fd)
+}
+
+func (p *Prober) send(dst net.HardwareAddr, m *message) error {
+	addr := &unix.SockaddrLinklayer{
+		Protocol: htons(EtherType),
+		Ifindex:  p.ifIndex,
+		Halen:    uint8(len(dst)),
+	}
+	copy(addr.Addr[:], dst)
+	return unix.Sendto(p.fd, m.marshal(dst, p.mac), 0, addr)
+}
+
+// Serve replies probe requests and records probe replies until ctx is done
+func (p *Prober) Serve(ctx context.Context) error {
+	buf := make([]byte, maxFrameLen)
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(p.fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return fmt.Errorf("failed to read from interface %v, %w", p.IfName, err)
+		}
+		m, src, err := parseMessage(buf[:n])
+		if err != nil || m.ProbeID != p.ProbeID || m.Node == p.Node {
+			continue
+		}
+		switch m.Type {
+		case msgRequest:
+			reply := *m
+			reply.Type = msgReply
+			reply.Node = p.Node
+			p.send(src, &reply)
+		case msgReply:
+			p.lock.Lock()
+			if uint16(m.Seq>>16) == p.round {
+				p.rtts[m.Node] = append(p.rtts[m.Node], time.Since(time.Unix(0, m.Timestamp)))
+			}
+			p.lock.Unlock()
+		}
+	}
+	return nil
+}
+
+// Round sends count probe requests to all nodes, and returns results of the nodes replied,
+// key is the node name; Serve must be running
+func (p *Prober) Round(ctx context.Context, count int, timeout time.Duration) (map[string]*PeerResult, error) {
+	p.lock.Lock()
+	p.round++
+	round := p.round
+	p.rtts = map[string][]time.Duration{}
+	p.lock.Unlock()
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(frameInterval):
+			}
+		}
+		m := &message{
+			Type:      msgRequest,
+			ProbeID:   p.ProbeID,
+			Seq:       uint32(round)<<16 | uint32(i),
+			Timestamp: time.Now().UnixNano(),
+			Node:      p.Node,
+		}
+		if err := p.send(broadcastMAC, m); err != nil {
+			return nil, fmt.Errorf("failed to send probe frame, %w", err)
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(timeout):
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	r := map[string]*PeerResult{}
+	for node, rtts := range p.rtts {
+		result := &PeerResult{Node: node, Sent: count, Received: min(len(rtts), count)}
+		var total time.Duration
+		for _, rtt := range rtts {
+			total += rtt
+		}
+		result.RTT = total / time.Duration(len(rtts))
+		r[node] = result
+	}
+	return r, nil
+}
@@ -0,0 +1,60 @@
+package probe
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestMessage(t *testing.T) {
+	src := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	m := &message{
+		Type:      msgRequest,
+		ProbeID:   0x12345678,
+		Seq:       1<<16 | 2,
+		Timestamp: 1700000000123456789,
+		Node:      "worker1",
+	}
+	frame := m.marshal(broadcastMAC, src)
+	if !bytes.Equal(frame[:6], broadcastMAC) || frame[12] != EtherType>>8 || frame[13] != EtherType&0xff {
+		t.Errorf("invalid ethernet header %x", frame[:ethHeaderLen])
+	}
+	got, gotSrc, err := parseMessage(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *m || gotSrc.String() != src.String() {
+		t.Errorf("parsed %+v from %v, expect %+v from %v", got, gotSrc, m, src)
+	}
+	//long node name is truncated
+	m.Node = strings.Repeat("n", maxNodeNameLen+10)
+	frame = m.marshal(broadcastMAC, src)
+	if len(frame) > maxFrameLen {
+		t.Errorf("frame length is %v", len(frame))
+	}
+	got, _, err = parseMessage(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Node != m.Node[:maxNodeNameLen] {
+		t.Errorf("node name is %v", got.Node)
+	}
+}
+
+func TestParseInvalidMessage(t *testing.T) {
+	src := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	frame := (&message{Type: msgReply, Node: "worker1"}).marshal(broadcastMAC, src)
+	notProbe := bytes.Clone(frame)
+	copy(notProbe[ethHeaderLen:], "XXXX")
+	for name, f := range map[string][]byte{
+		"short":          frame[:ethHeaderLen],
+		"not probe":      notProbe,
+		"truncated":      frame[:ethHeaderLen+len(magic)+5],
+		"truncated name": frame[:len(frame)-1],
+	} {
+		if _, _, err := parseMessage(f); err == nil {
+			t.Errorf("%v frame is parsed", name)
+		}
+	}
+}
@@ -33,7 +33,9 @@ func main() {
 	// 	log.Printf("failed to del ns, %v", err)
 	// }
 	time.Sleep(time.Second)
-	_, err := interfaces.Ensure("macvtap1", "spoke1", lanspec, "hjlaptop", "passthru")
+	lan := &v1beta1.LAN{Spec: *lanspec}
+	lan.UID = "testapply"
+	_, err := interfaces.Ensure("macvtap1", "spoke1", lan, "hjlaptop", "passthru", false, nil)
 	if err != nil {
 		log.Fatal(err)
 	}